@@ -2,8 +2,10 @@ package address
 
 import (
 	"crypto/ed25519"
+	"encoding/hex"
 	"fmt"
 
+	"github.com/cosmos/btcutil/base58"
 	"github.com/kocubinski/gardano/bech32"
 	"golang.org/x/crypto/blake2b"
 )
@@ -12,26 +14,37 @@ const blake2b224Len = 28
 
 type Address []byte
 
-// String returns the bech32 representation of the address
+// String returns the bech32 representation of the address, base58 for Byron addresses. Malformed addresses,
+// which come from untrusted input, are printed as hex.
 func (addr Address) String() string {
-	header := addr[0]
-	network := header & 0x0F
-	var hrp string
-	if network == 0 {
-		hrp = "addr_test"
-	} else if network == 1 {
-		hrp = "addr"
-	} else {
-		panic(fmt.Sprintf("invalid network: %d", network))
+	if addr.Type() == TypeByron {
+		return base58.Encode(addr)
 	}
-
-	res, err := bech32.ConvertAndEncode(hrp, addr)
+	res, err := addr.Bech32()
 	if err != nil {
-		panic(err)
+		return hex.EncodeToString(addr)
 	}
 	return res
 }
 
+// Bech32 returns the bech32 representation of a Shelley address, with the hrp of its type and network.
+func (addr Address) Bech32() (string, error) {
+	if err := addr.Validate(); err != nil {
+		return "", err
+	}
+	hrp := "addr"
+	if t := addr.Type(); t == TypeRewardKey || t == TypeRewardScript {
+		hrp = "stake"
+	} else if t == TypeByron {
+		return "", fmt.Errorf("byron addresses have no bech32 representation")
+	}
+	// CIP-19 only names mainnet, every other network is a testnet
+	if addr.Network() != NetworkMainnet {
+		hrp += "_test"
+	}
+	return bech32.ConvertAndEncode(hrp, addr)
+}
+
 func (addr Address) Equals(other Address) bool {
 	if len(addr) != len(other) {
 		return false
@@ -55,6 +68,30 @@ func NewAddressFromBech32(addrBech32 string) (Address, error) {
 	return Address(data), nil
 }
 
+// Parse reads a bech32 payment or stake address, a hex encoded address or a base58 Byron address and
+// checks it is well formed.
+func Parse(s string) (Address, error) {
+	var addr Address
+	if hrp, data, err := bech32.DecodeAndConvert(s); err == nil {
+		switch hrp {
+		case "addr", "addr_test", "stake", "stake_test":
+			addr = data
+		default:
+			return nil, fmt.Errorf("invalid address hrp: %s", hrp)
+		}
+	} else if data, err := hex.DecodeString(s); err == nil {
+		addr = data
+	} else if data := base58.Decode(s); len(data) > 0 {
+		addr = data
+	} else {
+		return nil, fmt.Errorf("address %q is neither bech32, hex nor base58", s)
+	}
+	if err := addr.Validate(); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
 func blake2b224(data []byte) (result [blake2b224Len]byte, err error) {
 	b2b, err := blake2b.New(blake2b224Len, nil)
 	if err != nil {
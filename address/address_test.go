@@ -0,0 +1,120 @@
+package address_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	. "github.com/kocubinski/gardano/address"
+	"github.com/stretchr/testify/require"
+)
+
+// CIP-19 test vectors
+const (
+	baseAddr    = "addr1qx2fxv2umyhttkxyxp8x0dlpdt3k6cwng5pxj3jhsydzer3n0d3vllmyqwsx5wktcd8cc3sq835lu7drv2xwl2wywfgse35a3x"
+	pointerAddr = "addr1gx2fxv2umyhttkxyxp8x0dlpdt3k6cwng5pxj3jhsydzer5pnz75xxcrzqf96k"
+	rewardAddr  = "stake1uyehkck0lajq8gr28t9uxnuvgcqrc6070x3k9r8048z8y5gh6ffgw"
+	paymentHash = "9493315cd92eb5d8c4304e67b7e16ae36d61d34502694657811a2c8e"
+	stakeHash   = "337b62cfff6403a06a3acbc34f8c46003c69fe79a3628cefa9c47251"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	bz, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return bz
+}
+
+func Test_Inspect(t *testing.T) {
+	addr, err := Parse(baseAddr)
+	require.NoError(t, err)
+	require.Equal(t, TypeBaseKeyKey, addr.Type())
+	info, err := Inspect(addr)
+	require.NoError(t, err)
+	require.Equal(t, "base", info.Type)
+	require.Equal(t, "mainnet", info.Network)
+	require.Equal(t, paymentHash, info.Payment.Hash)
+	require.Equal(t, "key", info.Stake.Type)
+	require.Equal(t, stakeHash, info.Stake.Hash)
+
+	addr, err = Parse(pointerAddr)
+	require.NoError(t, err)
+	info, err = Inspect(addr)
+	require.NoError(t, err)
+	require.Equal(t, "pointer", info.Type)
+	require.Equal(t, &Pointer{Slot: 2498243, TxIndex: 27, CertIndex: 3}, info.Pointer)
+	require.Nil(t, info.Stake)
+
+	// hex and bech32 parse to the same address
+	addr, err = Parse(rewardAddr)
+	require.NoError(t, err)
+	fromHex, err := Parse(hex.EncodeToString(addr))
+	require.NoError(t, err)
+	require.Equal(t, addr, fromHex)
+	require.Equal(t, rewardAddr, fromHex.String())
+
+	for _, bad := range []string{"", "zz", "addr1", "f0", hex.EncodeToString(addr[:20])} {
+		_, err := Parse(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func Test_String(t *testing.T) {
+	// networks other than mainnet are testnets, malformed addresses print as hex instead of panicking
+	addr := Address(append([]byte{0x65}, mustHex(t, paymentHash)...))
+	require.Contains(t, addr.String(), "addr_test1")
+	require.Equal(t, "9a01", Address{0x9a, 0x01}.String())
+	require.Equal(t, "", Address{}.String())
+}
+
+func Test_NewAddress(t *testing.T) {
+	payment := Credential{Hash: mustHex(t, paymentHash)}
+	stake := Credential{Hash: mustHex(t, stakeHash)}
+	addr, err := NewAddress(NetworkMainnet, payment, &stake)
+	require.NoError(t, err)
+	require.Equal(t, baseAddr, addr.String())
+
+	addr, err = NewRewardAddress(NetworkMainnet, stake)
+	require.NoError(t, err)
+	require.Equal(t, rewardAddr, addr.String())
+
+	addr, err = NewAddress(NetworkTestnet, Credential{Script: true, Hash: payment.Hash}, nil)
+	require.NoError(t, err)
+	require.Equal(t, TypeEnterpriseScript, addr.Type())
+	c, ok := addr.PaymentCredential()
+	require.True(t, ok)
+	require.True(t, c.Script)
+
+	_, err = NewAddress(NetworkMainnet, Credential{Hash: []byte{1}}, nil)
+	require.Error(t, err)
+}
+
+func Test_Hash(t *testing.T) {
+	encoded, err := EncodeHash("drep_vkh", mustHex(t, stakeHash))
+	require.NoError(t, err)
+	prefix, hash, err := ParseHash(encoded)
+	require.NoError(t, err)
+	require.Equal(t, "drep_vkh", prefix)
+	require.Equal(t, stakeHash, hex.EncodeToString(hash))
+
+	_, err = EncodeHash("addr", hash)
+	require.Error(t, err)
+}
+
+func Test_NativeScript(t *testing.T) {
+	s, err := ParseNativeScript([]byte(`{
+		"type": "all",
+		"scripts": [
+			{"type": "sig", "keyHash": "` + paymentHash + `"},
+			{"type": "before", "slot": 1000}
+		]
+	}`))
+	require.NoError(t, err)
+	bz, err := s.Cbor()
+	require.NoError(t, err)
+	require.Equal(t, "8201828200581c"+paymentHash+"82051903e8", hex.EncodeToString(bz))
+	hash, err := s.Hash()
+	require.NoError(t, err)
+	require.Len(t, hash, 28)
+
+	_, err = ParseNativeScript([]byte(`{"type": "atLeast", "required": 2, "scripts": [{"type": "after", "slot": 1}]}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,324 @@
+package address
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/kocubinski/gardano/bech32"
+)
+
+// Type is the address type of CIP-19, the upper nibble of the header byte.
+type Type byte
+
+const (
+	TypeBaseKeyKey Type = iota
+	TypeBaseScriptKey
+	TypeBaseKeyScript
+	TypeBaseScriptScript
+	TypePointerKey
+	TypePointerScript
+	TypeEnterpriseKey
+	TypeEnterpriseScript
+	TypeByron
+	TypeRewardKey    Type = 14
+	TypeRewardScript Type = 15
+)
+
+const (
+	NetworkTestnet byte = 0
+	NetworkMainnet byte = 1
+)
+
+// String returns the kind of the address type: base, pointer, enterprise, byron or reward.
+func (t Type) String() string {
+	switch {
+	case t <= TypeBaseScriptScript:
+		return "base"
+	case t <= TypePointerScript:
+		return "pointer"
+	case t <= TypeEnterpriseScript:
+		return "enterprise"
+	case t == TypeByron:
+		return "byron"
+	case t == TypeRewardKey || t == TypeRewardScript:
+		return "reward"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(t))
+	}
+}
+
+func (addr Address) Type() Type {
+	if len(addr) == 0 {
+		return 0
+	}
+	return Type(addr[0] >> 4)
+}
+
+// Network returns the network id of a Shelley address, 1 on mainnet and 0 on testnets.
+func (addr Address) Network() byte {
+	if len(addr) == 0 {
+		return 0
+	}
+	return addr[0] & 0x0f
+}
+
+// Validate checks the address is as long as its type requires.
+func (addr Address) Validate() error {
+	if len(addr) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+	want := 0
+	switch t := addr.Type(); {
+	case t <= TypeBaseScriptScript:
+		want = 1 + 2*blake2b224Len
+	case t <= TypePointerScript:
+		if len(addr) <= 1+blake2b224Len {
+			return fmt.Errorf("pointer address of %d bytes has no pointer", len(addr))
+		}
+		_, err := addr.Pointer()
+		return err
+	case t <= TypeEnterpriseScript, t == TypeRewardKey, t == TypeRewardScript:
+		want = 1 + blake2b224Len
+	case t == TypeByron:
+		// a CBOR array of the address root and its CRC
+		if addr[0] != 0x82 {
+			return fmt.Errorf("invalid byron address")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown address type %d", byte(t))
+	}
+	if len(addr) != want {
+		return fmt.Errorf("invalid %s address length %d, expected %d", addr.Type(), len(addr), want)
+	}
+	return nil
+}
+
+// Credential is a payment or stake credential, the hash of a key or of a script.
+type Credential struct {
+	Script bool
+	Hash   []byte
+}
+
+// KeyCredential returns the credential of an ed25519 verification key.
+func KeyCredential(pub []byte) (Credential, error) {
+	hash, err := blake2b224(pub)
+	if err != nil {
+		return Credential{}, err
+	}
+	return Credential{Hash: hash[:]}, nil
+}
+
+// PaymentCredential returns the payment part of a Shelley payment address.
+func (addr Address) PaymentCredential() (Credential, bool) {
+	t := addr.Type()
+	if t > TypeEnterpriseScript || addr.Validate() != nil {
+		return Credential{}, false
+	}
+	return Credential{Script: t&1 == 1, Hash: addr[1 : 1+blake2b224Len]}, true
+}
+
+// StakeCredential returns the stake part of a base or reward address.
+func (addr Address) StakeCredential() (Credential, bool) {
+	if addr.Validate() != nil {
+		return Credential{}, false
+	}
+	switch t := addr.Type(); {
+	case t <= TypeBaseScriptScript:
+		return Credential{Script: t&2 == 2, Hash: addr[1+blake2b224Len:]}, true
+	case t == TypeRewardKey || t == TypeRewardScript:
+		return Credential{Script: t == TypeRewardScript, Hash: addr[1:]}, true
+	default:
+		return Credential{}, false
+	}
+}
+
+// Pointer locates the stake registration certificate a pointer address delegates to.
+type Pointer struct {
+	Slot      uint64 `json:"slot"`
+	TxIndex   uint64 `json:"txIndex"`
+	CertIndex uint64 `json:"certIndex"`
+}
+
+// Pointer returns the stake pointer of a pointer address.
+func (addr Address) Pointer() (Pointer, error) {
+	if t := addr.Type(); t != TypePointerKey && t != TypePointerScript {
+		return Pointer{}, fmt.Errorf("%s address has no pointer", t)
+	}
+	if len(addr) <= 1+blake2b224Len {
+		return Pointer{}, fmt.Errorf("pointer address has no pointer")
+	}
+	rest := addr[1+blake2b224Len:]
+	var nums [3]uint64
+	for i := range nums {
+		var err error
+		if nums[i], rest, err = readVarNat(rest); err != nil {
+			return Pointer{}, fmt.Errorf("invalid pointer: %w", err)
+		}
+	}
+	if len(rest) != 0 {
+		return Pointer{}, fmt.Errorf("invalid pointer: %d trailing bytes", len(rest))
+	}
+	return Pointer{Slot: nums[0], TxIndex: nums[1], CertIndex: nums[2]}, nil
+}
+
+// readVarNat reads a big endian natural number of 7 bits per byte, the high bit marking continuation.
+func readVarNat(bz []byte) (uint64, []byte, error) {
+	var n uint64
+	for i, b := range bz {
+		if n > (1<<64-1)>>7 {
+			return 0, nil, fmt.Errorf("number overflows 64 bits")
+		}
+		n = n<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			return n, bz[i+1:], nil
+		}
+	}
+	return 0, nil, fmt.Errorf("truncated number")
+}
+
+// NewAddress returns the base address of payment and stake credentials, or the enterprise address of the
+// payment credential if stake is nil.
+func NewAddress(network byte, payment Credential, stake *Credential) (Address, error) {
+	if network > 0x0f {
+		return nil, fmt.Errorf("invalid network id %d", network)
+	}
+	if len(payment.Hash) != blake2b224Len {
+		return nil, fmt.Errorf("invalid payment credential length %d", len(payment.Hash))
+	}
+	t := TypeEnterpriseKey
+	if payment.Script {
+		t |= 1
+	}
+	addr := Address{0}
+	addr = append(addr, payment.Hash...)
+	if stake != nil {
+		if len(stake.Hash) != blake2b224Len {
+			return nil, fmt.Errorf("invalid stake credential length %d", len(stake.Hash))
+		}
+		t = TypeBaseKeyKey | t&1
+		if stake.Script {
+			t |= 2
+		}
+		addr = append(addr, stake.Hash...)
+	}
+	addr[0] = byte(t)<<4 | network
+	return addr, nil
+}
+
+// NewRewardAddress returns the reward, or stake, address of a stake credential.
+func NewRewardAddress(network byte, stake Credential) (Address, error) {
+	if network > 0x0f {
+		return nil, fmt.Errorf("invalid network id %d", network)
+	}
+	if len(stake.Hash) != blake2b224Len {
+		return nil, fmt.Errorf("invalid stake credential length %d", len(stake.Hash))
+	}
+	t := TypeRewardKey
+	if stake.Script {
+		t = TypeRewardScript
+	}
+	return append(Address{byte(t)<<4 | network}, stake.Hash...), nil
+}
+
+// HashPrefixes are the bech32 hrps of key and script hashes, from CIP-5 and CIP-105.
+var HashPrefixes = []string{
+	"addr_vkh", "stake_vkh", "addr_shared_vkh", "stake_shared_vkh", "script",
+	"drep_vkh", "drep_script", "cc_cold_vkh", "cc_cold_script", "cc_hot_vkh", "cc_hot_script",
+	"pool",
+}
+
+// EncodeHash returns the bech32 encoding of a key or script hash with one of HashPrefixes.
+func EncodeHash(prefix string, hash []byte) (string, error) {
+	if !slices.Contains(HashPrefixes, prefix) {
+		return "", fmt.Errorf("unknown hash prefix %q", prefix)
+	}
+	if len(hash) != blake2b224Len {
+		return "", fmt.Errorf("invalid hash length %d, expected %d", len(hash), blake2b224Len)
+	}
+	return bech32.ConvertAndEncode(prefix, hash)
+}
+
+// ParseHash reads a hex or bech32 key or script hash. The prefix is empty for hex hashes.
+func ParseHash(s string) (prefix string, hash []byte, err error) {
+	if prefix, hash, err = bech32.DecodeAndConvert(s); err == nil {
+		if !slices.Contains(HashPrefixes, prefix) {
+			return "", nil, fmt.Errorf("unknown hash prefix %q", prefix)
+		}
+	} else if hash, err = hex.DecodeString(s); err != nil {
+		return "", nil, fmt.Errorf("hash %q is neither bech32 nor hex", s)
+	}
+	if len(hash) != blake2b224Len {
+		return "", nil, fmt.Errorf("invalid hash length %d, expected %d", len(hash), blake2b224Len)
+	}
+	return prefix, hash, nil
+}
+
+// CredentialInfo describes a credential of an address.
+type CredentialInfo struct {
+	Type   string `json:"type"`
+	Hash   string `json:"hash"`
+	Bech32 string `json:"bech32"`
+}
+
+func credentialInfo(c Credential, keyPrefix string) *CredentialInfo {
+	info := &CredentialInfo{Type: "key", Hash: hex.EncodeToString(c.Hash)}
+	prefix := keyPrefix
+	if c.Script {
+		info.Type, prefix = "script", "script"
+	}
+	info.Bech32, _ = EncodeHash(prefix, c.Hash)
+	return info
+}
+
+// Info describes an address, see Inspect.
+type Info struct {
+	Address   string          `json:"address"`
+	Hex       string          `json:"hex"`
+	Type      string          `json:"type"`
+	Era       string          `json:"era"`
+	Network   string          `json:"network,omitempty"`
+	NetworkId *byte           `json:"networkId,omitempty"`
+	Payment   *CredentialInfo `json:"payment,omitempty"`
+	Stake     *CredentialInfo `json:"stake,omitempty"`
+	Pointer   *Pointer        `json:"pointer,omitempty"`
+}
+
+// Inspect describes the type, network and credentials of an address.
+func Inspect(addr Address) (*Info, error) {
+	if err := addr.Validate(); err != nil {
+		return nil, err
+	}
+	t := addr.Type()
+	info := &Info{
+		Address: addr.String(),
+		Hex:     hex.EncodeToString(addr),
+		Type:    t.String(),
+		Era:     "shelley",
+	}
+	if t == TypeByron {
+		info.Era = "byron"
+		return info, nil
+	}
+	network := addr.Network()
+	info.NetworkId = &network
+	info.Network = "testnet"
+	if network == NetworkMainnet {
+		info.Network = "mainnet"
+	}
+	if c, ok := addr.PaymentCredential(); ok {
+		info.Payment = credentialInfo(c, "addr_vkh")
+	}
+	if c, ok := addr.StakeCredential(); ok {
+		info.Stake = credentialInfo(c, "stake_vkh")
+	}
+	if t == TypePointerKey || t == TypePointerScript {
+		p, err := addr.Pointer()
+		if err != nil {
+			return nil, err
+		}
+		info.Pointer = &p
+	}
+	return info, nil
+}
@@ -0,0 +1,109 @@
+package address
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// NativeScript is a multi-signature and timelock script in the JSON format of cardano-cli:
+//
+//	{"type": "sig", "keyHash": "<hex>"}
+//	{"type": "all" | "any", "scripts": [...]}
+//	{"type": "atLeast", "required": 2, "scripts": [...]}
+//	{"type": "after" | "before", "slot": 1000}
+type NativeScript struct {
+	Type     string         `json:"type"`
+	KeyHash  string         `json:"keyHash,omitempty"`
+	Required uint64         `json:"required,omitempty"`
+	Slot     uint64         `json:"slot,omitempty"`
+	Scripts  []NativeScript `json:"scripts,omitempty"`
+}
+
+// ParseNativeScript reads the JSON of a native script.
+func ParseNativeScript(bz []byte) (NativeScript, error) {
+	var s NativeScript
+	if err := json.Unmarshal(bz, &s); err != nil {
+		return s, fmt.Errorf("failed to parse native script: %w", err)
+	}
+	if _, err := s.Cbor(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// Cbor returns the ledger encoding of the script.
+func (s NativeScript) Cbor() ([]byte, error) {
+	v, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(v)
+}
+
+func (s NativeScript) value() ([]any, error) {
+	scripts := func() ([]any, error) {
+		res := make([]any, 0, len(s.Scripts))
+		for _, sub := range s.Scripts {
+			v, err := sub.value()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	}
+	switch s.Type {
+	case "sig":
+		hash, err := hex.DecodeString(s.KeyHash)
+		if err != nil || len(hash) != blake2b224Len {
+			return nil, fmt.Errorf("invalid sig script key hash %q", s.KeyHash)
+		}
+		return []any{0, hash}, nil
+	case "all", "any":
+		subs, err := scripts()
+		if err != nil {
+			return nil, err
+		}
+		if s.Type == "all" {
+			return []any{1, subs}, nil
+		}
+		return []any{2, subs}, nil
+	case "atLeast":
+		subs, err := scripts()
+		if err != nil {
+			return nil, err
+		}
+		if s.Required > uint64(len(subs)) {
+			return nil, fmt.Errorf("atLeast script requires %d of %d scripts", s.Required, len(subs))
+		}
+		return []any{3, s.Required, subs}, nil
+	case "after":
+		return []any{4, s.Slot}, nil
+	case "before":
+		return []any{5, s.Slot}, nil
+	default:
+		return nil, fmt.Errorf("unknown native script type %q", s.Type)
+	}
+}
+
+// Hash returns the script hash, the credential of addresses locked by the script.
+func (s NativeScript) Hash() ([]byte, error) {
+	bz, err := s.Cbor()
+	if err != nil {
+		return nil, err
+	}
+	return ScriptHash(0, bz)
+}
+
+// ScriptHash hashes a script prefixed by its language tag: 0 for native scripts and 1, 2 and 3 for Plutus
+// V1, V2 and V3. Plutus scripts are the bytes wrapped in the CBOR of their text envelope.
+func ScriptHash(language byte, script []byte) ([]byte, error) {
+	hash, err := blake2b224(append([]byte{language}, script...))
+	if err != nil {
+		return nil, err
+	}
+	return hash[:], nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/envelope"
+)
+
+// addressCmd runs the `address inspect`, `address build` and `address convert` subcommands.
+func addressCmd(f *cliFlags, subcommand string, parseFlags func()) error {
+	switch subcommand {
+	case "inspect":
+		parseFlags()
+		if f.flagset.NArg() != 1 {
+			return fmt.Errorf("usage: gardano address inspect <bech32|hex|base58 address>")
+		}
+		addr, err := address.Parse(f.flagset.Arg(0))
+		if err != nil {
+			return err
+		}
+		info, err := address.Inspect(addr)
+		if err != nil {
+			return err
+		}
+		return printJSON(os.Stdout, info)
+	case "build":
+		f.flagset.StringVar(&f.paymentVKeyFile, "payment-vkey", "", "payment verification key envelope file")
+		f.flagset.StringVar(&f.scriptFile, "script-file", "", "native script JSON or script envelope file to use as payment credential")
+		f.flagset.StringVar(&f.stakeVKeyFile, "stake-vkey", "", "optional stake verification key envelope file, alone it builds a stake address")
+		f.flagset.StringVar(&f.network, "network", "testnet", "mainnet, testnet or a network id")
+		parseFlags()
+		return addressBuild(f)
+	case "convert":
+		f.flagset.StringVar(&f.convertTo, "to", "", "hex, bech32 or a key hash prefix like addr_vkh or drep_vkh, defaults to hex for bech32 input and bech32 for hex input")
+		parseFlags()
+		if f.flagset.NArg() != 1 {
+			return fmt.Errorf("usage: gardano address convert [-to hex|bech32|<prefix>] <address or key hash>")
+		}
+		res, err := convertAddress(f.flagset.Arg(0), f.convertTo)
+		if err != nil {
+			return err
+		}
+		fmt.Println(res)
+		return nil
+	default:
+		return fmt.Errorf("unknown address subcommand %q, expected inspect, build or convert", subcommand)
+	}
+}
+
+func addressBuild(f *cliFlags) error {
+	var network byte
+	switch f.network {
+	case "mainnet":
+		network = address.NetworkMainnet
+	case "testnet":
+		network = address.NetworkTestnet
+	default:
+		id, err := strconv.ParseUint(f.network, 10, 4)
+		if err != nil {
+			return fmt.Errorf("invalid network %q, expected mainnet, testnet or a network id up to 15", f.network)
+		}
+		network = byte(id)
+	}
+
+	var stake *address.Credential
+	if f.stakeVKeyFile != "" {
+		c, err := vkeyCredential(f.stakeVKeyFile)
+		if err != nil {
+			return err
+		}
+		stake = &c
+	}
+	var payment address.Credential
+	var err error
+	switch {
+	case f.paymentVKeyFile != "" && f.scriptFile != "":
+		return fmt.Errorf("only one of -payment-vkey and -script-file can be set")
+	case f.paymentVKeyFile != "":
+		payment, err = vkeyCredential(f.paymentVKeyFile)
+	case f.scriptFile != "":
+		payment, err = scriptCredential(f.scriptFile)
+	case stake != nil:
+		addr, err := address.NewRewardAddress(network, *stake)
+		if err != nil {
+			return err
+		}
+		fmt.Println(addr.String())
+		return nil
+	default:
+		return fmt.Errorf("one of -payment-vkey, -script-file and -stake-vkey is required")
+	}
+	if err != nil {
+		return err
+	}
+	addr, err := address.NewAddress(network, payment, stake)
+	if err != nil {
+		return err
+	}
+	fmt.Println(addr.String())
+	return nil
+}
+
+func vkeyCredential(path string) (address.Credential, error) {
+	e, err := envelope.ReadFile(path)
+	if err != nil {
+		return address.Credential{}, err
+	}
+	pub, err := e.VerificationKey()
+	if err != nil {
+		return address.Credential{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return address.KeyCredential(pub)
+}
+
+// scriptCredential reads a native script as JSON, or a native or Plutus script text envelope.
+func scriptCredential(path string) (address.Credential, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return address.Credential{}, fmt.Errorf("failed to read script file: %w", err)
+	}
+	var hash []byte
+	if e, err := envelope.Parse(bz); err == nil {
+		if e.Type == envelope.TypeSimpleScript {
+			script, err := e.Cbor()
+			if err != nil {
+				return address.Credential{}, err
+			}
+			hash, err = address.ScriptHash(0, script)
+			if err != nil {
+				return address.Credential{}, err
+			}
+		} else {
+			language, script, err := e.PlutusScript()
+			if err != nil {
+				return address.Credential{}, err
+			}
+			if hash, err = address.ScriptHash(language, script); err != nil {
+				return address.Credential{}, err
+			}
+		}
+	} else {
+		script, err := address.ParseNativeScript(bz)
+		if err != nil {
+			return address.Credential{}, err
+		}
+		if hash, err = script.Hash(); err != nil {
+			return address.Credential{}, err
+		}
+	}
+	return address.Credential{Script: true, Hash: hash}, nil
+}
+
+// convertAddress converts an address or a key hash between its hex and bech32 encodings.
+func convertAddress(input, to string) (string, error) {
+	// key and script hashes, in hex or with a hash prefix
+	if prefix, hash, err := address.ParseHash(input); err == nil {
+		switch {
+		case to == "hex" || (to == "" && prefix != ""):
+			return hex.EncodeToString(hash), nil
+		case to == "" || to == "bech32":
+			return "", fmt.Errorf("converting a hash to bech32 needs a prefix, like -to addr_vkh")
+		default:
+			return address.EncodeHash(to, hash)
+		}
+	}
+	addr, err := address.Parse(input)
+	if err != nil {
+		return "", err
+	}
+	_, hexErr := hex.DecodeString(input)
+	switch {
+	case to == "hex" || (to == "" && hexErr != nil):
+		return hex.EncodeToString(addr), nil
+	case to == "" || to == "bech32":
+		return addr.Bech32()
+	default:
+		return "", fmt.Errorf("addresses convert to hex or bech32, not %q", to)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/envelope"
+	"github.com/kocubinski/gardano/provider/pending"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// txBatch pays the payouts of -payouts in as few transactions as fit them, writing the transactions to
+// -out-dir and submitting them when -submit is set, and reports where each payout landed.
+func txBatch(f *cliFlags) error {
+	if f.payoutsFile == "" {
+		return fmt.Errorf("payouts file is not set")
+	}
+	if f.outDir == "" && !f.submit {
+		return fmt.Errorf("one of -out-dir and -submit is required")
+	}
+	if f.submit && f.signingKeyFiles == "" {
+		return fmt.Errorf("-submit needs -signing-key-file")
+	}
+	payouts, err := readPayouts(f.payoutsFile)
+	if err != nil {
+		return err
+	}
+	sourceAddr, err := address.NewAddressFromBech32(f.fromAddress)
+	if err != nil {
+		return fmt.Errorf("invalid -from address: %w", err)
+	}
+	var keys []ed25519.PrivateKey
+	if f.signingKeyFiles != "" {
+		if keys, err = readSigningKeys(f.signingKeyFiles); err != nil {
+			return err
+		}
+	}
+
+	var o *ouroboros.Connection
+	online := f.submit || f.pendingFile != "" || f.protocolParamsFile == "" || f.ttl == 0 ||
+		(f.utxoFile == "" && f.kupoURL == "" && f.indexFile == "")
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	if online {
+		if f.clientAddress == "" && f.clientSocket == "" {
+			return fmt.Errorf("client address/socket is not set, offline batches need -protocol-parameters-file, -utxo-file and -ttl")
+		}
+		if o, err = connectNodeToClient(f, log, ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig())); err != nil {
+			return err
+		}
+		defer o.Close()
+	}
+	protocol, err := protocolParams(f, o)
+	if err != nil {
+		return err
+	}
+	utxos, err := sourceUTxOs(f, o, sourceAddr)
+	if err != nil {
+		return err
+	}
+	var overlay *pending.Overlay
+	if f.pendingFile != "" {
+		tip, err := o.ChainSync().Client.GetCurrentTip()
+		if err != nil {
+			return fmt.Errorf("failed to get current tip: %w", err)
+		}
+		var unlock func() error
+		if overlay, unlock, err = loadPending(f, tip.Point.Slot); err != nil {
+			return err
+		}
+		defer unlock()
+		utxos = overlay.Apply(utxos, sourceAddr)
+	}
+	ttl := f.ttl
+	if ttl == 0 {
+		if ttl, err = defaultTTL(f, o, log); err != nil {
+			return err
+		}
+	}
+
+	opts := []tx.BatchOption{tx.WithBatchTTL(uint32(ttl))}
+	if len(keys) > 0 {
+		opts = append(opts, tx.WithBatchWitnessCount(len(keys)))
+	}
+	if f.memo != "" {
+		opts = append(opts, tx.WithBatchMemo(f.memo, tx.WithPassphrase(f.memoPassphrase)))
+	}
+	batch, err := tx.BatchPayout(protocol, payouts, utxos, sourceAddr, opts...)
+	if err != nil {
+		return err
+	}
+
+	// the report is written before anything is submitted, so it locates the payouts of the transactions
+	// which made it even if a later one fails
+	if err := writeBatchReport(f, batch.Receipts); err != nil {
+		return err
+	}
+	if f.outDir != "" {
+		if err := os.MkdirAll(f.outDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+	for i, tb := range batch.Txs {
+		var txBz []byte
+		signed := *tb.Tx()
+		if len(keys) > 0 {
+			if signed, err = tb.Sign(keys); err != nil {
+				return fmt.Errorf("failed to sign tx %d: %w", i+1, err)
+			}
+			txBz, err = signed.Bytes()
+			if err != nil {
+				return fmt.Errorf("failed to get transaction bytes: %w", err)
+			}
+		} else {
+			unsigned := *tb.Tx()
+			// drop the placeholder witnesses of the fee calculation
+			unsigned.WitnessSet = tx.NewTXWitness()
+			if txBz, err = unsigned.Bytes(); err != nil {
+				return fmt.Errorf("failed to get transaction bytes: %w", err)
+			}
+		}
+		if f.outDir != "" {
+			path := filepath.Join(f.outDir, fmt.Sprintf("tx-%d.json", i+1))
+			if err := envelope.WriteFile(path, envelope.NewTx(txBz, len(keys) > 0)); err != nil {
+				return err
+			}
+		}
+		if f.submit {
+			// later txs spend the change of earlier ones, which the node accepts from its mempool
+			if err := (nodeTxBackend{conn: o}).Submit(txBz); err != nil {
+				return fmt.Errorf("failed to submit tx %d of %d: %w", i+1, len(batch.Txs), err)
+			}
+			if err := savePending(f, overlay, &signed); err != nil {
+				return err
+			}
+		}
+		hash, err := tb.Tx().Hash()
+		if err != nil {
+			return fmt.Errorf("failed to hash transaction: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "tx %d: %x, fee = %d\n", i+1, hash, tb.Tx().Body.Fee)
+	}
+	return nil
+}
+
+// writeBatchReport writes the receipts of a batch to -report-file, or to stdout.
+func writeBatchReport(f *cliFlags, receipts []tx.PayoutReceipt) error {
+	if f.reportFile == "" {
+		return printJSON(os.Stdout, receipts)
+	}
+	out, err := os.Create(f.reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	if err := printJSON(out, receipts); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type payoutJSON struct {
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"`
+}
+
+// readPayouts reads a JSON array of address and lovelace amount objects, or, for files not ending in .json,
+// CSV lines of address and lovelace amount with an optional header.
+func readPayouts(path string) ([]tx.Payout, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payouts: %w", err)
+	}
+	var rows []payoutJSON
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(bz, &rows); err != nil {
+			return nil, fmt.Errorf("failed to parse payouts: %w", err)
+		}
+	} else {
+		r := csv.NewReader(bytes.NewReader(bz))
+		r.FieldsPerRecord = 2
+		r.TrimLeadingSpace = true
+		for line := 1; ; line++ {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse payouts: %w", err)
+			}
+			amount, err := strconv.ParseUint(record[1], 10, 64)
+			if err != nil {
+				if line == 1 {
+					// a header
+					continue
+				}
+				return nil, fmt.Errorf("invalid amount %q on line %d of payouts", record[1], line)
+			}
+			rows = append(rows, payoutJSON{Address: record[0], Amount: amount})
+		}
+	}
+
+	payouts := make([]tx.Payout, 0, len(rows))
+	for i, row := range rows {
+		addr, err := address.NewAddressFromBech32(row.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address of payout %d: %w", i+1, err)
+		}
+		payouts = append(payouts, tx.Payout{Address: addr, Amount: row.Amount})
+	}
+	return payouts, nil
+}
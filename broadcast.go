@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/kocubinski/gardano/envelope"
+	"github.com/kocubinski/gardano/outbox"
+	"github.com/kocubinski/gardano/supervisor"
+)
+
+// broadcastTx offers a signed transaction to relays over node-to-node TxSubmission2, until every
+// connected relay downloaded it or the timeout passes.
+func broadcastTx(f *cliFlags) error {
+	txBz, err := readTx(f)
+	if err != nil {
+		return err
+	}
+	network, ok := ouroboros.NetworkByNetworkMagic(f.networkMagic)
+	if !ok {
+		return fmt.Errorf("unknown network magic: %d", f.networkMagic)
+	}
+	var peers []string
+	if f.peerAddress != "" {
+		peers = strings.Split(f.peerAddress, ",")
+	}
+	if len(peers) == 0 {
+		for _, p := range network.BootstrapPeers {
+			peers = append(peers, net.JoinHostPort(p.Address, strconv.Itoa(int(p.Port))))
+		}
+	}
+	if len(peers) == 0 {
+		return fmt.Errorf("no peers to broadcast to for network %s", network.Name)
+	}
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+
+	box := outbox.New()
+	defer box.Close()
+	txHash, err := box.Add(ledger.TxTypeConway, txBz)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	connected := 0
+	for _, address := range peers {
+		o, err := supervisor.Connect(ctx, func(context.Context) (*ouroboros.Connection, error) {
+			conn, err := createClientConnection(address, f.useTls)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+			}
+			done := make(chan struct{})
+			o, err := ouroboros.NewConnection(
+				ouroboros.WithConnection(conn),
+				ouroboros.WithLogger(log),
+				ouroboros.WithNetwork(network),
+				ouroboros.WithNodeToNode(true),
+				ouroboros.WithKeepAlive(true),
+				ouroboros.WithTxSubmissionConfig(box.Config(done)),
+			)
+			if err != nil {
+				close(done)
+				conn.Close()
+				return nil, fmt.Errorf("failed to handshake with %s: %w", address, err)
+			}
+			// the error channel is closed once the connection shut down
+			go func() {
+				for err := range o.ErrorChan() {
+					log.Warn("peer connection error", "peer", address, "error", err)
+				}
+				close(done)
+			}()
+			return o, nil
+		}, supervisor.WithLogger(log), supervisor.WithMaxAttempts(3))
+		if err != nil {
+			log.Warn("skipping peer", "peer", address, "error", err)
+			continue
+		}
+		defer o.Close()
+		o.TxSubmission().Client.Init()
+		connected++
+	}
+	if connected == 0 {
+		return fmt.Errorf("failed to connect to any peer")
+	}
+
+	fmt.Printf("broadcasting tx %s to %d peers\n", txHash, connected)
+	if err := box.WaitDelivered(ctx, txHash, connected); err != nil {
+		delivered, _ := box.Delivered(txHash)
+		if delivered == 0 {
+			return fmt.Errorf("no peer downloaded tx %s: %w", txHash, err)
+		}
+		fmt.Printf("tx %s downloaded by %d of %d peers\n", txHash, delivered, connected)
+		return nil
+	}
+	fmt.Printf("tx %s downloaded by all %d peers\n", txHash, connected)
+	return nil
+}
+
+// readTx reads a transaction given as -tx hex or in -tx-file as a text envelope, hex or raw CBOR.
+func readTx(f *cliFlags) ([]byte, error) {
+	switch {
+	case f.txHex != "":
+		txBz, err := hex.DecodeString(strings.TrimSpace(f.txHex))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tx hex: %w", err)
+		}
+		return txBz, nil
+	case f.txFile != "":
+		bz, err := os.ReadFile(f.txFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tx file: %w", err)
+		}
+		if e, err := envelope.Parse(bz); err == nil {
+			return e.Tx()
+		}
+		if txBz, err := hex.DecodeString(string(bytes.TrimSpace(bz))); err == nil {
+			return txBz, nil
+		}
+		return bz, nil
+	default:
+		return nil, fmt.Errorf("tx is not set, use -tx or -tx-file")
+	}
+}
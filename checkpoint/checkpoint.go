@@ -0,0 +1,92 @@
+package checkpoint
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+)
+
+// DefaultSecurityParam is the number of points retained by default; mainnet's k. A rollback deeper than
+// k blocks is impossible, so one of the retained points is always on the current chain.
+const DefaultSecurityParam = 2160
+
+// Store records the points chain-sync has processed so a follower can resume after a restart.
+type Store interface {
+	// Points returns the retained points, most recent first.
+	Points() ([]common.Point, error)
+	// Save records point as the most recently processed point.
+	Save(point common.Point) error
+	// Rollback discards all points after point.
+	Rollback(point common.Point) error
+	Close() error
+}
+
+// IntersectPoints returns a subset of store's points to use for chain-sync's FindIntersect, see SparsePoints.
+// It returns nil if the store is empty.
+func IntersectPoints(store Store) ([]common.Point, error) {
+	points, err := store.Points()
+	if err != nil {
+		return nil, err
+	}
+	return SparsePoints(points), nil
+}
+
+// SparsePoints selects from points, ordered most recent first, the most recent points densely and older ones
+// at exponentially growing distances. This keeps a FindIntersect request small while still finding an
+// intersection after a deep rollback.
+func SparsePoints(points []common.Point) []common.Point {
+	var res []common.Point
+	for i, step := 0, 1; i < len(points); i += step {
+		res = append(res, points[i])
+		if len(res) > 4 {
+			step *= 2
+		}
+	}
+	if len(points) > 0 && !pointEqual(res[len(res)-1], points[len(points)-1]) {
+		res = append(res, points[len(points)-1])
+	}
+	return res
+}
+
+// window is the in-memory list of the last k points shared by the store implementations, oldest first.
+type window struct {
+	k      int
+	points []common.Point
+}
+
+func (w *window) save(point common.Point) error {
+	if n := len(w.points); n > 0 && point.Slot <= w.points[n-1].Slot {
+		if pointEqual(point, w.points[n-1]) {
+			return nil
+		}
+		return fmt.Errorf("point at slot %d is not after last checkpoint at slot %d", point.Slot, w.points[n-1].Slot)
+	}
+	w.points = append(w.points, point)
+	if len(w.points) > w.k {
+		w.points = slices.Delete(w.points, 0, len(w.points)-w.k)
+	}
+	return nil
+}
+
+// rollback truncates the window after point and reports how many points were dropped.
+func (w *window) rollback(point common.Point) int {
+	i := len(w.points)
+	for i > 0 && w.points[i-1].Slot > point.Slot {
+		i--
+	}
+	dropped := len(w.points) - i
+	w.points = w.points[:i]
+	return dropped
+}
+
+func (w *window) recent() []common.Point {
+	res := slices.Clone(w.points)
+	slices.Reverse(res)
+	return res
+}
+
+func pointEqual(a, b common.Point) bool {
+	return a.Slot == b.Slot && bytes.Equal(a.Hash, b.Hash)
+}
@@ -0,0 +1,84 @@
+package checkpoint_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	. "github.com/kocubinski/gardano/checkpoint"
+	"github.com/stretchr/testify/require"
+)
+
+func point(slot uint64) common.Point {
+	return common.NewPoint(slot, []byte{byte(slot), byte(slot >> 8)})
+}
+
+func Test_Stores(t *testing.T) {
+	cases := []struct {
+		name string
+		open func(t *testing.T, path string) Store
+	}{
+		{
+			name: "file",
+			open: func(t *testing.T, path string) Store {
+				s, err := NewFileStore(path, 3)
+				require.NoError(t, err)
+				return s
+			},
+		},
+		{
+			name: "sqlite",
+			open: func(t *testing.T, path string) Store {
+				s, err := NewSQLiteStore(path, 3)
+				require.NoError(t, err)
+				return s
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoints")
+			s := c.open(t, path)
+			for _, slot := range []uint64{10, 20, 30, 40} {
+				require.NoError(t, s.Save(point(slot)))
+			}
+			// saving the last point again is a no-op, going backwards is an error
+			require.NoError(t, s.Save(point(40)))
+			require.Error(t, s.Save(point(35)))
+
+			points, err := s.Points()
+			require.NoError(t, err)
+			require.Equal(t, []common.Point{point(40), point(30), point(20)}, points)
+
+			require.NoError(t, s.Rollback(point(30)))
+			require.NoError(t, s.Close())
+
+			// resume after restart
+			s = c.open(t, path)
+			defer s.Close()
+			points, err = s.Points()
+			require.NoError(t, err)
+			require.Equal(t, []common.Point{point(30), point(20)}, points)
+			require.NoError(t, s.Save(point(31)))
+		})
+	}
+}
+
+func Test_IntersectPoints(t *testing.T) {
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "checkpoints"), DefaultSecurityParam)
+	require.NoError(t, err)
+	empty, err := IntersectPoints(s)
+	require.NoError(t, err)
+	require.Nil(t, empty)
+
+	for slot := uint64(1); slot <= 100; slot++ {
+		require.NoError(t, s.Save(point(slot)))
+	}
+	points, err := IntersectPoints(s)
+	require.NoError(t, err)
+	var slots []uint64
+	for _, p := range points {
+		slots = append(slots, p.Slot)
+	}
+	require.Equal(t, []uint64{100, 99, 98, 97, 96, 94, 90, 82, 66, 34, 1}, slots)
+}
@@ -0,0 +1,121 @@
+package checkpoint
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+)
+
+// FileStore keeps checkpoints in a JSON file which is rewritten atomically on every change.
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	window window
+}
+
+var _ Store = (*FileStore)(nil)
+
+type filePoint struct {
+	Slot uint64 `json:"slot"`
+	Hash string `json:"hash"`
+}
+
+// NewFileStore opens the checkpoint file at path, creating it on the first Save, and retains up to k points.
+func NewFileStore(path string, k int) (*FileStore, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("invalid security parameter: %d", k)
+	}
+	s := &FileStore{path: path, window: window{k: k}}
+	bz, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var points []filePoint
+	if err := json.Unmarshal(bz, &points); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint file %s: %w", path, err)
+	}
+	for _, p := range points {
+		hash, err := hex.DecodeString(p.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash in checkpoint file %s: %w", path, err)
+		}
+		if err := s.window.save(common.NewPoint(p.Slot, hash)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) Points() ([]common.Point, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.window.recent(), nil
+}
+
+func (s *FileStore) Save(point common.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.window.save(point); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (s *FileStore) Rollback(point common.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.window.rollback(point) == 0 {
+		return nil
+	}
+	return s.flush()
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) flush() error {
+	points := make([]filePoint, len(s.window.points))
+	for i, p := range s.window.points {
+		points[i] = filePoint{Slot: p.Slot, Hash: hex.EncodeToString(p.Hash)}
+	}
+	bz, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	// a rename lost in a crash would take the cursor back to older points
+	d, err := os.Open(filepath.Dir(s.path))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,48 @@
+package checkpoint
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+)
+
+// MemoryStore keeps checkpoints in memory only. It lets a follower resume after a reconnection, but not
+// after a restart.
+type MemoryStore struct {
+	mu     sync.Mutex
+	window window
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore retaining up to k points.
+func NewMemoryStore(k int) (*MemoryStore, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("invalid security parameter: %d", k)
+	}
+	return &MemoryStore{window: window{k: k}}, nil
+}
+
+func (s *MemoryStore) Points() ([]common.Point, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.window.recent(), nil
+}
+
+func (s *MemoryStore) Save(point common.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.window.save(point)
+}
+
+func (s *MemoryStore) Rollback(point common.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.window.rollback(point)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
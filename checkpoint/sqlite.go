@@ -0,0 +1,99 @@
+package checkpoint
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore keeps checkpoints in a SQLite database, which lets them share a transaction boundary with
+// other state kept in the same database.
+type SQLiteStore struct {
+	db *sql.DB
+	k  int
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// NewSQLiteStore opens or creates the SQLite database at path and retains up to k points.
+func NewSQLiteStore(path string, k int) (*SQLiteStore, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("invalid security parameter: %d", k)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection serializes writers and keeps the pragmas below in effect
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA synchronous=FULL",
+		"CREATE TABLE IF NOT EXISTS checkpoints (slot INTEGER PRIMARY KEY, hash BLOB NOT NULL)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize checkpoint database: %w", err)
+		}
+	}
+	return &SQLiteStore{db: db, k: k}, nil
+}
+
+func (s *SQLiteStore) Points() ([]common.Point, error) {
+	rows, err := s.db.Query("SELECT slot, hash FROM checkpoints ORDER BY slot DESC LIMIT ?", s.k)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var points []common.Point
+	for rows.Next() {
+		var p common.Point
+		if err := rows.Scan(&p.Slot, &p.Hash); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *SQLiteStore) Save(point common.Point) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var last sql.NullInt64
+	if err := tx.QueryRow("SELECT MAX(slot) FROM checkpoints").Scan(&last); err != nil {
+		return err
+	}
+	if last.Valid && point.Slot <= uint64(last.Int64) {
+		var hash []byte
+		err := tx.QueryRow("SELECT hash FROM checkpoints WHERE slot = ?", point.Slot).Scan(&hash)
+		if err == nil && pointEqual(point, common.NewPoint(point.Slot, hash)) {
+			return nil
+		}
+		return fmt.Errorf("point at slot %d is not after last checkpoint at slot %d", point.Slot, last.Int64)
+	}
+	if _, err := tx.Exec("INSERT INTO checkpoints (slot, hash) VALUES (?, ?)", point.Slot, point.Hash); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"DELETE FROM checkpoints WHERE slot NOT IN (SELECT slot FROM checkpoints ORDER BY slot DESC LIMIT ?)",
+		s.k,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Rollback(point common.Point) error {
+	_, err := s.db.Exec("DELETE FROM checkpoints WHERE slot > ?", point.Slot)
+	return err
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -31,4 +31,6 @@ fi
 
 socat TCP-LISTEN:7007,reuseaddr,fork UNIX-CLIENT:devnet/node-spo1/node.sock &
 
+gardano submit-api -socket "$CARDANO_NODE_SOCKET_PATH" -magic "$CARDANO_NODE_NETWORK_ID" -listen :${SUBMIT_API_PORT:-8090} &
+
 wait
\ No newline at end of file
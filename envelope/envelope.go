@@ -0,0 +1,184 @@
+// Package envelope reads and writes the JSON text envelopes cardano-cli uses for transactions and keys.
+package envelope
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	TypeUnwitnessedTx = "Unwitnessed Tx ConwayEra"
+	TypeWitnessedTx   = "Witnessed Tx ConwayEra"
+	// TypeTx is written by older cardano-cli versions for both signed and unsigned transactions.
+	TypeTx = "Tx ConwayEra"
+
+	TypePaymentSigningKey      = "PaymentSigningKeyShelley_ed25519"
+	TypePaymentVerificationKey = "PaymentVerificationKeyShelley_ed25519"
+	// TypeGenesisUTxOSigningKey is the type of the devnet's utxo-keys, usable like payment signing keys.
+	TypeGenesisUTxOSigningKey      = "GenesisUTxOSigningKey_ed25519"
+	TypeGenesisUTxOVerificationKey = "GenesisUTxOVerificationKey_ed25519"
+	TypeStakeVerificationKey       = "StakeVerificationKeyShelley_ed25519"
+
+	TypeSimpleScript   = "SimpleScript"
+	TypePlutusScriptV1 = "PlutusScriptV1"
+	TypePlutusScriptV2 = "PlutusScriptV2"
+	TypePlutusScriptV3 = "PlutusScriptV3"
+
+	descriptionTx = "Ledger Cddl Format"
+)
+
+type Envelope struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	CborHex     string `json:"cborHex"`
+}
+
+func New(typ, description string, cborBz []byte) Envelope {
+	return Envelope{Type: typ, Description: description, CborHex: hex.EncodeToString(cborBz)}
+}
+
+// NewTx returns the envelope of a CBOR encoded Conway era transaction.
+func NewTx(txBz []byte, witnessed bool) Envelope {
+	if witnessed {
+		return New(TypeWitnessedTx, descriptionTx, txBz)
+	}
+	return New(TypeUnwitnessedTx, descriptionTx, txBz)
+}
+
+// NewSigningKey returns the envelope of a payment signing key.
+func NewSigningKey(priv ed25519.PrivateKey) (Envelope, error) {
+	bz, err := cbor.Marshal(priv.Seed())
+	if err != nil {
+		return Envelope{}, err
+	}
+	return New(TypePaymentSigningKey, "Payment Signing Key", bz), nil
+}
+
+// NewVerificationKey returns the envelope of a payment verification key.
+func NewVerificationKey(pub ed25519.PublicKey) (Envelope, error) {
+	bz, err := cbor.Marshal([]byte(pub))
+	if err != nil {
+		return Envelope{}, err
+	}
+	return New(TypePaymentVerificationKey, "Payment Verification Key", bz), nil
+}
+
+func (e Envelope) Cbor() ([]byte, error) {
+	bz, err := hex.DecodeString(e.CborHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cborHex: %w", err)
+	}
+	return bz, nil
+}
+
+// Tx returns the CBOR of a transaction envelope.
+func (e Envelope) Tx() ([]byte, error) {
+	if !strings.Contains(e.Type, "Tx") {
+		return nil, fmt.Errorf("envelope of type %q is not a transaction", e.Type)
+	}
+	return e.Cbor()
+}
+
+// SigningKey returns the key of a signing key envelope.
+func (e Envelope) SigningKey() (ed25519.PrivateKey, error) {
+	if e.Type != TypePaymentSigningKey && e.Type != TypeGenesisUTxOSigningKey {
+		return nil, fmt.Errorf("envelope of type %q is not a payment signing key", e.Type)
+	}
+	seed, err := e.keyBytes(ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// VerificationKey returns the key of a payment or stake verification key envelope.
+func (e Envelope) VerificationKey() (ed25519.PublicKey, error) {
+	switch e.Type {
+	case TypePaymentVerificationKey, TypeGenesisUTxOVerificationKey, TypeStakeVerificationKey:
+	default:
+		return nil, fmt.Errorf("envelope of type %q is not a verification key", e.Type)
+	}
+	pub, err := e.keyBytes(ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(pub), nil
+}
+
+// PlutusScript returns the language tag, 1 to 3 for Plutus V1 to V3, and the bytes of a Plutus script
+// envelope, which wraps them in a CBOR byte string.
+func (e Envelope) PlutusScript() (byte, []byte, error) {
+	var language byte
+	switch e.Type {
+	case TypePlutusScriptV1:
+		language = 1
+	case TypePlutusScriptV2:
+		language = 2
+	case TypePlutusScriptV3:
+		language = 3
+	default:
+		return 0, nil, fmt.Errorf("envelope of type %q is not a plutus script", e.Type)
+	}
+	bz, err := e.Cbor()
+	if err != nil {
+		return 0, nil, err
+	}
+	var script []byte
+	if err := cbor.Unmarshal(bz, &script); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode script: %w", err)
+	}
+	return language, script, nil
+}
+
+func (e Envelope) keyBytes(size int) ([]byte, error) {
+	bz, err := e.Cbor()
+	if err != nil {
+		return nil, err
+	}
+	var key []byte
+	if err := cbor.Unmarshal(bz, &key); err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("invalid key length %d, expected %d", len(key), size)
+	}
+	return key, nil
+}
+
+func Parse(bz []byte) (Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(bz, &e); err != nil {
+		return e, fmt.Errorf("failed to parse text envelope: %w", err)
+	}
+	if e.Type == "" || e.CborHex == "" {
+		return e, fmt.Errorf("not a text envelope: type and cborHex are required")
+	}
+	return e, nil
+}
+
+func ReadFile(path string) (Envelope, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Parse(bz)
+}
+
+// WriteFile writes e to path, readable only by its owner if it holds a signing key.
+func WriteFile(path string, e Envelope) error {
+	bz, err := json.MarshalIndent(e, "", "    ")
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0o644)
+	if strings.Contains(e.Type, "SigningKey") {
+		perm = 0o600
+	}
+	return os.WriteFile(path, append(bz, '\n'), perm)
+}
@@ -0,0 +1,56 @@
+package envelope_test
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/kocubinski/gardano/envelope"
+	"github.com/stretchr/testify/require"
+)
+
+// a key written by cardano-cli address key-gen
+const signingKey = `{
+    "type": "PaymentSigningKeyShelley_ed25519",
+    "description": "Payment Signing Key",
+    "cborHex": "58208e1d0ba4f0ef6a3e5b7f6d4c1a3f3d21d97ee4ee0ad4ee0e6ca3a9e3a6c1b0a2"
+}`
+
+func Test_Envelope(t *testing.T) {
+	e, err := Parse([]byte(signingKey))
+	require.NoError(t, err)
+	priv, err := e.SigningKey()
+	require.NoError(t, err)
+	_, err = e.Tx()
+	require.Error(t, err)
+
+	dir := t.TempDir()
+	vkey, err := NewVerificationKey(priv.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	require.NoError(t, WriteFile(filepath.Join(dir, "payment.vkey"), vkey))
+	vkey, err = ReadFile(filepath.Join(dir, "payment.vkey"))
+	require.NoError(t, err)
+	pub, err := vkey.VerificationKey()
+	require.NoError(t, err)
+	require.Equal(t, priv.Public(), pub)
+
+	// signing keys round trip and are private to their owner
+	skey, err := NewSigningKey(priv)
+	require.NoError(t, err)
+	require.Equal(t, e.CborHex, skey.CborHex)
+	path := filepath.Join(dir, "payment.skey")
+	require.NoError(t, WriteFile(path, skey))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	txEnvelope := NewTx([]byte{0x84}, true)
+	require.Equal(t, TypeWitnessedTx, txEnvelope.Type)
+	txBz, err := txEnvelope.Tx()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x84}, txBz)
+
+	_, err = Parse([]byte(`{"foo": 1}`))
+	require.Error(t, err)
+}
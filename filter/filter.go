@@ -0,0 +1,347 @@
+// Package filter selects the transactions chain-sync reports with expressions over addresses,
+// credentials, assets and metadata, see Parse.
+package filter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/kocubinski/gardano/indexer"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// Filter is a compiled filter expression.
+type Filter struct {
+	root node
+	// watches are the address terms of the expression, regardless of how they are combined
+	watches []watchTerm
+	// memoOpts decrypt the memos of transactions paying to or spending from watched addresses
+	memoOpts []tx.MemoOption
+}
+
+// Option configures a Filter.
+type Option func(*Filter)
+
+// WithMemoPassphrase decrypts CIP-83 encrypted memos with passphrase, both for memo terms and for
+// Match.Memo. Only the memos of transactions paying to or spending from a watched address are decrypted,
+// which keeps the key derivation off every other transaction of the chain.
+func WithMemoPassphrase(passphrase string) Option {
+	return func(f *Filter) {
+		f.memoOpts = []tx.MemoOption{tx.WithPassphrase(passphrase)}
+	}
+}
+
+// Spent is a transaction input spending an output paid to a watched address.
+type Spent struct {
+	Input   tx.TxInput
+	Address lcommon.Address
+}
+
+// Match is a transaction satisfying the filter.
+type Match struct {
+	Tx ledger.Transaction
+	// Deposits are the outputs of Tx paying to an address matched by an address term.
+	Deposits []lcommon.Utxo
+	// Withdrawals are the inputs of Tx spending from an address matched by an address term. Inputs are only
+	// seen if the UTxOProvider given to MatchBlock or MatchTx resolves them, see IndexerOptions.
+	Withdrawals []Spent
+	// Memo is the CIP-20 memo of Tx, decrypted if it is encrypted and Tx has deposits or withdrawals.
+	Memo string
+}
+
+// Watches reports whether addr is matched by any address term of the filter.
+func (f *Filter) Watches(addr lcommon.Address) bool {
+	for _, w := range f.watches {
+		if w.matchAddress(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexerOptions configures an indexer.Indexer to track the outputs of watched addresses, so that
+// MatchBlock can resolve the inputs spending them. An indexer can be seeded with the current outputs of
+// full addresses, but the outputs of credential terms can't be looked up by credential in the ledger state:
+// spends of outputs paid to a credential are only seen if the indexer followed the chain since they were
+// created.
+func (f *Filter) IndexerOptions() []indexer.Option {
+	var opts []indexer.Option
+	for _, w := range f.watches {
+		switch t := w.(type) {
+		case addrTerm:
+			opts = append(opts, indexer.WithAddresses(t.addr))
+		case credTerm:
+			if t.payment {
+				opts = append(opts, indexer.WithPaymentCredentials(t.hash.Bytes()))
+			}
+			if t.stake {
+				opts = append(opts, indexer.WithStakeCredentials(t.hash.Bytes()))
+			}
+		}
+	}
+	return opts
+}
+
+// MatchBlock returns the transactions of block satisfying the filter. Inputs are resolved against utxos,
+// typically an indexer configured with IndexerOptions which has not yet applied block, and against the
+// outputs of earlier transactions in the same block. utxos may be nil, in which case only spends of
+// outputs created in the same block are seen.
+func (f *Filter) MatchBlock(ctx context.Context, block ledger.Block, utxos provider.UTxOProvider) ([]Match, error) {
+	var matches []Match
+	produced := make(map[string]Spent)
+	for _, blockTx := range block.Transactions() {
+		m, ok, err := f.match(ctx, blockTx, utxos, produced)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+// MatchTx reports whether a single transaction, e.g. one pending in the mempool, satisfies the filter.
+// Inputs are resolved against utxos, which may be nil.
+func (f *Filter) MatchTx(ctx context.Context, t ledger.Transaction, utxos provider.UTxOProvider) (Match, bool, error) {
+	return f.match(ctx, t, utxos, nil)
+}
+
+// match evaluates t, resolving inputs against produced before utxos and recording its watched outputs in
+// produced if it is not nil.
+func (f *Filter) match(ctx context.Context, t ledger.Transaction, utxos provider.UTxOProvider, produced map[string]Spent) (Match, bool, error) {
+	m := Match{Tx: t}
+	// Consumed and Produced account for phase-2 invalid transactions, which only spend collateral
+	var unresolved []tx.TxInput
+	for _, in := range t.Consumed() {
+		if s, ok := produced[in.String()]; ok {
+			m.Withdrawals = append(m.Withdrawals, s)
+			continue
+		}
+		unresolved = append(unresolved, tx.NewTxInput(in.Id().String(), uint16(in.Index()), 0))
+	}
+	if utxos != nil && len(f.watches) > 0 && len(unresolved) > 0 {
+		resolved, err := utxos.UTxOsByTxIn(ctx, unresolved...)
+		if err != nil {
+			return m, false, fmt.Errorf("failed to resolve inputs of tx %s: %w", t.Hash(), err)
+		}
+		for _, utxo := range resolved {
+			addr, err := ledgerAddress(utxo.Address)
+			if err != nil {
+				return m, false, err
+			}
+			if f.Watches(addr) {
+				m.Withdrawals = append(m.Withdrawals, Spent{Input: utxo, Address: addr})
+			}
+		}
+	}
+	for _, utxo := range t.Produced() {
+		addr := utxo.Output.Address()
+		if !f.Watches(addr) {
+			continue
+		}
+		m.Deposits = append(m.Deposits, utxo)
+		if produced != nil {
+			txIn := tx.NewTxInput(utxo.Id.Id().String(), uint16(utxo.Id.Index()), utxo.Output.Amount())
+			txIn.Address = addr.Bytes()
+			txIn.Assets = tx.NewMultiAssetFromLedger(utxo.Output.Assets())
+			produced[utxo.Id.String()] = Spent{Input: txIn, Address: addr}
+		}
+	}
+	c := &txContext{tx: t, match: &m}
+	if len(m.Deposits) > 0 || len(m.Withdrawals) > 0 {
+		c.memoOpts = f.memoOpts
+	}
+	if !f.root.eval(c) {
+		return m, false, nil
+	}
+	c.decodeMetadata()
+	m.Memo = c.memo
+	return m, true, nil
+}
+
+func ledgerAddress(addrBz []byte) (lcommon.Address, error) {
+	var addr lcommon.Address
+	data, err := cbor.Encode(addrBz)
+	if err != nil {
+		return addr, err
+	}
+	if _, err := cbor.Decode(data, &addr); err != nil {
+		return addr, fmt.Errorf("invalid address: %w", err)
+	}
+	return addr, nil
+}
+
+// txContext caches the parts of a transaction decoded lazily during evaluation.
+type txContext struct {
+	tx    ledger.Transaction
+	match *Match
+
+	memoOpts        []tx.MemoOption
+	metadataDecoded bool
+	labels          []uint64
+	memo            string
+}
+
+func (c *txContext) addresses() []lcommon.Address {
+	var addrs []lcommon.Address
+	for _, utxo := range c.tx.Produced() {
+		addrs = append(addrs, utxo.Output.Address())
+	}
+	for _, s := range c.match.Withdrawals {
+		addrs = append(addrs, s.Address)
+	}
+	return addrs
+}
+
+// decodeMetadata decodes labels and memo once. Undecodable metadata matches no metadata term rather than
+// stopping the chain follower.
+func (c *txContext) decodeMetadata() {
+	if c.metadataDecoded {
+		return
+	}
+	c.metadataDecoded = true
+	c.labels, _ = tx.MetadataLabels(c.tx.Metadata())
+	c.memo, _ = tx.DecodeMemoFromMetadata(c.tx.Metadata(), c.memoOpts...)
+}
+
+type node interface {
+	eval(c *txContext) bool
+}
+
+type andNode struct{ lhs, rhs node }
+
+func (n andNode) eval(c *txContext) bool { return n.lhs.eval(c) && n.rhs.eval(c) }
+
+type orNode struct{ lhs, rhs node }
+
+func (n orNode) eval(c *txContext) bool { return n.lhs.eval(c) || n.rhs.eval(c) }
+
+type notNode struct{ n node }
+
+func (n notNode) eval(c *txContext) bool { return !n.n.eval(c) }
+
+// watchTerm is a term matching addresses, which applies to both outputs and spent inputs.
+type watchTerm interface {
+	node
+	matchAddress(addr lcommon.Address) bool
+}
+
+func collectWatches(n node, watches *[]watchTerm) {
+	switch t := n.(type) {
+	case andNode:
+		collectWatches(t.lhs, watches)
+		collectWatches(t.rhs, watches)
+	case orNode:
+		collectWatches(t.lhs, watches)
+		collectWatches(t.rhs, watches)
+	case notNode:
+		collectWatches(t.n, watches)
+	case watchTerm:
+		*watches = append(*watches, t)
+	}
+}
+
+func evalAddresses(w watchTerm, c *txContext) bool {
+	return slices.ContainsFunc(c.addresses(), w.matchAddress)
+}
+
+type addrTerm struct {
+	addr []byte
+}
+
+func (t addrTerm) eval(c *txContext) bool { return evalAddresses(t, c) }
+
+func (t addrTerm) matchAddress(addr lcommon.Address) bool {
+	return bytes.Equal(addr.Bytes(), t.addr)
+}
+
+type credTerm struct {
+	hash           lcommon.Blake2b224
+	payment, stake bool
+	// script restricts the term to script credentials
+	script bool
+}
+
+func (t credTerm) eval(c *txContext) bool { return evalAddresses(t, c) }
+
+func (t credTerm) matchAddress(addr lcommon.Address) bool {
+	typ := addr.Type()
+	if typ == lcommon.AddressTypeByron {
+		return false
+	}
+	if t.payment && typ < lcommon.AddressTypeByron && addr.PaymentKeyHash() == t.hash {
+		if !t.script || typ&0b0001 != 0 {
+			return true
+		}
+	}
+	hasStake := typ <= lcommon.AddressTypeScriptScript || typ == lcommon.AddressTypeNoneKey || typ == lcommon.AddressTypeNoneScript
+	if t.stake && hasStake && addr.StakeKeyHash() == t.hash {
+		stakeScript := typ == lcommon.AddressTypeKeyScript || typ == lcommon.AddressTypeScriptScript || typ == lcommon.AddressTypeNoneScript
+		if !t.script || stakeScript {
+			return true
+		}
+	}
+	return false
+}
+
+type assetTerm struct {
+	policy lcommon.Blake2b224
+	name   []byte
+	// exact requires the asset name to equal name, otherwise any asset of policy matches
+	exact bool
+}
+
+func (t assetTerm) eval(c *txContext) bool {
+	for _, utxo := range c.tx.Produced() {
+		if hasAsset(utxo.Output.Assets(), t) {
+			return true
+		}
+	}
+	return hasAsset(c.tx.AssetMint(), t)
+}
+
+func hasAsset[T lcommon.MultiAssetTypeOutput | lcommon.MultiAssetTypeMint](ma *lcommon.MultiAsset[T], t assetTerm) bool {
+	if ma == nil {
+		return false
+	}
+	for _, policy := range ma.Policies() {
+		if policy != t.policy {
+			continue
+		}
+		if !t.exact {
+			return true
+		}
+		for _, name := range ma.Assets(policy) {
+			if bytes.Equal(name, t.name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type labelTerm struct {
+	label uint64
+}
+
+func (t labelTerm) eval(c *txContext) bool {
+	c.decodeMetadata()
+	return slices.Contains(c.labels, t.label)
+}
+
+type memoTerm struct {
+	re *regexp.Regexp
+}
+
+func (t memoTerm) eval(c *txContext) bool {
+	c.decodeMetadata()
+	return c.memo != "" && t.re.MatchString(c.memo)
+}
@@ -0,0 +1,185 @@
+package filter_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	. "github.com/kocubinski/gardano/filter"
+	"github.com/kocubinski/gardano/indexer"
+	"github.com/kocubinski/gardano/internal/ledgertest"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	vaultPayment = bytes.Repeat([]byte{0x01}, 28)
+	vaultStake   = bytes.Repeat([]byte{0x02}, 28)
+	otherKey     = bytes.Repeat([]byte{0x03}, 28)
+)
+
+func txHash(n int) string {
+	return fmt.Sprintf("%064x", 0x1000+n)
+}
+
+func output(t *testing.T, payment, stake []byte, amount uint64) ledger.TransactionOutput {
+	addr, err := lcommon.NewAddressFromParts(lcommon.AddressTypeKeyKey, 1, payment, stake)
+	require.NoError(t, err)
+	return ledgertest.Output{Addr: addr, Coin: amount}
+}
+
+func memoMetadata(t *testing.T) *cbor.LazyValue {
+	// {674: {"msg": ["foo+bar-baz"]}}
+	bz, err := hex.DecodeString("a11902a2a1636d7367816b666f6f2b6261722d62617a")
+	require.NoError(t, err)
+	lv := &cbor.LazyValue{}
+	require.NoError(t, lv.UnmarshalCBOR(bz))
+	return lv
+}
+
+func matchedHashes(matches []Match) []string {
+	var res []string
+	for _, m := range matches {
+		res = append(res, m.Tx.Hash())
+	}
+	return res
+}
+
+func Test_Parse(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"payment",
+		"payment:zz",
+		"foo:bar",
+		"label:1 and",
+		"(label:1",
+		"label:1 label:2",
+		`memo:"unterminated`,
+		"memo:(",
+	} {
+		_, err := Parse(expr)
+		require.Error(t, err, expr)
+	}
+	_, err := Parse(`not (label:674 or memo:"^foo bar$") AND stake:` + hex.EncodeToString(vaultStake))
+	require.NoError(t, err)
+}
+
+func Test_MatchBlock(t *testing.T) {
+	f, err := Parse("stake:" + hex.EncodeToString(vaultStake) + ` or memo:"bar-\\w+$"`)
+	require.NoError(t, err)
+	ix := indexer.New(f.IndexerOptions()...)
+	require.NoError(t, ix.RollBackward(common.NewPoint(0, nil)))
+
+	// a deposit to a base address sharing the vault's stake credential, an unrelated transfer and a memo
+	block1 := ledgertest.Block{Number: 1, Txs: []ledger.Transaction{
+		ledgertest.Tx{ID: txHash(1), Out: []ledger.TransactionOutput{
+			output(t, otherKey, vaultStake, 100),
+			output(t, otherKey, otherKey, 5),
+		}},
+		ledgertest.Tx{ID: txHash(2), Out: []ledger.TransactionOutput{output(t, otherKey, otherKey, 7)}},
+		ledgertest.Tx{ID: txHash(3), Meta: memoMetadata(t)},
+	}}
+	matches, err := f.MatchBlock(context.Background(), block1, ix)
+	require.NoError(t, err)
+	require.Equal(t, []string{txHash(1), txHash(3)}, matchedHashes(matches))
+	require.Len(t, matches[0].Deposits, 1)
+	require.Equal(t, uint64(100), matches[0].Deposits[0].Output.Amount())
+	require.NoError(t, ix.RollForward(block1))
+
+	// a withdrawal from the vault, and a spend in the same block of an output created in that block
+	block2 := ledgertest.Block{Number: 2, Txs: []ledger.Transaction{
+		ledgertest.Tx{
+			ID:  txHash(4),
+			In:  []ledger.TransactionInput{ledger.NewShelleyTransactionInput(txHash(1), 0)},
+			Out: []ledger.TransactionOutput{output(t, vaultPayment, vaultStake, 50)},
+		},
+		ledgertest.Tx{
+			ID:  txHash(5),
+			In:  []ledger.TransactionInput{ledger.NewShelleyTransactionInput(txHash(4), 0)},
+			Out: []ledger.TransactionOutput{output(t, otherKey, otherKey, 49)},
+		},
+		ledgertest.Tx{
+			ID: txHash(6),
+			In: []ledger.TransactionInput{ledger.NewShelleyTransactionInput(txHash(1), 1)},
+		},
+	}}
+	matches, err = f.MatchBlock(context.Background(), block2, ix)
+	require.NoError(t, err)
+	require.Equal(t, []string{txHash(4), txHash(5)}, matchedHashes(matches))
+	require.Len(t, matches[0].Withdrawals, 1)
+	require.Equal(t, uint64(100), matches[0].Withdrawals[0].Input.Amount)
+	require.Empty(t, matches[1].Deposits)
+	require.Len(t, matches[1].Withdrawals, 1)
+	require.Equal(t, uint64(50), matches[1].Withdrawals[0].Input.Amount)
+}
+
+func Test_ScriptAndLabel(t *testing.T) {
+	script := hex.EncodeToString(vaultPayment)
+	f, err := Parse("script:" + script + " or (label:674 and not payment:" + hex.EncodeToString(otherKey) + ")")
+	require.NoError(t, err)
+
+	keyAddr, err := lcommon.NewAddressFromParts(lcommon.AddressTypeKeyNone, 1, vaultPayment, nil)
+	require.NoError(t, err)
+	scriptAddr, err := lcommon.NewAddressFromParts(lcommon.AddressTypeScriptNone, 1, vaultPayment, nil)
+	require.NoError(t, err)
+	require.False(t, f.Watches(keyAddr))
+	require.True(t, f.Watches(scriptAddr))
+
+	block := ledgertest.Block{Number: 1, Txs: []ledger.Transaction{
+		ledgertest.Tx{ID: txHash(1), Out: []ledger.TransactionOutput{ledgertest.Output{Addr: keyAddr, Coin: 1}}},
+		ledgertest.Tx{ID: txHash(2), Out: []ledger.TransactionOutput{ledgertest.Output{Addr: scriptAddr, Coin: 1}}},
+		ledgertest.Tx{ID: txHash(3), Meta: memoMetadata(t)},
+		ledgertest.Tx{ID: txHash(4), Meta: memoMetadata(t), Out: []ledger.TransactionOutput{output(t, otherKey, otherKey, 1)}},
+	}}
+	matches, err := f.MatchBlock(context.Background(), block, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{txHash(2), txHash(3)}, matchedHashes(matches))
+}
+
+func encryptedMemoMetadata(t *testing.T, memo, passphrase string) *cbor.LazyValue {
+	builder := tx.NewTxBuilder(nil)
+	require.NoError(t, builder.SetMemo(memo, tx.WithPassphrase(passphrase)))
+	bz, err := cbor.Encode(builder.Tx().Metadata)
+	require.NoError(t, err)
+	lv := &cbor.LazyValue{}
+	require.NoError(t, lv.UnmarshalCBOR(bz))
+	return lv
+}
+
+func Test_EncryptedMemo(t *testing.T) {
+	expr := "stake:" + hex.EncodeToString(vaultStake) + ` or memo:"^route"`
+	// a deposit to the vault and an unrelated transaction, both with encrypted memos
+	block := ledgertest.Block{Number: 1, Txs: []ledger.Transaction{
+		ledgertest.Tx{
+			ID:   txHash(1),
+			Out:  []ledger.TransactionOutput{output(t, otherKey, vaultStake, 100)},
+			Meta: encryptedMemoMetadata(t, "route 42", "secret"),
+		},
+		ledgertest.Tx{
+			ID:   txHash(2),
+			Out:  []ledger.TransactionOutput{output(t, otherKey, otherKey, 7)},
+			Meta: encryptedMemoMetadata(t, "route 43", "secret"),
+		},
+	}}
+
+	f, err := Parse(expr, WithMemoPassphrase("secret"))
+	require.NoError(t, err)
+	matches, err := f.MatchBlock(context.Background(), block, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{txHash(1)}, matchedHashes(matches))
+	require.Equal(t, "route 42", matches[0].Memo)
+
+	// without the passphrase the deposit still matches, without its memo
+	f, err = Parse(expr)
+	require.NoError(t, err)
+	matches, err = f.MatchBlock(context.Background(), block, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{txHash(1)}, matchedHashes(matches))
+	require.Empty(t, matches[0].Memo)
+}
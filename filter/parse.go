@@ -0,0 +1,237 @@
+package filter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// Parse compiles a filter expression. An expression combines terms of the form kind:value with and, or,
+// not and parentheses, and binds and tighter than or. Values containing spaces or parentheses may be
+// double quoted. The supported kinds are:
+//
+//	addr:<bech32>           exact address
+//	payment:<hex>           payment key or script hash
+//	stake:<hex>             stake key or script hash, matching every base address delegating to it
+//	script:<hex>            script hash in either the payment or the stake part of an address
+//	policy:<hex>            any asset of a policy, in an output or minted
+//	asset:<policy>.<name>   a single asset, with the name hex encoded
+//	label:<uint>            transaction metadata label
+//	memo:<regex>            CIP-20 memo matching a regular expression, see WithMemoPassphrase for
+//	                        encrypted memos
+//
+// The address terms addr, payment, stake and script match transactions paying to or spending from a
+// matching address.
+//
+// Example:
+//
+//	stake:8c6f... or (policy:a0028f... and not memo:"^test")
+func Parse(expr string, opts ...Option) (*Filter, error) {
+	toks, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q at end of filter", p.toks[p.pos].text)
+	}
+	f := &Filter{root: n}
+	collectWatches(n, &f.watches)
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(expr string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, token{text: string(c)})
+			i++
+		default:
+			// a word, in which a double quoted section runs to its closing quote
+			var sb strings.Builder
+			quoted := false
+			for i < len(expr) && !unicode.IsSpace(rune(expr[i])) && expr[i] != '(' && expr[i] != ')' {
+				if expr[i] != '"' {
+					sb.WriteByte(expr[i])
+					i++
+					continue
+				}
+				j := i + 1
+				for j < len(expr) && expr[j] != '"' {
+					if expr[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j >= len(expr) {
+					return nil, fmt.Errorf("unterminated quote in filter")
+				}
+				s, err := strconv.Unquote(expr[i : j+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted value %s: %w", expr[i:j+1], err)
+				}
+				sb.WriteString(s)
+				quoted = true
+				i = j + 1
+			}
+			toks = append(toks, token{text: sb.String(), quoted: quoted})
+		}
+	}
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) keyword(kw string) bool {
+	if p.pos < len(p.toks) && !p.toks[p.pos].quoted && strings.EqualFold(p.toks[p.pos].text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	n, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		rhs, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		n = orNode{n, rhs}
+	}
+	return n, nil
+}
+
+func (p *parser) and() (node, error) {
+	n, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		rhs, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		n = andNode{n, rhs}
+	}
+	return n, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	switch {
+	case p.keyword("not"):
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case p.keyword("("):
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("missing closing parenthesis in filter")
+		}
+		return n, nil
+	}
+	tok := p.toks[p.pos]
+	p.pos++
+	return parseTerm(tok.text)
+}
+
+func parseTerm(s string) (node, error) {
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid filter term %q, want kind:value", s)
+	}
+	switch kind {
+	case "addr":
+		addr, err := ledger.NewAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s: %w", value, err)
+		}
+		return addrTerm{addr: addr.Bytes()}, nil
+	case "payment", "stake", "script":
+		h, err := decodeHash(value)
+		if err != nil {
+			return nil, err
+		}
+		return credTerm{
+			hash:    h,
+			payment: kind != "stake",
+			stake:   kind != "payment",
+			script:  kind == "script",
+		}, nil
+	case "policy":
+		h, err := decodeHash(value)
+		if err != nil {
+			return nil, err
+		}
+		return assetTerm{policy: h}, nil
+	case "asset":
+		policy, name, _ := strings.Cut(value, ".")
+		h, err := decodeHash(policy)
+		if err != nil {
+			return nil, err
+		}
+		nameBz, err := hex.DecodeString(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset name %s: %w", name, err)
+		}
+		return assetTerm{policy: h, name: nameBz, exact: true}, nil
+	case "label":
+		label, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata label %s: %w", value, err)
+		}
+		return labelTerm{label: label}, nil
+	case "memo":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memo regex: %w", err)
+		}
+		return memoTerm{re: re}, nil
+	default:
+		return nil, fmt.Errorf("unknown filter term kind %q", kind)
+	}
+}
+
+func decodeHash(s string) (lcommon.Blake2b224, error) {
+	bz, err := hex.DecodeString(s)
+	if err != nil || len(bz) != lcommon.Blake2b224Size {
+		return lcommon.Blake2b224{}, fmt.Errorf("invalid hash %q, want %d hex encoded bytes", s, lcommon.Blake2b224Size)
+	}
+	return lcommon.NewBlake2b224(bz), nil
+}
@@ -0,0 +1,404 @@
+package follower
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/checkpoint"
+)
+
+// Handler receives the selected chain, in the same order chain-sync would deliver it from a single peer.
+type Handler interface {
+	RollForward(block ledger.Block) error
+	// RollBackward reverts all blocks after point. tip is the tip of the chain being switched to.
+	RollBackward(point, tip common.Point) error
+}
+
+// FetchFunc fetches the block at point from one of peers, which are ordered by preference.
+type FetchFunc func(peers []string, point common.Point) (ledger.Block, error)
+
+type header struct {
+	point  common.Point
+	number uint64
+}
+
+// candidate is the chain a peer follows, starting after the intersection anchor.
+type candidate struct {
+	anchor       *common.Point
+	headers      []header
+	lastProgress time.Time
+}
+
+func (c *candidate) tip() (header, bool) {
+	if len(c.headers) == 0 {
+		return header{}, false
+	}
+	return c.headers[len(c.headers)-1], true
+}
+
+// find returns the index of hash in the candidate, -1 for the anchor, or false if it is not on the chain.
+func (c *candidate) find(hash []byte) (int, bool) {
+	for i := len(c.headers) - 1; i >= 0; i-- {
+		if bytes.Equal(c.headers[i].point.Hash, hash) {
+			return i, true
+		}
+	}
+	if c.anchor != nil && bytes.Equal(c.anchor.Hash, hash) {
+		return -1, true
+	}
+	return 0, false
+}
+
+// PeerChain describes the candidate chain of a peer.
+type PeerChain struct {
+	Peer         string       `json:"peer"`
+	Tip          common.Point `json:"-"`
+	TipSlot      uint64       `json:"tip_slot"`
+	TipNumber    uint64       `json:"tip_number"`
+	LastProgress time.Time    `json:"last_progress"`
+	Selected     bool         `json:"selected"`
+}
+
+// ChainSelector implements fork choice over the candidate chains reported by several peers. It follows
+// the longest candidate, rolling the handler back to the common ancestor and forward along the new chain
+// whenever a longer one appears or the followed chain disappears from every peer. Fetched blocks must link
+// to the applied chain.
+type ChainSelector struct {
+	handler Handler
+	fetch   FetchFunc
+	k       int
+
+	mu         sync.Mutex
+	candidates map[string]*candidate
+	selected   string
+	// switching is set while the blocks of a switch are fetched
+	switching bool
+	// chain is the applied chain after base, oldest first
+	chain []header
+	base  *common.Point
+}
+
+// NewChainSelector returns a ChainSelector retaining k blocks of every chain, the deepest possible fork.
+func NewChainSelector(handler Handler, fetch FetchFunc, k int) *ChainSelector {
+	return &ChainSelector{
+		handler:    handler,
+		fetch:      fetch,
+		k:          max(k, 1),
+		candidates: make(map[string]*candidate),
+	}
+}
+
+// RollBackward records a rollback of peer's chain, including the initial one to the intersection.
+func (s *ChainSelector) RollBackward(peer string, point common.Point) error {
+	s.mu.Lock()
+	c := s.candidate(peer)
+	i := len(c.headers)
+	for i > 0 && c.headers[i-1].point.Slot > point.Slot {
+		i--
+	}
+	c.headers = c.headers[:i]
+	if i == 0 {
+		c.anchor = &point
+	}
+	c.lastProgress = time.Now()
+	s.mu.Unlock()
+	return s.update()
+}
+
+// RollForward records a new header at the tip of peer's chain. A header not extending the peer's chain is
+// rejected.
+func (s *ChainSelector) RollForward(peer string, h ledger.BlockHeader) error {
+	hash, err := hex.DecodeString(h.Hash())
+	if err != nil {
+		return fmt.Errorf("invalid block hash: %w", err)
+	}
+	prev, err := hex.DecodeString(h.PrevHash())
+	if err != nil {
+		return fmt.Errorf("invalid previous block hash: %w", err)
+	}
+	s.mu.Lock()
+	c := s.candidate(peer)
+	var parent []byte
+	if tip, ok := c.tip(); ok {
+		parent = tip.point.Hash
+	} else if c.anchor != nil {
+		parent = c.anchor.Hash
+	}
+	if len(parent) > 0 && !bytes.Equal(parent, prev) {
+		s.mu.Unlock()
+		return fmt.Errorf("header %s of peer %s does not extend its chain", h.Hash(), peer)
+	}
+	c.headers = append(c.headers, header{
+		point:  common.NewPoint(h.SlotNumber(), hash),
+		number: h.BlockNumber(),
+	})
+	if n := len(c.headers) - s.k; n > 0 {
+		anchor := c.headers[n-1].point
+		c.anchor = &anchor
+		c.headers = slices.Delete(c.headers, 0, n)
+	}
+	c.lastProgress = time.Now()
+	s.mu.Unlock()
+	return s.update()
+}
+
+// RemovePeer forgets peer's candidate, e.g. after its connection failed.
+func (s *ChainSelector) RemovePeer(peer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.candidates, peer)
+	if s.selected == peer {
+		s.selected = ""
+	}
+}
+
+// Points returns the recent points of the applied chain, most recent first, to intersect new peers with.
+func (s *ChainSelector) Points() []common.Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	points := make([]common.Point, 0, len(s.chain)+1)
+	for i := len(s.chain) - 1; i >= 0; i-- {
+		points = append(points, s.chain[i].point)
+	}
+	if s.base != nil {
+		points = append(points, *s.base)
+	}
+	return checkpoint.SparsePoints(points)
+}
+
+// Peers returns the candidate chains of all peers.
+func (s *ChainSelector) Peers() []PeerChain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []PeerChain
+	for peer, c := range s.candidates {
+		pc := PeerChain{Peer: peer, LastProgress: c.lastProgress, Selected: peer == s.selected}
+		if tip, ok := c.tip(); ok {
+			pc.Tip, pc.TipSlot, pc.TipNumber = tip.point, tip.point.Slot, tip.number
+		} else if c.anchor != nil {
+			pc.Tip, pc.TipSlot = *c.anchor, c.anchor.Slot
+		}
+		res = append(res, pc)
+	}
+	slices.SortFunc(res, func(a, b PeerChain) int { return strings.Compare(a.Peer, b.Peer) })
+	return res
+}
+
+func (s *ChainSelector) candidate(peer string) *candidate {
+	c, ok := s.candidates[peer]
+	if !ok {
+		c = &candidate{}
+		s.candidates[peer] = c
+	}
+	return c
+}
+
+// onChain reports whether the applied chain's tip is on some peer's candidate.
+func (s *ChainSelector) onChain() bool {
+	var tip []byte
+	switch {
+	case len(s.chain) > 0:
+		tip = s.chain[len(s.chain)-1].point.Hash
+	case s.base != nil:
+		tip = s.base.Hash
+	default:
+		return false
+	}
+	for _, c := range s.candidates {
+		if _, ok := c.find(tip); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// update switches to the best candidate until the applied chain is the best one. Blocks are fetched
+// without holding the lock, so headers keep arriving meanwhile; a single switch runs at a time and the one
+// running selects again once it is applied.
+func (s *ChainSelector) update() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.switching {
+		return nil
+	}
+	for {
+		p, err := s.plan()
+		if err != nil || p == nil {
+			return err
+		}
+		s.switching = true
+		s.mu.Unlock()
+		blocks, err := s.fetchBlocks(p)
+		s.mu.Lock()
+		s.switching = false
+		if err != nil {
+			return err
+		}
+		if err := s.apply(p, blocks); err != nil {
+			return err
+		}
+	}
+}
+
+// switchPlan is a switch to the candidate of peer: a rollback of the applied chain to its common ancestor
+// with the candidate, and the candidate's headers after it.
+type switchPlan struct {
+	peer string
+	tip  common.Point
+	// base is the intersection the applied chain starts from, set on the first switch only
+	base *common.Point
+	// ancestor is the index of the common ancestor in the applied chain, -1 for its base
+	ancestor     int
+	ancestorHash []byte
+	headers      []header
+	// peers are the peers to fetch each header from
+	peers [][]string
+}
+
+// plan returns the switch to the best candidate, or nil if the applied chain is still the best one.
+func (s *ChainSelector) plan() (*switchPlan, error) {
+	var best string
+	var bestTip header
+	for peer, c := range s.candidates {
+		tip, ok := c.tip()
+		if !ok {
+			continue
+		}
+		if best == "" || tip.number > bestTip.number || (tip.number == bestTip.number && peer == s.selected) {
+			best, bestTip = peer, tip
+		}
+	}
+	if best == "" {
+		return nil, nil
+	}
+	var number uint64
+	if len(s.chain) > 0 {
+		number = s.chain[len(s.chain)-1].number
+	}
+	if s.onChain() && bestTip.number <= number {
+		return nil, nil
+	}
+
+	c := s.candidates[best]
+	p := &switchPlan{peer: best, tip: bestTip.point}
+	base := s.base
+	if base == nil {
+		if c.anchor == nil {
+			return nil, nil
+		}
+		anchor := *c.anchor
+		base, p.base = &anchor, &anchor
+	}
+	// walk back the applied chain to the most recent block on the candidate
+	i, j := len(s.chain)-1, 0
+	for ; i >= -1; i-- {
+		p.ancestorHash = base.Hash
+		if i >= 0 {
+			p.ancestorHash = s.chain[i].point.Hash
+		}
+		var ok bool
+		if j, ok = c.find(p.ancestorHash); ok {
+			break
+		}
+	}
+	if i < -1 {
+		// the fork is older than the retained chain, which cannot happen for forks shorter than k
+		return nil, fmt.Errorf("no common ancestor with the chain of peer %s", best)
+	}
+	p.ancestor = i
+	p.headers = slices.Clone(c.headers[j+1:])
+	if p.base == nil && p.ancestor == len(s.chain)-1 && len(p.headers) == 0 {
+		// the applied chain is the candidate's
+		s.selected = best
+		return nil, nil
+	}
+	for _, h := range p.headers {
+		p.peers = append(p.peers, s.peersWith(best, h.point.Hash))
+	}
+	return p, nil
+}
+
+// fetchBlocks fetches the blocks of p, checking that they are the blocks of its headers and that each one
+// links to the previous one, the first to the common ancestor.
+func (s *ChainSelector) fetchBlocks(p *switchPlan) ([]ledger.Block, error) {
+	blocks := make([]ledger.Block, 0, len(p.headers))
+	prev := hex.EncodeToString(p.ancestorHash)
+	for i, h := range p.headers {
+		block, err := s.fetch(p.peers[i], h.point)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch block %x: %w", h.point.Hash, err)
+		}
+		hash := hex.EncodeToString(h.point.Hash)
+		if block.Hash() != hash {
+			return nil, fmt.Errorf("fetched block %s instead of %s", block.Hash(), hash)
+		}
+		if block.PrevHash() != prev {
+			return nil, fmt.Errorf("block %s does not link to %s", hash, prev)
+		}
+		blocks = append(blocks, block)
+		prev = hash
+	}
+	return blocks, nil
+}
+
+// apply rolls the applied chain back to the common ancestor of p and forward along its blocks. A plan
+// whose candidate changed while its blocks were fetched is dropped, the next one accounts for the change.
+func (s *ChainSelector) apply(p *switchPlan, blocks []ledger.Block) error {
+	c, ok := s.candidates[p.peer]
+	if !ok {
+		return nil
+	}
+	if _, ok := c.find(p.tip.Hash); !ok {
+		return nil
+	}
+	if p.base != nil {
+		s.base = p.base
+		if err := s.handler.RollBackward(*p.base, p.tip); err != nil {
+			return err
+		}
+	}
+	s.selected = p.peer
+	if p.ancestor < len(s.chain)-1 {
+		point := *s.base
+		if p.ancestor >= 0 {
+			point = s.chain[p.ancestor].point
+		}
+		if err := s.handler.RollBackward(point, p.tip); err != nil {
+			return err
+		}
+		s.chain = s.chain[:p.ancestor+1]
+	}
+	for i, block := range blocks {
+		if err := s.handler.RollForward(block); err != nil {
+			return err
+		}
+		s.chain = append(s.chain, p.headers[i])
+		if n := len(s.chain) - s.k; n > 0 {
+			base := s.chain[n-1].point
+			s.base = &base
+			s.chain = slices.Delete(s.chain, 0, n)
+		}
+	}
+	return nil
+}
+
+// peersWith returns the peers whose candidate contains hash, preferred first.
+func (s *ChainSelector) peersWith(preferred string, hash []byte) []string {
+	peers := []string{preferred}
+	for peer, c := range s.candidates {
+		if peer == preferred {
+			continue
+		}
+		if i, ok := c.find(hash); ok && i >= 0 {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,132 @@
+package follower_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	. "github.com/kocubinski/gardano/follower"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHeader struct {
+	ledger.BlockHeader
+	hash, prev string
+	number     uint64
+}
+
+func (h fakeHeader) Hash() string        { return h.hash }
+func (h fakeHeader) PrevHash() string    { return h.prev }
+func (h fakeHeader) BlockNumber() uint64 { return h.number }
+func (h fakeHeader) SlotNumber() uint64  { return h.number * 10 }
+
+type fakeBlock struct {
+	ledger.Block
+	hash, prev string
+}
+
+func (b fakeBlock) Hash() string     { return b.hash }
+func (b fakeBlock) PrevHash() string { return b.prev }
+
+// hash returns a block hash for a fork and a block number.
+func hash(fork byte, number uint64) string {
+	return fmt.Sprintf("%02x%062x", fork, number)
+}
+
+// prevHashes maps the hashes of the headers made by header to their previous hash, for fetch to serve
+// blocks linking like them.
+var prevHashes = map[string]string{}
+
+func header(fork byte, number uint64, prevFork byte) fakeHeader {
+	prevHashes[hash(fork, number)] = hash(prevFork, number-1)
+	return fakeHeader{hash: hash(fork, number), prev: hash(prevFork, number-1), number: number}
+}
+
+func point(fork byte, number uint64) common.Point {
+	h, _ := hex.DecodeString(hash(fork, number))
+	return common.NewPoint(number*10, h)
+}
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) RollForward(block ledger.Block) error {
+	r.events = append(r.events, "forward "+block.Hash()[:2]+block.Hash()[62:])
+	return nil
+}
+
+func (r *recorder) RollBackward(p, _ common.Point) error {
+	r.events = append(r.events, fmt.Sprintf("backward %d", p.Slot/10))
+	return nil
+}
+
+func Test_ChainSelector(t *testing.T) {
+	r := &recorder{}
+	var fetchedFrom [][]string
+	var s *ChainSelector
+	fetch := func(peers []string, p common.Point) (ledger.Block, error) {
+		fetchedFrom = append(fetchedFrom, peers)
+		// blocks are fetched without holding the selector's lock
+		s.Peers()
+		h := hex.EncodeToString(p.Hash)
+		return fakeBlock{hash: h, prev: prevHashes[h]}, nil
+	}
+	s = NewChainSelector(r, fetch, 10)
+
+	// peer a intersects at block 0 and delivers two blocks
+	require.NoError(t, s.RollBackward("a", point(0, 0)))
+	require.Empty(t, r.events)
+	require.NoError(t, s.RollForward("a", header(0, 1, 0)))
+	require.NoError(t, s.RollForward("a", header(0, 2, 0)))
+	require.Equal(t, []string{"backward 0", "forward 0001", "forward 0002"}, r.events)
+
+	// peer b is on a fork from block 1, which is followed once it is longer
+	r.events = nil
+	require.NoError(t, s.RollBackward("b", point(0, 1)))
+	require.NoError(t, s.RollForward("b", header(1, 2, 0)))
+	require.Empty(t, r.events)
+	require.NoError(t, s.RollForward("b", header(1, 3, 1)))
+	require.Equal(t, []string{"backward 1", "forward 0102", "forward 0103"}, r.events)
+	require.Equal(t, []string{"b"}, fetchedFrom[len(fetchedFrom)-1])
+
+	// a header not extending the peer's chain is rejected
+	require.Error(t, s.RollForward("a", header(0, 4, 0)))
+
+	// once b is gone its fork is abandoned for a's chain, even though it is not longer
+	r.events = nil
+	s.RemovePeer("b")
+	require.NoError(t, s.RollForward("a", header(0, 3, 0)))
+	require.Equal(t, []string{"backward 1", "forward 0002", "forward 0003"}, r.events)
+
+	// a peer joining on the same chain shares the fetching
+	r.events = nil
+	require.NoError(t, s.RollBackward("c", point(0, 3)))
+	require.NoError(t, s.RollForward("a", header(0, 4, 0)))
+	require.NoError(t, s.RollForward("c", header(0, 4, 0)))
+	require.NoError(t, s.RollForward("c", header(0, 5, 0)))
+	require.Equal(t, []string{"forward 0004", "forward 0005"}, r.events)
+	require.Equal(t, []string{"c"}, fetchedFrom[len(fetchedFrom)-1])
+
+	points := s.Points()
+	require.Equal(t, point(0, 5), points[0])
+	peers := s.Peers()
+	require.Len(t, peers, 2)
+	require.Equal(t, "c", peers[1].Peer)
+	require.True(t, peers[1].Selected)
+	require.Equal(t, uint64(5), peers[1].TipNumber)
+}
+
+func Test_ChainSelectorUnlinkedBlock(t *testing.T) {
+	r := &recorder{}
+	fetch := func(peers []string, p common.Point) (ledger.Block, error) {
+		// the peer serves a block of another chain under the announced hash
+		return fakeBlock{hash: hex.EncodeToString(p.Hash), prev: hash(9, 0)}, nil
+	}
+	s := NewChainSelector(r, fetch, 10)
+	require.NoError(t, s.RollBackward("a", point(0, 0)))
+	require.ErrorContains(t, s.RollForward("a", header(0, 1, 0)), "does not link")
+	require.Empty(t, r.events)
+}
@@ -0,0 +1,348 @@
+// Package follower follows the chain from several node-to-node peers at once, selecting the longest
+// candidate chain and failing over when a peer stalls or disconnects.
+package follower
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/blockfetch"
+	"github.com/blinklabs-io/gouroboros/protocol/chainsync"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/checkpoint"
+	"github.com/kocubinski/gardano/supervisor"
+)
+
+type peer struct {
+	address string
+	// shared peers are dropped once they fail, configured peers are retried forever
+	shared bool
+	sup    *supervisor.Supervisor[*ouroboros.Connection]
+	conn   *ouroboros.Connection
+}
+
+// Follower maintains node-to-node connections to a set of peers, feeding the chain-sync headers of each
+// into a ChainSelector.
+type Follower struct {
+	network      ouroboros.Network
+	selector     *ChainSelector
+	dial         func(address string) (net.Conn, error)
+	points       func() ([]common.Point, error)
+	maxPeers     int
+	peerSharing  bool
+	stallTimeout time.Duration
+	log          *slog.Logger
+
+	mu    sync.Mutex
+	peers map[string]*peer
+}
+
+type Option func(*Follower)
+
+// WithDialer sets how peer connections are opened, e.g. over TLS. Defaults to plain TCP.
+func WithDialer(dial func(address string) (net.Conn, error)) Option {
+	return func(f *Follower) {
+		f.dial = dial
+	}
+}
+
+// WithIntersectPoints sets the points to start following from while no block has been selected yet,
+// most recent first. Without them, following starts at the tip of the first peer.
+func WithIntersectPoints(points func() ([]common.Point, error)) Option {
+	return func(f *Follower) {
+		f.points = points
+	}
+}
+
+// WithMaxPeers caps the number of peers, including those discovered through peer sharing. Defaults to 3.
+func WithMaxPeers(n int) Option {
+	return func(f *Follower) {
+		f.maxPeers = n
+	}
+}
+
+// WithPeerSharing enables discovering additional peers from connected ones. Enabled by default.
+func WithPeerSharing(enabled bool) Option {
+	return func(f *Follower) {
+		f.peerSharing = enabled
+	}
+}
+
+// WithStallTimeout sets how long a peer behind the best chain may go without progress before it is
+// disconnected. Defaults to 2 minutes.
+func WithStallTimeout(d time.Duration) Option {
+	return func(f *Follower) {
+		f.stallTimeout = d
+	}
+}
+
+// WithSecurityParam sets the number of blocks retained per chain, the deepest fork which can be followed.
+func WithSecurityParam(k int) Option {
+	return func(f *Follower) {
+		f.selector.k = max(k, 1)
+	}
+}
+
+func WithLogger(log *slog.Logger) Option {
+	return func(f *Follower) {
+		f.log = log
+	}
+}
+
+// New returns a Follower delivering the selected chain to handler.
+func New(network ouroboros.Network, handler Handler, opts ...Option) *Follower {
+	f := &Follower{
+		network: network,
+		dial: func(address string) (net.Conn, error) {
+			return net.Dial("tcp", address)
+		},
+		points:       func() ([]common.Point, error) { return nil, nil },
+		maxPeers:     3,
+		peerSharing:  true,
+		stallTimeout: 2 * time.Minute,
+		log:          slog.Default(),
+		peers:        make(map[string]*peer),
+	}
+	f.selector = NewChainSelector(handler, f.fetch, checkpoint.DefaultSecurityParam)
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Run follows the chain from peers, host:port addresses, until ctx is done.
+func (f *Follower) Run(ctx context.Context, peers ...string) error {
+	if len(peers) == 0 {
+		for _, p := range f.network.BootstrapPeers {
+			peers = append(peers, net.JoinHostPort(p.Address, strconv.Itoa(int(p.Port))))
+		}
+	}
+	if len(peers) == 0 {
+		return fmt.Errorf("no peers to follow for network %s", f.network.Name)
+	}
+	for _, address := range peers {
+		f.addPeer(ctx, address, false)
+	}
+	ticker := time.NewTicker(max(f.stallTimeout/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			f.disconnectStalled()
+		}
+	}
+}
+
+func (f *Follower) addPeer(ctx context.Context, address string, shared bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.peers[address]; ok || len(f.peers) >= f.maxPeers {
+		return
+	}
+	p := &peer{address: address, shared: shared}
+	opts := []supervisor.Option{supervisor.WithLogger(f.log.With("peer", address))}
+	if shared {
+		opts = append(opts, supervisor.WithMaxAttempts(3))
+	}
+	p.sup = supervisor.New(
+		func(context.Context) (*ouroboros.Connection, error) { return f.connect(p) },
+		func(ctx context.Context, conn *ouroboros.Connection) error { return f.start(ctx, p, conn) },
+		opts...,
+	)
+	f.peers[address] = p
+	go func() {
+		err := p.sup.Run(ctx)
+		f.mu.Lock()
+		delete(f.peers, address)
+		f.mu.Unlock()
+		f.selector.RemovePeer(address)
+		if ctx.Err() == nil {
+			f.log.Warn("dropped peer", "peer", address, "error", err)
+		}
+	}()
+}
+
+func (f *Follower) connect(p *peer) (*ouroboros.Connection, error) {
+	// a new session starts a new candidate chain
+	f.selector.RemovePeer(p.address)
+	client, err := f.dial(p.address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ouroboros.NewConnection(
+		ouroboros.WithConnection(client),
+		ouroboros.WithNetwork(f.network),
+		ouroboros.WithLogger(f.log),
+		ouroboros.WithNodeToNode(true),
+		ouroboros.WithKeepAlive(true),
+		ouroboros.WithPeerSharing(f.peerSharing),
+		ouroboros.WithChainSyncConfig(chainsync.NewConfig(
+			chainsync.WithRollForwardFunc(func(_ chainsync.CallbackContext, _ uint, data any, _ chainsync.Tip) error {
+				h, ok := data.(ledger.BlockHeader)
+				if !ok {
+					return fmt.Errorf("unexpected chain-sync payload %T", data)
+				}
+				return f.selector.RollForward(p.address, h)
+			}),
+			chainsync.WithRollBackwardFunc(func(_ chainsync.CallbackContext, point common.Point, _ chainsync.Tip) error {
+				return f.selector.RollBackward(p.address, point)
+			}),
+		)),
+		ouroboros.WithBlockFetchConfig(blockfetch.NewConfig()),
+	)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (f *Follower) start(ctx context.Context, p *peer, conn *ouroboros.Connection) error {
+	f.mu.Lock()
+	p.conn = conn
+	f.mu.Unlock()
+
+	points := f.selector.Points()
+	if len(points) == 0 {
+		var err error
+		if points, err = f.points(); err != nil {
+			return err
+		}
+	}
+	if len(points) == 0 {
+		tip, err := conn.ChainSync().Client.GetCurrentTip()
+		if err != nil {
+			return fmt.Errorf("failed to get current tip: %w", err)
+		}
+		points = []common.Point{tip.Point}
+	}
+	if err := conn.ChainSync().Client.Sync(points); err != nil {
+		return fmt.Errorf("failed to start chain-sync: %w", err)
+	}
+	if f.peerSharing {
+		go f.discover(ctx, conn)
+	}
+	return nil
+}
+
+// discover adds the peers shared by conn, up to the maximum number of peers.
+func (f *Follower) discover(ctx context.Context, conn *ouroboros.Connection) {
+	f.mu.Lock()
+	want := f.maxPeers - len(f.peers)
+	f.mu.Unlock()
+	if want <= 0 || conn.PeerSharing() == nil {
+		return
+	}
+	shared, err := conn.PeerSharing().Client.GetPeers(uint8(min(want, 255)))
+	if err != nil {
+		f.log.Debug("peer sharing failed", "error", err)
+		return
+	}
+	for _, s := range shared {
+		f.addPeer(ctx, net.JoinHostPort(s.IP.String(), strconv.Itoa(int(s.Port))), true)
+	}
+}
+
+// fetch implements FetchFunc over the peers' block-fetch clients.
+func (f *Follower) fetch(peers []string, point common.Point) (ledger.Block, error) {
+	var errs []error
+	for _, address := range peers {
+		f.mu.Lock()
+		p, ok := f.peers[address]
+		var conn *ouroboros.Connection
+		if ok {
+			conn = p.conn
+		}
+		f.mu.Unlock()
+		if conn == nil {
+			continue
+		}
+		block, err := conn.BlockFetch().Client.GetBlock(point)
+		if err == nil {
+			return block, nil
+		}
+		errs = append(errs, fmt.Errorf("peer %s: %w", address, err))
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no connected peer has the block")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// disconnectStalled closes the connections of peers which made no progress for the stall timeout while
+// behind the best chain, so they reconnect.
+func (f *Follower) disconnectStalled() {
+	chains := f.selector.Peers()
+	var best uint64
+	for _, c := range chains {
+		best = max(best, c.TipNumber)
+	}
+	for _, c := range chains {
+		if c.TipNumber >= best || time.Since(c.LastProgress) < f.stallTimeout {
+			continue
+		}
+		f.mu.Lock()
+		var conn *ouroboros.Connection
+		if p, ok := f.peers[c.Peer]; ok {
+			conn = p.conn
+		}
+		f.mu.Unlock()
+		if conn != nil {
+			f.log.Warn("peer stalled, reconnecting", "peer", c.Peer, "tip", c.TipNumber, "best", best)
+			conn.Close()
+		}
+	}
+}
+
+// PeerStatus is the health of a peer connection and the chain it follows.
+type PeerStatus struct {
+	PeerChain
+	Connection supervisor.Status `json:"connection"`
+}
+
+// Status returns the status of every peer.
+func (f *Follower) Status() []PeerStatus {
+	chains := make(map[string]PeerChain)
+	for _, c := range f.selector.Peers() {
+		chains[c.Peer] = c
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []PeerStatus
+	for address, p := range f.peers {
+		c := chains[address]
+		c.Peer = address
+		res = append(res, PeerStatus{PeerChain: c, Connection: p.sup.Status()})
+	}
+	slices.SortFunc(res, func(a, b PeerStatus) int { return strings.Compare(a.Peer, b.Peer) })
+	return res
+}
+
+// ServeHTTP reports Status as JSON, with status code 200 while at least one peer is connected and 503
+// otherwise.
+func (f *Follower) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	status := f.Status()
+	w.Header().Set("Content-Type", "application/json")
+	healthy := false
+	for _, s := range status {
+		healthy = healthy || s.Connection.State == supervisor.StateConnected
+	}
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,301 @@
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/checkpoint"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// ErrRollbackTooDeep is returned when a rollback targets a point older than the retained undo log.
+var ErrRollbackTooDeep = fmt.Errorf("rollback deeper than undo log")
+
+type ref struct {
+	txHash string
+	index  uint16
+}
+
+func refOf(txIn tx.TxInput) ref {
+	return ref{txHash: hex.EncodeToString(txIn.TxHash), index: txIn.Index}
+}
+
+// undo records the changes a block made to the index so they can be reverted on rollback.
+type undo struct {
+	point   common.Point
+	number  uint64
+	added   []ref
+	removed []tx.TxInput
+}
+
+// Indexer maintains the UTxO set of a configured set of addresses, payment credentials and stake
+// credentials from the blocks delivered by chain-sync. It implements provider.Provider so transactions
+// can be built from local state.
+type Indexer struct {
+	mu sync.RWMutex
+
+	addresses          map[string]bool
+	paymentCredentials map[lcommon.Blake2b224]bool
+	stakeCredentials   map[lcommon.Blake2b224]bool
+	retain             int
+
+	utxos map[ref]tx.TxInput
+	// undo log of the most recent blocks, oldest first
+	undo []undo
+	// base is the point before the oldest undo entry, the deepest point the index can roll back to
+	base common.Point
+	tip  common.Point
+}
+
+var _ provider.Provider = (*Indexer)(nil)
+
+type Option func(*Indexer)
+
+// WithAddresses indexes outputs paying exactly to addrs.
+func WithAddresses(addrs ...address.Address) Option {
+	return func(ix *Indexer) {
+		for _, addr := range addrs {
+			ix.addresses[string(addr)] = true
+		}
+	}
+}
+
+// WithPaymentCredentials indexes outputs whose payment part is one of the given key or script hashes.
+func WithPaymentCredentials(hashes ...[]byte) Option {
+	return func(ix *Indexer) {
+		for _, h := range hashes {
+			ix.paymentCredentials[lcommon.NewBlake2b224(h)] = true
+		}
+	}
+}
+
+// WithStakeCredentials indexes outputs whose delegation part is one of the given key or script hashes,
+// which covers every base address sharing the stake credential.
+func WithStakeCredentials(hashes ...[]byte) Option {
+	return func(ix *Indexer) {
+		for _, h := range hashes {
+			ix.stakeCredentials[lcommon.NewBlake2b224(h)] = true
+		}
+	}
+}
+
+// WithRetention sets the number of blocks kept in the undo log, which bounds the depth of rollbacks the
+// index can follow. It defaults to the security parameter k.
+func WithRetention(blocks int) Option {
+	return func(ix *Indexer) {
+		ix.retain = max(blocks, 1)
+	}
+}
+
+// New returns an empty Indexer.
+func New(opts ...Option) *Indexer {
+	ix := &Indexer{
+		addresses:          make(map[string]bool),
+		paymentCredentials: make(map[lcommon.Blake2b224]bool),
+		stakeCredentials:   make(map[lcommon.Blake2b224]bool),
+		retain:             checkpoint.DefaultSecurityParam,
+		utxos:              make(map[ref]tx.TxInput),
+	}
+	for _, opt := range opts {
+		opt(ix)
+	}
+	return ix
+}
+
+func (ix *Indexer) matches(addr lcommon.Address) bool {
+	if ix.addresses[string(addr.Bytes())] {
+		return true
+	}
+	if addr.Type() == lcommon.AddressTypeByron {
+		return false
+	}
+	var zero lcommon.Blake2b224
+	if h := addr.PaymentKeyHash(); h != zero && ix.paymentCredentials[h] {
+		return true
+	}
+	if h := addr.StakeKeyHash(); h != zero && ix.stakeCredentials[h] {
+		return true
+	}
+	return false
+}
+
+// Addresses returns the addresses the index matches exactly, whose UTxOs Seed expects. It returns false
+// if the index also matches payment or stake credentials, whose UTxOs can't be listed by address.
+func (ix *Indexer) Addresses() ([]address.Address, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	addrs := make([]address.Address, 0, len(ix.addresses))
+	for addr := range ix.addresses {
+		addrs = append(addrs, address.Address(addr))
+	}
+	slices.SortFunc(addrs, func(a, b address.Address) int { return slices.Compare(a, b) })
+	return addrs, len(ix.paymentCredentials) == 0 && len(ix.stakeCredentials) == 0
+}
+
+// Seed starts an empty index from the UTxOs of the ledger state at point, read from the node or another
+// provider, so that outputs created before chain-sync starts are indexed too. Only utxos the index
+// matches are kept. Blocks up to point are already part of the state and are skipped by RollForward.
+func (ix *Indexer) Seed(point common.Point, utxos []tx.TxInput) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if len(ix.utxos) > 0 || len(ix.undo) > 0 || ix.tip.Hash != nil {
+		return fmt.Errorf("index is not empty")
+	}
+	for _, utxo := range utxos {
+		addr, err := lcommon.NewAddress(utxo.Address.String())
+		if err != nil {
+			return fmt.Errorf("invalid address of %x#%d: %w", utxo.TxHash, utxo.Index, err)
+		}
+		if ix.matches(addr) {
+			ix.utxos[refOf(utxo)] = utxo
+		}
+	}
+	ix.base, ix.tip = point, point
+	return nil
+}
+
+// RollForward applies a block at the tip of the chain to the index. Blocks at or before the tip of the
+// index, which chain-sync replays when it resumes from an older checkpoint, are already applied and
+// skipped.
+func (ix *Indexer) RollForward(block ledger.Block) error {
+	blockHash, err := hex.DecodeString(block.Hash())
+	if err != nil {
+		return fmt.Errorf("invalid block hash: %w", err)
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.tip.Hash != nil && block.SlotNumber() <= ix.tip.Slot {
+		return nil
+	}
+
+	u := undo{
+		point:  common.NewPoint(block.SlotNumber(), blockHash),
+		number: block.BlockNumber(),
+	}
+	for _, blockTx := range block.Transactions() {
+		// Consumed and Produced account for phase-2 invalid transactions, which only spend collateral
+		for _, in := range blockTx.Consumed() {
+			r := ref{txHash: in.Id().String(), index: uint16(in.Index())}
+			if utxo, ok := ix.utxos[r]; ok {
+				u.removed = append(u.removed, utxo)
+				delete(ix.utxos, r)
+			}
+		}
+		for _, utxo := range blockTx.Produced() {
+			out := utxo.Output
+			if !ix.matches(out.Address()) {
+				continue
+			}
+			txIn := tx.NewTxInput(utxo.Id.Id().String(), uint16(utxo.Id.Index()), out.Amount())
+			txIn.Address = address.Address(out.Address().Bytes())
+			txIn.Assets = tx.NewMultiAssetFromLedger(out.Assets())
+			if h := out.DatumHash(); h != nil {
+				txIn.DatumHash = h.Bytes()
+			}
+			if d := out.Datum(); d != nil {
+				txIn.Datum = d.Cbor()
+			}
+			r := refOf(txIn)
+			ix.utxos[r] = txIn
+			u.added = append(u.added, r)
+		}
+	}
+	ix.undo = append(ix.undo, u)
+	if n := len(ix.undo) - ix.retain; n > 0 {
+		ix.base = ix.undo[n-1].point
+		ix.undo = slices.Delete(ix.undo, 0, n)
+	}
+	ix.tip = u.point
+	return nil
+}
+
+// RollBackward reverts all blocks after point. It returns ErrRollbackTooDeep if point is older than the
+// undo log, in which case the index must be rebuilt.
+func (ix *Indexer) RollBackward(point common.Point) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if point.Slot < ix.base.Slot {
+		return fmt.Errorf("%w: rollback to slot %d, oldest recoverable slot is %d", ErrRollbackTooDeep, point.Slot, ix.base.Slot)
+	}
+	for len(ix.undo) > 0 && ix.undo[len(ix.undo)-1].point.Slot > point.Slot {
+		u := ix.undo[len(ix.undo)-1]
+		for _, r := range u.added {
+			delete(ix.utxos, r)
+		}
+		for _, utxo := range u.removed {
+			ix.utxos[refOf(utxo)] = utxo
+		}
+		ix.undo = ix.undo[:len(ix.undo)-1]
+	}
+	ix.tip = point
+	if len(ix.undo) == 0 {
+		ix.base = point
+	}
+	return nil
+}
+
+// Points returns the points of the retained blocks, most recent first, followed by the base point the
+// oldest of them builds on, for resuming chain-sync.
+func (ix *Indexer) Points() []common.Point {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	points := make([]common.Point, 0, len(ix.undo)+1)
+	for i := len(ix.undo) - 1; i >= 0; i-- {
+		points = append(points, ix.undo[i].point)
+	}
+	if ix.base.Hash != nil {
+		points = append(points, ix.base)
+	}
+	return points
+}
+
+// UTxOsByAddress returns the indexed unspent outputs locked at addr.
+func (ix *Indexer) UTxOsByAddress(_ context.Context, addr address.Address) ([]tx.TxInput, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	var res []tx.TxInput
+	for _, utxo := range ix.utxos {
+		if utxo.Address.Equals(addr) {
+			res = append(res, utxo)
+		}
+	}
+	sortTxInputs(res)
+	return res, nil
+}
+
+// UTxOsByTxIn resolves the given inputs against the index.
+func (ix *Indexer) UTxOsByTxIn(_ context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	var res []tx.TxInput
+	for _, txIn := range txIns {
+		if utxo, ok := ix.utxos[refOf(txIn)]; ok {
+			res = append(res, utxo)
+		}
+	}
+	return res, nil
+}
+
+// Tip returns the point of the last block applied to the index.
+func (ix *Indexer) Tip(context.Context) (common.Point, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.tip, nil
+}
+
+func sortTxInputs(txIns []tx.TxInput) {
+	slices.SortFunc(txIns, func(a, b tx.TxInput) int {
+		if c := slices.Compare(a.TxHash, b.TxHash); c != 0 {
+			return c
+		}
+		return int(a.Index) - int(b.Index)
+	})
+}
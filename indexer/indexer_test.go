@@ -0,0 +1,146 @@
+package indexer_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/address"
+	. "github.com/kocubinski/gardano/indexer"
+	"github.com/kocubinski/gardano/internal/ledgertest"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	watched = "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz"
+	other   = "addr1v8hc0xl88ehea8698tjejhwjum87hsusdpne787znge7sps4x4v8v"
+)
+
+func txHash(n int) string {
+	return fmt.Sprintf("%064x", 0x1000+n)
+}
+
+func output(t *testing.T, addrBech32 string, amount uint64) ledger.TransactionOutput {
+	addr, err := ledger.NewAddress(addrBech32)
+	require.NoError(t, err)
+	return ledgertest.Output{Addr: addr, Coin: amount}
+}
+
+func amounts(t *testing.T, ix *Indexer) []uint64 {
+	addr, err := address.NewAddressFromBech32(watched)
+	require.NoError(t, err)
+	utxos, err := ix.UTxOsByAddress(context.Background(), addr)
+	require.NoError(t, err)
+	var res []uint64
+	for _, utxo := range utxos {
+		res = append(res, utxo.Amount)
+	}
+	return res
+}
+
+func Test_Indexer(t *testing.T) {
+	addr, err := address.NewAddressFromBech32(watched)
+	require.NoError(t, err)
+	ix := New(WithPaymentCredentials(addr[1:]))
+
+	require.NoError(t, ix.RollBackward(common.NewPoint(0, nil)))
+	require.NoError(t, ix.RollForward(ledgertest.Block{Number: 1, Txs: []ledger.Transaction{
+		ledgertest.Tx{ID: txHash(1), Out: []ledger.TransactionOutput{
+			output(t, watched, 100),
+			output(t, other, 5),
+			output(t, watched, 200),
+		}},
+	}}))
+	require.Equal(t, []uint64{100, 200}, amounts(t, ix))
+
+	// block 2 spends the first output
+	require.NoError(t, ix.RollForward(ledgertest.Block{Number: 2, Txs: []ledger.Transaction{
+		ledgertest.Tx{
+			ID:  txHash(2),
+			In:  []ledger.TransactionInput{ledger.NewShelleyTransactionInput(txHash(1), 0)},
+			Out: []ledger.TransactionOutput{output(t, watched, 90)},
+		},
+	}}))
+	require.Equal(t, []uint64{200, 90}, amounts(t, ix))
+
+	spent, err := ix.UTxOsByTxIn(context.Background(), tx.NewTxInput(txHash(1), 0, 0), tx.NewTxInput(txHash(1), 2, 0))
+	require.NoError(t, err)
+	require.Len(t, spent, 1)
+	require.Equal(t, uint64(200), spent[0].Amount)
+
+	// snapshot round trip keeps the undo log
+	path := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, ix.SaveFile(path))
+	ix, err = LoadFile(path, WithPaymentCredentials(addr[1:]))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{200, 90}, amounts(t, ix))
+	require.Equal(t, []common.Point{
+		common.NewPoint(20, fakeBlockHash(2)),
+		common.NewPoint(10, fakeBlockHash(1)),
+	}, ix.Points())
+
+	// rolling back block 2 restores the spent output
+	require.NoError(t, ix.RollBackward(common.NewPoint(10, fakeBlockHash(1))))
+	require.Equal(t, []uint64{100, 200}, amounts(t, ix))
+	tip, err := ix.Tip(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), tip.Slot)
+}
+
+func Test_RollbackTooDeep(t *testing.T) {
+	ix := New(WithRetention(2))
+	require.NoError(t, ix.RollBackward(common.NewPoint(5, []byte{0x05})))
+	for n := uint64(1); n <= 4; n++ {
+		require.NoError(t, ix.RollForward(ledgertest.Block{Number: n}))
+	}
+	require.NoError(t, ix.RollBackward(common.NewPoint(20, fakeBlockHash(2))))
+	require.ErrorIs(t, ix.RollBackward(common.NewPoint(10, fakeBlockHash(1))), ErrRollbackTooDeep)
+}
+
+func fakeBlockHash(n uint64) []byte {
+	var h lcommon.Blake2b256
+	h[31] = byte(n)
+	return h.Bytes()
+}
+
+func Test_Seed(t *testing.T) {
+	addr, err := address.NewAddressFromBech32(watched)
+	require.NoError(t, err)
+	otherAddr, err := address.NewAddressFromBech32(other)
+	require.NoError(t, err)
+	ix := New(WithAddresses(addr))
+	addrs, complete := ix.Addresses()
+	require.Equal(t, []address.Address{addr}, addrs)
+	require.True(t, complete)
+
+	// outputs created before chain-sync starts come from the ledger state at the seed point
+	seeded := tx.NewTxInput(txHash(1), 0, 100)
+	seeded.Address = addr
+	unwatched := tx.NewTxInput(txHash(1), 1, 5)
+	unwatched.Address = otherAddr
+	seedPoint := common.NewPoint(20, fakeBlockHash(2))
+	require.NoError(t, ix.Seed(seedPoint, []tx.TxInput{seeded, unwatched}))
+	require.Equal(t, []uint64{100}, amounts(t, ix))
+	require.Equal(t, []common.Point{seedPoint}, ix.Points())
+	require.Error(t, ix.Seed(seedPoint, nil))
+
+	// blocks up to the seed point are part of the state already
+	require.NoError(t, ix.RollForward(ledgertest.Block{Number: 2, Txs: []ledger.Transaction{
+		ledgertest.Tx{ID: txHash(1), Out: []ledger.TransactionOutput{output(t, watched, 100)}},
+	}}))
+	require.Equal(t, []uint64{100}, amounts(t, ix))
+	require.NoError(t, ix.RollForward(ledgertest.Block{Number: 3, Txs: []ledger.Transaction{
+		ledgertest.Tx{
+			ID:  txHash(3),
+			In:  []ledger.TransactionInput{ledger.NewShelleyTransactionInput(txHash(1), 0)},
+			Out: []ledger.TransactionOutput{output(t, watched, 90)},
+		},
+	}}))
+	require.Equal(t, []uint64{90}, amounts(t, ix))
+	require.ErrorIs(t, ix.RollBackward(common.NewPoint(10, fakeBlockHash(1))), ErrRollbackTooDeep)
+}
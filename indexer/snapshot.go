@@ -0,0 +1,204 @@
+package indexer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/tx"
+)
+
+type snapshotPoint struct {
+	Slot uint64 `json:"slot"`
+	Hash string `json:"hash"`
+}
+
+type snapshotUTxO struct {
+	TxHash    string        `json:"tx_hash"`
+	Index     uint16        `json:"index"`
+	Address   string        `json:"address"`
+	Amount    uint64        `json:"amount"`
+	Assets    tx.MultiAsset `json:"assets,omitempty"`
+	DatumHash string        `json:"datum_hash,omitempty"`
+	Datum     string        `json:"datum,omitempty"`
+}
+
+type snapshotUndo struct {
+	Point   snapshotPoint  `json:"point"`
+	Number  uint64         `json:"block_number"`
+	Added   []snapshotUTxO `json:"added,omitempty"`
+	Removed []snapshotUTxO `json:"removed,omitempty"`
+}
+
+type snapshot struct {
+	Base  snapshotPoint  `json:"base"`
+	Tip   snapshotPoint  `json:"tip"`
+	UTxOs []snapshotUTxO `json:"utxos"`
+	Undo  []snapshotUndo `json:"undo"`
+}
+
+// SaveFile atomically writes the index state, including its undo log, to path.
+func (ix *Indexer) SaveFile(path string) error {
+	ix.mu.RLock()
+	snap := snapshot{
+		Base: toSnapshotPoint(ix.base),
+		Tip:  toSnapshotPoint(ix.tip),
+	}
+	utxos := make([]tx.TxInput, 0, len(ix.utxos))
+	for _, utxo := range ix.utxos {
+		utxos = append(utxos, utxo)
+	}
+	for _, u := range ix.undo {
+		su := snapshotUndo{Point: toSnapshotPoint(u.point), Number: u.number}
+		for _, r := range u.added {
+			su.Added = append(su.Added, snapshotUTxO{TxHash: r.txHash, Index: r.index})
+		}
+		for _, utxo := range u.removed {
+			su.Removed = append(su.Removed, toSnapshotUTxO(utxo))
+		}
+		snap.Undo = append(snap.Undo, su)
+	}
+	ix.mu.RUnlock()
+
+	sortTxInputs(utxos)
+	for _, utxo := range utxos {
+		snap.UTxOs = append(snap.UTxOs, toSnapshotUTxO(utxo))
+	}
+	bz, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return err
+	}
+	// the checkpoint saved after the index must not survive a crash which loses the index
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes the entries of dir, making a rename in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// LoadFile returns an Indexer configured by opts with the state saved at path. A missing file yields an
+// empty index.
+func LoadFile(path string, opts ...Option) (*Indexer, error) {
+	ix := New(opts...)
+	bz, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(bz, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode index snapshot %s: %w", path, err)
+	}
+	if ix.base, err = fromSnapshotPoint(snap.Base); err != nil {
+		return nil, err
+	}
+	if ix.tip, err = fromSnapshotPoint(snap.Tip); err != nil {
+		return nil, err
+	}
+	for _, su := range snap.UTxOs {
+		utxo, err := fromSnapshotUTxO(su)
+		if err != nil {
+			return nil, err
+		}
+		ix.utxos[refOf(utxo)] = utxo
+	}
+	for _, su := range snap.Undo {
+		u := undo{number: su.Number}
+		if u.point, err = fromSnapshotPoint(su.Point); err != nil {
+			return nil, err
+		}
+		for _, a := range su.Added {
+			u.added = append(u.added, ref{txHash: a.TxHash, index: a.Index})
+		}
+		for _, r := range su.Removed {
+			utxo, err := fromSnapshotUTxO(r)
+			if err != nil {
+				return nil, err
+			}
+			u.removed = append(u.removed, utxo)
+		}
+		ix.undo = append(ix.undo, u)
+	}
+	return ix, nil
+}
+
+func toSnapshotPoint(p common.Point) snapshotPoint {
+	return snapshotPoint{Slot: p.Slot, Hash: hex.EncodeToString(p.Hash)}
+}
+
+func fromSnapshotPoint(p snapshotPoint) (common.Point, error) {
+	if p.Hash == "" {
+		return common.NewPoint(p.Slot, nil), nil
+	}
+	hash, err := hex.DecodeString(p.Hash)
+	if err != nil {
+		return common.Point{}, fmt.Errorf("invalid point hash: %w", err)
+	}
+	return common.NewPoint(p.Slot, hash), nil
+}
+
+func toSnapshotUTxO(utxo tx.TxInput) snapshotUTxO {
+	return snapshotUTxO{
+		TxHash:    hex.EncodeToString(utxo.TxHash),
+		Index:     utxo.Index,
+		Address:   hex.EncodeToString(utxo.Address),
+		Amount:    utxo.Amount,
+		Assets:    utxo.Assets,
+		DatumHash: hex.EncodeToString(utxo.DatumHash),
+		Datum:     hex.EncodeToString(utxo.Datum),
+	}
+}
+
+func fromSnapshotUTxO(su snapshotUTxO) (tx.TxInput, error) {
+	utxo := tx.NewTxInput(su.TxHash, su.Index, su.Amount)
+	var err error
+	if utxo.Address, err = hex.DecodeString(su.Address); err != nil {
+		return utxo, fmt.Errorf("invalid address: %w", err)
+	}
+	if utxo.DatumHash, err = decodeOptionalHex(su.DatumHash); err != nil {
+		return utxo, err
+	}
+	if utxo.Datum, err = decodeOptionalHex(su.Datum); err != nil {
+		return utxo, err
+	}
+	utxo.Assets = su.Assets
+	return utxo, nil
+}
+
+func decodeOptionalHex(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
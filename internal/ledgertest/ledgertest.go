@@ -0,0 +1,59 @@
+// Package ledgertest provides minimal blocks, transactions and outputs for the tests of the components fed
+// by chain-sync. Only the methods those components call are implemented, the others panic.
+package ledgertest
+
+import (
+	"fmt"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// Output is a transaction output of Coin lovelace to Addr, without assets or datum.
+type Output struct {
+	ledger.TransactionOutput
+	Addr ledger.Address
+	Coin uint64
+}
+
+func (o Output) Address() ledger.Address                                   { return o.Addr }
+func (o Output) Amount() uint64                                            { return o.Coin }
+func (o Output) Assets() *lcommon.MultiAsset[lcommon.MultiAssetTypeOutput] { return nil }
+func (o Output) DatumHash() *lcommon.Blake2b256                            { return nil }
+func (o Output) Datum() *cbor.LazyValue                                    { return nil }
+
+// Tx is a transaction with hash ID spending In and creating Out. Invalid marks it phase-2 invalid.
+type Tx struct {
+	ledger.Transaction
+	ID      string
+	In      []ledger.TransactionInput
+	Out     []ledger.TransactionOutput
+	Meta    *cbor.LazyValue
+	Invalid bool
+}
+
+func (t Tx) Hash() string                                               { return t.ID }
+func (t Tx) IsValid() bool                                              { return !t.Invalid }
+func (t Tx) Consumed() []ledger.TransactionInput                        { return t.In }
+func (t Tx) Metadata() *cbor.LazyValue                                  { return t.Meta }
+func (t Tx) AssetMint() *lcommon.MultiAsset[lcommon.MultiAssetTypeMint] { return nil }
+func (t Tx) Produced() []lcommon.Utxo {
+	var res []lcommon.Utxo
+	for i, out := range t.Out {
+		res = append(res, lcommon.Utxo{Id: ledger.NewShelleyTransactionInput(t.ID, i), Output: out})
+	}
+	return res
+}
+
+// Block is the block with number Number, at slot 10 times its number, holding Txs.
+type Block struct {
+	ledger.Block
+	Number uint64
+	Txs    []ledger.Transaction
+}
+
+func (b Block) Hash() string                       { return fmt.Sprintf("%064x", b.Number) }
+func (b Block) SlotNumber() uint64                 { return b.Number * 10 }
+func (b Block) BlockNumber() uint64                { return b.Number }
+func (b Block) Transactions() []ledger.Transaction { return b.Txs }
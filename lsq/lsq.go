@@ -0,0 +1,225 @@
+// Package lsq runs local state queries over a node-to-client connection. Unlike the gouroboros client it
+// sends arbitrary queries, which covers queries the client lacks or drops the parameters of, such as the
+// filtered delegations and reward accounts behind stake address info.
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/protocol"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+)
+
+// ErrEraMismatch is returned by Shelley queries sent for an era other than the ledger's.
+var ErrEraMismatch = errors.New("query era does not match the ledger era")
+
+// ErrTimeout is returned by a request the node did not answer in time, and by all requests after it.
+var ErrTimeout = errors.New("timed out waiting for the node")
+
+// Client sends local state queries against the node's volatile tip. The tip is acquired on the first
+// query and held until Release, so that consecutive queries see the same ledger state.
+//
+// A request the node does not answer in time leaves the protocol waiting for the answer, so the client
+// closes its connection and fails all later requests. A new client on a new connection is needed then.
+type Client struct {
+	mu       sync.Mutex
+	conn     *ouroboros.Connection
+	proto    *protocol.Protocol
+	msgs     chan protocol.Message
+	errs     chan error
+	timeout  time.Duration
+	acquired bool
+	era      int
+	// err is why the client stopped, every request fails with it
+	err error
+}
+
+type Option func(*Client)
+
+// WithTimeout sets how long to wait for the node to answer a request, 1 minute by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// New starts a local state query client on conn. The connection must be created with
+// ouroboros.WithDelayProtocolStart(true), as gouroboros' own client would otherwise claim the mini-protocol.
+func New(conn *ouroboros.Connection, logger *slog.Logger, opts ...Option) *Client {
+	c := &Client{
+		conn:    conn,
+		msgs:    make(chan protocol.Message, 1),
+		errs:    make(chan error, 1),
+		timeout: time.Minute,
+		era:     -1,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.proto = protocol.New(protocol.ProtocolConfig{
+		Name:                localstatequery.ProtocolName,
+		ProtocolId:          localstatequery.ProtocolId,
+		ErrorChan:           c.errs,
+		Muxer:               conn.Muxer(),
+		Logger:              logger,
+		Mode:                protocol.ProtocolModeNodeToClient,
+		Role:                protocol.ProtocolRoleClient,
+		MessageHandlerFunc:  c.handleMessage,
+		MessageFromCborFunc: localstatequery.NewMsgFromCbor,
+		StateMap:            localstatequery.StateMap,
+		// the idle state of localstatequery.StateMap
+		InitialState: protocol.NewState(1, "Idle"),
+	})
+	c.proto.Start()
+	return c
+}
+
+func (c *Client) handleMessage(msg protocol.Message) error {
+	c.msgs <- msg
+	return nil
+}
+
+// send sends a request, unless the client stopped.
+func (c *Client) send(msg protocol.Message) error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.proto.SendMessage(msg)
+}
+
+// await returns the node's answer to the last request.
+func (c *Client) await() (protocol.Message, error) {
+	select {
+	case msg := <-c.msgs:
+		return msg, nil
+	case err := <-c.errs:
+		c.err = fmt.Errorf("local state query failed: %w", err)
+		return nil, err
+	case <-time.After(c.timeout):
+		c.err = ErrTimeout
+		c.conn.Close()
+		return nil, ErrTimeout
+	}
+}
+
+func (c *Client) acquire() error {
+	if err := c.send(localstatequery.NewMsgAcquireVolatileTip()); err != nil {
+		return err
+	}
+	msg, err := c.await()
+	if err != nil {
+		return fmt.Errorf("failed to acquire ledger state: %w", err)
+	}
+	switch m := msg.(type) {
+	case *localstatequery.MsgAcquired:
+		c.acquired = true
+		return nil
+	case *localstatequery.MsgFailure:
+		return fmt.Errorf("failed to acquire ledger state: failure %d", m.Failure)
+	default:
+		return fmt.Errorf("unexpected message %T while acquiring", msg)
+	}
+}
+
+// Query sends query, whose CBOR encoding follows the LocalStateQuery CDDL, and decodes the result into
+// result.
+func (c *Client) Query(query any, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.query(query, result)
+}
+
+func (c *Client) query(query any, result any) error {
+	if !c.acquired {
+		if err := c.acquire(); err != nil {
+			return err
+		}
+	}
+	if err := c.send(localstatequery.NewMsgQuery(query)); err != nil {
+		return err
+	}
+	msg, err := c.await()
+	if err != nil {
+		return fmt.Errorf("failed to run query: %w", err)
+	}
+	res, ok := msg.(*localstatequery.MsgResult)
+	if !ok {
+		return fmt.Errorf("unexpected message %T while querying", msg)
+	}
+	if _, err := cbor.Decode(res.Result, result); err != nil {
+		return fmt.Errorf("failed to decode query result: %w", err)
+	}
+	return nil
+}
+
+// Release lets the node free the acquired ledger state. The next query acquires the tip again.
+func (c *Client) Release() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.acquired {
+		return nil
+	}
+	c.acquired = false
+	c.era = -1
+	return c.send(localstatequery.NewMsgRelease())
+}
+
+// Era returns the id of the ledger's current era.
+func (c *Client) Era() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.currentEra()
+}
+
+func (c *Client) currentEra() (int, error) {
+	if c.era >= 0 {
+		return c.era, nil
+	}
+	var era int
+	if err := c.query(hardForkQuery(localstatequery.QueryTypeHardForkCurrentEra), &era); err != nil {
+		return 0, err
+	}
+	c.era = era
+	return era, nil
+}
+
+// HardForkQuery runs a query of the hard fork combinator, like the era history.
+func (c *Client) HardForkQuery(result any, queryType int, params ...any) error {
+	return c.Query(hardForkQuery(queryType, params...), result)
+}
+
+// ShelleyQuery runs a ledger query in the current era and decodes its result into result.
+func (c *Client) ShelleyQuery(result any, queryType int, params ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	era, err := c.currentEra()
+	if err != nil {
+		return err
+	}
+	query := []any{localstatequery.QueryTypeBlock,
+		[]any{localstatequery.QueryTypeShelley,
+			[]any{era, append([]any{queryType}, params...)}}}
+	// the result is wrapped in a single element list, or is the mismatching eras
+	var wrapped []cbor.RawMessage
+	if err := c.query(query, &wrapped); err != nil {
+		return err
+	}
+	if len(wrapped) != 1 {
+		return ErrEraMismatch
+	}
+	if _, err := cbor.Decode(wrapped[0], result); err != nil {
+		return fmt.Errorf("failed to decode query result: %w", err)
+	}
+	return nil
+}
+
+func hardForkQuery(queryType int, params ...any) []any {
+	return []any{localstatequery.QueryTypeBlock,
+		[]any{localstatequery.QueryTypeHardFork, append([]any{queryType}, params...)}}
+}
@@ -0,0 +1,253 @@
+package lsq_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/kocubinski/gardano/bech32"
+	. "github.com/kocubinski/gardano/lsq"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode answers queries by matching their CBOR encoding.
+type fakeNode struct {
+	t       *testing.T
+	answers map[string]any
+	// delay holds back every answer
+	delay atomic.Int64
+}
+
+func (n *fakeNode) query(query any, result any) {
+	bz, err := cbor.Encode(query)
+	require.NoError(n.t, err)
+	n.answers[string(bz)] = result
+}
+
+// shelley registers the answer to a query of the Conway era, wrapped like the node wraps it.
+func (n *fakeNode) shelley(queryType int, result any, params ...any) {
+	n.query([]any{0, []any{0, []any{ledger.EraIdConway, append([]any{queryType}, params...)}}}, []any{result})
+}
+
+func connect(t *testing.T, node *fakeNode, opts ...Option) *Client {
+	clientConn, serverConn := net.Pipe()
+	// both ends block on the handshake until the other one starts
+	serverErr := make(chan error, 1)
+	go func() {
+		server, err := ouroboros.NewConnection(
+			ouroboros.WithConnection(serverConn),
+			ouroboros.WithNetworkMagic(42),
+			ouroboros.WithServer(true),
+			ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig(
+				localstatequery.WithAcquireFunc(func(localstatequery.CallbackContext, localstatequery.AcquireTarget, bool) error {
+					return nil
+				}),
+				localstatequery.WithQueryFunc(func(_ localstatequery.CallbackContext, q localstatequery.QueryWrapper) (any, error) {
+					time.Sleep(time.Duration(node.delay.Load()))
+					for key, answer := range node.answers {
+						if bytes.Equal([]byte(key), q.Cbor()) {
+							return answer, nil
+						}
+					}
+					t.Errorf("unexpected query %x", q.Cbor())
+					return nil, nil
+				}),
+				localstatequery.WithReleaseFunc(func(localstatequery.CallbackContext) error { return nil }),
+			)),
+		)
+		if err == nil {
+			t.Cleanup(func() { server.Close() })
+		}
+		serverErr <- err
+	}()
+
+	conn, err := ouroboros.NewConnection(
+		ouroboros.WithConnection(clientConn),
+		ouroboros.WithNetworkMagic(42),
+		ouroboros.WithDelayProtocolStart(true),
+	)
+	require.NoError(t, err)
+	require.NoError(t, <-serverErr)
+	t.Cleanup(func() { conn.Close() })
+	return New(conn, nil, append([]Option{WithTimeout(5 * time.Second)}, opts...)...)
+}
+
+func Test_Queries(t *testing.T) {
+	node := &fakeNode{t: t, answers: map[string]any{}}
+	node.query([]any{0, []any{2, []any{1}}}, ledger.EraIdConway)
+	node.query([]any{1}, []any{2017, 266, uint64(78_291_000_000_000_000)})
+	node.query([]any{2}, []any{1, 4492800})
+	node.query([]any{3}, common.NewPoint(72316896, bytes.Repeat([]byte{0xcd}, 32)))
+	node.shelley(localstatequery.QueryTypeShelleyEpochNo, 12)
+	// mainnet's Byron era, whose end overflows 64 bits of picoseconds
+	byronEnd, _ := new(big.Int).SetString("89856000000000000000", 10)
+	node.query([]any{0, []any{2, []any{0}}}, []any{
+		[]any{
+			[]any{0, 0, 0},
+			[]any{byronEnd, 4492800, 208},
+			[]any{21600, 20000, []any{0, 4320, []any{0}}, 0},
+		},
+		[]any{
+			[]any{byronEnd, 4492800, 208},
+			nil,
+			[]any{432000, 1000, []any{0, 129600, []any{0}}, 0},
+		},
+	})
+
+	c := connect(t, node)
+	era, err := c.Era()
+	require.NoError(t, err)
+	require.Equal(t, ledger.EraIdConway, era)
+
+	tip, err := c.Tip()
+	require.NoError(t, err)
+	require.Equal(t, Tip{
+		Point: common.NewPoint(72316896, bytes.Repeat([]byte{0xcd}, 32)),
+		Block: 4492800,
+		Epoch: 12,
+		Era:   ledger.EraIdConway,
+	}, tip)
+
+	start, err := c.SystemStart()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2017, time.September, 23, 21, 44, 51, 0, time.UTC), start)
+
+	history, err := c.EraHistory()
+	require.NoError(t, err)
+	require.Equal(t, []EraSummary{
+		{
+			Start:       Bound{},
+			End:         &Bound{Time: 89856000 * time.Second, Slot: 4492800, Epoch: 208},
+			EpochLength: 21600,
+			SlotLength:  20 * time.Second,
+		},
+		{
+			Start:       Bound{Time: 89856000 * time.Second, Slot: 4492800, Epoch: 208},
+			EpochLength: 432000,
+			SlotLength:  time.Second,
+		},
+	}, history)
+
+	summary, err := c.Slotting()
+	require.NoError(t, err)
+	at, err := summary.SlotToTime(4492800)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, time.July, 29, 21, 44, 51, 0, time.UTC), at)
+	require.NoError(t, c.Release())
+}
+
+func Test_StakeCredential(t *testing.T) {
+	hash := bytes.Repeat([]byte{0x01}, 28)
+	stakeAddr, err := bech32.ConvertAndEncode("stake_test", append([]byte{0xe0}, hash...))
+	require.NoError(t, err)
+	addr, err := ledger.NewAddress(stakeAddr)
+	require.NoError(t, err)
+	cred, err := StakeCredential(addr)
+	require.NoError(t, err)
+	require.Equal(t, Credential{Type: CredentialKey, Hash: ledger.Blake2b224(hash)}, cred)
+
+	// enterprise addresses have no stake credential
+	enterprise, err := bech32.ConvertAndEncode("addr_test", append([]byte{0x60}, hash...))
+	require.NoError(t, err)
+	addr, err = ledger.NewAddress(enterprise)
+	require.NoError(t, err)
+	_, err = StakeCredential(addr)
+	require.Error(t, err)
+}
+
+func Test_Provider(t *testing.T) {
+	owner := bytes.Repeat([]byte{0x02}, 28)
+	ownerAddr, err := bech32.ConvertAndEncode("addr_test", append([]byte{0x60}, owner...))
+	require.NoError(t, err)
+	txHash := bytes.Repeat([]byte{0x10}, 32)
+	var id localstatequery.UtxoId
+	copy(id.Hash[:], txHash)
+	id.Idx = 1
+
+	node := &fakeNode{t: t, answers: map[string]any{}}
+	node.query([]any{0, []any{2, []any{1}}}, ledger.EraIdConway)
+	node.shelley(localstatequery.QueryTypeShelleyUtxoByTxin,
+		map[localstatequery.UtxoId]any{id: map[int]any{0: append([]byte{0x60}, owner...), 1: uint64(2_000_000)}},
+		[][]any{{txHash, uint16(1)}, {txHash, uint16(2)}})
+
+	p := connect(t, node).Provider()
+	in1 := tx.NewTxInput(hex.EncodeToString(txHash), 1, 0)
+	in2 := tx.NewTxInput(hex.EncodeToString(txHash), 2, 0)
+	// each lookup acquires the tip again
+	for range 2 {
+		utxos, err := p.UTxOsByTxIn(context.Background(), in1, in2)
+		require.NoError(t, err)
+		require.Len(t, utxos, 1)
+		require.Equal(t, uint16(1), utxos[0].Index)
+		require.Equal(t, uint64(2_000_000), utxos[0].Amount)
+		require.Equal(t, ownerAddr, utxos[0].Address.String())
+	}
+}
+
+func Test_UTxOsByAddress(t *testing.T) {
+	owner := bytes.Repeat([]byte{0x02}, 28)
+	ownerAddr, err := bech32.ConvertAndEncode("addr_test", append([]byte{0x60}, owner...))
+	require.NoError(t, err)
+	addr, err := ledger.NewAddress(ownerAddr)
+	require.NoError(t, err)
+	txHash := bytes.Repeat([]byte{0x10}, 32)
+	var plain, withAssets localstatequery.UtxoId
+	copy(plain.Hash[:], txHash)
+	copy(withAssets.Hash[:], txHash)
+	withAssets.Idx = 1
+	policy := bytes.Repeat([]byte{0x30}, 28)
+
+	node := &fakeNode{t: t, answers: map[string]any{}}
+	node.query([]any{0, []any{2, []any{1}}}, ledger.EraIdConway)
+	node.shelley(localstatequery.QueryTypeShelleyUtxoByAddress,
+		map[localstatequery.UtxoId]any{
+			plain: map[int]any{0: append([]byte{0x60}, owner...), 1: uint64(5_000_000)},
+			withAssets: map[int]any{0: append([]byte{0x60}, owner...), 1: []any{
+				uint64(2_000_000), map[ledger.Blake2b224]map[cbor.ByteString]uint64{
+					ledger.NewBlake2b224(policy): {cbor.NewByteString([]byte("coin")): 7},
+				},
+			}},
+		},
+		[]ledger.Address{addr})
+
+	utxos, err := connect(t, node).UTxOsByAddress(addr)
+	require.NoError(t, err)
+	require.Len(t, utxos, 2)
+	require.Equal(t, uint64(5_000_000), utxos[0].Amount)
+	require.Equal(t, ownerAddr, utxos[0].Address.String())
+	require.Empty(t, utxos[0].Assets)
+	require.Equal(t, uint64(2_000_000), utxos[1].Amount)
+	require.Equal(t, ownerAddr, utxos[1].Address.String())
+	require.Equal(t, tx.MultiAsset{hex.EncodeToString(policy): {hex.EncodeToString([]byte("coin")): 7}}, utxos[1].Assets)
+}
+
+func Test_QueryTimeout(t *testing.T) {
+	node := &fakeNode{t: t, answers: map[string]any{}}
+	node.query([]any{2}, []any{1, 4492800})
+	node.query([]any{3}, common.NewPoint(72316896, bytes.Repeat([]byte{0xcd}, 32)))
+	c := connect(t, node, WithTimeout(150*time.Millisecond))
+
+	node.delay.Store(int64(200 * time.Millisecond))
+	var blockNo []uint64
+	require.ErrorIs(t, c.Query([]any{2}, &blockNo), ErrTimeout)
+
+	// the protocol still waits for the late answer, so a query sent after a timeout fails right away
+	// rather than taking the late answer for its own
+	node.delay.Store(0)
+	var point common.Point
+	start := time.Now()
+	require.ErrorIs(t, c.Query([]any{3}, &point), ErrTimeout)
+	require.Less(t, time.Since(start), 150*time.Millisecond)
+	require.ErrorIs(t, c.Release(), ErrTimeout)
+}
@@ -0,0 +1,39 @@
+package lsq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// Provider returns a provider.UTxOProvider over the ledger state. Each lookup releases the ledger state the
+// client holds first, so that it sees the current tip.
+func (c *Client) Provider() provider.UTxOProvider {
+	return clientProvider{c: c}
+}
+
+type clientProvider struct {
+	c *Client
+}
+
+func (p clientProvider) UTxOsByAddress(_ context.Context, addr address.Address) ([]tx.TxInput, error) {
+	ledgerAddr, err := ledger.NewAddress(addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert address: %w", err)
+	}
+	if err := p.c.Release(); err != nil {
+		return nil, err
+	}
+	return p.c.UTxOsByAddress(ledgerAddr)
+}
+
+func (p clientProvider) UTxOsByTxIn(_ context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	if err := p.c.Release(); err != nil {
+		return nil, err
+	}
+	return p.c.UTxOsByTxIn(txIns...)
+}
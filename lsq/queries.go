@@ -0,0 +1,329 @@
+package lsq
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/pparams"
+	"github.com/kocubinski/gardano/slotting"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// Tip describes the ledger state the client acquired.
+type Tip struct {
+	Point common.Point
+	Block uint64
+	Epoch uint64
+	Era   int
+}
+
+func (c *Client) Tip() (Tip, error) {
+	var tip Tip
+	if err := c.Query([]any{localstatequery.QueryTypeChainPoint}, &tip.Point); err != nil {
+		return Tip{}, fmt.Errorf("failed to query chain point: %w", err)
+	}
+	// the block number is [1, number], or [0] at origin
+	var blockNo []uint64
+	if err := c.Query([]any{localstatequery.QueryTypeChainBlockNo}, &blockNo); err != nil {
+		return Tip{}, fmt.Errorf("failed to query block number: %w", err)
+	}
+	if len(blockNo) == 2 {
+		tip.Block = blockNo[1]
+	}
+	if err := c.ShelleyQuery(&tip.Epoch, localstatequery.QueryTypeShelleyEpochNo); err != nil {
+		return Tip{}, fmt.Errorf("failed to query epoch: %w", err)
+	}
+	era, err := c.Era()
+	if err != nil {
+		return Tip{}, fmt.Errorf("failed to query era: %w", err)
+	}
+	tip.Era = era
+	return tip, nil
+}
+
+// SystemStart returns the time of the network's first slot.
+func (c *Client) SystemStart() (time.Time, error) {
+	var res localstatequery.SystemStartResult
+	if err := c.Query([]any{localstatequery.QueryTypeSystemStart}, &res); err != nil {
+		return time.Time{}, fmt.Errorf("failed to query system start: %w", err)
+	}
+	start := time.Date(res.Year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, res.Day-1)
+	return start.Add(time.Duration(res.Picoseconds / 1000)), nil
+}
+
+// Bound is the start or end of an era.
+type Bound struct {
+	// Time is relative to the system start.
+	Time  time.Duration
+	Slot  uint64
+	Epoch uint64
+}
+
+func (b *Bound) UnmarshalCBOR(data []byte) error {
+	var bound struct {
+		cbor.StructAsArray
+		// picoseconds, which overflow 64 bits on long running networks
+		Time  *big.Int
+		Slot  uint64
+		Epoch uint64
+	}
+	if _, err := cbor.Decode(data, &bound); err != nil {
+		return err
+	}
+	ns := new(big.Int).Quo(bound.Time, big.NewInt(1000))
+	if !ns.IsInt64() {
+		return fmt.Errorf("era bound time %s ps out of range", bound.Time)
+	}
+	*b = Bound{Time: time.Duration(ns.Int64()), Slot: bound.Slot, Epoch: bound.Epoch}
+	return nil
+}
+
+// EraSummary is the slotting of one era of the era history.
+type EraSummary struct {
+	Start Bound
+	// End is nil for an era without a known end.
+	End         *Bound
+	EpochLength uint64
+	SlotLength  time.Duration
+}
+
+func (s *EraSummary) UnmarshalCBOR(data []byte) error {
+	var summary struct {
+		cbor.StructAsArray
+		Start  Bound
+		End    cbor.RawMessage
+		Params []cbor.RawMessage
+	}
+	if _, err := cbor.Decode(data, &summary); err != nil {
+		return err
+	}
+	*s = EraSummary{Start: summary.Start}
+	if !bytes.Equal(summary.End, []byte{0xf6}) {
+		s.End = &Bound{}
+		if _, err := cbor.Decode(summary.End, s.End); err != nil {
+			return err
+		}
+	}
+	if len(summary.Params) < 2 {
+		return fmt.Errorf("invalid era parameters, expected at least 2 elements, got %d", len(summary.Params))
+	}
+	if _, err := cbor.Decode(summary.Params[0], &s.EpochLength); err != nil {
+		return err
+	}
+	var slotLengthMs uint64
+	if _, err := cbor.Decode(summary.Params[1], &slotLengthMs); err != nil {
+		return err
+	}
+	s.SlotLength = time.Duration(slotLengthMs) * time.Millisecond
+	return nil
+}
+
+// EraHistory returns the summaries of all eras up to the current one, indexed by era id.
+func (c *Client) EraHistory() ([]EraSummary, error) {
+	var res []EraSummary
+	if err := c.HardForkQuery(&res, localstatequery.QueryTypeHardForkEraHistory); err != nil {
+		return nil, fmt.Errorf("failed to query era history: %w", err)
+	}
+	return res, nil
+}
+
+// Slotting returns the node's era history for converting between slots and time.
+func (c *Client) Slotting() (*slotting.Summary, error) {
+	start, err := c.SystemStart()
+	if err != nil {
+		return nil, err
+	}
+	history, err := c.EraHistory()
+	if err != nil {
+		return nil, err
+	}
+	bound := func(b Bound) slotting.Bound {
+		return slotting.Bound{Time: b.Time, Slot: b.Slot, Epoch: b.Epoch}
+	}
+	eras := make([]slotting.Era, 0, len(history))
+	for _, summary := range history {
+		era := slotting.Era{
+			Start:       bound(summary.Start),
+			EpochLength: summary.EpochLength,
+			SlotLength:  summary.SlotLength,
+		}
+		if summary.End != nil {
+			end := bound(*summary.End)
+			era.End = &end
+		}
+		eras = append(eras, era)
+	}
+	return slotting.New(start, eras)
+}
+
+// ProtocolParams returns the parameters of the current era.
+func (c *Client) ProtocolParams() (*pparams.PParams, error) {
+	era, err := c.Era()
+	if err != nil {
+		return nil, err
+	}
+	var pp lcommon.ProtocolParameters
+	switch era {
+	case ledger.EraIdShelley:
+		pp = &ledger.ShelleyProtocolParameters{}
+	case ledger.EraIdAllegra:
+		pp = &ledger.AllegraProtocolParameters{}
+	case ledger.EraIdMary:
+		pp = &ledger.MaryProtocolParameters{}
+	case ledger.EraIdAlonzo:
+		pp = &ledger.AlonzoProtocolParameters{}
+	case ledger.EraIdBabbage:
+		pp = &ledger.BabbageProtocolParameters{}
+	case ledger.EraIdConway:
+		pp = &ledger.ConwayProtocolParameters{}
+	default:
+		return nil, fmt.Errorf("no protocol parameters in era %d", era)
+	}
+	if err := c.ShelleyQuery(pp, localstatequery.QueryTypeShelleyCurrentProtocolParams); err != nil {
+		return nil, fmt.Errorf("failed to query protocol parameters: %w", err)
+	}
+	return pparams.FromLedger(pp)
+}
+
+// UTxOsByAddress returns the unspent outputs of addrs.
+func (c *Client) UTxOsByAddress(addrs ...ledger.Address) ([]tx.TxInput, error) {
+	var res map[localstatequery.UtxoId]ledger.BabbageTransactionOutput
+	if err := c.ShelleyQuery(&res, localstatequery.QueryTypeShelleyUtxoByAddress, addrs); err != nil {
+		return nil, fmt.Errorf("failed to query utxo: %w", err)
+	}
+	return UTxOs(res), nil
+}
+
+// UTxOsByTxIn returns those of txIns which are unspent, with the outputs they spend.
+func (c *Client) UTxOsByTxIn(txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	ins := make([][]any, 0, len(txIns))
+	for _, in := range txIns {
+		ins = append(ins, []any{in.TxHash, in.Index})
+	}
+	var res map[localstatequery.UtxoId]ledger.BabbageTransactionOutput
+	if err := c.ShelleyQuery(&res, localstatequery.QueryTypeShelleyUtxoByTxin, ins); err != nil {
+		return nil, fmt.Errorf("failed to query utxo: %w", err)
+	}
+	return UTxOs(res), nil
+}
+
+// UTxOs converts the outputs of a UTxO query into inputs spending them, with their address, assets and
+// datum, sorted by transaction and index.
+func UTxOs(res map[localstatequery.UtxoId]ledger.BabbageTransactionOutput) []tx.TxInput {
+	utxos := make([]tx.TxInput, 0, len(res))
+	for id, out := range res {
+		txIn := tx.NewTxInput(id.Hash.String(), uint16(id.Idx), out.Amount())
+		txIn.Address = address.Address(out.Address().Bytes())
+		txIn.Assets = tx.NewMultiAssetFromLedger(out.Assets())
+		if h := out.DatumHash(); h != nil {
+			txIn.DatumHash = h.Bytes()
+		}
+		if d := out.Datum(); d != nil {
+			txIn.Datum = d.Cbor()
+		}
+		utxos = append(utxos, txIn)
+	}
+	slices.SortFunc(utxos, func(a, b tx.TxInput) int {
+		if c := bytes.Compare(a.TxHash, b.TxHash); c != 0 {
+			return c
+		}
+		return int(a.Index) - int(b.Index)
+	})
+	return utxos
+}
+
+// PoolStake is a stake pool's share of the active stake.
+type PoolStake struct {
+	PoolId   ledger.PoolId
+	Fraction *big.Rat
+	VrfHash  ledger.Blake2b256
+}
+
+// StakeDistribution returns the stake of all pools, largest first.
+func (c *Client) StakeDistribution() ([]PoolStake, error) {
+	var res map[ledger.PoolId]struct {
+		cbor.StructAsArray
+		StakeFraction *cbor.Rat
+		VrfHash       ledger.Blake2b256
+	}
+	if err := c.ShelleyQuery(&res, localstatequery.QueryTypeShelleyStakeDistribution); err != nil {
+		return nil, fmt.Errorf("failed to query stake distribution: %w", err)
+	}
+	pools := make([]PoolStake, 0, len(res))
+	for id, stake := range res {
+		pools = append(pools, PoolStake{PoolId: id, Fraction: stake.StakeFraction.Rat, VrfHash: stake.VrfHash})
+	}
+	slices.SortFunc(pools, func(a, b PoolStake) int {
+		if c := b.Fraction.Cmp(a.Fraction); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.PoolId[:], b.PoolId[:])
+	})
+	return pools, nil
+}
+
+// Credential is a stake credential, a key hash or a script hash.
+type Credential struct {
+	cbor.StructAsArray
+	Type uint8
+	Hash ledger.Blake2b224
+}
+
+const (
+	CredentialKey    = 0
+	CredentialScript = 1
+)
+
+// StakeCredential returns the stake credential of a reward or base address.
+func StakeCredential(addr ledger.Address) (Credential, error) {
+	switch addr.Type() {
+	case lcommon.AddressTypeNoneKey, lcommon.AddressTypeKeyKey, lcommon.AddressTypeScriptKey:
+		return Credential{Type: CredentialKey, Hash: addr.StakeKeyHash()}, nil
+	case lcommon.AddressTypeNoneScript, lcommon.AddressTypeKeyScript, lcommon.AddressTypeScriptScript:
+		return Credential{Type: CredentialScript, Hash: addr.StakeKeyHash()}, nil
+	default:
+		return Credential{}, fmt.Errorf("address %s has no stake credential", addr.String())
+	}
+}
+
+// StakeAddressInfo is the delegation and reward balance of a stake credential.
+type StakeAddressInfo struct {
+	Credential Credential
+	// Registered is false for credentials without a reward account.
+	Registered    bool
+	RewardBalance uint64
+	// Delegation is nil for credentials not delegated to a pool.
+	Delegation *ledger.PoolId
+}
+
+// StakeAddressInfo returns the delegation and rewards of each credential, in the given order.
+func (c *Client) StakeAddressInfo(creds ...Credential) ([]StakeAddressInfo, error) {
+	var res struct {
+		cbor.StructAsArray
+		Delegations map[Credential]ledger.PoolId
+		Rewards     map[Credential]uint64
+	}
+	param := cbor.Tag{Number: 258, Content: creds}
+	if err := c.ShelleyQuery(&res, localstatequery.QueryTypeShelleyFilteredDelegationAndRewardAccounts, param); err != nil {
+		return nil, fmt.Errorf("failed to query stake address info: %w", err)
+	}
+	infos := make([]StakeAddressInfo, 0, len(creds))
+	for _, cred := range creds {
+		info := StakeAddressInfo{Credential: cred}
+		info.RewardBalance, info.Registered = res.Rewards[cred]
+		if pool, ok := res.Delegations[cred]; ok {
+			info.Delegation = &pool
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -2,17 +2,20 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	"crypto/ed25519"
 	"crypto/tls"
 	"encoding/hex"
-	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
-	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	ouroboros "github.com/blinklabs-io/gouroboros"
@@ -22,8 +25,23 @@ import (
 	"github.com/blinklabs-io/gouroboros/protocol/chainsync"
 	"github.com/blinklabs-io/gouroboros/protocol/common"
 	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxmonitor"
 	"github.com/cosmos/btcutil/bech32"
 	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/checkpoint"
+	"github.com/kocubinski/gardano/filter"
+	"github.com/kocubinski/gardano/follower"
+	"github.com/kocubinski/gardano/indexer"
+	"github.com/kocubinski/gardano/lsq"
+	"github.com/kocubinski/gardano/metadata"
+	"github.com/kocubinski/gardano/observer"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/provider/kupo"
+	"github.com/kocubinski/gardano/provider/pending"
+	"github.com/kocubinski/gardano/provider/utxofile"
+	"github.com/kocubinski/gardano/sink"
+	"github.com/kocubinski/gardano/submitter"
+	"github.com/kocubinski/gardano/supervisor"
 	"github.com/kocubinski/gardano/tx"
 )
 
@@ -46,17 +64,78 @@ type cliFlags struct {
 	// client
 	clientAddress string
 	clientSocket  string
+	peerAddress   string
+	useTls        bool
+	maxPeers      int
 
 	// Tx submission
-	receiverAddress string
-	sendAmount      uint64
-	memo            string
-	fee             uint64
+	receiverAddress    string
+	sendAmount         uint64
+	memo               string
+	memoPassphrase     string
+	metadataJSONFile   string
+	metadataDetailed   bool
+	fee                uint64
+	kupoURL            string
+	indexFile          string
+	pendingFile        string
+	utxoFile           string
+	wait               bool
+	txHex              string
+	fromAddress        string
+	ttl                uint64
+	validUntil         string
+	witnesses          int
+	outFile            string
+	signingKeyFiles    string
+	protocolParamsFile string
+	txFile             string
+	timeout            time.Duration
+	payoutsFile        string
+	outDir             string
+	reportFile         string
+	submit             bool
+
+	// query
+	queryAddresses string
+	txIns          string
+
+	// address
+	paymentVKeyFile string
+	stakeVKeyFile   string
+	scriptFile      string
+	network         string
+	convertTo       string
+
+	// mempool
+	mempoolHas string
+	watch      bool
+	interval   time.Duration
+
+	// utxorpc, submit-api
+	listenAddress string
+	poolSize      int
 
 	// chain sync
 	filterAddresses string
+	filterExpr      string
 	startHash       string
 	startSlot       uint64
+	checkpointFile  string
+	checkpointDB    string
+	checkpointK     int
+	confirmations   uint64
+	healthListen    string
+	indexAddresses  string
+	indexPayment    string
+	indexStake      string
+	output          string
+	outputFile      string
+	outputFileSize  int64
+	webhookURL      string
+	webhookSecret   string
+	natsURL         string
+	natsSubject     string
 }
 
 func main() {
@@ -83,18 +162,134 @@ func main() {
 		f.flagset.StringVar(&f.clientAddress, "address", "", "TCP address for n2c communication")
 		f.flagset.StringVar(&f.clientSocket, "socket", "", "unix socket address for n2c communication")
 		f.flagset.StringVar(&f.memo, "memo", "", "optional tx memo")
+		f.flagset.StringVar(&f.memoPassphrase, "memo-passphrase", "", "optional passphrase to encrypt -memo with (CIP-83)")
+		f.flagset.StringVar(&f.metadataJSONFile, "metadata-json-file", "", "optional JSON file of metadata to attach, keyed by label")
+		f.flagset.BoolVar(&f.metadataDetailed, "json-metadata-detailed-schema", false, "read -metadata-json-file in the detailed schema instead of no schema")
 		f.flagset.Uint64Var(&f.fee, "fee", 0, "if unset fees are dynamically calculated")
+		f.flagset.StringVar(&f.validUntil, "valid-until", "", "RFC3339 time or duration from now after which the tx is invalid, defaults to 300 slots after the node's tip")
+		f.flagset.StringVar(&f.kupoURL, "kupo-url", "", "optional Kupo URL to query UTxOs from instead of the node")
+		f.flagset.StringVar(&f.indexFile, "index-file", "", "optional UTxO index written by chain-sync to select inputs from instead of the node")
+		f.flagset.StringVar(&f.pendingFile, "pending-file", "", "optional JSON file of submitted but unconfirmed txs whose change later txs may spend")
+		f.flagset.StringVar(&f.protocolParamsFile, "protocol-parameters-file", "", "cardano-cli protocol parameters JSON to use instead of querying the node")
+		f.flagset.BoolVar(&f.wait, "wait", false, "wait until the transaction is confirmed, resubmitting it if it drops out of the mempool")
+		f.flagset.Uint64Var(&f.confirmations, "confirmations", 1, "number of blocks on chain before -wait reports the transaction confirmed")
+		f.flagset.DurationVar(&f.interval, "interval", 20*time.Second, "how often -wait checks the transaction is still in the mempool")
 		var networkMagic uint
 		f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
 		parseFlags()
 		f.networkMagic = uint32(networkMagic)
 		err = sendTx(f)
 	case "chain-sync":
+		f.flagset.StringVar(&f.clientAddress, "address", "", "TCP address for n2c communication")
+		f.flagset.StringVar(&f.clientSocket, "socket", "", "unix socket address for n2c communication")
+		f.flagset.StringVar(&f.peerAddress, "peer", "", "comma separated host:port of nodes to follow over n2n, defaults to the network's bootstrap peers")
+		f.flagset.IntVar(&f.maxPeers, "max-peers", 3, "number of n2n peers to follow, including those found through peer sharing")
+		f.flagset.BoolVar(&f.useTls, "tls", false, "use TLS for TCP connections")
+		var networkMagic uint
+		f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
 		f.flagset.StringVar(&f.filterAddresses, "filter-addresses", "", "Filter addresses")
+		f.flagset.StringVar(&f.filterExpr, "filter", "", "filter expression selecting transactions to report, e.g. 'stake:<hex> or label:674'")
+		f.flagset.StringVar(&f.memoPassphrase, "memo-passphrase", "", "passphrase to decrypt CIP-83 encrypted memos of transactions to or from watched addresses")
 		f.flagset.StringVar(&f.startHash, "start-hash", "", "Start hash")
 		f.flagset.Uint64Var(&f.startSlot, "start-slot", 0, "Start slot")
+		f.flagset.StringVar(&f.checkpointFile, "checkpoint-file", "", "JSON file to persist the chain-sync cursor in, with the emitted deposits of -filter-addresses in <file>.observer.json")
+		f.flagset.StringVar(&f.checkpointDB, "checkpoint-db", "", "SQLite database to persist the chain-sync cursor in, with the emitted deposits of -filter-addresses in <file>.observer.json")
+		f.flagset.IntVar(&f.checkpointK, "checkpoint-k", checkpoint.DefaultSecurityParam, "number of recent points to keep for resuming")
+		f.flagset.Uint64Var(&f.confirmations, "confirmations", 1, "number of blocks on chain before a filtered deposit is reported")
+		f.flagset.StringVar(&f.indexFile, "index-file", "", "JSON file to maintain a local UTxO index in")
+		f.flagset.StringVar(&f.indexAddresses, "index-addresses", "", "comma separated addresses to index")
+		f.flagset.StringVar(&f.indexPayment, "index-payment-credentials", "", "comma separated hex payment key or script hashes to index")
+		f.flagset.StringVar(&f.indexStake, "index-stake-credentials", "", "comma separated hex stake key or script hashes to index")
+		f.flagset.StringVar(&f.healthListen, "health-listen", "", "address to serve the connection health on at /healthz")
+		f.flagset.StringVar(&f.output, "output", "text", "stdout format, text or json (JSON lines)")
+		f.flagset.StringVar(&f.outputFile, "output-file", "", "file to append JSON line events to, rotated by size")
+		f.flagset.Int64Var(&f.outputFileSize, "output-file-max-size", 100, "size in MiB after which the output file is rotated")
+		f.flagset.StringVar(&f.webhookURL, "webhook-url", "", "URL to POST JSON events to")
+		f.flagset.StringVar(&f.webhookSecret, "webhook-secret", "", "secret to sign webhook bodies with (HMAC-SHA256)")
+		f.flagset.StringVar(&f.natsURL, "nats-url", "", "NATS server to publish JSON events to, e.g. nats://localhost:4222")
+		f.flagset.StringVar(&f.natsSubject, "nats-subject", "gardano", "subject prefix for published events")
 		parseFlags()
+		f.networkMagic = uint32(networkMagic)
 		err = runNode(f)
+	case "mempool":
+		f.flagset.StringVar(&f.clientAddress, "address", "", "TCP address for n2c communication")
+		f.flagset.StringVar(&f.clientSocket, "socket", "", "unix socket address for n2c communication")
+		f.flagset.StringVar(&f.mempoolHas, "has", "", "comma separated tx hashes to look up in the mempool")
+		f.flagset.BoolVar(&f.watch, "watch", false, "stream transactions entering the mempool")
+		f.flagset.DurationVar(&f.interval, "interval", time.Second, "mempool polling interval for -watch")
+		f.flagset.StringVar(&f.filterExpr, "filter", "", "filter expression selecting the transactions -watch reports, see chain-sync")
+		f.flagset.StringVar(&f.memoPassphrase, "memo-passphrase", "", "passphrase to decrypt CIP-83 encrypted memos matched by -filter")
+		var networkMagic uint
+		f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
+		parseFlags()
+		f.networkMagic = uint32(networkMagic)
+		err = mempoolCmd(f)
+	case "serve-utxorpc":
+		f.flagset.StringVar(&f.clientAddress, "address", "", "TCP address for n2c communication")
+		f.flagset.StringVar(&f.clientSocket, "socket", "", "unix socket address for n2c communication")
+		f.flagset.StringVar(&f.listenAddress, "listen", ":50051", "address to serve utxorpc on")
+		var networkMagic uint
+		f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
+		parseFlags()
+		f.networkMagic = uint32(networkMagic)
+		err = serveUtxorpc(f)
+	case "tx":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: gardano tx build|batch|sign|submit|view")
+			os.Exit(1)
+		}
+		f.flagset = flag.NewFlagSet("tx "+os.Args[2], flag.ExitOnError)
+		err = txCmd(f, os.Args[2], func() {
+			if err := f.flagset.Parse(os.Args[3:]); err != nil {
+				fmt.Println("failed to parse flags:", err)
+				os.Exit(1)
+			}
+		})
+	case "address":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: gardano address inspect|build|convert")
+			os.Exit(1)
+		}
+		f.flagset = flag.NewFlagSet("address "+os.Args[2], flag.ExitOnError)
+		err = addressCmd(f, os.Args[2], func() {
+			if err := f.flagset.Parse(os.Args[3:]); err != nil {
+				fmt.Println("failed to parse flags:", err)
+				os.Exit(1)
+			}
+		})
+	case "query":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: gardano query utxo|balance|tip|protocol-parameters|stake-address-info|stake-distribution|era-history")
+			os.Exit(1)
+		}
+		f.flagset = flag.NewFlagSet("query "+os.Args[2], flag.ExitOnError)
+		err = queryCmd(f, os.Args[2], func() {
+			if err := f.flagset.Parse(os.Args[3:]); err != nil {
+				fmt.Println("failed to parse flags:", err)
+				os.Exit(1)
+			}
+		})
+	case "broadcast":
+		f.flagset.StringVar(&f.peerAddress, "peer", "", "comma separated host:port of relays to offer the tx to over n2n, defaults to the network's bootstrap peers")
+		f.flagset.BoolVar(&f.useTls, "tls", false, "use TLS for TCP connections")
+		f.flagset.StringVar(&f.txHex, "tx", "", "hex encoded signed transaction")
+		f.flagset.StringVar(&f.txFile, "tx-file", "", "file holding the signed transaction as a text envelope, hex or CBOR")
+		f.flagset.DurationVar(&f.timeout, "timeout", time.Minute, "time to wait for the relays to download the tx")
+		var networkMagic uint
+		f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
+		parseFlags()
+		f.networkMagic = uint32(networkMagic)
+		err = broadcastTx(f)
+	case "submit-api":
+		f.flagset.StringVar(&f.clientAddress, "address", "", "TCP address for n2c communication")
+		f.flagset.StringVar(&f.clientSocket, "socket", "", "unix socket address for n2c communication")
+		f.flagset.StringVar(&f.listenAddress, "listen", ":8090", "address to serve the submit API and /metrics on")
+		f.flagset.IntVar(&f.poolSize, "pool-size", 4, "number of node connections shared by concurrent submissions")
+		var networkMagic uint
+		f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
+		parseFlags()
+		f.networkMagic = uint32(networkMagic)
+		err = submitAPI(f)
 	case "key-pair":
 		f.flagset.StringVar(&f.seed, "seed", "", "random seed for key pair")
 		var networkMagic uint
@@ -209,19 +404,7 @@ func sendTx(f *cliFlags) error {
 	if err != nil {
 		return err
 	}
-	addr, err := ledger.NewAddress(sourceAddr.String())
-	if err != nil {
-		return fmt.Errorf("failed to create address: %w", err)
-	}
 
-	errorChan := make(chan error)
-	go func() {
-		for {
-			err := <-errorChan
-			fmt.Printf("ERROR(async): %s\n", err)
-			// os.Exit(1)
-		}
-	}()
 	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	}))
@@ -229,43 +412,247 @@ func sendTx(f *cliFlags) error {
 	if !ok {
 		return fmt.Errorf("unknown network magic: %d", f.networkMagic)
 	}
-	var client net.Conn
-	if f.clientSocket != "" {
-		client, err = net.Dial("unix", f.clientSocket)
-	} else {
-		client, err = net.Dial("tcp", f.clientAddress)
+	var sub *submitter.Submitter
+	o, err := supervisor.Connect(context.Background(), func(context.Context) (*ouroboros.Connection, error) {
+		client, err := dialNodeToClient(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client connection: %w", err)
+		}
+		o, err := ouroboros.NewConnection(
+			ouroboros.WithConnection(client),
+			ouroboros.WithLogger(log),
+			ouroboros.WithNetwork(network),
+			ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig()),
+			ouroboros.WithLocalTxMonitorConfig(localtxmonitor.NewConfig()),
+			ouroboros.WithChainSyncConfig(submitterChainSyncConfig(&sub)),
+			ouroboros.WithKeepAlive(true),
+		)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to network: %w", err)
+		}
+		return o, nil
+	}, supervisor.WithLogger(log), supervisor.WithMaxAttempts(5))
+	if err != nil {
+		return err
 	}
+	defer o.Close()
+	// a dead connection would leave the queries of the payment blocked forever, closing it ends them
+	paid := make(chan error, 1)
+	go func() {
+		paid <- payOver(f, o, &sub, sourceAddr, priv, log)
+	}()
+	select {
+	case err := <-paid:
+		return err
+	case err, ok := <-o.ErrorChan():
+		if !ok {
+			return <-paid
+		}
+		o.Close()
+		// the payment releases -pending-file once its queries fail
+		<-paid
+		return fmt.Errorf("connection failed: %w", err)
+	}
+}
+
+// payOver builds, submits and with -wait follows the payment of sendTx over o, feeding the blocks of its
+// chain-sync to *sub.
+func payOver(f *cliFlags, o *ouroboros.Connection, sub **submitter.Submitter, sourceAddr address.Address, priv ed25519.PrivateKey, log *slog.Logger) error {
+	protocol, err := protocolParams(f, o)
 	if err != nil {
-		return fmt.Errorf("failed to create client connection: %w", err)
+		return err
 	}
-	o, err := ouroboros.NewConnection(
-		ouroboros.WithConnection(client),
-		ouroboros.WithErrorChan(errorChan),
-		ouroboros.WithLogger(log),
-		ouroboros.WithNetwork(network),
-		ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig()),
-		ouroboros.WithKeepAlive(true),
+	txBuilder := tx.NewTxBuilder(protocol)
+
+	utxos, err := sourceUTxOs(f, o, sourceAddr)
+	if err != nil {
+		return err
+	}
+	tip, err := o.ChainSync().Client.GetCurrentTip()
+	if err != nil {
+		return fmt.Errorf("failed to get current tip for TTL: %w", err)
+	}
+	overlay, unlockPending, err := loadPending(f, tip.Point.Slot)
+	if err != nil {
+		return err
+	}
+	defer unlockPending()
+	if overlay != nil {
+		utxos = overlay.Apply(utxos, sourceAddr)
+	}
+	ttl := tip.Point.Slot + 300
+	if f.validUntil != "" {
+		if ttl, err = defaultTTL(f, o, log); err != nil {
+			return err
+		}
+	}
+	if err := buildPayment(f, txBuilder, utxos, sourceAddr, uint32(ttl)); err != nil {
+		return err
+	}
+	txFinal, err := txBuilder.Sign([]ed25519.PrivateKey{priv})
+	if err != nil {
+		return fmt.Errorf("failed to build transaction: %w", err)
+	}
+	txBz, err := txFinal.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction bytes: %w", err)
+	}
+	view, err := tx.NewView(txBz)
+	if err != nil {
+		return err
+	}
+	fmt.Println("txFinal:")
+	if err := printJSON(os.Stdout, view); err != nil {
+		return err
+	}
+
+	*sub = submitter.New(nodeTxBackend{conn: o},
+		submitter.WithConfirmations(f.confirmations),
+		submitter.WithCheckInterval(f.interval),
+		submitter.WithCallback(func(s submitter.Status) {
+			fmt.Printf("tx %s: %s, depth = %d, submissions = %d\n", s.TxHash, s.State, s.Depth, s.Submissions)
+			if s.State == submitter.StateExpired {
+				if err := removePending(f, s.TxHash); err != nil {
+					fmt.Printf("ERROR: failed to remove expired tx from pending txs: %s\n", err)
+				}
+			}
+		}),
 	)
+	tracked, err := (*sub).Submit(&txFinal)
 	if err != nil {
-		return fmt.Errorf("failed to connect to network: %w", err)
+		return err
+	}
+	if err := savePending(f, overlay, &txFinal); err != nil {
+		return err
 	}
-	pparams, err := o.LocalStateQuery().Client.GetCurrentProtocolParams()
+	// other commands may chain on the transaction while this one waits for it
+	if err := unlockPending(); err != nil {
+		return fmt.Errorf("failed to unlock pending txs: %w", err)
+	}
+	if !f.wait {
+		return nil
+	}
+	return waitForTx(o, *sub, tracked, tip.Point)
+}
+
+// sourceUTxOs returns the UTxOs at sourceAddr from -kupo-url, -index-file or -utxo-file, or else from the
+// node's local state, in which case o must be connected.
+func sourceUTxOs(f *cliFlags, o *ouroboros.Connection, sourceAddr address.Address) ([]tx.TxInput, error) {
+	var utxos []tx.TxInput
+	var err error
+	switch {
+	case f.kupoURL != "":
+		utxos, err = kupo.NewClient(f.kupoURL).UTxOsByAddress(context.Background(), sourceAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get utxo from kupo: %w", err)
+		}
+	case f.indexFile != "":
+		ix, err := indexer.LoadFile(f.indexFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load utxo index: %w", err)
+		}
+		utxos, err = ix.UTxOsByAddress(context.Background(), sourceAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get utxo from index: %w", err)
+		}
+	case f.utxoFile != "":
+		p, err := utxofile.NewProvider(f.utxoFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load utxo file: %w", err)
+		}
+		utxos, err = p.UTxOsByAddress(context.Background(), sourceAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get utxo from file: %w", err)
+		}
+	case o == nil:
+		return nil, fmt.Errorf("no utxo source, connect to a node or set -utxo-file")
+	default:
+		addr, err := ledger.NewAddress(sourceAddr.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create address: %w", err)
+		}
+		utxoRes, err := o.LocalStateQuery().Client.GetUTxOByAddress([]ledger.Address{addr})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get utxo: %w", err)
+		}
+		utxos = lsq.UTxOs(utxoRes.Results)
+	}
+	return utxos, nil
+}
+
+// pendingLockTimeout is how long a command waits for another one to release -pending-file.
+const pendingLockTimeout = time.Minute
+
+// loadPending locks and reads -pending-file, dropping the transactions expired at slot. The file stays
+// locked until unlock is called, which may be called more than once. The overlay is nil when the flag is
+// unset.
+func loadPending(f *cliFlags, slot uint64) (overlay *pending.Overlay, unlock func() error, err error) {
+	if f.pendingFile == "" {
+		return nil, func() error { return nil }, nil
+	}
+	unlockFile, err := pending.Lock(f.pendingFile, pendingLockTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to load protocol parameters: %w", err)
+		return nil, nil, err
 	}
-	txBuilder := tx.NewTxBuilder(pparams.Utxorpc())
+	unlock = sync.OnceValue(unlockFile)
+	if overlay, err = pending.LoadFile(f.pendingFile); err != nil {
+		unlock()
+		return nil, nil, fmt.Errorf("failed to load pending txs: %w", err)
+	}
+	overlay.Expire(slot)
+	return overlay, unlock, nil
+}
 
-	utxoRes, err := o.LocalStateQuery().Client.GetUTxOByAddress([]ledger.Address{addr})
+// savePending adds t, which was just submitted, to overlay and writes it back to -pending-file.
+func savePending(f *cliFlags, overlay *pending.Overlay, t *tx.Tx) error {
+	if overlay == nil {
+		return nil
+	}
+	if err := overlay.Add(t); err != nil {
+		return err
+	}
+	if err := overlay.SaveFile(f.pendingFile); err != nil {
+		return fmt.Errorf("failed to save pending txs: %w", err)
+	}
+	return nil
+}
+
+// removePending drops a transaction which will never be on chain from -pending-file, so that later
+// transactions spend its inputs again rather than its outputs.
+func removePending(f *cliFlags, txHash string) error {
+	if f.pendingFile == "" {
+		return nil
+	}
+	unlock, err := pending.Lock(f.pendingFile, pendingLockTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to get utxo: %w", err)
+		return err
 	}
+	defer unlock()
+	overlay, err := pending.LoadFile(f.pendingFile)
+	if err != nil {
+		return fmt.Errorf("failed to load pending txs: %w", err)
+	}
+	overlay.Remove(txHash)
+	if err := overlay.SaveFile(f.pendingFile); err != nil {
+		return fmt.Errorf("failed to save pending txs: %w", err)
+	}
+	return nil
+}
 
+// buildPayment builds a payment of -amount to -receiver-address with an optional -memo and metadata,
+// spending utxos without native assets and returning the change to sourceAddr. The fee is calculated
+// unless -fee is set.
+func buildPayment(f *cliFlags, txBuilder *tx.TxBuilder, utxos []tx.TxInput, sourceAddr address.Address, ttl uint32) error {
 	estimatedFee := uint64(167217)
 	if f.fee > 0 {
 		estimatedFee = f.fee
 	}
 	minRequired := f.sendAmount + estimatedFee // estimate fee
-	txIns, err := maxNumberUTxOs(utxoRes, uint64(minRequired))
+	for _, txIn := range utxos {
+		fmt.Fprintf(os.Stderr, "txId: %x, txOut: %d\n", txIn.TxHash, txIn.Amount)
+	}
+	txIns, err := tx.SelectInputs(utxos, uint64(minRequired))
 	if err != nil {
 		return err
 	}
@@ -276,12 +663,7 @@ func sendTx(f *cliFlags) error {
 		return fmt.Errorf("failed to create address: %w", err)
 	}
 	txBuilder.AddOutputs(tx.NewTxOutput(toAddr, f.sendAmount))
-
-	tip, err := o.ChainSync().Client.GetCurrentTip()
-	if err != nil {
-		return fmt.Errorf("failed to get current tip for TTL: %w", err)
-	}
-	txBuilder.SetTTL(uint32(tip.Point.Slot + 300))
+	txBuilder.SetTTL(ttl)
 
 	if err := txBuilder.AddChangeIfNeeded(sourceAddr); err != nil {
 		return fmt.Errorf("failed to add change: %w", err)
@@ -289,110 +671,218 @@ func sendTx(f *cliFlags) error {
 
 	// set memo if present
 	if f.memo != "" {
-		if err := txBuilder.SetMemo(f.memo); err != nil {
+		if err := txBuilder.SetMemo(f.memo, tx.WithPassphrase(f.memoPassphrase)); err != nil {
 			return fmt.Errorf("failed to set memo: %w", err)
 		}
 	}
+	if f.metadataJSONFile != "" {
+		schema := metadata.NoSchema
+		if f.metadataDetailed {
+			schema = metadata.DetailedSchema
+		}
+		md, err := metadata.ReadFile(f.metadataJSONFile, schema)
+		if err != nil {
+			return err
+		}
+		if err := txBuilder.AddMetadata(md); err != nil {
+			return fmt.Errorf("failed to add metadata: %w", err)
+		}
+	}
 	if err = txBuilder.CalculateFee(); err != nil {
 		return fmt.Errorf("failed to calculate fee: %w", err)
 	}
-	txFinal, err := txBuilder.Sign([]ed25519.PrivateKey{priv})
-	if err != nil {
-		return fmt.Errorf("failed to build transaction: %w", err)
-	}
-	txBz, err := txFinal.Bytes()
-	if err != nil {
-		return fmt.Errorf("failed to get transaction bytes: %w", err)
-	}
-	jsonBz, err := json.MarshalIndent(txFinal, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to json marshal transaction: %w", err)
-	}
-	fmt.Printf("txFinal:\n%s\n", jsonBz)
-
-	era, err := o.LocalStateQuery().Client.GetCurrentEra()
-	if err != nil {
-		return fmt.Errorf("failed to get current era: %w", err)
-	}
-	err = o.LocalTxSubmission().Client.SubmitTx(uint16(era), txBz)
-	if err != nil {
-		return fmt.Errorf("failed to submit transaction: %w", err)
-	}
-
 	return nil
 }
 
 func runNode(f *cliFlags) error {
-	if f.filterAddresses != "" {
-		filterAddresses = strings.Split(f.filterAddresses, ",")
+	if err := openEventSink(f); err != nil {
+		return err
 	}
-	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	if eventSink != nil {
+		defer eventSink.Close()
+	}
+	log := slog.New(slog.NewTextHandler(textOut, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	}))
-	// Create error channel
-	networkError := make(chan error)
-	// start error handler
-	go func() {
-		for {
-			err := <-networkError
-			log.Error("network error", "error", err)
-			time.Sleep(1 * time.Second)
+	if f.filterAddresses != "" {
+		opts := []observer.Option{observer.WithConfirmations(f.confirmations), observer.WithLogger(log)}
+		if f.memoPassphrase != "" {
+			opts = append(opts, observer.WithMemoPassphrase(f.memoPassphrase))
 		}
-	}()
+		depositObserver = observer.New(strings.Split(f.filterAddresses, ","), handleDepositEvent, opts...)
+	}
 	network, ok := ouroboros.NetworkByNetworkMagic(f.networkMagic)
 	if !ok {
 		return fmt.Errorf("unknown network magic: %d", f.networkMagic)
 	}
-	peer := network.BootstrapPeers[0]
-	client, err := createClientConnection(fmt.Sprintf("%s:%d", peer.Address, peer.Port), false)
-	if err != nil {
-		return fmt.Errorf("failed to create client connection: %w", err)
-	}
-	o, err := ouroboros.NewConnection(
-		ouroboros.WithPeerSharing(true),
-		ouroboros.WithConnection(client),
-		ouroboros.WithNetwork(network),
-		ouroboros.WithLogger(log),
-		ouroboros.WithErrorChan(networkError),
-		ouroboros.WithNodeToNode(true),
-		ouroboros.WithKeepAlive(true),
-		ouroboros.WithChainSyncConfig(buildChainSyncConfig()),
-		ouroboros.WithBlockFetchConfig(buildBlockFetchConfig()),
-		// ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig()),
-		// ouroboros.WithTxSubmissionConfig(buildTxSubmissionConfig()),
-	)
-	ouroborosConnection = o
+
+	var err error
+	switch {
+	case f.checkpointFile != "":
+		checkpointStore, err = checkpoint.NewFileStore(f.checkpointFile, f.checkpointK)
+	case f.checkpointDB != "":
+		checkpointStore, err = checkpoint.NewSQLiteStore(f.checkpointDB, f.checkpointK)
+	default:
+		// without persistence checkpoints still let a reconnection resume where it left off
+		checkpointStore, err = checkpoint.NewMemoryStore(f.checkpointK)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create connection: %w", err)
+		return fmt.Errorf("failed to open checkpoint store: %w", err)
+	}
+	defer checkpointStore.Close()
+	if depositObserver != nil {
+		// emitted deposits are persisted next to the checkpoints so a rollback after a restart retracts them
+		if path := cmp.Or(f.checkpointFile, f.checkpointDB); path != "" {
+			observerFile = path + ".observer.json"
+			if err := depositObserver.LoadFile(observerFile); err != nil {
+				return fmt.Errorf("failed to load observer state: %w", err)
+			}
+		}
 	}
 
-	var point common.Point
+	if f.filterExpr != "" {
+		var opts []filter.Option
+		if f.memoPassphrase != "" {
+			opts = append(opts, filter.WithMemoPassphrase(f.memoPassphrase))
+		}
+		if txFilter, err = filter.Parse(f.filterExpr, opts...); err != nil {
+			return fmt.Errorf("failed to parse filter: %w", err)
+		}
+	}
+	if f.indexFile != "" {
+		if utxoIndex, err = loadIndex(f); err != nil {
+			return err
+		}
+	} else if txFilter != nil {
+		// track watched outputs in memory so spends of those created since start are seen
+		utxoIndex = indexer.New(append(txFilter.IndexerOptions(), indexer.WithRetention(f.checkpointK))...)
+	}
+	if utxoIndex != nil && len(utxoIndex.Points()) == 0 {
+		if err := seedIndex(f, log); err != nil {
+			return err
+		}
+	}
+
+	var startPoints []common.Point
 	if f.startHash != "" && f.startSlot != 0 {
 		h, err := hex.DecodeString(f.startHash)
 		if err != nil {
 			return fmt.Errorf("failed to decode start hash: %w", err)
 		}
-		point = common.Point{
+		startPoints = []common.Point{{
 			Slot: f.startSlot,
 			Hash: h,
+		}}
+	}
+
+	if f.clientSocket == "" && f.clientAddress == "" {
+		return followPeers(f, network, log, startPoints)
+	}
+
+	dial := func(ctx context.Context) (*ouroboros.Connection, error) {
+		client, err := dialNodeToClient(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client connection: %w", err)
 		}
-	} else {
-		tip, err := o.ChainSync().Client.GetCurrentTip()
+		o, err := ouroboros.NewConnection(
+			ouroboros.WithConnection(client),
+			ouroboros.WithNetwork(network),
+			ouroboros.WithLogger(log),
+			ouroboros.WithKeepAlive(true),
+			ouroboros.WithChainSyncConfig(buildChainSyncConfig()),
+		)
 		if err != nil {
-			return fmt.Errorf("failed to get current tip: %w", err)
+			client.Close()
+			return nil, fmt.Errorf("failed to create connection: %w", err)
 		}
-		point = tip.Point
-		fmt.Printf("queried tip: slot = %d, hash = %x\n", point.Slot, point.Hash)
+		return o, nil
 	}
+	started := false
+	start := func(ctx context.Context, o *ouroboros.Connection) error {
+		ouroborosConnection = o
+		points := startPoints
+		if started || len(points) == 0 {
+			var err error
+			if points, err = intersectPoints(startPoints); err != nil {
+				return err
+			}
+		}
+		if len(points) == 0 {
+			tip, err := o.ChainSync().Client.GetCurrentTip()
+			if err != nil {
+				return fmt.Errorf("failed to get current tip: %w", err)
+			}
+			// a reconnection before the first checkpoint resumes from here
+			startPoints = []common.Point{tip.Point}
+			points = startPoints
+			fmt.Fprintf(textOut, "queried tip: slot = %d, hash = %x\n", tip.Point.Slot, tip.Point.Hash)
+		}
+		if err := o.ChainSync().Client.Sync(points); err != nil {
+			return fmt.Errorf("failed to start chain-sync: %w", err)
+		}
+		started = true
+		return nil
+	}
+	sup := supervisor.New(dial, start, supervisor.WithLogger(log))
+	serveHealth(f, sup, log)
+	return sup.Run(context.Background())
+}
 
-	if err := o.ChainSync().Client.Sync([]common.Point{point}); err != nil {
-		fmt.Printf("ERROR: failed to start chain-sync: %s\n", err)
-		os.Exit(1)
+// followPeers follows the chain over node-to-node from several peers, selecting the longest chain.
+func followPeers(f *cliFlags, network ouroboros.Network, log *slog.Logger, startPoints []common.Point) error {
+	var peers []string
+	if f.peerAddress != "" {
+		peers = strings.Split(f.peerAddress, ",")
 	}
+	fol := follower.New(network, chainHandler{},
+		follower.WithLogger(log),
+		follower.WithMaxPeers(max(f.maxPeers, len(peers))),
+		follower.WithSecurityParam(f.checkpointK),
+		follower.WithDialer(func(address string) (net.Conn, error) {
+			return createClientConnection(address, f.useTls)
+		}),
+		follower.WithIntersectPoints(func() ([]common.Point, error) {
+			if len(startPoints) > 0 {
+				return startPoints, nil
+			}
+			return intersectPoints(nil)
+		}),
+	)
+	serveHealth(f, fol, log)
+	return fol.Run(context.Background(), peers...)
+}
 
-	// o.TxSubmission().Client.Init()
+func serveHealth(f *cliFlags, health http.Handler, log *slog.Logger) {
+	if f.healthListen == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health)
+	go func() {
+		if err := http.ListenAndServe(f.healthListen, mux); err != nil {
+			log.Error("health endpoint failed", "error", err)
+		}
+	}()
+}
 
-	select {}
+// intersectPoints returns the points chain-sync resumes from: the checkpoints, then the points of the
+// utxo index, then fallback.
+func intersectPoints(fallback []common.Point) ([]common.Point, error) {
+	points, err := checkpoint.IntersectPoints(checkpointStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoints: %w", err)
+	}
+	if len(points) > 0 {
+		fmt.Fprintf(textOut, "resuming from checkpoint: slot = %d, hash = %x\n", points[0].Slot, points[0].Hash)
+		return points, nil
+	}
+	if utxoIndex != nil {
+		if points = checkpoint.SparsePoints(utxoIndex.Points()); len(points) > 0 {
+			fmt.Fprintf(textOut, "resuming from utxo index: slot = %d, hash = %x\n", points[0].Slot, points[0].Hash)
+			return points, nil
+		}
+	}
+	return fallback, nil
 }
 
 func buildChainSyncConfig() chainsync.Config {
@@ -409,7 +899,205 @@ func buildBlockFetchConfig() blockfetch.Config {
 	)
 }
 
-var filterAddresses []string
+var (
+	depositObserver *observer.Observer
+	checkpointStore checkpoint.Store
+	utxoIndex       *indexer.Indexer
+	utxoIndexFile   string
+	observerFile    string
+	eventSink       sink.Sink
+	txFilter        *filter.Filter
+	// textOut receives the human readable output, moved off stdout when it carries JSON events
+	textOut io.Writer = os.Stdout
+)
+
+func openEventSink(f *cliFlags) error {
+	var sinks sink.Multi
+	switch f.output {
+	case "text":
+	case "json":
+		sinks = append(sinks, sink.NewJSONLines(os.Stdout))
+		textOut = os.Stderr
+	default:
+		return fmt.Errorf("unknown output format %q", f.output)
+	}
+	if f.outputFile != "" {
+		file, err := sink.NewFile(f.outputFile, sink.WithMaxSize(f.outputFileSize<<20))
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, file)
+	}
+	if f.webhookURL != "" {
+		var opts []sink.WebhookOption
+		if f.webhookSecret != "" {
+			opts = append(opts, sink.WithSecret([]byte(f.webhookSecret)))
+		}
+		sinks = append(sinks, sink.NewWebhook(f.webhookURL, opts...))
+	}
+	if f.natsURL != "" {
+		pub, err := sink.DialNATS(context.Background(), f.natsURL)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink.NewQueue(pub, f.natsSubject))
+	}
+	if len(sinks) > 0 {
+		eventSink = sinks
+	}
+	return nil
+}
+
+func handleDepositEvent(ev observer.Event) error {
+	if eventSink != nil {
+		return eventSink.Emit(context.Background(), sink.OutputEvent(ev))
+	}
+	return printDepositEvent(ev)
+}
+
+func loadIndex(f *cliFlags) (*indexer.Indexer, error) {
+	var opts []indexer.Option
+	if f.indexAddresses != "" {
+		for _, s := range strings.Split(f.indexAddresses, ",") {
+			addr, err := address.NewAddressFromBech32(s)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode index address %s: %w", s, err)
+			}
+			opts = append(opts, indexer.WithAddresses(addr))
+		}
+	}
+	decodeHashes := func(list string) ([][]byte, error) {
+		if list == "" {
+			return nil, nil
+		}
+		var hashes [][]byte
+		for _, s := range strings.Split(list, ",") {
+			h, err := hex.DecodeString(s)
+			if err != nil || len(h) != 28 {
+				return nil, fmt.Errorf("invalid credential hash %q", s)
+			}
+			hashes = append(hashes, h)
+		}
+		return hashes, nil
+	}
+	payment, err := decodeHashes(f.indexPayment)
+	if err != nil {
+		return nil, err
+	}
+	stake, err := decodeHashes(f.indexStake)
+	if err != nil {
+		return nil, err
+	}
+	if txFilter != nil {
+		opts = append(opts, txFilter.IndexerOptions()...)
+	}
+	opts = append(opts,
+		indexer.WithPaymentCredentials(payment...),
+		indexer.WithStakeCredentials(stake...),
+		indexer.WithRetention(f.checkpointK),
+	)
+	ix, err := indexer.LoadFile(f.indexFile, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load utxo index: %w", err)
+	}
+	utxoIndexFile = f.indexFile
+	return ix, nil
+}
+
+// seedIndex fills the empty utxo index with the UTxOs of its addresses in the node's ledger state, so that
+// outputs created before chain-sync starts are indexed too. Without checkpoints chain-sync then starts from
+// the point of that ledger state.
+func seedIndex(f *cliFlags, log *slog.Logger) error {
+	addrs, complete := utxoIndex.Addresses()
+	if f.clientSocket == "" && f.clientAddress == "" {
+		fmt.Fprintln(textOut, "warning: the utxo index starts empty, outputs created before chain-sync starts are not indexed without -socket or -address")
+		return nil
+	}
+	if !complete {
+		fmt.Fprintln(textOut, "warning: outputs of indexed credentials created before chain-sync starts are not indexed, only those of addresses")
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	ledgerAddrs := make([]ledger.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		ledgerAddr, err := ledger.NewAddress(addr.String())
+		if err != nil {
+			return fmt.Errorf("failed to convert index address: %w", err)
+		}
+		ledgerAddrs = append(ledgerAddrs, ledgerAddr)
+	}
+	conn, err := connectNodeToClient(f, log, ouroboros.WithDelayProtocolStart(true))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	c := lsq.New(conn, log)
+	defer c.Release()
+	// both queries run against the same acquired ledger state
+	tip, err := c.Tip()
+	if err != nil {
+		return err
+	}
+	utxos, err := c.UTxOsByAddress(ledgerAddrs...)
+	if err != nil {
+		return err
+	}
+	if err := utxoIndex.Seed(tip.Point, utxos); err != nil {
+		return fmt.Errorf("failed to seed utxo index: %w", err)
+	}
+	fmt.Fprintf(textOut, "seeded utxo index with %d utxos at slot %d\n", len(utxos), tip.Point.Slot)
+	if utxoIndexFile != "" {
+		if err := utxoIndex.SaveFile(utxoIndexFile); err != nil {
+			return fmt.Errorf("failed to save utxo index: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveObserver persists the deposit observer state when checkpoints are persisted.
+func saveObserver() error {
+	if observerFile == "" {
+		return nil
+	}
+	if err := depositObserver.SaveFile(observerFile); err != nil {
+		return fmt.Errorf("failed to save observer state: %w", err)
+	}
+	return nil
+}
+
+func printDepositEvent(ev observer.Event) error {
+	d := ev.Deposit
+	fmt.Fprintf(textOut, "%s:\n  tx-hash: %s#%d\n  address: %s\n  amount: %d\n  slot: %d\n  block: %s\n",
+		ev.Type, d.TxHash, d.Index, d.Address, d.Amount, d.Slot, d.BlockHash,
+	)
+	for policyId, assets := range d.Assets {
+		for name, qty := range assets {
+			fmt.Fprintf(textOut, "  asset: %s.%s %d\n", policyId, name, qty)
+		}
+	}
+	if d.Memo != "" {
+		fmt.Fprintf(textOut, "  memo: %s\n", d.Memo)
+	}
+	return nil
+}
+
+func handleMatch(block ledger.Block, m filter.Match) error {
+	if eventSink != nil {
+		return eventSink.Emit(context.Background(), sink.MatchEvent(block, m))
+	}
+	fmt.Fprintf(textOut, "match:\n  tx-hash: %s\n  slot: %d\n", m.Tx.Hash(), block.SlotNumber())
+	for _, utxo := range m.Deposits {
+		fmt.Fprintf(textOut, "  deposit: %s %s %d\n", utxo.Id, utxo.Output.Address(), utxo.Output.Amount())
+	}
+	for _, s := range m.Withdrawals {
+		fmt.Fprintf(textOut, "  withdrawal: %x#%d %s %d\n", s.Input.TxHash, s.Input.Index, s.Address, s.Input.Amount)
+	}
+	if m.Memo != "" {
+		fmt.Fprintf(textOut, "  memo: %s\n", m.Memo)
+	}
+	return nil
+}
 
 func chainSyncRollForwardHandler(
 	ctx chainsync.CallbackContext,
@@ -424,18 +1112,23 @@ func chainSyncRollForwardHandler(
 	case ledger.BlockHeader:
 		blockSlot := v.SlotNumber()
 		blockHash, _ := hex.DecodeString(v.Hash())
-		fmt.Printf("block header, fetching block (%d, %x)\n", blockSlot, blockHash)
+		fmt.Fprintf(textOut, "block header, fetching block (%d, %x)\n", blockSlot, blockHash)
 		var err error
 		block, err = ouroborosConnection.BlockFetch().Client.GetBlock(common.NewPoint(blockSlot, blockHash))
 		if err != nil {
 			return err
 		}
 	}
+	return processBlock(blockType, block)
+}
+
+// processBlock hands a block at the tip of the followed chain to the observer, filter, index and checkpoints.
+func processBlock(blockType uint, block ledger.Block) error {
 	// Display block info
 	switch blockType {
 	case ledger.BlockTypeByronEbb:
 		byronEbbBlock := block.(*ledger.ByronEpochBoundaryBlock)
-		fmt.Printf(
+		fmt.Fprintf(textOut,
 			"era = Byron (EBB), epoch = %d, slot = %d, id = %s\n",
 			byronEbbBlock.BlockHeader.ConsensusData.Epoch,
 			byronEbbBlock.SlotNumber(),
@@ -443,7 +1136,7 @@ func chainSyncRollForwardHandler(
 		)
 	case ledger.BlockTypeByronMain:
 		byronBlock := block.(*ledger.ByronMainBlock)
-		fmt.Printf(
+		fmt.Fprintf(textOut,
 			"era = Byron, epoch = %d, slot = %d, id = %s\n",
 			byronBlock.BlockHeader.ConsensusData.SlotId.Epoch,
 			byronBlock.SlotNumber(),
@@ -453,7 +1146,7 @@ func chainSyncRollForwardHandler(
 		if block == nil {
 			return fmt.Errorf("block is nil")
 		}
-		fmt.Printf(
+		fmt.Fprintf(textOut,
 			"era = %s, slot = %d, block_no = %d, hash = %s, txs=%d\n",
 			block.Era().Name,
 			block.SlotNumber(),
@@ -461,29 +1154,67 @@ func chainSyncRollForwardHandler(
 			block.Hash(),
 			len(block.Transactions()),
 		)
-		if len(filterAddresses) > 0 {
-			for _, blockTx := range block.Transactions() {
-				for _, txOut := range blockTx.Outputs() {
-					for _, addr := range filterAddresses {
-						if addr == txOut.Address().String() {
-							fmt.Printf("filter tx:\n  tx-hash: %s\n  address: %s\n  amount: %d\n",
-								blockTx.Hash(),
-								txOut.Address(),
-								txOut.Amount(),
-							)
-							memo, err := tx.DecodeMemo(blockTx)
-							if err != nil {
-								fmt.Printf("ERROR: failed to decode memo: %s\n", err)
-							} else if memo != "" {
-								fmt.Printf("  memo: %s\n", memo)
-							}
-						}
-					}
+		if eventSink != nil {
+			for _, ev := range sink.BlockEvents(block) {
+				if err := eventSink.Emit(context.Background(), ev); err != nil {
+					return err
+				}
+			}
+		}
+		if depositObserver != nil {
+			if err := depositObserver.RollForward(block); err != nil {
+				return err
+			}
+		}
+		if txFilter != nil {
+			// match before indexing the block, so its inputs still resolve against the index
+			var utxos provider.UTxOProvider
+			if utxoIndex != nil {
+				utxos = utxoIndex
+			}
+			matches, err := txFilter.MatchBlock(context.Background(), block, utxos)
+			if err != nil {
+				return err
+			}
+			for _, m := range matches {
+				if err := handleMatch(block, m); err != nil {
+					return err
+				}
+			}
+		}
+		if utxoIndex != nil {
+			if err := utxoIndex.RollForward(block); err != nil {
+				return fmt.Errorf("failed to index block: %w", err)
+			}
+			if utxoIndexFile != "" {
+				if err := utxoIndex.SaveFile(utxoIndexFile); err != nil {
+					return fmt.Errorf("failed to save utxo index: %w", err)
 				}
 			}
 		}
 	}
 
+	if checkpointStore != nil {
+		blockHash, err := hex.DecodeString(block.Hash())
+		if err != nil {
+			return err
+		}
+		point := common.NewPoint(block.SlotNumber(), blockHash)
+		if depositObserver != nil {
+			// resume from the last block whose deposits were all emitted so pending ones are seen again
+			var ok bool
+			if point, ok = depositObserver.LastConfirmed(); !ok {
+				return nil
+			}
+			if err := saveObserver(); err != nil {
+				return err
+			}
+		}
+		if err := checkpointStore.Save(point); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -494,11 +1225,11 @@ func blockFetchBlockHandler(
 ) error {
 	switch block := blockData.(type) {
 	case *ledger.ByronEpochBoundaryBlock:
-		fmt.Printf("era = Byron (EBB), epoch = %d, slot = %d, id = %s\n", block.BlockHeader.ConsensusData.Epoch, block.SlotNumber(), block.Hash())
+		fmt.Fprintf(textOut, "era = Byron (EBB), epoch = %d, slot = %d, id = %s\n", block.BlockHeader.ConsensusData.Epoch, block.SlotNumber(), block.Hash())
 	case *ledger.ByronMainBlock:
-		fmt.Printf("era = Byron, epoch = %d, slot = %d, id = %s\n", block.BlockHeader.ConsensusData.SlotId.Epoch, block.SlotNumber(), block.Hash())
+		fmt.Fprintf(textOut, "era = Byron, epoch = %d, slot = %d, id = %s\n", block.BlockHeader.ConsensusData.SlotId.Epoch, block.SlotNumber(), block.Hash())
 	case ledger.Block:
-		fmt.Printf("era = %s, slot = %d, block_no = %d, id = %s\n", block.Era().Name, block.SlotNumber(), block.BlockNumber(), block.Hash())
+		fmt.Fprintf(textOut, "era = %s, slot = %d, block_no = %d, id = %s\n", block.Era().Name, block.SlotNumber(), block.BlockNumber(), block.Hash())
 	}
 	return nil
 }
@@ -509,7 +1240,7 @@ func chainSyncRollForwardRawHandler(
 	rawBlockData []byte,
 	tip chainsync.Tip,
 ) error {
-	fmt.Printf("roll forward raw: tip = (%d, %x) bytes = %d\n", tip.Point.Slot, tip.Point.Hash, len(rawBlockData))
+	fmt.Fprintf(textOut, "roll forward raw: tip = (%d, %x) bytes = %d\n", tip.Point.Slot, tip.Point.Hash, len(rawBlockData))
 	return nil
 }
 
@@ -518,52 +1249,67 @@ func chainSyncRollBackwardHandler(
 	point common.Point,
 	tip chainsync.Tip,
 ) error {
-	fmt.Printf("roll backward: point = (%d, %x), tip = (%d, %x)\n",
+	return processRollback(point, tip)
+}
+
+func processRollback(point common.Point, tip chainsync.Tip) error {
+	fmt.Fprintf(textOut, "roll backward: point = (%d, %x), tip = (%d, %x)\n",
 		point.Slot, point.Hash,
 		tip.Point.Slot, tip.Point.Hash,
 	)
+	if eventSink != nil {
+		if err := eventSink.Emit(context.Background(), sink.RollbackEvent(point, tip.Point)); err != nil {
+			return err
+		}
+	}
+	if depositObserver != nil {
+		if err := depositObserver.RollBackward(point); err != nil {
+			return err
+		}
+		if err := saveObserver(); err != nil {
+			return err
+		}
+	}
+	if utxoIndex != nil {
+		if err := utxoIndex.RollBackward(point); err != nil {
+			return err
+		}
+		if utxoIndexFile != "" {
+			if err := utxoIndex.SaveFile(utxoIndexFile); err != nil {
+				return fmt.Errorf("failed to save utxo index: %w", err)
+			}
+		}
+	}
+	if checkpointStore != nil {
+		if err := checkpointStore.Rollback(point); err != nil {
+			return fmt.Errorf("failed to roll back checkpoints: %w", err)
+		}
+	}
 	return nil
 }
 
-func createClientConnection(address string, useTls bool) (net.Conn, error) {
-	if useTls {
-		return tls.Dial("tcp", address, nil)
-	} else {
-		return net.Dial("tcp", address)
-	}
+// chainHandler delivers the chain selected by a multi-peer follower to the same processing as chain-sync.
+type chainHandler struct{}
+
+func (chainHandler) RollForward(block ledger.Block) error {
+	return processBlock(uint(block.Type()), block)
 }
 
-func maxNumberUTxOs(utxoRes *localstatequery.UTxOByAddressResult, targetAmount uint64) ([]tx.TxInput, error) {
-	var txIns []tx.TxInput
-	for txId, txOut := range utxoRes.Results {
-		txIn := tx.NewTxInput(txId.Hash.String(), uint16(txId.Idx), txOut.Amount())
-		txIns = append(txIns, txIn)
-		fmt.Printf("txId: %s, txOut: %d\n", txId.Hash.String(), txOut.Amount())
-	}
-	slices.SortFunc(txIns, func(a, b tx.TxInput) int {
-		switch {
-		case a.Amount < b.Amount:
-			return -1
-		case a.Amount > b.Amount:
-			return 1
-		default:
-			return 0
-		}
-	})
+func (chainHandler) RollBackward(point, tip common.Point) error {
+	return processRollback(point, chainsync.Tip{Point: tip})
+}
 
-	var res []tx.TxInput
-	for _, txIn := range txIns {
-		res = append(res, txIn)
-		if txIn.Amount > targetAmount {
-			targetAmount = 0
-			break
-		}
-		targetAmount -= txIn.Amount
+func dialNodeToClient(f *cliFlags) (net.Conn, error) {
+	if f.clientSocket != "" {
+		return net.Dial("unix", f.clientSocket)
 	}
+	return createClientConnection(f.clientAddress, f.useTls)
+}
 
-	if targetAmount > 0 {
-		return nil, fmt.Errorf("insufficient funds; short by %d", targetAmount)
+func createClientConnection(address string, useTls bool) (net.Conn, error) {
+	if useTls {
+		return tls.Dial("tcp", address, nil)
+	} else {
+		return net.Dial("tcp", address)
 	}
-
-	return res, nil
 }
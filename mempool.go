@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxmonitor"
+	"github.com/kocubinski/gardano/filter"
+	"github.com/kocubinski/gardano/lsq"
+	"github.com/kocubinski/gardano/mempool"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/supervisor"
+)
+
+func mempoolCmd(f *cliFlags) error {
+	if f.clientAddress == "" && f.clientSocket == "" {
+		return fmt.Errorf("client address/socket is not set")
+	}
+	network, ok := ouroboros.NetworkByNetworkMagic(f.networkMagic)
+	if !ok {
+		return fmt.Errorf("unknown network magic: %d", f.networkMagic)
+	}
+	var txFilter *filter.Filter
+	if f.filterExpr != "" {
+		var opts []filter.Option
+		if f.memoPassphrase != "" {
+			opts = append(opts, filter.WithMemoPassphrase(f.memoPassphrase))
+		}
+		var err error
+		if txFilter, err = filter.Parse(f.filterExpr, opts...); err != nil {
+			return fmt.Errorf("failed to parse filter: %w", err)
+		}
+	}
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	dial := func(context.Context) (*ouroboros.Connection, error) {
+		client, err := dialNodeToClient(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client connection: %w", err)
+		}
+		o, err := ouroboros.NewConnection(
+			ouroboros.WithConnection(client),
+			ouroboros.WithLogger(log),
+			ouroboros.WithNetwork(network),
+			ouroboros.WithLocalTxMonitorConfig(localtxmonitor.NewConfig()),
+			ouroboros.WithKeepAlive(true),
+		)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to network: %w", err)
+		}
+		return o, nil
+	}
+	if f.watch {
+		return watchMempool(f, log, dial, txFilter)
+	}
+	o, err := supervisor.Connect(context.Background(), dial, supervisor.WithLogger(log), supervisor.WithMaxAttempts(5))
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+	monitor := mempool.NewMonitor(o.LocalTxMonitor().Client)
+
+	switch {
+	case f.mempoolHas != "":
+		hashes := strings.Split(f.mempoolHas, ",")
+		has, err := monitor.HasTx(hashes...)
+		if err != nil {
+			return err
+		}
+		for _, hash := range hashes {
+			fmt.Printf("%s: %t\n", hash, has[hash])
+		}
+		return nil
+	default:
+		snap, err := monitor.Snapshot()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("capacity = %d, size = %d, txs = %d\n", snap.Capacity, snap.Size, snap.NumTxs)
+		for _, t := range snap.Txs {
+			fmt.Printf("tx-hash = %s, bytes = %d\n", t.Hash, len(t.Cbor))
+		}
+		return nil
+	}
+}
+
+// watchedConn is a node connection with a mempool watch running on it. Its ErrorChan reports the first
+// failure of either, and closing it stops the watch.
+type watchedConn struct {
+	*ouroboros.Connection
+	errs   chan error
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newWatchedConn(ctx context.Context, o *ouroboros.Connection) *watchedConn {
+	c := &watchedConn{Connection: o, errs: make(chan error, 1)}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	go func() {
+		err, ok := <-o.ErrorChan()
+		if !ok || err == nil {
+			err = supervisor.ErrConnectionClosed
+		}
+		c.fail(err)
+	}()
+	return c
+}
+
+func (c *watchedConn) ErrorChan() chan error {
+	return c.errs
+}
+
+// fail reports err unless a failure was reported already, the supervisor only waits for the first one.
+func (c *watchedConn) fail(err error) {
+	select {
+	case c.errs <- err:
+	default:
+	}
+}
+
+func (c *watchedConn) Close() error {
+	c.cancel()
+	return c.Connection.Close()
+}
+
+// watchMempool reports transactions entering the mempool, or those matching txFilter, redialing the node
+// with backoff whenever the connection fails. Transactions still in the mempool after a reconnection are
+// reported again.
+func watchMempool(f *cliFlags, log *slog.Logger, dial func(context.Context) (*ouroboros.Connection, error), txFilter *filter.Filter) error {
+	dialWatched := func(ctx context.Context) (*watchedConn, error) {
+		o, err := dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newWatchedConn(ctx, o), nil
+	}
+	// start returns once the watch runs, so that the supervisor reports the connection as up
+	start := func(_ context.Context, o *watchedConn) error {
+		// inputs of pending transactions are resolved against the ledger state for address terms to see
+		// withdrawals
+		var utxos provider.UTxOProvider
+		var conn *ouroboros.Connection
+		if txFilter != nil {
+			var err error
+			if conn, err = connectNodeToClient(f, log, ouroboros.WithDelayProtocolStart(true)); err != nil {
+				return err
+			}
+			utxos = lsq.New(conn, log).Provider()
+		}
+		go func() {
+			if conn != nil {
+				defer conn.Close()
+			}
+			err := watch(o.ctx, f, o.Connection, txFilter, utxos)
+			if err == nil {
+				err = supervisor.ErrConnectionClosed
+			}
+			o.fail(err)
+		}()
+		return nil
+	}
+	return supervisor.New(dialWatched, start, supervisor.WithLogger(log)).Run(context.Background())
+}
+
+// watch prints the transactions entering the mempool of o, or those matching txFilter, until the connection
+// fails or ctx is done.
+func watch(ctx context.Context, f *cliFlags, o *ouroboros.Connection, txFilter *filter.Filter, utxos provider.UTxOProvider) error {
+	return mempool.NewMonitor(o.LocalTxMonitor().Client).Watch(ctx, f.interval, func(t mempool.Tx) error {
+		if txFilter == nil {
+			fmt.Printf("pending: tx-hash = %s, bytes = %d\n", t.Hash, len(t.Cbor))
+			return nil
+		}
+		if t.Tx == nil {
+			return nil
+		}
+		m, ok, err := txFilter.MatchTx(ctx, t.Tx, utxos)
+		if err != nil || !ok {
+			return err
+		}
+		fmt.Printf("pending: tx-hash = %s\n", t.Hash)
+		for _, utxo := range m.Deposits {
+			fmt.Printf("  deposit: %s %s %d\n", utxo.Id, utxo.Output.Address(), utxo.Output.Amount())
+		}
+		for _, s := range m.Withdrawals {
+			fmt.Printf("  withdrawal: %x#%d %s %d\n", s.Input.TxHash, s.Input.Index, s.Address, s.Input.Amount)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,180 @@
+// Package mempool inspects a node's mempool over the node-to-client LocalTxMonitor mini-protocol.
+package mempool
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// Client is the part of *localtxmonitor.Client used by Monitor.
+type Client interface {
+	Acquire() error
+	Release() error
+	HasTx(txId []byte) (bool, error)
+	NextTx() ([]byte, error)
+	GetSizes() (capacity uint32, size uint32, numTxs uint32, err error)
+}
+
+// Sizes describes a mempool snapshot. Capacity and Size are in bytes.
+type Sizes struct {
+	Capacity uint32
+	Size     uint32
+	NumTxs   uint32
+}
+
+// Tx is a transaction in the mempool.
+type Tx struct {
+	Hash string
+	Cbor []byte
+	// Tx is the decoded transaction, nil if the node returned a transaction this version cannot decode.
+	Tx ledger.Transaction
+}
+
+type Snapshot struct {
+	Sizes
+	Txs []Tx
+}
+
+// Monitor queries consistent snapshots of the mempool. Every query acquires a fresh snapshot and
+// releases it afterwards, so the node is never held back.
+type Monitor struct {
+	mu     sync.Mutex
+	client Client
+}
+
+// NewMonitor returns a Monitor for client, typically conn.LocalTxMonitor().Client of a node-to-client
+// connection.
+func NewMonitor(client Client) *Monitor {
+	return &Monitor{client: client}
+}
+
+func (m *Monitor) acquired(fn func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.client.Acquire(); err != nil {
+		return fmt.Errorf("failed to acquire mempool snapshot: %w", err)
+	}
+	err := fn()
+	if releaseErr := m.client.Release(); releaseErr != nil && err == nil {
+		err = fmt.Errorf("failed to release mempool snapshot: %w", releaseErr)
+	}
+	return err
+}
+
+// Sizes returns the size and capacity of the mempool.
+func (m *Monitor) Sizes() (Sizes, error) {
+	var sizes Sizes
+	err := m.acquired(func() (err error) {
+		sizes, err = m.sizes()
+		return err
+	})
+	return sizes, err
+}
+
+func (m *Monitor) sizes() (Sizes, error) {
+	capacity, size, numTxs, err := m.client.GetSizes()
+	if err != nil {
+		return Sizes{}, fmt.Errorf("failed to get mempool sizes: %w", err)
+	}
+	return Sizes{Capacity: capacity, Size: size, NumTxs: numTxs}, nil
+}
+
+// Snapshot returns the sizes of the mempool and all transactions in it.
+func (m *Monitor) Snapshot() (*Snapshot, error) {
+	var snap Snapshot
+	err := m.acquired(func() error {
+		var err error
+		if snap.Sizes, err = m.sizes(); err != nil {
+			return err
+		}
+		for {
+			bz, err := m.client.NextTx()
+			if err != nil {
+				return fmt.Errorf("failed to get next mempool tx: %w", err)
+			}
+			if bz == nil {
+				return nil
+			}
+			t, err := decodeTx(bz)
+			if err != nil {
+				return err
+			}
+			snap.Txs = append(snap.Txs, t)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// HasTx reports for each of the hex encoded transaction hashes whether it is in the mempool.
+func (m *Monitor) HasTx(hashes ...string) (map[string]bool, error) {
+	res := make(map[string]bool, len(hashes))
+	err := m.acquired(func() error {
+		for _, hash := range hashes {
+			txId, err := hex.DecodeString(hash)
+			if err != nil {
+				return fmt.Errorf("invalid tx hash %s: %w", hash, err)
+			}
+			if res[hash], err = m.client.HasTx(txId); err != nil {
+				return fmt.Errorf("failed to query mempool for tx %s: %w", hash, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Watch polls the mempool every interval and calls handler once for every transaction entering it, until
+// ctx is done or handler returns an error. A transaction leaving the mempool without being included in a
+// block and later resubmitted is reported again.
+func (m *Monitor) Watch(ctx context.Context, interval time.Duration, handler func(Tx) error) error {
+	seen := make(map[string]bool)
+	for {
+		snap, err := m.Snapshot()
+		if err != nil {
+			return err
+		}
+		current := make(map[string]bool, len(snap.Txs))
+		for _, t := range snap.Txs {
+			current[t.Hash] = true
+			if seen[t.Hash] {
+				continue
+			}
+			if err := handler(t); err != nil {
+				return err
+			}
+		}
+		seen = current
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func decodeTx(bz []byte) (Tx, error) {
+	hash, err := tx.HashFromBytes(bz)
+	if err != nil {
+		return Tx{}, fmt.Errorf("failed to hash mempool tx: %w", err)
+	}
+	t := Tx{Hash: hex.EncodeToString(hash[:]), Cbor: bz}
+	// nearly every mempool transaction is of the current era
+	if t.Tx, err = ledger.NewTransactionFromCbor(ledger.TxTypeConway, bz); err != nil {
+		if txType, err := ledger.DetermineTransactionType(bz); err == nil {
+			t.Tx, _ = ledger.NewTransactionFromCbor(txType, bz)
+		}
+	}
+	return t, nil
+}
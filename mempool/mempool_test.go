@@ -0,0 +1,100 @@
+package mempool_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/kocubinski/gardano/mempool"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+// a payment of 2.5 ada to addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz
+const txCbor = "84a300d9010281825820086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab00018182581d6153e3d1d246eae7d89af8e41ae709979bc98ae0f9e23eac1f0e6609aa1a002625a0021a00028969a0f5f6"
+
+type fakeClient struct {
+	txs      [][]byte
+	next     int
+	acquired bool
+}
+
+func (c *fakeClient) Acquire() error {
+	c.acquired = true
+	c.next = 0
+	return nil
+}
+
+func (c *fakeClient) Release() error {
+	if !c.acquired {
+		return errors.New("not acquired")
+	}
+	c.acquired = false
+	return nil
+}
+
+func (c *fakeClient) HasTx(txId []byte) (bool, error) {
+	for _, bz := range c.txs {
+		hash, err := tx.HashFromBytes(bz)
+		if err != nil {
+			return false, err
+		}
+		if hex.EncodeToString(hash[:]) == hex.EncodeToString(txId) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *fakeClient) NextTx() ([]byte, error) {
+	if c.next >= len(c.txs) {
+		return nil, nil
+	}
+	c.next++
+	return c.txs[c.next-1], nil
+}
+
+func (c *fakeClient) GetSizes() (uint32, uint32, uint32, error) {
+	var size uint32
+	for _, bz := range c.txs {
+		size += uint32(len(bz))
+	}
+	return 1000, size, uint32(len(c.txs)), nil
+}
+
+func Test_Monitor(t *testing.T) {
+	bz, err := hex.DecodeString(txCbor)
+	require.NoError(t, err)
+	hash, err := tx.HashFromBytes(bz)
+	require.NoError(t, err)
+	txHash := hex.EncodeToString(hash[:])
+
+	client := &fakeClient{txs: [][]byte{bz}}
+	m := NewMonitor(client)
+
+	snap, err := m.Snapshot()
+	require.NoError(t, err)
+	require.False(t, client.acquired)
+	require.Equal(t, Sizes{Capacity: 1000, Size: uint32(len(bz)), NumTxs: 1}, snap.Sizes)
+	require.Len(t, snap.Txs, 1)
+	require.Equal(t, txHash, snap.Txs[0].Hash)
+	require.NotNil(t, snap.Txs[0].Tx)
+	require.Equal(t, uint64(2500000), snap.Txs[0].Tx.Outputs()[0].Amount())
+
+	has, err := m.HasTx(txHash, "00"+txHash[2:])
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{txHash: true, "00" + txHash[2:]: false}, has)
+
+	// the transaction is reported once while it stays in the mempool
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var reported []string
+	err = m.Watch(ctx, time.Millisecond, func(tx Tx) error {
+		reported = append(reported, tx.Hash)
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, []string{txHash}, reported)
+}
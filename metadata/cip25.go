@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+// LabelCIP25 is the metadata label of NFT metadata, https://cips.cardano.org/cip/CIP-25
+const LabelCIP25 = 721
+
+// File is a file of a CIP-25 NFT.
+type File struct {
+	Name      string
+	MediaType string
+	Src       string
+}
+
+// NFT is the CIP-25 metadata of a token. Image, Description and file sources longer than MaxLength are
+// chunked. Properties are added to the token's map after the standard fields.
+type NFT struct {
+	Name        string
+	Image       string
+	MediaType   string
+	Description string
+	Files       []File
+	Properties  Map
+}
+
+func (n NFT) metadatum() (Map, error) {
+	if n.Name == "" || n.Image == "" {
+		return nil, fmt.Errorf("CIP-25 metadata requires a name and an image")
+	}
+	m := Map{{"name", n.Name}, {"image", stringOrChunks(n.Image)}}
+	if n.MediaType != "" {
+		m = append(m, Pair{"mediaType", n.MediaType})
+	}
+	if n.Description != "" {
+		m = append(m, Pair{"description", stringOrChunks(n.Description)})
+	}
+	if len(n.Files) > 0 {
+		files := make([]any, 0, len(n.Files))
+		for _, f := range n.Files {
+			if f.Name == "" || f.MediaType == "" || f.Src == "" {
+				return nil, fmt.Errorf("CIP-25 files require a name, a media type and a src")
+			}
+			files = append(files, Map{{"name", f.Name}, {"mediaType", f.MediaType}, {"src", stringOrChunks(f.Src)}})
+		}
+		m = append(m, Pair{"files", files})
+	}
+	return append(m, n.Properties...), nil
+}
+
+// CIP25 returns the label 721 metadatum of tokens, keyed by hex policy id and then asset name. Version 1
+// keys assets by their names as text, version 2 by their raw bytes.
+func CIP25(version int, assets map[string]map[string]NFT) (Metadata, error) {
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("unknown CIP-25 version %d", version)
+	}
+	policies := make([]string, 0, len(assets))
+	for policy := range assets {
+		policies = append(policies, policy)
+	}
+	slices.Sort(policies)
+
+	res := make(Map, 0, len(assets)+1)
+	for _, policy := range policies {
+		policyId, err := hex.DecodeString(policy)
+		if err != nil || len(policyId) != 28 {
+			return nil, fmt.Errorf("invalid policy id %q", policy)
+		}
+		names := make([]string, 0, len(assets[policy]))
+		for name := range assets[policy] {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		tokens := make(Map, 0, len(names))
+		for _, name := range names {
+			if len(name) > 32 {
+				return nil, fmt.Errorf("asset name %x is longer than 32 bytes", name)
+			}
+			nft, err := assets[policy][name].metadatum()
+			if err != nil {
+				return nil, fmt.Errorf("asset %s.%x: %w", policy, name, err)
+			}
+			if version == 1 {
+				if !utf8.ValidString(name) {
+					return nil, fmt.Errorf("asset name %x is not UTF-8, which version 1 requires", name)
+				}
+				tokens = append(tokens, Pair{name, nft})
+			} else {
+				tokens = append(tokens, Pair{[]byte(name), nft})
+			}
+		}
+		if version == 1 {
+			res = append(res, Pair{policy, tokens})
+		} else {
+			res = append(res, Pair{policyId, tokens})
+		}
+	}
+	if version == 1 {
+		res = append(res, Pair{"version", "1.0"})
+	} else {
+		res = append(res, Pair{"version", 2})
+	}
+	md := Metadata{LabelCIP25: res}
+	if err := md.Validate(); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Asset name labels of CIP-68 tokens, https://cips.cardano.org/cip/CIP-68
+const (
+	LabelReferenceNFT = 100
+	LabelNFT          = 222
+	LabelFT           = 333
+	LabelRFT          = 444
+)
+
+// AssetNameLabel returns the 4 byte asset name prefix of a CIP-67 label: the label and its CRC-8 between
+// zero nibbles.
+func AssetNameLabel(label uint16) []byte {
+	crc := crc8([]byte{byte(label >> 8), byte(label)})
+	n := uint32(label)<<12 | uint32(crc)<<4
+	return []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+// crc8 is the CRC-8 of polynomial 0x07 that CIP-67 checksums labels with.
+func crc8(bz []byte) byte {
+	var crc byte
+	for _, b := range bz {
+		crc ^= b
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// AssetName returns the asset name of a CIP-68 token, its name prefixed with the label.
+func AssetName(label uint16, name []byte) ([]byte, error) {
+	res := append(AssetNameLabel(label), name...)
+	if len(res) > 32 {
+		return nil, fmt.Errorf("asset name of %d bytes is longer than 32 bytes", len(res))
+	}
+	return res, nil
+}
+
+// Datum returns the inline datum of a CIP-68 reference token, the Plutus data constructor 0 of the
+// metadata, the version and an empty extra field. Strings become UTF-8 byte strings, as Plutus data has no
+// text, and may be of any length.
+func Datum(metadata Map, version int) ([]byte, error) {
+	md, err := plutusData(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return encMode.Marshal(cbor.Tag{Number: 121, Content: []any{md, version, cbor.Tag{Number: 121, Content: []any{}}}})
+}
+
+// plutusBytes is a Plutus data byte string, which is split into an indefinite length byte string of 64
+// byte chunks when longer than 64 bytes.
+type plutusBytes []byte
+
+func (p plutusBytes) MarshalCBOR() ([]byte, error) {
+	if len(p) <= MaxLength {
+		return cbor.Marshal([]byte(p))
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(0x5f)
+	for _, chunk := range ChunkBytes(p) {
+		writeHead(&buf, 2, uint64(len(chunk)))
+		buf.Write(chunk)
+	}
+	buf.WriteByte(0xff)
+	return buf.Bytes(), nil
+}
+
+// plutusData converts a metadatum to Plutus data.
+func plutusData(v any) (any, error) {
+	switch m := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("null is not Plutus data")
+	case *big.Int:
+		return m, nil
+	case string:
+		return plutusBytes(m), nil
+	case []byte:
+		return plutusBytes(m), nil
+	case Map:
+		res := make(Map, 0, len(m))
+		for _, pair := range m {
+			k, err := plutusData(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := plutusData(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, Pair{k, v})
+		}
+		return res, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v, nil
+	case reflect.String:
+		return plutusBytes(rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			bz := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(bz), rv)
+			return plutusBytes(bz), nil
+		}
+		list := make([]any, 0, rv.Len())
+		for i := range rv.Len() {
+			item, err := plutusData(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("%T is not Plutus data, use Map for maps", v)
+	}
+}
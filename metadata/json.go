@@ -0,0 +1,350 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Schema is a JSON format of metadata, as in cardano-cli.
+type Schema int
+
+const (
+	// NoSchema maps JSON directly to metadata: numbers are integers, strings starting with 0x are byte
+	// strings, and object keys are integers, byte strings or strings as they parse.
+	NoSchema Schema = iota
+	// DetailedSchema spells out the type of every value:
+	//
+	//	{"int": 1}, {"bytes": "cafe"}, {"string": "foo"}, {"list": [...]}, {"map": [{"k": ..., "v": ...}]}
+	DetailedSchema
+)
+
+// FromJSON reads metadata from a JSON object whose keys are labels.
+func FromJSON(bz []byte, schema Schema) (Metadata, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(bz, &top); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata JSON: %w", err)
+	}
+	md := make(Metadata, len(top))
+	for key, raw := range top {
+		label, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata label %q, expected an unsigned integer", key)
+		}
+		var value any
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to parse label %d: %w", label, err)
+		}
+		if schema == DetailedSchema {
+			md[label], err = fromDetailed(value)
+		} else {
+			md[label], err = fromNoSchema(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", label, err)
+		}
+	}
+	if err := md.Validate(); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+// ReadFile reads metadata from a JSON file, see FromJSON.
+func ReadFile(path string, schema Schema) (Metadata, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata file: %w", err)
+	}
+	return FromJSON(bz, schema)
+}
+
+func fromNoSchema(value any) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return parseInt(v.String())
+	case string:
+		if bz, ok := parseHex(v); ok {
+			return bz, nil
+		}
+		return v, nil
+	case []any:
+		list := make([]any, 0, len(v))
+		for _, item := range v {
+			m, err := fromNoSchema(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, m)
+		}
+		return list, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		m := make(Map, 0, len(v))
+		for _, key := range keys {
+			value, err := fromNoSchema(v[key])
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, Pair{Key: noSchemaKey(key), Value: value})
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("%v is not a metadatum, metadata has no null, boolean or fractional values", value)
+	}
+}
+
+// noSchemaKey reads an object key as an integer, a 0x prefixed byte string or else a string.
+func noSchemaKey(key string) any {
+	if n, err := parseInt(key); err == nil {
+		return n
+	}
+	if bz, ok := parseHex(key); ok {
+		return bz
+	}
+	return key
+}
+
+func fromDetailed(value any) (any, error) {
+	obj, ok := value.(map[string]any)
+	if !ok || len(obj) != 1 {
+		return nil, fmt.Errorf("expected an object with one of int, bytes, string, list or map, got %v", value)
+	}
+	for typ, v := range obj {
+		switch typ {
+		case "int":
+			n, ok := v.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("int must be a number, got %v", v)
+			}
+			return parseInt(n.String())
+		case "bytes":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("bytes must be a hex string, got %v", v)
+			}
+			bz, err := hex.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bytes %q: %w", s, err)
+			}
+			return bz, nil
+		case "string":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("string must be a string, got %v", v)
+			}
+			return s, nil
+		case "list":
+			items, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("list must be an array, got %v", v)
+			}
+			list := make([]any, 0, len(items))
+			for _, item := range items {
+				m, err := fromDetailed(item)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, m)
+			}
+			return list, nil
+		case "map":
+			items, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("map must be an array of k and v objects, got %v", v)
+			}
+			m := make(Map, 0, len(items))
+			for _, item := range items {
+				kv, ok := item.(map[string]any)
+				if !ok || len(kv) != 2 || kv["k"] == nil || kv["v"] == nil {
+					return nil, fmt.Errorf("map entries must be objects of k and v, got %v", item)
+				}
+				key, err := fromDetailed(kv["k"])
+				if err != nil {
+					return nil, err
+				}
+				value, err := fromDetailed(kv["v"])
+				if err != nil {
+					return nil, err
+				}
+				m = append(m, Pair{Key: key, Value: value})
+			}
+			return m, nil
+		default:
+			return nil, fmt.Errorf("unknown detailed schema type %q", typ)
+		}
+	}
+	panic("unreachable")
+}
+
+// parseInt reads a decimal integer as an int64, a uint64, or a *big.Int for the rest of the metadatum
+// range.
+func parseInt(s string) (any, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n, nil
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an integer", s)
+	}
+	if n.Cmp(minInt) < 0 || n.Cmp(maxInt) > 0 {
+		return nil, fmt.Errorf("integer %s out of range", s)
+	}
+	return n, nil
+}
+
+func parseHex(s string) ([]byte, bool) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, false
+	}
+	bz, err := hex.DecodeString(s[2:])
+	return bz, err == nil
+}
+
+// ToJSON converts metadata, or the labels to metadatum map of a decoded transaction, to JSON in schema.
+func ToJSON(md map[uint64]any, schema Schema) ([]byte, error) {
+	res := make(map[string]any, len(md))
+	for label, v := range md {
+		value, err := JSONValue(v, schema)
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", label, err)
+		}
+		res[strconv.FormatUint(label, 10)] = value
+	}
+	return json.Marshal(res)
+}
+
+// JSONValue converts a metadatum to a value encoding/json prints in schema. It accepts the values this
+// package builds as well as decoded CBOR, such as map[any]any and byte strings with a Bytes method.
+func JSONValue(v any, schema Schema) (any, error) {
+	detailed := func(typ string, v any) any {
+		if schema == DetailedSchema {
+			return map[string]any{typ: v}
+		}
+		return v
+	}
+	switch m := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("null is not a metadatum")
+	case *big.Int:
+		return detailed("int", json.Number(m.String())), nil
+	case string:
+		return detailed("string", m), nil
+	case []byte:
+		return jsonBytes(m, schema), nil
+	case Map:
+		pairs := make([][2]any, 0, len(m))
+		for _, pair := range m {
+			pairs = append(pairs, [2]any{pair.Key, pair.Value})
+		}
+		return jsonMap(pairs, schema)
+	case interface{ Bytes() []byte }:
+		return jsonBytes(m.Bytes(), schema), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return detailed("int", json.Number(strconv.FormatInt(rv.Int(), 10))), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return detailed("int", json.Number(strconv.FormatUint(rv.Uint(), 10))), nil
+	case reflect.String:
+		return detailed("string", rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			bz := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(bz), rv)
+			return jsonBytes(bz, schema), nil
+		}
+		list := make([]any, 0, rv.Len())
+		for i := range rv.Len() {
+			item, err := JSONValue(rv.Index(i).Interface(), schema)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return detailed("list", list), nil
+	case reflect.Map:
+		pairs := make([][2]any, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			pairs = append(pairs, [2]any{iter.Key().Interface(), iter.Value().Interface()})
+		}
+		return jsonMap(pairs, schema)
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, fmt.Errorf("null is not a metadatum")
+		}
+		return JSONValue(rv.Elem().Interface(), schema)
+	default:
+		return nil, fmt.Errorf("%T is not a metadatum", v)
+	}
+}
+
+func jsonBytes(bz []byte, schema Schema) any {
+	if schema == DetailedSchema {
+		return map[string]any{"bytes": hex.EncodeToString(bz)}
+	}
+	return "0x" + hex.EncodeToString(bz)
+}
+
+// jsonMap converts map entries to a JSON object without schema, keyed by the text of each key, or to the
+// list of k and v objects of the detailed schema.
+func jsonMap(pairs [][2]any, schema Schema) (any, error) {
+	if schema == DetailedSchema {
+		entries := make([]any, 0, len(pairs))
+		for _, pair := range pairs {
+			k, err := JSONValue(pair[0], schema)
+			if err != nil {
+				return nil, err
+			}
+			v, err := JSONValue(pair[1], schema)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, map[string]any{"k": k, "v": v})
+		}
+		return map[string]any{"map": entries}, nil
+	}
+	res := make(map[string]any, len(pairs))
+	for _, pair := range pairs {
+		k, err := JSONValue(pair[0], schema)
+		if err != nil {
+			return nil, err
+		}
+		v, err := JSONValue(pair[1], schema)
+		if err != nil {
+			return nil, err
+		}
+		switch key := k.(type) {
+		case string:
+			res[key] = v
+		case json.Number:
+			res[key.String()] = v
+		default:
+			// lists and maps as keys have no JSON object key, print them as JSON
+			bz, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			res[string(bz)] = v
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,234 @@
+// Package metadata builds, validates and converts transaction metadata: the cardano-cli JSON schemas,
+// the ledger's metadatum constraints, chunking of long strings, and the CIP-25 and CIP-68 token standards.
+//
+// Metadatum values are Go integers or *big.Int, strings, byte slices, slices of values, and maps of values,
+// either Go maps or Map, which keeps its pairs in order.
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// MaxLength is the most bytes a metadatum string or byte string can hold.
+const MaxLength = 64
+
+var (
+	// the range of metadatum integers, -2^64 to 2^64-1
+	minInt = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 64))
+	maxInt = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(1))
+
+	// nested Go maps are sorted so that encoding the same metadata twice gives the same bytes
+	encMode, _ = cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+)
+
+// Metadata maps labels to metadatum values. It encodes with its labels in ascending order, so that the
+// auxiliary data hash is stable.
+type Metadata map[uint64]any
+
+func (md Metadata) MarshalCBOR() ([]byte, error) {
+	if md == nil {
+		return []byte{0xf6}, nil
+	}
+	labels := make([]uint64, 0, len(md))
+	for label := range md {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	pairs := make(Map, 0, len(md))
+	for _, label := range labels {
+		pairs = append(pairs, Pair{Key: label, Value: md[label]})
+	}
+	return pairs.MarshalCBOR()
+}
+
+// Validate checks every value of md is a metadatum within the ledger's limits.
+func (md Metadata) Validate() error {
+	for label, v := range md {
+		if err := Validate(v); err != nil {
+			return fmt.Errorf("label %d: %w", label, err)
+		}
+	}
+	return nil
+}
+
+// Pair is an entry of a Map.
+type Pair struct {
+	Key   any
+	Value any
+}
+
+// Map is a metadatum map which keeps its keys in the order they were added.
+type Map []Pair
+
+func (m Map) MarshalCBOR() ([]byte, error) {
+	var buf bytes.Buffer
+	writeHead(&buf, 5, uint64(len(m)))
+	for _, pair := range m {
+		for _, v := range []any{pair.Key, pair.Value} {
+			bz, err := encMode.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(bz)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Get returns the value of key, compared by its CBOR encoding.
+func (m Map) Get(key any) (any, bool) {
+	want, err := encMode.Marshal(key)
+	if err != nil {
+		return nil, false
+	}
+	for _, pair := range m {
+		if got, err := encMode.Marshal(pair.Key); err == nil && bytes.Equal(got, want) {
+			return pair.Value, true
+		}
+	}
+	return nil, false
+}
+
+// writeHead writes the head of a CBOR data item of major type major and argument n.
+func writeHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= 0xff:
+		buf.Write([]byte{major | 24, byte(n)})
+	case n <= 0xffff:
+		buf.Write([]byte{major | 25, byte(n >> 8), byte(n)})
+	case n <= 0xffffffff:
+		buf.Write([]byte{major | 26, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+	default:
+		buf.WriteByte(major | 27)
+		for shift := 56; shift >= 0; shift -= 8 {
+			buf.WriteByte(byte(n >> shift))
+		}
+	}
+}
+
+// Validate checks v is a metadatum: strings and byte strings of at most MaxLength bytes, integers between
+// -2^64 and 2^64-1, and lists and maps of metadata.
+func Validate(v any) error {
+	switch m := v.(type) {
+	case nil:
+		return fmt.Errorf("null is not a metadatum")
+	case *big.Int:
+		if m.Cmp(minInt) < 0 || m.Cmp(maxInt) > 0 {
+			return fmt.Errorf("integer %s out of range", m)
+		}
+		return nil
+	case Map:
+		for _, pair := range m {
+			if err := Validate(pair.Key); err != nil {
+				return err
+			}
+			if err := Validate(pair.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case interface{ Bytes() []byte }:
+		return validateBytes(m.Bytes())
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	case reflect.String:
+		if !utf8.ValidString(rv.String()) {
+			return fmt.Errorf("string %q is not valid UTF-8", rv.String())
+		}
+		if rv.Len() > MaxLength {
+			return fmt.Errorf("string of %d bytes is longer than %d bytes, see ChunkString", rv.Len(), MaxLength)
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.Len() > MaxLength {
+				return fmt.Errorf("byte string of %d bytes is longer than %d bytes, see ChunkBytes", rv.Len(), MaxLength)
+			}
+			return nil
+		}
+		for i := range rv.Len() {
+			if err := Validate(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := Validate(iter.Key().Interface()); err != nil {
+				return err
+			}
+			if err := Validate(iter.Value().Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return fmt.Errorf("null is not a metadatum")
+		}
+		return Validate(rv.Elem().Interface())
+	default:
+		return fmt.Errorf("%T is not a metadatum", v)
+	}
+}
+
+func validateBytes(bz []byte) error {
+	if len(bz) > MaxLength {
+		return fmt.Errorf("byte string of %d bytes is longer than %d bytes, see ChunkBytes", len(bz), MaxLength)
+	}
+	return nil
+}
+
+// ChunkString splits s into strings of at most MaxLength bytes, without splitting UTF-8 characters.
+func ChunkString(s string) []string {
+	var chunks []string
+	for len(s) > MaxLength {
+		cut := MaxLength
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		chunks = append(chunks, s[:cut])
+		s = s[cut:]
+	}
+	if len(s) > 0 {
+		chunks = append(chunks, s)
+	}
+	return chunks
+}
+
+// ChunkBytes splits bz into byte strings of at most MaxLength bytes.
+func ChunkBytes(bz []byte) [][]byte {
+	var chunks [][]byte
+	for len(bz) > MaxLength {
+		chunks = append(chunks, bz[:MaxLength])
+		bz = bz[MaxLength:]
+	}
+	if len(bz) > 0 {
+		chunks = append(chunks, bz)
+	}
+	return chunks
+}
+
+// stringOrChunks returns s, or its chunks if it is too long for a metadatum string. Standards such as
+// CIP-25 accept both for their long fields, like URLs.
+func stringOrChunks(s string) any {
+	if len(s) <= MaxLength {
+		return s
+	}
+	return ChunkString(s)
+}
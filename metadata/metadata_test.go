@@ -0,0 +1,128 @@
+package metadata_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	. "github.com/kocubinski/gardano/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Validate(t *testing.T) {
+	require.NoError(t, Validate(Map{{"msg", []string{strings.Repeat("a", 64)}}}))
+	require.Error(t, Validate(strings.Repeat("a", 65)))
+	require.Error(t, Validate(make([]byte, 65)))
+	require.Error(t, Validate([]any{nil}))
+	require.Error(t, Validate(true))
+	require.Error(t, Validate(1.5))
+
+	_, err := FromJSON([]byte(`{"1": 18446744073709551615, "2": -18446744073709551616}`), NoSchema)
+	require.NoError(t, err)
+	_, err = FromJSON([]byte(`{"1": 18446744073709551616}`), NoSchema)
+	require.Error(t, err)
+}
+
+func Test_Chunk(t *testing.T) {
+	s := strings.Repeat("ä", 40)
+	chunks := ChunkString(s)
+	require.Len(t, chunks, 2)
+	require.Equal(t, 64, len(chunks[0]))
+	require.Equal(t, s, strings.Join(chunks, ""))
+	require.Len(t, ChunkBytes(make([]byte, 129)), 3)
+	require.Nil(t, ChunkString(""))
+}
+
+func Test_Cbor(t *testing.T) {
+	// labels and nested map keys encode in order
+	md := Metadata{674: map[string]any{"msg": []string{"foo+bar-baz"}}, 1: Map{{"b", 1}, {"a", 2}}}
+	bz, err := cbor.Marshal(md)
+	require.NoError(t, err)
+	require.Equal(t, "a201a2616201616102"+"1902a2a1636d7367816b666f6f2b6261722d62617a", hex.EncodeToString(bz))
+}
+
+func Test_JSON(t *testing.T) {
+	noSchema := `{"1337": {"name": "hello", "0xcafe": [1, -2, "0xbeef"], "7": "world"}}`
+	md, err := FromJSON([]byte(noSchema), NoSchema)
+	require.NoError(t, err)
+	m := md[1337].(Map)
+	v, ok := m.Get([]byte{0xca, 0xfe})
+	require.True(t, ok)
+	require.Equal(t, []any{int64(1), int64(-2), []byte{0xbe, 0xef}}, v)
+	v, ok = m.Get(7)
+	require.True(t, ok)
+	require.Equal(t, "world", v)
+
+	// the detailed schema of the same metadata reads back to the same CBOR
+	detailed, err := ToJSON(md, DetailedSchema)
+	require.NoError(t, err)
+	fromDetailed, err := FromJSON(detailed, DetailedSchema)
+	require.NoError(t, err)
+	want, err := cbor.Marshal(md)
+	require.NoError(t, err)
+	got, err := cbor.Marshal(fromDetailed)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	bz, err := ToJSON(md, NoSchema)
+	require.NoError(t, err)
+	require.JSONEq(t, noSchema, string(bz))
+
+	_, err = FromJSON([]byte(`{"1": {"int": 1, "bytes": "00"}}`), DetailedSchema)
+	require.Error(t, err)
+	_, err = FromJSON([]byte(`{"1": null}`), NoSchema)
+	require.Error(t, err)
+	_, err = FromJSON([]byte(`{"label": 1}`), NoSchema)
+	require.Error(t, err)
+}
+
+func Test_CIP25(t *testing.T) {
+	policy := "00000002df633853f6a47465c9496721d2d5b1291b8398016c0e87ae"
+	image := "ipfs://" + strings.Repeat("x", 70)
+	nft := NFT{Name: "NFT 1", Image: image, MediaType: "image/png", Properties: Map{{"rarity", "rare"}}}
+
+	md, err := CIP25(1, map[string]map[string]NFT{policy: {"NFT1": nft}})
+	require.NoError(t, err)
+	bz, err := ToJSON(md, NoSchema)
+	require.NoError(t, err)
+	var res map[string]map[string]any
+	require.NoError(t, json.Unmarshal(bz, &res))
+	require.Equal(t, "1.0", res["721"]["version"])
+	token := res["721"][policy].(map[string]any)["NFT1"].(map[string]any)
+	require.Equal(t, []any{image[:64], image[64:]}, token["image"])
+	require.Equal(t, "rare", token["rarity"])
+
+	md, err = CIP25(2, map[string]map[string]NFT{policy: {"NFT1": nft}})
+	require.NoError(t, err)
+	bz, err = ToJSON(md, NoSchema)
+	require.NoError(t, err)
+	require.Contains(t, string(bz), `"0x`+policy+`"`)
+	require.Contains(t, string(bz), `"0x4e465431"`)
+
+	_, err = CIP25(1, map[string]map[string]NFT{policy: {"NFT1": {Name: "no image"}}})
+	require.Error(t, err)
+	_, err = CIP25(1, map[string]map[string]NFT{"cafe": {"NFT1": nft}})
+	require.Error(t, err)
+}
+
+func Test_CIP68(t *testing.T) {
+	require.Equal(t, "000643b0", hex.EncodeToString(AssetNameLabel(LabelReferenceNFT)))
+	require.Equal(t, "000de140", hex.EncodeToString(AssetNameLabel(LabelNFT)))
+	require.Equal(t, "0014df10", hex.EncodeToString(AssetNameLabel(LabelFT)))
+	name, err := AssetName(LabelNFT, []byte("NFT1"))
+	require.NoError(t, err)
+	require.Equal(t, "000de1404e465431", hex.EncodeToString(name))
+	_, err = AssetName(LabelNFT, make([]byte, 29))
+	require.Error(t, err)
+
+	bz, err := Datum(Map{{"name", "NFT 1"}}, 1)
+	require.NoError(t, err)
+	require.Equal(t, "d87983a1446e616d65454e4654203101d87980", hex.EncodeToString(bz))
+
+	// long values are chunked
+	bz, err = Datum(Map{{"image", strings.Repeat("x", 65)}}, 1)
+	require.NoError(t, err)
+	require.Contains(t, hex.EncodeToString(bz), "5f5840")
+}
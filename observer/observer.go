@@ -0,0 +1,240 @@
+package observer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/checkpoint"
+	"github.com/kocubinski/gardano/tx"
+)
+
+type EventType int
+
+const (
+	// EventDeposit reports a deposit which reached the configured number of confirmations.
+	EventDeposit EventType = iota
+	// EventRetraction reports that a previously emitted deposit was rolled back and is no longer on chain.
+	EventRetraction
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventDeposit:
+		return "deposit"
+	case EventRetraction:
+		return "retraction"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Deposit is a transaction output paying to a watched address.
+type Deposit struct {
+	TxHash      string
+	Index       uint32
+	Address     string
+	Amount      uint64
+	Assets      tx.MultiAsset
+	Memo        string
+	Slot        uint64
+	BlockHash   string
+	BlockNumber uint64
+}
+
+type Event struct {
+	Type    EventType
+	Deposit Deposit
+}
+
+// Handler is called synchronously for each event, in chain order for deposits and reverse chain order for
+// retractions. Returning an error aborts processing of the current block.
+type Handler func(Event) error
+
+type observedBlock struct {
+	point    common.Point
+	number   uint64
+	deposits []Deposit
+}
+
+// Observer turns the blocks delivered by chain-sync into Deposit events for a set of watched addresses.
+// Deposits are held back until their block is buried under the configured number of confirmations, and
+// retracted if a rollback removes a block whose deposits were already emitted.
+type Observer struct {
+	addresses     map[string]bool
+	handler       Handler
+	confirmations uint64
+	retain        uint64
+	memoOpts      []tx.MemoOption
+	log           *slog.Logger
+
+	// blocks still waiting for confirmations, oldest first
+	unconfirmed []observedBlock
+	// blocks with emitted deposits which a rollback may still remove, oldest first
+	confirmed     []observedBlock
+	lastConfirmed *common.Point
+}
+
+type Option func(*Observer)
+
+// WithConfirmations sets the number of blocks, including the one containing a deposit, which must be on
+// chain before the deposit is emitted. The default of 1 emits deposits as soon as they are seen.
+func WithConfirmations(n uint64) Option {
+	return func(o *Observer) {
+		o.confirmations = max(n, 1)
+	}
+}
+
+// WithRetention sets how many blocks deep emitted deposits can be retracted, which defaults to the
+// security parameter k. Rollbacks can never be deeper than k.
+func WithRetention(blocks uint64) Option {
+	return func(o *Observer) {
+		o.retain = blocks
+	}
+}
+
+// WithMemoPassphrase decrypts the CIP-83 encrypted memos of deposits with passphrase. Deposits whose memo
+// is encrypted under another passphrase, or when none is set, are emitted without a memo.
+func WithMemoPassphrase(passphrase string) Option {
+	return func(o *Observer) {
+		o.memoOpts = []tx.MemoOption{tx.WithPassphrase(passphrase)}
+	}
+}
+
+// WithLogger sets the logger warning about memos which cannot be decoded, slog.Default() by default.
+func WithLogger(log *slog.Logger) Option {
+	return func(o *Observer) {
+		o.log = log
+	}
+}
+
+// New returns an Observer emitting deposits to addresses, given in bech32, to handler.
+func New(addresses []string, handler Handler, opts ...Option) *Observer {
+	o := &Observer{
+		addresses:     make(map[string]bool),
+		handler:       handler,
+		confirmations: 1,
+		retain:        checkpoint.DefaultSecurityParam,
+		log:           slog.Default(),
+	}
+	for _, addr := range addresses {
+		o.addresses[addr] = true
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// RollForward processes a new block at the tip of the chain.
+func (o *Observer) RollForward(block ledger.Block) error {
+	// after a restart chain-sync may deliver blocks whose deposits were already emitted
+	if o.lastConfirmed != nil && block.SlotNumber() <= o.lastConfirmed.Slot {
+		return nil
+	}
+	blockHash, err := hex.DecodeString(block.Hash())
+	if err != nil {
+		return fmt.Errorf("invalid block hash: %w", err)
+	}
+	b := observedBlock{
+		point:  common.NewPoint(block.SlotNumber(), blockHash),
+		number: block.BlockNumber(),
+	}
+	for _, blockTx := range block.Transactions() {
+		var memo string
+		memoDecoded := false
+		// Produced accounts for phase-2 invalid transactions, which only create their collateral return
+		for _, utxo := range blockTx.Produced() {
+			addr := utxo.Output.Address().String()
+			if !o.addresses[addr] {
+				continue
+			}
+			if !memoDecoded {
+				var err error
+				memo, err = tx.DecodeMemo(blockTx, o.memoOpts...)
+				memoDecoded = true
+				// anyone paying a watched address chooses its metadata, so a memo the observer cannot decode
+				// or decrypt must not stop the deposit feed, the deposit is emitted without it
+				if err != nil {
+					memo = ""
+					if !errors.Is(err, tx.ErrEncryptedMemo) {
+						o.log.Warn("ignoring undecodable memo", "tx", blockTx.Hash(), "error", err)
+					}
+				}
+			}
+			b.deposits = append(b.deposits, Deposit{
+				TxHash:      blockTx.Hash(),
+				Index:       utxo.Id.Index(),
+				Address:     addr,
+				Amount:      utxo.Output.Amount(),
+				Assets:      tx.NewMultiAssetFromLedger(utxo.Output.Assets()),
+				Memo:        memo,
+				Slot:        b.point.Slot,
+				BlockHash:   block.Hash(),
+				BlockNumber: b.number,
+			})
+		}
+	}
+	o.unconfirmed = append(o.unconfirmed, b)
+	return o.confirm(b.number)
+}
+
+// confirm emits the deposits of all blocks which have enough confirmations at tip block number tip.
+func (o *Observer) confirm(tip uint64) error {
+	for len(o.unconfirmed) > 0 && tip+1 >= o.unconfirmed[0].number+o.confirmations {
+		b := o.unconfirmed[0]
+		for _, d := range b.deposits {
+			if err := o.handler(Event{Type: EventDeposit, Deposit: d}); err != nil {
+				return err
+			}
+		}
+		o.unconfirmed = o.unconfirmed[1:]
+		point := b.point
+		o.lastConfirmed = &point
+		if len(b.deposits) > 0 {
+			o.confirmed = append(o.confirmed, b)
+		}
+	}
+	// blocks deeper than the retention window can no longer be rolled back
+	i := 0
+	for i < len(o.confirmed) && o.confirmed[i].number+o.retain < tip {
+		i++
+	}
+	o.confirmed = slices.Delete(o.confirmed, 0, i)
+	return nil
+}
+
+// RollBackward discards all blocks after point, retracting any of their deposits which were emitted.
+func (o *Observer) RollBackward(point common.Point) error {
+	for len(o.unconfirmed) > 0 && o.unconfirmed[len(o.unconfirmed)-1].point.Slot > point.Slot {
+		o.unconfirmed = o.unconfirmed[:len(o.unconfirmed)-1]
+	}
+	for len(o.confirmed) > 0 && o.confirmed[len(o.confirmed)-1].point.Slot > point.Slot {
+		b := o.confirmed[len(o.confirmed)-1]
+		for i := len(b.deposits) - 1; i >= 0; i-- {
+			if err := o.handler(Event{Type: EventRetraction, Deposit: b.deposits[i]}); err != nil {
+				return err
+			}
+		}
+		o.confirmed = o.confirmed[:len(o.confirmed)-1]
+	}
+	if o.lastConfirmed != nil && o.lastConfirmed.Slot > point.Slot {
+		p := point
+		o.lastConfirmed = &p
+	}
+	return nil
+}
+
+// LastConfirmed returns the most recent point whose deposits have all been emitted. Persisting this point,
+// rather than the tip, as the chain-sync cursor ensures unconfirmed deposits are seen again after a restart;
+// SaveFile persists the emitted ones which may still be retracted.
+func (o *Observer) LastConfirmed() (common.Point, bool) {
+	if o.lastConfirmed == nil {
+		return common.Point{}, false
+	}
+	return *o.lastConfirmed, true
+}
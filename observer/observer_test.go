@@ -0,0 +1,146 @@
+package observer_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/internal/ledgertest"
+	. "github.com/kocubinski/gardano/observer"
+	"github.com/stretchr/testify/require"
+)
+
+const watched = "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz"
+
+func newBlock(t *testing.T, number uint64, amounts ...uint64) ledgertest.Block {
+	addr, err := ledger.NewAddress(watched)
+	require.NoError(t, err)
+	b := ledgertest.Block{Number: number}
+	for i, amount := range amounts {
+		b.Txs = append(b.Txs, ledgertest.Tx{
+			ID:  fmt.Sprintf("%062x%02x", number, i),
+			Out: []ledger.TransactionOutput{ledgertest.Output{Addr: addr, Coin: amount}},
+		})
+	}
+	return b
+}
+
+func Test_Observer(t *testing.T) {
+	var events []Event
+	o := New([]string{watched}, func(ev Event) error {
+		events = append(events, ev)
+		return nil
+	}, WithConfirmations(3))
+
+	require.NoError(t, o.RollForward(newBlock(t, 1, 100)))
+	require.NoError(t, o.RollForward(newBlock(t, 2, 200)))
+	require.Empty(t, events)
+	_, ok := o.LastConfirmed()
+	require.False(t, ok)
+
+	// block 1 reaches 3 confirmations
+	require.NoError(t, o.RollForward(newBlock(t, 3)))
+	require.Len(t, events, 1)
+	require.Equal(t, EventDeposit, events[0].Type)
+	require.Equal(t, uint64(100), events[0].Deposit.Amount)
+	require.Equal(t, uint64(10), events[0].Deposit.Slot)
+	require.Equal(t, watched, events[0].Deposit.Address)
+
+	require.NoError(t, o.RollForward(newBlock(t, 4)))
+	require.Len(t, events, 2)
+	require.Equal(t, uint64(200), events[1].Deposit.Amount)
+	lastConfirmed, ok := o.LastConfirmed()
+	require.True(t, ok)
+	require.Equal(t, uint64(20), lastConfirmed.Slot)
+
+	// a rollback to block 1 retracts the emitted deposit of block 2 only
+	events = nil
+	require.NoError(t, o.RollBackward(common.NewPoint(10, nil)))
+	require.Len(t, events, 1)
+	require.Equal(t, EventRetraction, events[0].Type)
+	require.Equal(t, uint64(200), events[0].Deposit.Amount)
+	lastConfirmed, _ = o.LastConfirmed()
+	require.Equal(t, uint64(10), lastConfirmed.Slot)
+
+	// an unconfirmed deposit rolled back is never emitted
+	events = nil
+	require.NoError(t, o.RollForward(newBlock(t, 2, 300)))
+	require.NoError(t, o.RollBackward(common.NewPoint(10, nil)))
+	require.NoError(t, o.RollForward(newBlock(t, 2)))
+	require.NoError(t, o.RollForward(newBlock(t, 3)))
+	require.NoError(t, o.RollForward(newBlock(t, 4)))
+	require.Empty(t, events)
+}
+
+func Test_ObserverRestart(t *testing.T) {
+	var events []Event
+	handler := func(ev Event) error {
+		events = append(events, ev)
+		return nil
+	}
+	o := New([]string{watched}, handler, WithConfirmations(2))
+	require.NoError(t, o.RollForward(newBlock(t, 1, 100)))
+	require.NoError(t, o.RollForward(newBlock(t, 2, 200)))
+	require.NoError(t, o.RollForward(newBlock(t, 3)))
+	require.Len(t, events, 2)
+	path := filepath.Join(t.TempDir(), "observer.json")
+	require.NoError(t, o.SaveFile(path))
+
+	// chain-sync resumes from the last confirmed block 2, delivering block 3 again
+	events = nil
+	restarted := New([]string{watched}, handler, WithConfirmations(2))
+	require.NoError(t, restarted.LoadFile(path))
+	lastConfirmed, ok := restarted.LastConfirmed()
+	require.True(t, ok)
+	require.Equal(t, uint64(20), lastConfirmed.Slot)
+	require.NoError(t, restarted.RollBackward(lastConfirmed))
+	require.NoError(t, restarted.RollForward(newBlock(t, 2, 200)))
+	require.NoError(t, restarted.RollForward(newBlock(t, 3)))
+	require.Empty(t, events)
+
+	// deposits emitted before the restart are still retracted
+	require.NoError(t, restarted.RollBackward(common.NewPoint(10, nil)))
+	require.Len(t, events, 1)
+	require.Equal(t, EventRetraction, events[0].Type)
+	require.Equal(t, uint64(200), events[0].Deposit.Amount)
+
+	require.NoError(t, New(nil, handler).LoadFile(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+func metadata(t *testing.T, md any) *cbor.LazyValue {
+	bz, err := cbor.Encode(md)
+	require.NoError(t, err)
+	lv := &cbor.LazyValue{}
+	require.NoError(t, lv.UnmarshalCBOR(bz))
+	return lv
+}
+
+func Test_ObserverInvalidMemo(t *testing.T) {
+	for name, md := range map[string]any{
+		"msg not a list": map[uint64]any{674: map[string]any{"msg": 5}},
+		"invalid base64": map[uint64]any{674: map[string]any{"enc": "basic", "msg": []string{"!not base64!"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var events []Event
+			o := New([]string{watched}, func(ev Event) error {
+				events = append(events, ev)
+				return nil
+			})
+			// a junk memo paid to a watched address does not stop the deposit feed
+			b := newBlock(t, 1, 100)
+			tx := b.Txs[0].(ledgertest.Tx)
+			tx.Meta = metadata(t, md)
+			b.Txs[0] = tx
+			require.NoError(t, o.RollForward(b))
+			require.Len(t, events, 1)
+			require.Equal(t, uint64(100), events[0].Deposit.Amount)
+			require.Empty(t, events[0].Deposit.Memo)
+			lastConfirmed, ok := o.LastConfirmed()
+			require.True(t, ok)
+			require.Equal(t, uint64(10), lastConfirmed.Slot)
+		})
+	}
+}
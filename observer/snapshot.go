@@ -0,0 +1,123 @@
+package observer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+)
+
+type snapshotPoint struct {
+	Slot uint64 `json:"slot"`
+	Hash string `json:"hash"`
+}
+
+type snapshotBlock struct {
+	Point    snapshotPoint `json:"point"`
+	Number   uint64        `json:"block_number"`
+	Deposits []Deposit     `json:"deposits"`
+}
+
+type snapshot struct {
+	LastConfirmed *snapshotPoint  `json:"last_confirmed,omitempty"`
+	Confirmed     []snapshotBlock `json:"confirmed"`
+}
+
+// SaveFile atomically writes the emitted deposits which a rollback may still retract, and the last
+// confirmed point, to path. Unconfirmed blocks are not saved: chain-sync resumes from LastConfirmed and
+// delivers them again.
+func (o *Observer) SaveFile(path string) error {
+	var snap snapshot
+	if o.lastConfirmed != nil {
+		p := toSnapshotPoint(*o.lastConfirmed)
+		snap.LastConfirmed = &p
+	}
+	for _, b := range o.confirmed {
+		snap.Confirmed = append(snap.Confirmed, snapshotBlock{
+			Point:    toSnapshotPoint(b.point),
+			Number:   b.number,
+			Deposits: b.deposits,
+		})
+	}
+	bz, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return err
+	}
+	// the checkpoint saved after the observer must not survive a crash which loses its deposits
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	d, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// LoadFile restores the state saved at path by SaveFile, so that deposits emitted before a restart are
+// still retracted by a rollback. A missing file leaves the observer as it is.
+func (o *Observer) LoadFile(path string) error {
+	bz, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(bz, &snap); err != nil {
+		return fmt.Errorf("failed to decode observer snapshot %s: %w", path, err)
+	}
+	o.unconfirmed, o.confirmed, o.lastConfirmed = nil, nil, nil
+	if snap.LastConfirmed != nil {
+		p, err := fromSnapshotPoint(*snap.LastConfirmed)
+		if err != nil {
+			return err
+		}
+		o.lastConfirmed = &p
+	}
+	for _, sb := range snap.Confirmed {
+		point, err := fromSnapshotPoint(sb.Point)
+		if err != nil {
+			return err
+		}
+		o.confirmed = append(o.confirmed, observedBlock{point: point, number: sb.Number, deposits: sb.Deposits})
+	}
+	return nil
+}
+
+func toSnapshotPoint(p common.Point) snapshotPoint {
+	return snapshotPoint{Slot: p.Slot, Hash: hex.EncodeToString(p.Hash)}
+}
+
+func fromSnapshotPoint(p snapshotPoint) (common.Point, error) {
+	if p.Hash == "" {
+		return common.NewPoint(p.Slot, nil), nil
+	}
+	hash, err := hex.DecodeString(p.Hash)
+	if err != nil {
+		return common.Point{}, fmt.Errorf("invalid point hash: %w", err)
+	}
+	return common.NewPoint(p.Slot, hash), nil
+}
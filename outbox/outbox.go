@@ -0,0 +1,226 @@
+// Package outbox offers transactions to remote nodes over the node-to-node TxSubmission2 protocol, in
+// which the remote node pulls the ids and bodies of the transactions it wants.
+package outbox
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/blinklabs-io/gouroboros/protocol/txsubmission"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// ErrNotFound is returned for transactions which are not in the outbox.
+var ErrNotFound = errors.New("transaction not in outbox")
+
+type entry struct {
+	id        txsubmission.TxId
+	body      []byte
+	delivered int
+}
+
+// Outbox holds transactions until they are removed, offering each to every connected peer once.
+type Outbox struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	txs    []*entry
+	peers  map[*peer]bool
+	closed bool
+}
+
+func New() *Outbox {
+	o := &Outbox{peers: make(map[*peer]bool)}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// Add queues a CBOR encoded transaction of the given era, e.g. ledger.TxTypeConway, and returns its hash.
+func (o *Outbox) Add(era uint16, txBz []byte) (string, error) {
+	hash, err := tx.HashFromBytes(txBz)
+	if err != nil {
+		return "", err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.find(hash) == nil {
+		o.txs = append(o.txs, &entry{
+			id:   txsubmission.TxId{EraId: era, TxId: hash},
+			body: txBz,
+		})
+		o.cond.Broadcast()
+	}
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Remove stops offering a transaction, e.g. once it is confirmed or expired.
+func (o *Outbox) Remove(txHash string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.txs = slices.DeleteFunc(o.txs, func(e *entry) bool {
+		if hex.EncodeToString(e.id.TxId[:]) != txHash {
+			return false
+		}
+		for p := range o.peers {
+			delete(p.announced, e.id.TxId)
+		}
+		return true
+	})
+}
+
+// Len returns the number of transactions in the outbox.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.txs)
+}
+
+// Delivered returns the number of peers which downloaded a transaction.
+func (o *Outbox) Delivered(txHash string) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, err := o.lookup(txHash)
+	if err != nil {
+		return 0, err
+	}
+	return e.delivered, nil
+}
+
+// WaitDelivered blocks until at least n peers downloaded a transaction or ctx is done.
+func (o *Outbox) WaitDelivered(ctx context.Context, txHash string, n int) error {
+	stop := context.AfterFunc(ctx, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.cond.Broadcast()
+	})
+	defer stop()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for {
+		e, err := o.lookup(txHash)
+		if err != nil {
+			return err
+		}
+		if e.delivered >= n {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		o.cond.Wait()
+	}
+}
+
+// Close releases peers blocked waiting for new transactions, which then receive none.
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.cond.Broadcast()
+}
+
+func (o *Outbox) lookup(txHash string) (*entry, error) {
+	hash, err := hex.DecodeString(txHash)
+	if err != nil || len(hash) != 32 {
+		return nil, fmt.Errorf("invalid tx hash %q", txHash)
+	}
+	e := o.find([32]byte(hash))
+	if e == nil {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+func (o *Outbox) find(hash [32]byte) *entry {
+	for _, e := range o.txs {
+		if e.id.TxId == hash {
+			return e
+		}
+	}
+	return nil
+}
+
+// peer is the TxSubmission2 state of one connection: the ids announced to it which are still in the
+// outbox, and those it has not acknowledged yet, oldest first. closed is set once the connection ended.
+type peer struct {
+	outbox    *Outbox
+	announced map[[32]byte]bool
+	unacked   []txsubmission.TxId
+	closed    bool
+}
+
+// Config returns the TxSubmission2 callbacks serving the outbox to one connection. Each connection needs
+// its own Config, and must call TxSubmission().Client.Init() once established. Closing done, once the
+// connection ended, releases a blocked request of the connection and drops its state. A nil done is
+// never closed.
+func (o *Outbox) Config(done <-chan struct{}) txsubmission.Config {
+	p := &peer{outbox: o, announced: make(map[[32]byte]bool)}
+	o.mu.Lock()
+	o.peers[p] = true
+	o.mu.Unlock()
+	if done != nil {
+		go func() {
+			<-done
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			delete(o.peers, p)
+			p.closed = true
+			o.cond.Broadcast()
+		}()
+	}
+	return txsubmission.NewConfig(
+		txsubmission.WithRequestTxIdsFunc(p.requestTxIds),
+		txsubmission.WithRequestTxsFunc(p.requestTxs),
+	)
+}
+
+// requestTxIds acknowledges the ack oldest announced ids and announces up to req new ones. A blocking
+// request waits until there is at least one, or the outbox or connection is closed.
+func (p *peer) requestTxIds(_ txsubmission.CallbackContext, blocking bool, ack, req uint16) ([]txsubmission.TxIdAndSize, error) {
+	o := p.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if int(ack) > len(p.unacked) {
+		return nil, fmt.Errorf("peer acknowledged %d tx ids, only %d are outstanding", ack, len(p.unacked))
+	}
+	p.unacked = p.unacked[ack:]
+	for {
+		var res []txsubmission.TxIdAndSize
+		for _, e := range o.txs {
+			if len(res) >= int(req) {
+				break
+			}
+			if p.announced[e.id.TxId] {
+				continue
+			}
+			p.announced[e.id.TxId] = true
+			p.unacked = append(p.unacked, e.id)
+			res = append(res, txsubmission.TxIdAndSize{TxId: e.id, Size: uint32(len(e.body))})
+		}
+		if len(res) > 0 || !blocking || o.closed || p.closed {
+			return res, nil
+		}
+		o.cond.Wait()
+	}
+}
+
+// requestTxs returns the requested bodies which are still in the outbox.
+func (p *peer) requestTxs(_ txsubmission.CallbackContext, ids []txsubmission.TxId) ([]txsubmission.TxBody, error) {
+	o := p.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var res []txsubmission.TxBody
+	for _, id := range ids {
+		e := o.find(id.TxId)
+		if e == nil {
+			continue
+		}
+		e.delivered++
+		res = append(res, txsubmission.TxBody{EraId: e.id.EraId, TxBody: e.body})
+	}
+	o.cond.Broadcast()
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/txsubmission"
+	. "github.com/kocubinski/gardano/outbox"
+	"github.com/stretchr/testify/require"
+)
+
+// a payment of 2.5 ada to addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz
+const txCbor = "84a300d9010281825820086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab00018182581d6153e3d1d246eae7d89af8e41ae709979bc98ae0f9e23eac1f0e6609aa1a002625a0021a00028969a0f5f6"
+
+func Test_Outbox(t *testing.T) {
+	o := New()
+	cfg := o.Config(nil)
+	var cbCtx txsubmission.CallbackContext
+
+	// a non-blocking request on an empty outbox returns no ids
+	ids, err := cfg.RequestTxIdsFunc(cbCtx, false, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	// a blocking request waits for a transaction
+	txBz, err := hex.DecodeString(txCbor)
+	require.NoError(t, err)
+	result := make(chan []txsubmission.TxIdAndSize)
+	go func() {
+		ids, err := cfg.RequestTxIdsFunc(cbCtx, true, 0, 10)
+		require.NoError(t, err)
+		result <- ids
+	}()
+	time.Sleep(10 * time.Millisecond)
+	hash, err := o.Add(ledger.TxTypeConway, txBz)
+	require.NoError(t, err)
+	ids = <-result
+	require.Len(t, ids, 1)
+	require.Equal(t, hash, hex.EncodeToString(ids[0].TxId.TxId[:]))
+	require.Equal(t, uint16(ledger.TxTypeConway), ids[0].TxId.EraId)
+	require.Equal(t, uint32(len(txBz)), ids[0].Size)
+
+	bodies, err := cfg.RequestTxsFunc(cbCtx, []txsubmission.TxId{ids[0].TxId})
+	require.NoError(t, err)
+	require.Equal(t, []txsubmission.TxBody{{EraId: ledger.TxTypeConway, TxBody: txBz}}, bodies)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, o.WaitDelivered(ctx, hash, 1))
+
+	// announced ids are not announced again, and cannot be over-acknowledged
+	ids, err = cfg.RequestTxIdsFunc(cbCtx, false, 1, 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+	_, err = cfg.RequestTxIdsFunc(cbCtx, false, 1, 10)
+	require.Error(t, err)
+
+	// another connection is offered the same transaction
+	other := o.Config(nil)
+	ids, err = other.RequestTxIdsFunc(cbCtx, false, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+
+	// removed transactions are no longer served
+	o.Remove(hash)
+	bodies, err = other.RequestTxsFunc(cbCtx, []txsubmission.TxId{ids[0].TxId})
+	require.NoError(t, err)
+	require.Empty(t, bodies)
+	_, err = o.Delivered(hash)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// a removed transaction is offered again once it is added back
+	_, err = o.Add(ledger.TxTypeConway, txBz)
+	require.NoError(t, err)
+	ids, err = cfg.RequestTxIdsFunc(cbCtx, false, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+	o.Remove(hash)
+
+	// the end of a connection releases its blocked request
+	done := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(done)
+	}()
+	ids, err = o.Config(done).RequestTxIdsFunc(cbCtx, true, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	// closing releases blocked peers
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		o.Close()
+	}()
+	ids, err = o.Config(nil).RequestTxIdsFunc(cbCtx, true, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
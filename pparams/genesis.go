@@ -0,0 +1,149 @@
+package pparams
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type shelleyGenesis struct {
+	ProtocolParams struct {
+		MinFeeA            uint64          `json:"minFeeA"`
+		MinFeeB            uint64          `json:"minFeeB"`
+		MaxBlockBodySize   uint64          `json:"maxBlockBodySize"`
+		MaxTxSize          uint64          `json:"maxTxSize"`
+		MaxBlockHeaderSize uint64          `json:"maxBlockHeaderSize"`
+		KeyDeposit         uint64          `json:"keyDeposit"`
+		PoolDeposit        uint64          `json:"poolDeposit"`
+		EMax               uint64          `json:"eMax"`
+		NOpt               uint64          `json:"nOpt"`
+		A0                 *Rational       `json:"a0"`
+		Rho                *Rational       `json:"rho"`
+		Tau                *Rational       `json:"tau"`
+		ProtocolVersion    ProtocolVersion `json:"protocolVersion"`
+		MinUTxOValue       uint64          `json:"minUTxOValue"`
+		MinPoolCost        uint64          `json:"minPoolCost"`
+	} `json:"protocolParams"`
+}
+
+type alonzoGenesis struct {
+	LovelacePerUTxOWord uint64 `json:"lovelacePerUTxOWord"`
+	ExecutionPrices     struct {
+		PrSteps *Rational `json:"prSteps"`
+		PrMem   *Rational `json:"prMem"`
+	} `json:"executionPrices"`
+	MaxTxExUnits         genesisExUnits `json:"maxTxExUnits"`
+	MaxBlockExUnits      genesisExUnits `json:"maxBlockExUnits"`
+	MaxValueSize         uint64         `json:"maxValueSize"`
+	CollateralPercentage uint64         `json:"collateralPercentage"`
+	MaxCollateralInputs  uint64         `json:"maxCollateralInputs"`
+	CostModels           CostModels     `json:"costModels"`
+}
+
+type genesisExUnits struct {
+	Mem   uint64 `json:"exUnitsMem"`
+	Steps uint64 `json:"exUnitsSteps"`
+}
+
+type conwayGenesis struct {
+	PoolVotingThresholds       *PoolVotingThresholds `json:"poolVotingThresholds"`
+	DRepVotingThresholds       *DRepVotingThresholds `json:"dRepVotingThresholds"`
+	CommitteeMinSize           uint64                `json:"committeeMinSize"`
+	CommitteeMaxTermLength     uint64                `json:"committeeMaxTermLength"`
+	GovActionLifetime          uint64                `json:"govActionLifetime"`
+	GovActionDeposit           uint64                `json:"govActionDeposit"`
+	DRepDeposit                uint64                `json:"dRepDeposit"`
+	DRepActivity               uint64                `json:"dRepActivity"`
+	MinFeeRefScriptCostPerByte *Rational             `json:"minFeeRefScriptCostPerByte"`
+	PlutusV3CostModel          []int64               `json:"plutusV3CostModel"`
+}
+
+// FromGenesis returns the parameters a chain starts with, given the contents of its Shelley genesis and
+// optionally its Alonzo and Conway genesis. The protocol version is raised to the first one of the latest
+// era given, as networks which hard fork at epoch 0 keep a Shelley version in their Shelley genesis.
+func FromGenesis(shelley, alonzo, conway []byte) (*PParams, error) {
+	var sg shelleyGenesis
+	if err := json.Unmarshal(shelley, &sg); err != nil {
+		return nil, fmt.Errorf("failed to parse shelley genesis: %w", err)
+	}
+	sp := sg.ProtocolParams
+	p := &PParams{
+		TxFeePerByte:        sp.MinFeeA,
+		TxFeeFixed:          sp.MinFeeB,
+		MaxBlockBodySize:    sp.MaxBlockBodySize,
+		MaxTxSize:           sp.MaxTxSize,
+		MaxBlockHeaderSize:  sp.MaxBlockHeaderSize,
+		StakeAddressDeposit: sp.KeyDeposit,
+		StakePoolDeposit:    sp.PoolDeposit,
+		PoolRetireMaxEpoch:  sp.EMax,
+		StakePoolTargetNum:  sp.NOpt,
+		PoolPledgeInfluence: sp.A0,
+		MonetaryExpansion:   sp.Rho,
+		TreasuryCut:         sp.Tau,
+		ProtocolVersion:     sp.ProtocolVersion,
+		MinUTxOValue:        sp.MinUTxOValue,
+		MinPoolCost:         sp.MinPoolCost,
+	}
+	if alonzo != nil {
+		var ag alonzoGenesis
+		if err := json.Unmarshal(alonzo, &ag); err != nil {
+			return nil, fmt.Errorf("failed to parse alonzo genesis: %w", err)
+		}
+		p.MinUTxOValue = 0
+		p.UtxoCostPerByte = ag.LovelacePerUTxOWord / 8
+		p.CostModels = ag.CostModels
+		p.ExecutionUnitPrices = &ExecutionUnitPrices{
+			PriceMemory: ag.ExecutionPrices.PrMem,
+			PriceSteps:  ag.ExecutionPrices.PrSteps,
+		}
+		p.MaxTxExecutionUnits = &ExUnits{Memory: ag.MaxTxExUnits.Mem, Steps: ag.MaxTxExUnits.Steps}
+		p.MaxBlockExecutionUnits = &ExUnits{Memory: ag.MaxBlockExUnits.Mem, Steps: ag.MaxBlockExUnits.Steps}
+		p.MaxValueSize = ag.MaxValueSize
+		p.CollateralPercentage = ag.CollateralPercentage
+		p.MaxCollateralInputs = ag.MaxCollateralInputs
+		if p.ProtocolVersion.Major < 5 {
+			p.ProtocolVersion = ProtocolVersion{Major: 5}
+		}
+	}
+	if conway != nil {
+		var cg conwayGenesis
+		if err := json.Unmarshal(conway, &cg); err != nil {
+			return nil, fmt.Errorf("failed to parse conway genesis: %w", err)
+		}
+		p.PoolVotingThresholds = cg.PoolVotingThresholds
+		p.DRepVotingThresholds = cg.DRepVotingThresholds
+		p.CommitteeMinSize = cg.CommitteeMinSize
+		p.CommitteeMaxTermLength = cg.CommitteeMaxTermLength
+		p.GovActionLifetime = cg.GovActionLifetime
+		p.GovActionDeposit = cg.GovActionDeposit
+		p.DRepDeposit = cg.DRepDeposit
+		p.DRepActivity = cg.DRepActivity
+		p.MinFeeRefScriptCostPerByte = cg.MinFeeRefScriptCostPerByte
+		if len(cg.PlutusV3CostModel) > 0 {
+			if p.CostModels == nil {
+				p.CostModels = make(CostModels)
+			}
+			p.CostModels["PlutusV3"] = cg.PlutusV3CostModel
+		}
+		if p.ProtocolVersion.Major < 9 {
+			p.ProtocolVersion = ProtocolVersion{Major: 9}
+		}
+	}
+	return p, nil
+}
+
+// ReadGenesisFiles reads the genesis files FromGenesis expects. The Alonzo and Conway paths may be empty.
+func ReadGenesisFiles(shelleyPath, alonzoPath, conwayPath string) (*PParams, error) {
+	var contents [3][]byte
+	for i, path := range []string{shelleyPath, alonzoPath, conwayPath} {
+		if path == "" {
+			continue
+		}
+		bz, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read genesis: %w", err)
+		}
+		contents[i] = bz
+	}
+	return FromGenesis(contents[0], contents[1], contents[2])
+}
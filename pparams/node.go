@@ -0,0 +1,234 @@
+package pparams
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger/allegra"
+	"github.com/blinklabs-io/gouroboros/ledger/alonzo"
+	"github.com/blinklabs-io/gouroboros/ledger/babbage"
+	"github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/ledger/conway"
+	"github.com/blinklabs-io/gouroboros/ledger/mary"
+	"github.com/blinklabs-io/gouroboros/ledger/shelley"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+)
+
+// FromNode queries the parameters of the node's current era.
+func FromNode(client *localstatequery.Client) (*PParams, error) {
+	pp, err := client.GetCurrentProtocolParams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query protocol parameters: %w", err)
+	}
+	return FromLedger(pp)
+}
+
+// FromLedger converts the era specific parameters returned by gouroboros.
+func FromLedger(pp common.ProtocolParameters) (*PParams, error) {
+	switch v := pp.(type) {
+	case *shelley.ShelleyProtocolParameters:
+		return fromShelley(v), nil
+	case *allegra.AllegraProtocolParameters:
+		return fromShelley(&v.ShelleyProtocolParameters), nil
+	case *mary.MaryProtocolParameters:
+		return fromShelley(&v.ShelleyProtocolParameters), nil
+	case *alonzo.AlonzoProtocolParameters:
+		p := fromShelley(&v.ShelleyProtocolParameters)
+		p.MinUTxOValue = 0
+		p.MinPoolCost = v.MinPoolCost
+		p.UtxoCostPerByte = v.AdaPerUtxoByte
+		p.CostModels = fromLedgerCostModels(v.CostModels)
+		p.ExecutionUnitPrices = fromLedgerPrices(v.ExecutionCosts)
+		p.MaxTxExecutionUnits = fromLedgerExUnits(v.MaxTxExUnits)
+		p.MaxBlockExecutionUnits = fromLedgerExUnits(v.MaxBlockExUnits)
+		p.MaxValueSize = uint64(v.MaxValueSize)
+		p.CollateralPercentage = uint64(v.CollateralPercentage)
+		p.MaxCollateralInputs = uint64(v.MaxCollateralInputs)
+		return p, nil
+	case *babbage.BabbageProtocolParameters:
+		return &PParams{
+			TxFeePerByte:           uint64(v.MinFeeA),
+			TxFeeFixed:             uint64(v.MinFeeB),
+			MaxBlockBodySize:       uint64(v.MaxBlockBodySize),
+			MaxTxSize:              uint64(v.MaxTxSize),
+			MaxBlockHeaderSize:     uint64(v.MaxBlockHeaderSize),
+			StakeAddressDeposit:    uint64(v.KeyDeposit),
+			StakePoolDeposit:       uint64(v.PoolDeposit),
+			PoolRetireMaxEpoch:     uint64(v.MaxEpoch),
+			StakePoolTargetNum:     uint64(v.NOpt),
+			PoolPledgeInfluence:    fromLedgerRat(v.A0),
+			MonetaryExpansion:      fromLedgerRat(v.Rho),
+			TreasuryCut:            fromLedgerRat(v.Tau),
+			ProtocolVersion:        ProtocolVersion{Major: uint64(v.ProtocolMajor), Minor: uint64(v.ProtocolMinor)},
+			MinPoolCost:            v.MinPoolCost,
+			UtxoCostPerByte:        v.AdaPerUtxoByte,
+			CostModels:             fromLedgerCostModels(v.CostModels),
+			ExecutionUnitPrices:    fromLedgerPrices(v.ExecutionCosts),
+			MaxTxExecutionUnits:    fromLedgerExUnits(v.MaxTxExUnits),
+			MaxBlockExecutionUnits: fromLedgerExUnits(v.MaxBlockExUnits),
+			MaxValueSize:           uint64(v.MaxValueSize),
+			CollateralPercentage:   uint64(v.CollateralPercentage),
+			MaxCollateralInputs:    uint64(v.MaxCollateralInputs),
+		}, nil
+	case *conway.ConwayProtocolParameters:
+		pt, dt := &v.PoolVotingThresholds, &v.DRepVotingThresholds
+		return &PParams{
+			TxFeePerByte:           uint64(v.MinFeeA),
+			TxFeeFixed:             uint64(v.MinFeeB),
+			MaxBlockBodySize:       uint64(v.MaxBlockBodySize),
+			MaxTxSize:              uint64(v.MaxTxSize),
+			MaxBlockHeaderSize:     uint64(v.MaxBlockHeaderSize),
+			StakeAddressDeposit:    uint64(v.KeyDeposit),
+			StakePoolDeposit:       uint64(v.PoolDeposit),
+			PoolRetireMaxEpoch:     uint64(v.MaxEpoch),
+			StakePoolTargetNum:     uint64(v.NOpt),
+			PoolPledgeInfluence:    fromLedgerRat(v.A0),
+			MonetaryExpansion:      fromLedgerRat(v.Rho),
+			TreasuryCut:            fromLedgerRat(v.Tau),
+			ProtocolVersion:        ProtocolVersion{Major: uint64(v.ProtocolVersion.Major), Minor: uint64(v.ProtocolVersion.Minor)},
+			MinPoolCost:            v.MinPoolCost,
+			UtxoCostPerByte:        v.AdaPerUtxoByte,
+			CostModels:             fromLedgerCostModels(v.CostModels),
+			ExecutionUnitPrices:    fromLedgerPrices(v.ExecutionCosts),
+			MaxTxExecutionUnits:    fromLedgerExUnits(v.MaxTxExUnits),
+			MaxBlockExecutionUnits: fromLedgerExUnits(v.MaxBlockExUnits),
+			MaxValueSize:           uint64(v.MaxValueSize),
+			CollateralPercentage:   uint64(v.CollateralPercentage),
+			MaxCollateralInputs:    uint64(v.MaxCollateralInputs),
+			PoolVotingThresholds: &PoolVotingThresholds{
+				MotionNoConfidence:    fromLedgerRat(&pt.MotionNoConfidence),
+				CommitteeNormal:       fromLedgerRat(&pt.CommitteeNormal),
+				CommitteeNoConfidence: fromLedgerRat(&pt.CommitteeNoConfidence),
+				HardForkInitiation:    fromLedgerRat(&pt.HardForkInitiation),
+				PpSecurityGroup:       fromLedgerRat(&pt.PpSecurityGroup),
+			},
+			DRepVotingThresholds: &DRepVotingThresholds{
+				MotionNoConfidence:    fromLedgerRat(&dt.MotionNoConfidence),
+				CommitteeNormal:       fromLedgerRat(&dt.CommitteeNormal),
+				CommitteeNoConfidence: fromLedgerRat(&dt.CommitteeNoConfidence),
+				UpdateToConstitution:  fromLedgerRat(&dt.UpdateToConstitution),
+				HardForkInitiation:    fromLedgerRat(&dt.HardForkInitiation),
+				PpNetworkGroup:        fromLedgerRat(&dt.PpNetworkGroup),
+				PpEconomicGroup:       fromLedgerRat(&dt.PpEconomicGroup),
+				PpTechnicalGroup:      fromLedgerRat(&dt.PpTechnicalGroup),
+				PpGovGroup:            fromLedgerRat(&dt.PpGovGroup),
+				TreasuryWithdrawal:    fromLedgerRat(&dt.TreasuryWithdrawal),
+			},
+			CommitteeMinSize:           uint64(v.MinCommitteeSize),
+			CommitteeMaxTermLength:     v.CommitteeTermLimit,
+			GovActionLifetime:          v.GovActionValidityPeriod,
+			GovActionDeposit:           v.GovActionDeposit,
+			DRepDeposit:                v.DRepDeposit,
+			DRepActivity:               v.DRepInactivityPeriod,
+			MinFeeRefScriptCostPerByte: fromLedgerRat(v.MinFeeRefScriptCostPerByte),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported protocol parameters type %T", pp)
+	}
+}
+
+func fromShelley(v *shelley.ShelleyProtocolParameters) *PParams {
+	return &PParams{
+		TxFeePerByte:        uint64(v.MinFeeA),
+		TxFeeFixed:          uint64(v.MinFeeB),
+		MaxBlockBodySize:    uint64(v.MaxBlockBodySize),
+		MaxTxSize:           uint64(v.MaxTxSize),
+		MaxBlockHeaderSize:  uint64(v.MaxBlockHeaderSize),
+		StakeAddressDeposit: uint64(v.KeyDeposit),
+		StakePoolDeposit:    uint64(v.PoolDeposit),
+		PoolRetireMaxEpoch:  uint64(v.MaxEpoch),
+		StakePoolTargetNum:  uint64(v.NOpt),
+		PoolPledgeInfluence: fromLedgerRat(v.A0),
+		MonetaryExpansion:   fromLedgerRat(v.Rho),
+		TreasuryCut:         fromLedgerRat(v.Tau),
+		ProtocolVersion:     ProtocolVersion{Major: uint64(v.ProtocolMajor), Minor: uint64(v.ProtocolMinor)},
+		MinUTxOValue:        uint64(v.MinUtxoValue),
+	}
+}
+
+func fromLedgerRat(r *cbor.Rat) *Rational {
+	if r == nil || r.Rat == nil {
+		return nil
+	}
+	return &Rational{Rat: r.Rat}
+}
+
+// fromLedgerCostModels converts cost models keyed by the ledger's language ids, 0 for PlutusV1.
+func fromLedgerCostModels(models map[uint][]int64) CostModels {
+	if len(models) == 0 {
+		return nil
+	}
+	res := make(CostModels)
+	for lang, values := range models {
+		if int(lang) < len(languages) {
+			res[languages[lang]] = values
+		}
+	}
+	return res
+}
+
+func fromLedgerPrices(prices common.ExUnitPrice) *ExecutionUnitPrices {
+	return &ExecutionUnitPrices{
+		PriceMemory: fromLedgerRat(prices.MemPrice),
+		PriceSteps:  fromLedgerRat(prices.StepPrice),
+	}
+}
+
+func fromLedgerExUnits(units common.ExUnit) *ExUnits {
+	return &ExUnits{Memory: uint64(units.Mem), Steps: uint64(units.Steps)}
+}
+
+// Cache holds the protocol parameters of the epoch they were fetched in. Parameter updates only take effect
+// at epoch boundaries, so the parameters are fetched again once the epoch changes.
+type Cache struct {
+	mu           sync.Mutex
+	fetch        func() (*PParams, error)
+	epoch        func() (uint64, error)
+	params       *PParams
+	fetchedEpoch uint64
+}
+
+// NewCache returns a Cache loading parameters with fetch and learning the current epoch from epoch.
+func NewCache(fetch func() (*PParams, error), epoch func() (uint64, error)) *Cache {
+	return &Cache{fetch: fetch, epoch: epoch}
+}
+
+// NewNodeCache returns a Cache querying the node's parameters and epoch over local state query.
+func NewNodeCache(client *localstatequery.Client) *Cache {
+	return NewCache(func() (*PParams, error) {
+		return FromNode(client)
+	}, func() (uint64, error) {
+		epoch, err := client.GetEpochNo()
+		if err != nil {
+			return 0, fmt.Errorf("failed to query epoch: %w", err)
+		}
+		return uint64(epoch), nil
+	})
+}
+
+// Get returns the parameters of the current epoch.
+func (c *Cache) Get() (*PParams, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	epoch, err := c.epoch()
+	if err != nil {
+		return nil, err
+	}
+	if c.params != nil && c.fetchedEpoch == epoch {
+		return c.params, nil
+	}
+	params, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.params, c.fetchedEpoch = params, epoch
+	return params, nil
+}
+
+// Invalidate drops the cached parameters, for example after reconnecting to a different node.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.params = nil
+}
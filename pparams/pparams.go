@@ -0,0 +1,448 @@
+// Package pparams models protocol parameters independently of where they come from: the JSON written by
+// `cardano-cli query protocol-parameters`, the Shelley, Alonzo and Conway genesis files, or a node's local
+// state query. Parameters introduced by later eras are left empty when the source predates them.
+package pparams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
+)
+
+// PParams holds the protocol parameters of any Shelley based era. Its JSON encoding is the one of
+// `cardano-cli query protocol-parameters`.
+type PParams struct {
+	TxFeePerByte        uint64          `json:"txFeePerByte"`
+	TxFeeFixed          uint64          `json:"txFeeFixed"`
+	MaxBlockBodySize    uint64          `json:"maxBlockBodySize"`
+	MaxTxSize           uint64          `json:"maxTxSize"`
+	MaxBlockHeaderSize  uint64          `json:"maxBlockHeaderSize"`
+	StakeAddressDeposit uint64          `json:"stakeAddressDeposit"`
+	StakePoolDeposit    uint64          `json:"stakePoolDeposit"`
+	PoolRetireMaxEpoch  uint64          `json:"poolRetireMaxEpoch"`
+	StakePoolTargetNum  uint64          `json:"stakePoolTargetNum"`
+	PoolPledgeInfluence *Rational       `json:"poolPledgeInfluence"`
+	MonetaryExpansion   *Rational       `json:"monetaryExpansion"`
+	TreasuryCut         *Rational       `json:"treasuryCut"`
+	ProtocolVersion     ProtocolVersion `json:"protocolVersion"`
+	MinPoolCost         uint64          `json:"minPoolCost"`
+
+	// MinUTxOValue is only used before Alonzo, which replaced it with UtxoCostPerByte.
+	MinUTxOValue uint64 `json:"minUTxOValue,omitempty"`
+
+	// Alonzo
+	UtxoCostPerByte        uint64               `json:"utxoCostPerByte,omitempty"`
+	CostModels             CostModels           `json:"costModels,omitempty"`
+	ExecutionUnitPrices    *ExecutionUnitPrices `json:"executionUnitPrices,omitempty"`
+	MaxTxExecutionUnits    *ExUnits             `json:"maxTxExecutionUnits,omitempty"`
+	MaxBlockExecutionUnits *ExUnits             `json:"maxBlockExecutionUnits,omitempty"`
+	MaxValueSize           uint64               `json:"maxValueSize,omitempty"`
+	CollateralPercentage   uint64               `json:"collateralPercentage,omitempty"`
+	MaxCollateralInputs    uint64               `json:"maxCollateralInputs,omitempty"`
+
+	// Conway
+	PoolVotingThresholds       *PoolVotingThresholds `json:"poolVotingThresholds,omitempty"`
+	DRepVotingThresholds       *DRepVotingThresholds `json:"dRepVotingThresholds,omitempty"`
+	CommitteeMinSize           uint64                `json:"committeeMinSize,omitempty"`
+	CommitteeMaxTermLength     uint64                `json:"committeeMaxTermLength,omitempty"`
+	GovActionLifetime          uint64                `json:"govActionLifetime,omitempty"`
+	GovActionDeposit           uint64                `json:"govActionDeposit,omitempty"`
+	DRepDeposit                uint64                `json:"dRepDeposit,omitempty"`
+	DRepActivity               uint64                `json:"dRepActivity,omitempty"`
+	MinFeeRefScriptCostPerByte *Rational             `json:"minFeeRefScriptCostPerByte,omitempty"`
+}
+
+type ProtocolVersion struct {
+	Major uint64 `json:"major"`
+	Minor uint64 `json:"minor"`
+}
+
+type ExUnits struct {
+	Memory uint64 `json:"memory"`
+	Steps  uint64 `json:"steps"`
+}
+
+type ExecutionUnitPrices struct {
+	PriceMemory *Rational `json:"priceMemory"`
+	PriceSteps  *Rational `json:"priceSteps"`
+}
+
+type PoolVotingThresholds struct {
+	MotionNoConfidence    *Rational `json:"motionNoConfidence"`
+	CommitteeNormal       *Rational `json:"committeeNormal"`
+	CommitteeNoConfidence *Rational `json:"committeeNoConfidence"`
+	HardForkInitiation    *Rational `json:"hardForkInitiation"`
+	PpSecurityGroup       *Rational `json:"ppSecurityGroup"`
+}
+
+// list returns the thresholds in ledger order.
+func (t *PoolVotingThresholds) list() []*Rational {
+	return []*Rational{t.MotionNoConfidence, t.CommitteeNormal, t.CommitteeNoConfidence, t.HardForkInitiation,
+		t.PpSecurityGroup}
+}
+
+type DRepVotingThresholds struct {
+	MotionNoConfidence    *Rational `json:"motionNoConfidence"`
+	CommitteeNormal       *Rational `json:"committeeNormal"`
+	CommitteeNoConfidence *Rational `json:"committeeNoConfidence"`
+	UpdateToConstitution  *Rational `json:"updateToConstitution"`
+	HardForkInitiation    *Rational `json:"hardForkInitiation"`
+	PpNetworkGroup        *Rational `json:"ppNetworkGroup"`
+	PpEconomicGroup       *Rational `json:"ppEconomicGroup"`
+	PpTechnicalGroup      *Rational `json:"ppTechnicalGroup"`
+	PpGovGroup            *Rational `json:"ppGovGroup"`
+	TreasuryWithdrawal    *Rational `json:"treasuryWithdrawal"`
+}
+
+// list returns the thresholds in ledger order.
+func (t *DRepVotingThresholds) list() []*Rational {
+	return []*Rational{t.MotionNoConfidence, t.CommitteeNormal, t.CommitteeNoConfidence, t.UpdateToConstitution,
+		t.HardForkInitiation, t.PpNetworkGroup, t.PpEconomicGroup, t.PpTechnicalGroup, t.PpGovGroup,
+		t.TreasuryWithdrawal}
+}
+
+// Era returns the ledger era the parameters belong to, derived from the major protocol version.
+func (p *PParams) Era() ledger.Era {
+	switch major := p.ProtocolVersion.Major; {
+	case major >= 9:
+		return ledger.GetEraById(ledger.EraIdConway)
+	case major >= 7:
+		return ledger.GetEraById(ledger.EraIdBabbage)
+	case major >= 5:
+		return ledger.GetEraById(ledger.EraIdAlonzo)
+	case major == 4:
+		return ledger.GetEraById(ledger.EraIdMary)
+	case major == 3:
+		return ledger.GetEraById(ledger.EraIdAllegra)
+	case major == 2:
+		return ledger.GetEraById(ledger.EraIdShelley)
+	default:
+		return ledger.EraInvalid
+	}
+}
+
+func (p *PParams) UnmarshalJSON(data []byte) error {
+	type plain PParams
+	aux := struct {
+		*plain
+		// written instead of utxoCostPerByte in the Alonzo era
+		UtxoCostPerWord *uint64 `json:"utxoCostPerWord"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.UtxoCostPerWord != nil && p.UtxoCostPerByte == 0 {
+		p.UtxoCostPerByte = *aux.UtxoCostPerWord / 8
+	}
+	return nil
+}
+
+// Parse decodes the output of `cardano-cli query protocol-parameters`.
+func Parse(bz []byte) (*PParams, error) {
+	var p PParams
+	if err := json.Unmarshal(bz, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse protocol parameters: %w", err)
+	}
+	if p.TxFeePerByte == 0 && p.TxFeeFixed == 0 {
+		return nil, fmt.Errorf("protocol parameters lack txFeePerByte and txFeeFixed")
+	}
+	return &p, nil
+}
+
+// ReadFile reads protocol parameters written by `cardano-cli query protocol-parameters`.
+func ReadFile(path string) (*PParams, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protocol parameters: %w", err)
+	}
+	return Parse(bz)
+}
+
+// CostModels maps the Plutus language names PlutusV1, PlutusV2 and PlutusV3 to their cost model
+// parameters in ledger order.
+type CostModels map[string][]int64
+
+var languages = []string{"PlutusV1", "PlutusV2", "PlutusV3"}
+
+// UnmarshalJSON accepts cost models as arrays and, as older cardano-cli versions and the Alonzo genesis
+// write them, as objects keyed by parameter name. The ledger orders named parameters by name.
+func (c *CostModels) UnmarshalJSON(data []byte) error {
+	var models map[string]json.RawMessage
+	if err := json.Unmarshal(data, &models); err != nil {
+		return err
+	}
+	*c = make(CostModels)
+	for lang, raw := range models {
+		var values []int64
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+			var named map[string]int64
+			if err := json.Unmarshal(raw, &named); err != nil {
+				return fmt.Errorf("invalid %s cost model: %w", lang, err)
+			}
+			names := make([]string, 0, len(named))
+			for name := range named {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			for _, name := range names {
+				values = append(values, named[name])
+			}
+		} else if err := json.Unmarshal(raw, &values); err != nil {
+			return fmt.Errorf("invalid %s cost model: %w", lang, err)
+		}
+		(*c)[lang] = values
+	}
+	return nil
+}
+
+// Rational is a protocol parameter ratio. It is written as a JSON number, like cardano-cli does, and also
+// read from the {"numerator", "denominator"} objects of the Alonzo genesis.
+type Rational struct {
+	*big.Rat
+}
+
+func NewRational(num, denom int64) *Rational {
+	return &Rational{Rat: big.NewRat(num, denom)}
+}
+
+func (r *Rational) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var frac struct {
+			Numerator   int64 `json:"numerator"`
+			Denominator int64 `json:"denominator"`
+		}
+		if err := json.Unmarshal(data, &frac); err != nil {
+			return err
+		}
+		if frac.Denominator == 0 {
+			return fmt.Errorf("invalid rational %s: zero denominator", data)
+		}
+		r.Rat = big.NewRat(frac.Numerator, frac.Denominator)
+		return nil
+	}
+	rat, ok := new(big.Rat).SetString(string(data))
+	if !ok {
+		return fmt.Errorf("invalid rational %s", data)
+	}
+	r.Rat = rat
+	return nil
+}
+
+// MarshalJSON writes the exact decimal expansion of r when it has one, and the nearest float otherwise.
+func (r Rational) MarshalJSON() ([]byte, error) {
+	if r.Rat == nil {
+		return []byte("null"), nil
+	}
+	for prec := 0; prec <= 20; prec++ {
+		s := r.FloatString(prec)
+		if v, ok := new(big.Rat).SetString(s); ok && v.Cmp(r.Rat) == 0 {
+			return []byte(s), nil
+		}
+	}
+	f, _ := r.Float64()
+	return []byte(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+// Utxorpc converts the parameters to their utxorpc representation, which the tx builder consumes.
+func (p *PParams) Utxorpc() (*utxocardano.PParams, error) {
+	res := &utxocardano.PParams{
+		CoinsPerUtxoByte:         p.UtxoCostPerByte,
+		MaxTxSize:                p.MaxTxSize,
+		MinFeeCoefficient:        p.TxFeePerByte,
+		MinFeeConstant:           p.TxFeeFixed,
+		MaxBlockBodySize:         p.MaxBlockBodySize,
+		MaxBlockHeaderSize:       p.MaxBlockHeaderSize,
+		StakeKeyDeposit:          p.StakeAddressDeposit,
+		PoolDeposit:              p.StakePoolDeposit,
+		PoolRetirementEpochBound: p.PoolRetireMaxEpoch,
+		DesiredNumberOfPools:     p.StakePoolTargetNum,
+		MinPoolCost:              p.MinPoolCost,
+		ProtocolVersion: &utxocardano.ProtocolVersion{
+			Major: uint32(p.ProtocolVersion.Major),
+			Minor: uint32(p.ProtocolVersion.Minor),
+		},
+		MaxValueSize:                   p.MaxValueSize,
+		CollateralPercentage:           p.CollateralPercentage,
+		MaxCollateralInputs:            p.MaxCollateralInputs,
+		MinCommitteeSize:               uint32(p.CommitteeMinSize),
+		CommitteeTermLimit:             p.CommitteeMaxTermLength,
+		GovernanceActionValidityPeriod: p.GovActionLifetime,
+		GovernanceActionDeposit:        p.GovActionDeposit,
+		DrepDeposit:                    p.DRepDeposit,
+		DrepInactivityPeriod:           p.DRepActivity,
+	}
+	var err error
+	rationals := []struct {
+		dst **utxocardano.RationalNumber
+		src *Rational
+	}{
+		{&res.PoolInfluence, p.PoolPledgeInfluence},
+		{&res.MonetaryExpansion, p.MonetaryExpansion},
+		{&res.TreasuryExpansion, p.TreasuryCut},
+		{&res.MinFeeScriptRefCostPerByte, p.MinFeeRefScriptCostPerByte},
+	}
+	for _, r := range rationals {
+		if *r.dst, err = r.src.utxorpc(); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.CostModels) > 0 {
+		res.CostModels = &utxocardano.CostModels{}
+		for lang, values := range p.CostModels {
+			model := &utxocardano.CostModel{Values: values}
+			switch lang {
+			case "PlutusV1":
+				res.CostModels.PlutusV1 = model
+			case "PlutusV2":
+				res.CostModels.PlutusV2 = model
+			case "PlutusV3":
+				res.CostModels.PlutusV3 = model
+			}
+		}
+	}
+	if prices := p.ExecutionUnitPrices; prices != nil {
+		res.Prices = &utxocardano.ExPrices{}
+		if res.Prices.Memory, err = prices.PriceMemory.utxorpc(); err != nil {
+			return nil, err
+		}
+		if res.Prices.Steps, err = prices.PriceSteps.utxorpc(); err != nil {
+			return nil, err
+		}
+	}
+	if units := p.MaxTxExecutionUnits; units != nil {
+		res.MaxExecutionUnitsPerTransaction = &utxocardano.ExUnits{Memory: units.Memory, Steps: units.Steps}
+	}
+	if units := p.MaxBlockExecutionUnits; units != nil {
+		res.MaxExecutionUnitsPerBlock = &utxocardano.ExUnits{Memory: units.Memory, Steps: units.Steps}
+	}
+	if t := p.PoolVotingThresholds; t != nil {
+		if res.PoolVotingThresholds, err = votingThresholds(t.list()); err != nil {
+			return nil, err
+		}
+	}
+	if t := p.DRepVotingThresholds; t != nil {
+		if res.DrepVotingThresholds, err = votingThresholds(t.list()); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// FromUtxorpc converts parameters queried through utxorpc.
+func FromUtxorpc(pp *utxocardano.PParams) *PParams {
+	p := &PParams{
+		TxFeePerByte:               pp.GetMinFeeCoefficient(),
+		TxFeeFixed:                 pp.GetMinFeeConstant(),
+		MaxBlockBodySize:           pp.GetMaxBlockBodySize(),
+		MaxTxSize:                  pp.GetMaxTxSize(),
+		MaxBlockHeaderSize:         pp.GetMaxBlockHeaderSize(),
+		StakeAddressDeposit:        pp.GetStakeKeyDeposit(),
+		StakePoolDeposit:           pp.GetPoolDeposit(),
+		PoolRetireMaxEpoch:         pp.GetPoolRetirementEpochBound(),
+		StakePoolTargetNum:         pp.GetDesiredNumberOfPools(),
+		PoolPledgeInfluence:        fromUtxorpcRational(pp.GetPoolInfluence()),
+		MonetaryExpansion:          fromUtxorpcRational(pp.GetMonetaryExpansion()),
+		TreasuryCut:                fromUtxorpcRational(pp.GetTreasuryExpansion()),
+		MinPoolCost:                pp.GetMinPoolCost(),
+		UtxoCostPerByte:            pp.GetCoinsPerUtxoByte(),
+		MaxValueSize:               pp.GetMaxValueSize(),
+		CollateralPercentage:       pp.GetCollateralPercentage(),
+		MaxCollateralInputs:        pp.GetMaxCollateralInputs(),
+		CommitteeMinSize:           uint64(pp.GetMinCommitteeSize()),
+		CommitteeMaxTermLength:     pp.GetCommitteeTermLimit(),
+		GovActionLifetime:          pp.GetGovernanceActionValidityPeriod(),
+		GovActionDeposit:           pp.GetGovernanceActionDeposit(),
+		DRepDeposit:                pp.GetDrepDeposit(),
+		DRepActivity:               pp.GetDrepInactivityPeriod(),
+		MinFeeRefScriptCostPerByte: fromUtxorpcRational(pp.GetMinFeeScriptRefCostPerByte()),
+	}
+	if v := pp.GetProtocolVersion(); v != nil {
+		p.ProtocolVersion = ProtocolVersion{Major: uint64(v.GetMajor()), Minor: uint64(v.GetMinor())}
+	}
+	if models := pp.GetCostModels(); models != nil {
+		p.CostModels = make(CostModels)
+		for i, model := range []*utxocardano.CostModel{models.GetPlutusV1(), models.GetPlutusV2(), models.GetPlutusV3()} {
+			if model != nil {
+				p.CostModels[languages[i]] = model.GetValues()
+			}
+		}
+	}
+	if prices := pp.GetPrices(); prices != nil {
+		p.ExecutionUnitPrices = &ExecutionUnitPrices{
+			PriceMemory: fromUtxorpcRational(prices.GetMemory()),
+			PriceSteps:  fromUtxorpcRational(prices.GetSteps()),
+		}
+	}
+	if units := pp.GetMaxExecutionUnitsPerTransaction(); units != nil {
+		p.MaxTxExecutionUnits = &ExUnits{Memory: units.GetMemory(), Steps: units.GetSteps()}
+	}
+	if units := pp.GetMaxExecutionUnitsPerBlock(); units != nil {
+		p.MaxBlockExecutionUnits = &ExUnits{Memory: units.GetMemory(), Steps: units.GetSteps()}
+	}
+	if t := pp.GetPoolVotingThresholds().GetThresholds(); len(t) == 5 {
+		p.PoolVotingThresholds = &PoolVotingThresholds{
+			MotionNoConfidence:    fromUtxorpcRational(t[0]),
+			CommitteeNormal:       fromUtxorpcRational(t[1]),
+			CommitteeNoConfidence: fromUtxorpcRational(t[2]),
+			HardForkInitiation:    fromUtxorpcRational(t[3]),
+			PpSecurityGroup:       fromUtxorpcRational(t[4]),
+		}
+	}
+	if t := pp.GetDrepVotingThresholds().GetThresholds(); len(t) == 10 {
+		p.DRepVotingThresholds = &DRepVotingThresholds{
+			MotionNoConfidence:    fromUtxorpcRational(t[0]),
+			CommitteeNormal:       fromUtxorpcRational(t[1]),
+			CommitteeNoConfidence: fromUtxorpcRational(t[2]),
+			UpdateToConstitution:  fromUtxorpcRational(t[3]),
+			HardForkInitiation:    fromUtxorpcRational(t[4]),
+			PpNetworkGroup:        fromUtxorpcRational(t[5]),
+			PpEconomicGroup:       fromUtxorpcRational(t[6]),
+			PpTechnicalGroup:      fromUtxorpcRational(t[7]),
+			PpGovGroup:            fromUtxorpcRational(t[8]),
+			TreasuryWithdrawal:    fromUtxorpcRational(t[9]),
+		}
+	}
+	return p
+}
+
+func (r *Rational) utxorpc() (*utxocardano.RationalNumber, error) {
+	if r == nil || r.Rat == nil {
+		return nil, nil
+	}
+	num, denom := r.Num(), r.Denom()
+	if !num.IsInt64() || num.Int64() > math.MaxInt32 || num.Int64() < math.MinInt32 ||
+		!denom.IsUint64() || denom.Uint64() > math.MaxUint32 {
+		return nil, fmt.Errorf("rational %s does not fit a utxorpc rational number", r.RatString())
+	}
+	return &utxocardano.RationalNumber{Numerator: int32(num.Int64()), Denominator: uint32(denom.Uint64())}, nil
+}
+
+func fromUtxorpcRational(r *utxocardano.RationalNumber) *Rational {
+	if r == nil || r.GetDenominator() == 0 {
+		return nil
+	}
+	return NewRational(int64(r.GetNumerator()), int64(r.GetDenominator()))
+}
+
+func votingThresholds(rationals []*Rational) (*utxocardano.VotingThresholds, error) {
+	res := &utxocardano.VotingThresholds{}
+	for _, r := range rationals {
+		n, err := r.utxorpc()
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			n = &utxocardano.RationalNumber{Denominator: 1}
+		}
+		res.Thresholds = append(res.Thresholds, n)
+	}
+	return res, nil
+}
@@ -0,0 +1,192 @@
+package pparams_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	. "github.com/kocubinski/gardano/pparams"
+	"github.com/stretchr/testify/require"
+)
+
+const conwayParams = `{
+    "collateralPercentage": 150,
+    "committeeMaxTermLength": 146,
+    "committeeMinSize": 7,
+    "costModels": {
+        "PlutusV1": [100788, 420, 1],
+        "PlutusV3": [100788, 420, 1, 1]
+    },
+    "dRepActivity": 20,
+    "dRepDeposit": 500000000,
+    "dRepVotingThresholds": {
+        "committeeNoConfidence": 0.6,
+        "committeeNormal": 0.67,
+        "hardForkInitiation": 0.6,
+        "motionNoConfidence": 0.67,
+        "ppEconomicGroup": 0.67,
+        "ppGovGroup": 0.75,
+        "ppNetworkGroup": 0.67,
+        "ppTechnicalGroup": 0.67,
+        "treasuryWithdrawal": 0.67,
+        "updateToConstitution": 0.75
+    },
+    "executionUnitPrices": {
+        "priceMemory": 5.77e-2,
+        "priceSteps": 7.21e-5
+    },
+    "govActionDeposit": 100000000000,
+    "govActionLifetime": 6,
+    "maxBlockBodySize": 90112,
+    "maxBlockExecutionUnits": {
+        "memory": 62000000,
+        "steps": 20000000000
+    },
+    "maxBlockHeaderSize": 1100,
+    "maxCollateralInputs": 3,
+    "maxTxExecutionUnits": {
+        "memory": 14000000,
+        "steps": 10000000000
+    },
+    "maxTxSize": 16384,
+    "maxValueSize": 5000,
+    "minFeeRefScriptCostPerByte": 15,
+    "minPoolCost": 170000000,
+    "monetaryExpansion": 3.0e-3,
+    "poolPledgeInfluence": 0.3,
+    "poolRetireMaxEpoch": 18,
+    "poolVotingThresholds": {
+        "committeeNoConfidence": 0.51,
+        "committeeNormal": 0.51,
+        "hardForkInitiation": 0.51,
+        "motionNoConfidence": 0.51,
+        "ppSecurityGroup": 0.51
+    },
+    "protocolVersion": {
+        "major": 10,
+        "minor": 0
+    },
+    "stakeAddressDeposit": 2000000,
+    "stakePoolDeposit": 500000000,
+    "stakePoolTargetNum": 500,
+    "treasuryCut": 0.2,
+    "txFeeFixed": 155381,
+    "txFeePerByte": 44,
+    "utxoCostPerByte": 4310
+}`
+
+func Test_Parse(t *testing.T) {
+	p, err := Parse([]byte(conwayParams))
+	require.NoError(t, err)
+	require.Equal(t, uint64(44), p.TxFeePerByte)
+	require.Equal(t, uint64(155381), p.TxFeeFixed)
+	require.Equal(t, ledger.EraIdConway, int(p.Era().Id))
+	require.Equal(t, big.NewRat(577, 10000), p.ExecutionUnitPrices.PriceMemory.Rat)
+	require.Equal(t, big.NewRat(3, 4), p.DRepVotingThresholds.PpGovGroup.Rat)
+
+	pp, err := p.Utxorpc()
+	require.NoError(t, err)
+	require.Equal(t, uint64(44), pp.MinFeeCoefficient)
+	require.Equal(t, uint64(155381), pp.MinFeeConstant)
+	require.Equal(t, uint64(4310), pp.CoinsPerUtxoByte)
+	require.Equal(t, int32(721), pp.Prices.Steps.Numerator)
+	require.Equal(t, uint32(10000000), pp.Prices.Steps.Denominator)
+	require.Equal(t, []int64{100788, 420, 1, 1}, pp.CostModels.PlutusV3.Values)
+	require.Nil(t, pp.CostModels.PlutusV2)
+	require.Len(t, pp.DrepVotingThresholds.Thresholds, 10)
+	require.Equal(t, FromUtxorpc(pp), p)
+
+	// the JSON encoding is the one it was read from
+	bz, err := json.Marshal(p)
+	require.NoError(t, err)
+	require.JSONEq(t, conwayParams, string(bz))
+}
+
+func Test_ParseAlonzo(t *testing.T) {
+	p, err := Parse([]byte(`{
+		"txFeePerByte": 44,
+		"txFeeFixed": 155381,
+		"utxoCostPerWord": 34482,
+		"costModels": {"PlutusV1": {"b-cpu": 2, "a-cpu": 1}},
+		"protocolVersion": {"major": 6, "minor": 0}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, ledger.EraIdAlonzo, int(p.Era().Id))
+	require.Equal(t, uint64(4310), p.UtxoCostPerByte)
+	require.Equal(t, []int64{1, 2}, p.CostModels["PlutusV1"])
+
+	_, err = Parse([]byte(`{"protocolVersion": {"major": 10, "minor": 0}}`))
+	require.Error(t, err)
+}
+
+func Test_FromGenesis(t *testing.T) {
+	shelley := []byte(`{
+		"systemStart": "2024-01-01T00:00:00Z",
+		"protocolParams": {
+			"minFeeA": 44,
+			"minFeeB": 155381,
+			"maxTxSize": 16384,
+			"keyDeposit": 2000000,
+			"a0": 0.3,
+			"rho": 0.003,
+			"tau": 0.2,
+			"protocolVersion": {"major": 2, "minor": 0},
+			"minUTxOValue": 1000000
+		}
+	}`)
+	p, err := FromGenesis(shelley, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, ledger.EraIdShelley, int(p.Era().Id))
+	require.Equal(t, uint64(1000000), p.MinUTxOValue)
+	require.Equal(t, big.NewRat(3, 1000), p.MonetaryExpansion.Rat)
+
+	alonzo, err := os.ReadFile("../scripts/alonzo-babbage-test-genesis.json")
+	require.NoError(t, err)
+	conway, err := os.ReadFile("../scripts/conway-babbage-test-genesis.json")
+	require.NoError(t, err)
+	p, err = FromGenesis(shelley, alonzo, conway)
+	require.NoError(t, err)
+	require.Equal(t, ledger.EraIdConway, int(p.Era().Id))
+	require.Equal(t, uint64(44), p.TxFeePerByte)
+	require.Zero(t, p.MinUTxOValue)
+	require.Equal(t, uint64(34482/8), p.UtxoCostPerByte)
+	require.Equal(t, big.NewRat(721, 10000000), p.ExecutionUnitPrices.PriceSteps.Rat)
+	require.Equal(t, uint64(16000000), p.MaxTxExecutionUnits.Memory)
+	require.Equal(t, uint64(2000000), p.DRepDeposit)
+	require.Equal(t, big.NewRat(51, 100), p.PoolVotingThresholds.PpSecurityGroup.Rat)
+	require.Contains(t, p.CostModels, "PlutusV1")
+	require.Contains(t, p.CostModels, "PlutusV3")
+	_, err = p.Utxorpc()
+	require.NoError(t, err)
+}
+
+func Test_Cache(t *testing.T) {
+	epoch := uint64(5)
+	fetches := 0
+	c := NewCache(func() (*PParams, error) {
+		fetches++
+		return &PParams{TxFeePerByte: uint64(fetches)}, nil
+	}, func() (uint64, error) {
+		return epoch, nil
+	})
+
+	p, err := c.Get()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), p.TxFeePerByte)
+	_, err = c.Get()
+	require.NoError(t, err)
+	require.Equal(t, 1, fetches)
+
+	// crossing the epoch boundary fetches again
+	epoch++
+	p, err = c.Get()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), p.TxFeePerByte)
+
+	c.Invalidate()
+	p, err = c.Get()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), p.TxFeePerByte)
+}
@@ -0,0 +1,257 @@
+package kupo
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// Client implements provider.Provider against the Kupo HTTP API, see https://cardanosolutions.github.io/kupo
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+var _ provider.Provider = (*Client)(nil)
+
+type ClientOption func(*Client)
+
+// WithHTTPClient overrides the http.Client used for requests.
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(k *Client) {
+		k.httpClient = c
+	}
+}
+
+// NewClient returns a Kupo client for the server at baseURL, e.g. http://localhost:1442
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type match struct {
+	TransactionId string `json:"transaction_id"`
+	OutputIndex   uint16 `json:"output_index"`
+	Address       string `json:"address"`
+	Value         struct {
+		Coins  uint64            `json:"coins"`
+		Assets map[string]uint64 `json:"assets"`
+	} `json:"value"`
+	DatumHash  *string `json:"datum_hash"`
+	DatumType  string  `json:"datum_type"`
+	ScriptHash *string `json:"script_hash"`
+	CreatedAt  point   `json:"created_at"`
+	SpentAt    *point  `json:"spent_at"`
+}
+
+type point struct {
+	SlotNo     uint64 `json:"slot_no"`
+	HeaderHash string `json:"header_hash"`
+}
+
+// UTxOsByAddress returns the unspent outputs at addr.
+func (c *Client) UTxOsByAddress(ctx context.Context, addr address.Address) ([]tx.TxInput, error) {
+	return c.matches(ctx, addr.String(), newResolver(c))
+}
+
+// UTxOsByTxIn resolves the unspent outputs referenced by txIns.
+func (c *Client) UTxOsByTxIn(ctx context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	var res []tx.TxInput
+	r := newResolver(c)
+	for _, txIn := range txIns {
+		ins, err := c.matches(ctx, fmt.Sprintf("%d@%x", txIn.Index, txIn.TxHash), r)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ins...)
+	}
+	return res, nil
+}
+
+// Tip returns the most recent checkpoint Kupo has indexed.
+func (c *Client) Tip(ctx context.Context) (common.Point, error) {
+	var checkpoints []point
+	if err := c.get(ctx, "/checkpoints", &checkpoints); err != nil {
+		return common.Point{}, err
+	}
+	if len(checkpoints) == 0 {
+		return common.Point{}, fmt.Errorf("kupo has no checkpoints: %w", provider.ErrNotFound)
+	}
+	// checkpoints are sorted in descending slot order
+	return checkpoints[0].toPoint()
+}
+
+// Datum returns the CBOR encoded datum with the given hash.
+func (c *Client) Datum(ctx context.Context, datumHash []byte) ([]byte, error) {
+	var res *struct {
+		Datum string `json:"datum"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/datums/%x", datumHash), &res); err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, fmt.Errorf("datum %x: %w", datumHash, provider.ErrNotFound)
+	}
+	return hex.DecodeString(res.Datum)
+}
+
+// Script returns the language and serialized script with the given hash.
+func (c *Client) Script(ctx context.Context, scriptHash []byte) (language string, script []byte, err error) {
+	var res *struct {
+		Language string `json:"language"`
+		Script   string `json:"script"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/scripts/%x", scriptHash), &res); err != nil {
+		return "", nil, err
+	}
+	if res == nil {
+		return "", nil, fmt.Errorf("script %x: %w", scriptHash, provider.ErrNotFound)
+	}
+	script, err = hex.DecodeString(res.Script)
+	return res.Language, script, err
+}
+
+func (c *Client) matches(ctx context.Context, pattern string, r *resolver) ([]tx.TxInput, error) {
+	var matches []match
+	if err := c.get(ctx, "/matches/"+url.PathEscape(pattern)+"?unspent", &matches); err != nil {
+		return nil, err
+	}
+	res := make([]tx.TxInput, 0, len(matches))
+	for _, m := range matches {
+		txIn, err := r.toTxInput(ctx, m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert match %d@%s: %w", m.OutputIndex, m.TransactionId, err)
+		}
+		res = append(res, txIn)
+	}
+	return res, nil
+}
+
+// resolver converts matches to inputs, looking up each datum and script once however many outputs hold
+// it, as Kupo has no endpoint resolving several at a time.
+type resolver struct {
+	client  *Client
+	datums  map[string][]byte
+	scripts map[string][]byte
+}
+
+func newResolver(c *Client) *resolver {
+	return &resolver{
+		client:  c,
+		datums:  make(map[string][]byte),
+		scripts: make(map[string][]byte),
+	}
+}
+
+// datum returns the datum with the given hash, or nil when Kupo hasn't seen it in any transaction, as
+// happens for outputs only holding the hash of a datum which was never revealed.
+func (r *resolver) datum(ctx context.Context, hash []byte) ([]byte, error) {
+	key := string(hash)
+	if datum, ok := r.datums[key]; ok {
+		return datum, nil
+	}
+	datum, err := r.client.Datum(ctx, hash)
+	if err != nil && !errors.Is(err, provider.ErrNotFound) {
+		return nil, err
+	}
+	r.datums[key] = datum
+	return datum, nil
+}
+
+func (r *resolver) script(ctx context.Context, hash []byte) ([]byte, error) {
+	key := string(hash)
+	if script, ok := r.scripts[key]; ok {
+		return script, nil
+	}
+	_, script, err := r.client.Script(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	r.scripts[key] = script
+	return script, nil
+}
+
+func (r *resolver) toTxInput(ctx context.Context, m match) (tx.TxInput, error) {
+	txIn := tx.NewTxInput(m.TransactionId, m.OutputIndex, m.Value.Coins)
+	if len(txIn.TxHash) != 32 {
+		return txIn, fmt.Errorf("invalid transaction id %q", m.TransactionId)
+	}
+	addr, err := address.NewAddressFromBech32(m.Address)
+	if err != nil {
+		return txIn, err
+	}
+	txIn.Address = addr
+
+	if len(m.Value.Assets) > 0 {
+		txIn.Assets = make(tx.MultiAsset)
+		for unit, qty := range m.Value.Assets {
+			// assets are keyed as {policy_id}.{asset_name} or {policy_id} for empty asset names
+			policyId, assetName, _ := strings.Cut(unit, ".")
+			txIn.Assets.Add(policyId, assetName, qty)
+		}
+	}
+
+	if m.DatumHash != nil {
+		if txIn.DatumHash, err = hex.DecodeString(*m.DatumHash); err != nil {
+			return txIn, err
+		}
+		if txIn.Datum, err = r.datum(ctx, txIn.DatumHash); err != nil {
+			return txIn, err
+		}
+	}
+	if m.ScriptHash != nil {
+		if txIn.ScriptHash, err = hex.DecodeString(*m.ScriptHash); err != nil {
+			return txIn, err
+		}
+		if txIn.Script, err = r.script(ctx, txIn.ScriptHash); err != nil {
+			return txIn, err
+		}
+	}
+	return txIn, nil
+}
+
+func (p point) toPoint() (common.Point, error) {
+	hash, err := hex.DecodeString(p.HeaderHash)
+	if err != nil {
+		return common.Point{}, fmt.Errorf("invalid header hash: %w", err)
+	}
+	return common.NewPoint(p.SlotNo, hash), nil
+}
+
+func (c *Client) get(ctx context.Context, path string, res any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("kupo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("kupo %s: %s: %s", path, resp.Status, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("failed to decode kupo response: %w", err)
+	}
+	return nil
+}
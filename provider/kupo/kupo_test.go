@@ -0,0 +1,169 @@
+package kupo_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/provider"
+	. "github.com/kocubinski/gardano/provider/kupo"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAddr   = "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz"
+	scriptAddr = "addr1w8phkx6acpnf78fuvxn0mkew3l0fd058hzquvz7w36x4gtcyjy7wx"
+	testTxId   = "086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab"
+	testPolicy = "1d7f33bd23d85e1a25d87d86fac4f199c3197a2f7afeb662a0f34e1e"
+	testDatum  = "d87980"
+	datumHash  = "923918e403bf43c34b4ef6b48eb2ee04babed17320d8d1b9ff9ad086e86f44ec"
+	// unknownDatumHash is the hash of a datum never revealed on chain
+	unknownDatumHash = "0000000000000000000000000000000000000000000000000000000000000001"
+	testScript       = "4e4d01000033222220051200120011"
+	scriptHash       = "67f33146617a5e61936081db3b2117cbf59bd2123748f58ac9678656"
+)
+
+// stubServer counts the datum and script lookups of the client.
+type stubServer struct {
+	*httptest.Server
+	datumLookups  atomic.Int32
+	scriptLookups atomic.Int32
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	stub := &stubServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/matches/{pattern}", func(w http.ResponseWriter, r *http.Request) {
+		_, unspent := r.URL.Query()["unspent"]
+		require.True(t, unspent)
+		switch r.PathValue("pattern") {
+		case scriptAddr:
+			// two outputs holding the same datum and script, and one whose datum is unknown
+			w.Write([]byte(`[` + scriptMatch(0, datumHash) + `,` + scriptMatch(1, datumHash) + `,` + scriptMatch(2, unknownDatumHash) + `]`))
+		case testAddr, "1@" + testTxId:
+			w.Write([]byte(`[{
+				"transaction_index": 3,
+				"transaction_id": "` + testTxId + `",
+				"output_index": 1,
+				"address": "` + testAddr + `",
+				"value": {"coins": 2500000, "assets": {"` + testPolicy + `.6e6674": 2, "` + testPolicy + `": 5}},
+				"datum_hash": "` + datumHash + `",
+				"datum_type": "inline",
+				"script_hash": null,
+				"created_at": {"slot_no": 100, "header_hash": "aa"},
+				"spent_at": null
+			}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	})
+	mux.HandleFunc("/datums/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		stub.datumLookups.Add(1)
+		if r.PathValue("hash") == datumHash {
+			w.Write([]byte(`{"datum": "` + testDatum + `"}`))
+			return
+		}
+		w.Write([]byte(`null`))
+	})
+	mux.HandleFunc("/scripts/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		stub.scriptLookups.Add(1)
+		if r.PathValue("hash") == scriptHash {
+			w.Write([]byte(`{"language": "plutus:v2", "script": "` + testScript + `"}`))
+			return
+		}
+		w.Write([]byte(`null`))
+	})
+	mux.HandleFunc("/checkpoints", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"slot_no": 200, "header_hash": "bb"}, {"slot_no": 100, "header_hash": "aa"}]`))
+	})
+	stub.Server = httptest.NewServer(mux)
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// scriptMatch returns an output of testTxId at scriptAddr holding the datum with the given hash and the
+// reference script testScript.
+func scriptMatch(index int, datum string) string {
+	return `{
+		"transaction_id": "` + testTxId + `",
+		"output_index": ` + strconv.Itoa(index) + `,
+		"address": "` + scriptAddr + `",
+		"value": {"coins": 3000000, "assets": {}},
+		"datum_hash": "` + datum + `",
+		"datum_type": "hash",
+		"script_hash": "` + scriptHash + `",
+		"created_at": {"slot_no": 100, "header_hash": "aa"},
+		"spent_at": null
+	}`
+}
+
+func Test_UTxOsByAddress(t *testing.T) {
+	srv := newStubServer(t)
+	client := NewClient(srv.URL)
+	addr, err := address.NewAddressFromBech32(testAddr)
+	require.NoError(t, err)
+
+	utxos, err := client.UTxOsByAddress(context.Background(), addr)
+	require.NoError(t, err)
+	require.Len(t, utxos, 1)
+	utxo := utxos[0]
+	require.Equal(t, testTxId, hex.EncodeToString(utxo.TxHash))
+	require.Equal(t, uint16(1), utxo.Index)
+	require.Equal(t, uint64(2500000), utxo.Amount)
+	require.True(t, addr.Equals(utxo.Address))
+	require.Equal(t, tx.MultiAsset{testPolicy: {"6e6674": 2, "": 5}}, utxo.Assets)
+	require.Equal(t, datumHash, hex.EncodeToString(utxo.DatumHash))
+	require.Equal(t, testDatum, hex.EncodeToString(utxo.Datum))
+
+	byRef, err := client.UTxOsByTxIn(context.Background(), tx.NewTxInput(testTxId, 1, 0), tx.NewTxInput(testTxId, 0, 0))
+	require.NoError(t, err)
+	require.Equal(t, utxos, byRef)
+}
+
+func Test_TipAndDatum(t *testing.T) {
+	srv := newStubServer(t)
+	client := NewClient(srv.URL)
+
+	tip, err := client.Tip(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), tip.Slot)
+	require.Equal(t, []byte{0xbb}, tip.Hash)
+
+	_, err = client.Datum(context.Background(), []byte{0x01})
+	require.True(t, errors.Is(err, provider.ErrNotFound))
+}
+
+func Test_UTxOsDatumsAndScripts(t *testing.T) {
+	srv := newStubServer(t)
+	client := NewClient(srv.URL)
+	addr, err := address.NewAddressFromBech32(scriptAddr)
+	require.NoError(t, err)
+
+	utxos, err := client.UTxOsByAddress(context.Background(), addr)
+	require.NoError(t, err)
+	require.Len(t, utxos, 3)
+	for _, utxo := range utxos {
+		require.Equal(t, scriptHash, hex.EncodeToString(utxo.ScriptHash))
+		require.Equal(t, testScript, hex.EncodeToString(utxo.Script))
+	}
+	require.Equal(t, testDatum, hex.EncodeToString(utxos[0].Datum))
+	require.Equal(t, testDatum, hex.EncodeToString(utxos[1].Datum))
+	// an unknown datum leaves only its hash
+	require.Equal(t, unknownDatumHash, hex.EncodeToString(utxos[2].DatumHash))
+	require.Nil(t, utxos[2].Datum)
+	// each datum and script is looked up once
+	require.Equal(t, int32(2), srv.datumLookups.Load())
+	require.Equal(t, int32(1), srv.scriptLookups.Load())
+
+	language, script, err := client.Script(context.Background(), []byte{0x01})
+	require.True(t, errors.Is(err, provider.ErrNotFound))
+	require.Empty(t, language)
+	require.Nil(t, script)
+}
@@ -0,0 +1,240 @@
+// Package pending lets transactions spend the outputs of earlier transactions which were submitted but are
+// not yet on chain, see Overlay.
+package pending
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// DefaultRetention is the number of blocks a transaction stays in the overlay after its inclusion, giving
+// the underlying provider time to catch up with the block.
+const DefaultRetention = 3
+
+// Overlay holds transactions which were submitted but are not yet on chain, to show UTxOs as they will be
+// once they are: the outputs they spend are hidden and the outputs they create are added, so that one
+// transaction can follow another without waiting for a block. See Apply and Provider.
+//
+// Transactions leave the overlay when their TTL passes, when Remove is called, for instance because the
+// node rejected them, or some blocks after their inclusion. Blocks are fed to RollForward and RollBackward,
+// normally from chain-sync; callers without a chain follower call Expire with the slot of the tip instead.
+type Overlay struct {
+	retain uint64
+
+	mu  sync.Mutex
+	txs []*pendingTx
+}
+
+type pendingTx struct {
+	hash    string
+	ttl     uint64
+	inputs  []string
+	outputs []tx.TxInput
+	// inBlock is the number of the block including the transaction, 0 while it is pending
+	inBlock uint64
+	// inSlot is the slot of that block
+	inSlot uint64
+}
+
+type Option func(*Overlay)
+
+// WithRetention sets the number of blocks a transaction stays in the overlay after its inclusion.
+func WithRetention(blocks uint64) Option {
+	return func(o *Overlay) {
+		o.retain = max(blocks, 1)
+	}
+}
+
+// New returns an empty Overlay.
+func New(opts ...Option) *Overlay {
+	o := &Overlay{retain: DefaultRetention}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func txInKey(hash []byte, index uint16) string {
+	return fmt.Sprintf("%x#%d", hash, index)
+}
+
+// Add records a submitted transaction. Its outputs are unspent until another added transaction spends them.
+func (o *Overlay) Add(t *tx.Tx) error {
+	hash, err := t.Hash()
+	if err != nil {
+		return fmt.Errorf("failed to hash transaction: %w", err)
+	}
+	p := &pendingTx{hash: hex.EncodeToString(hash[:]), ttl: uint64(t.Body.TTL)}
+	for _, in := range t.Body.Inputs.TxIns {
+		p.inputs = append(p.inputs, txInKey(in.TxHash, in.Index))
+	}
+	for i, out := range t.Body.Outputs {
+		utxo := tx.NewTxInput(p.hash, uint16(i), out.Amount)
+		utxo.Address = out.Address
+		p.outputs = append(p.outputs, utxo)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.txs = slices.DeleteFunc(o.txs, func(other *pendingTx) bool { return other.hash == p.hash })
+	o.txs = append(o.txs, p)
+	return nil
+}
+
+// Remove drops a transaction which will never be on chain, together with the transactions spending its
+// outputs.
+func (o *Overlay) Remove(txHash string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.remove(txHash)
+}
+
+func (o *Overlay) remove(txHash string) {
+	i := slices.IndexFunc(o.txs, func(p *pendingTx) bool { return p.hash == txHash })
+	if i < 0 {
+		return
+	}
+	removed := o.txs[i]
+	o.txs = slices.Delete(o.txs, i, i+1)
+	for _, out := range removed.outputs {
+		key := txInKey(out.TxHash, out.Index)
+		for _, p := range slices.Clone(o.txs) {
+			if slices.Contains(p.inputs, key) {
+				o.remove(p.hash)
+			}
+		}
+	}
+}
+
+// Expire drops the transactions whose TTL is at or before slot. Whether or not they made it on chain, the
+// underlying provider is the authority on their outputs from then on.
+func (o *Overlay) Expire(slot uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.txs = slices.DeleteFunc(o.txs, func(p *pendingTx) bool { return p.ttl > 0 && slot >= p.ttl })
+}
+
+// Pending returns the hashes of the transactions in the overlay, in the order they were added.
+func (o *Overlay) Pending() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var res []string
+	for _, p := range o.txs {
+		res = append(res, p.hash)
+	}
+	return res
+}
+
+// RollForward processes a block at the tip of the chain. Transactions it includes are kept for the
+// retention before they leave the overlay, and phase-2 invalid ones, which only spent their collateral,
+// are removed.
+func (o *Overlay) RollForward(block ledger.Block) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, blockTx := range block.Transactions() {
+		i := slices.IndexFunc(o.txs, func(p *pendingTx) bool { return p.hash == blockTx.Hash() })
+		if i < 0 {
+			continue
+		}
+		if !blockTx.IsValid() {
+			o.remove(blockTx.Hash())
+			continue
+		}
+		o.txs[i].inBlock = block.BlockNumber()
+		o.txs[i].inSlot = block.SlotNumber()
+	}
+	slot := block.SlotNumber()
+	o.txs = slices.DeleteFunc(o.txs, func(p *pendingTx) bool {
+		if p.inBlock > 0 {
+			return block.BlockNumber() >= p.inBlock+o.retain
+		}
+		return p.ttl > 0 && slot >= p.ttl
+	})
+	return nil
+}
+
+// RollBackward reverts all blocks after point. Transactions they included are pending again, they are back
+// in the node's mempool or will expire.
+func (o *Overlay) RollBackward(point common.Point) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, p := range o.txs {
+		if p.inBlock > 0 && p.inSlot > point.Slot {
+			p.inBlock, p.inSlot = 0, 0
+		}
+	}
+	return nil
+}
+
+// Apply returns utxos, outputs of the underlying chain state, without those spent by the transactions of
+// the overlay and with their unspent outputs, restricted to addr when it is not nil.
+func (o *Overlay) Apply(utxos []tx.TxInput, addr address.Address) []tx.TxInput {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	spent := make(map[string]bool)
+	for _, p := range o.txs {
+		for _, in := range p.inputs {
+			spent[in] = true
+		}
+	}
+	var res []tx.TxInput
+	seen := make(map[string]bool)
+	for _, utxo := range utxos {
+		key := txInKey(utxo.TxHash, utxo.Index)
+		seen[key] = true
+		if !spent[key] {
+			res = append(res, utxo)
+		}
+	}
+	for _, p := range o.txs {
+		for _, out := range p.outputs {
+			key := txInKey(out.TxHash, out.Index)
+			// the underlying provider may already have the output of an included transaction
+			if spent[key] || seen[key] || (addr != nil && !out.Address.Equals(addr)) {
+				continue
+			}
+			res = append(res, out)
+		}
+	}
+	return res
+}
+
+// Provider returns a UTxOProvider of the outputs of base with the overlay applied.
+func (o *Overlay) Provider(base provider.UTxOProvider) provider.UTxOProvider {
+	return &overlayProvider{overlay: o, base: base}
+}
+
+type overlayProvider struct {
+	overlay *Overlay
+	base    provider.UTxOProvider
+}
+
+func (p *overlayProvider) UTxOsByAddress(ctx context.Context, addr address.Address) ([]tx.TxInput, error) {
+	utxos, err := p.base.UTxOsByAddress(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return p.overlay.Apply(utxos, addr), nil
+}
+
+func (p *overlayProvider) UTxOsByTxIn(ctx context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	utxos, err := p.base.UTxOsByTxIn(ctx, txIns...)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(txIns))
+	for _, in := range txIns {
+		wanted[txInKey(in.TxHash, in.Index)] = true
+	}
+	return slices.DeleteFunc(p.overlay.Apply(utxos, nil), func(utxo tx.TxInput) bool {
+		return !wanted[txInKey(utxo.TxHash, utxo.Index)]
+	}), nil
+}
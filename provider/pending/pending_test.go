@@ -0,0 +1,178 @@
+package pending_test
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/internal/ledgertest"
+	. "github.com/kocubinski/gardano/provider/pending"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBase struct {
+	utxos []tx.TxInput
+}
+
+func (b *fakeBase) UTxOsByAddress(_ context.Context, addr address.Address) ([]tx.TxInput, error) {
+	var res []tx.TxInput
+	for _, utxo := range b.utxos {
+		if utxo.Address.Equals(addr) {
+			res = append(res, utxo)
+		}
+	}
+	return res, nil
+}
+
+func (b *fakeBase) UTxOsByTxIn(_ context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	var res []tx.TxInput
+	for _, utxo := range b.utxos {
+		for _, in := range txIns {
+			if hex.EncodeToString(in.TxHash) == hex.EncodeToString(utxo.TxHash) && in.Index == utxo.Index {
+				res = append(res, utxo)
+			}
+		}
+	}
+	return res, nil
+}
+
+var (
+	sender, _ = address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	payee, _  = address.NewAddressFromBech32("addr1v8hc0xl88ehea8698tjejhwjum87hsusdpne787znge7sps4x4v8v")
+)
+
+// payment returns a transaction spending in to pay 2 ada to payee, with the change to sender as output 1.
+func payment(in tx.TxInput, ttl uint32) (*tx.Tx, tx.TxInput) {
+	t := tx.NewTx()
+	t.AddInputs(in)
+	t.AddOutputs(tx.NewTxOutput(payee, 2_000_000), tx.NewTxOutput(sender, in.Amount-2_200_000))
+	t.Body.Fee = 200_000
+	t.Body.TTL = ttl
+	hash, _ := t.Hash()
+	change := tx.NewTxInput(hex.EncodeToString(hash[:]), 1, in.Amount-2_200_000)
+	change.Address = sender
+	return t, change
+}
+
+func hashOf(t *tx.Tx) string {
+	hash, _ := t.Hash()
+	return hex.EncodeToString(hash[:])
+}
+
+func Test_Chaining(t *testing.T) {
+	ctx := context.Background()
+	funding := tx.NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 10_000_000)
+	funding.Address = sender
+	base := &fakeBase{utxos: []tx.TxInput{funding}}
+	overlay := New(WithRetention(2))
+	p := overlay.Provider(base)
+
+	tx1, change1 := payment(funding, 100)
+	tx2, change2 := payment(change1, 100)
+	require.NoError(t, overlay.Add(tx1))
+	utxos, err := p.UTxOsByAddress(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, []tx.TxInput{change1}, utxos)
+
+	// the second payment spends the change of the first
+	require.NoError(t, overlay.Add(tx2))
+	utxos, err = p.UTxOsByAddress(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, []tx.TxInput{change2}, utxos)
+	utxos, err = p.UTxOsByTxIn(ctx, funding, change1, change2)
+	require.NoError(t, err)
+	require.Equal(t, []tx.TxInput{change2}, utxos)
+	utxos, err = p.UTxOsByAddress(ctx, payee)
+	require.NoError(t, err)
+	require.Len(t, utxos, 2)
+
+	// a rejected transaction takes the ones spending its outputs with it
+	overlay.Remove(hashOf(tx1))
+	require.Empty(t, overlay.Pending())
+	utxos, err = p.UTxOsByAddress(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, []tx.TxInput{funding}, utxos)
+
+	// included transactions stay for the retention, the underlying provider lagging behind the block
+	require.NoError(t, overlay.Add(tx1))
+	require.NoError(t, overlay.Add(tx2))
+	require.NoError(t, overlay.RollForward(ledgertest.Block{Number: 1, Txs: []ledger.Transaction{ledgertest.Tx{ID: hashOf(tx1)}}}))
+	require.NoError(t, overlay.RollForward(ledgertest.Block{Number: 2}))
+	require.NoError(t, overlay.RollBackward(common.NewPoint(5, nil)))
+	require.NoError(t, overlay.RollForward(ledgertest.Block{Number: 3}))
+	require.Equal(t, []string{hashOf(tx1), hashOf(tx2)}, overlay.Pending())
+	require.NoError(t, overlay.RollForward(ledgertest.Block{Number: 4, Txs: []ledger.Transaction{ledgertest.Tx{ID: hashOf(tx1)}}}))
+	require.NoError(t, overlay.RollForward(ledgertest.Block{Number: 5}))
+	require.NoError(t, overlay.RollForward(ledgertest.Block{Number: 6}))
+	require.Equal(t, []string{hashOf(tx2)}, overlay.Pending())
+	base.utxos = []tx.TxInput{change1}
+	utxos, err = p.UTxOsByAddress(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, []tx.TxInput{change2}, utxos)
+
+	// the overlay survives a restart
+	path := filepath.Join(t.TempDir(), "pending.json")
+	require.NoError(t, overlay.SaveFile(path))
+	loaded, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, overlay.Pending(), loaded.Pending())
+	utxos, err = loaded.Provider(base).UTxOsByAddress(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, []tx.TxInput{change2}, utxos)
+
+	// expired transactions are the underlying provider's business
+	loaded.Expire(100)
+	require.Empty(t, loaded.Pending())
+	utxos, err = loaded.Provider(base).UTxOsByAddress(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, []tx.TxInput{change1}, utxos)
+
+	empty, err := LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	require.Empty(t, empty.Pending())
+}
+
+func Test_InvalidTx(t *testing.T) {
+	funding := tx.NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 10_000_000)
+	funding.Address = sender
+	overlay := New()
+	tx1, change1 := payment(funding, 100)
+	tx2, _ := payment(change1, 100)
+	require.NoError(t, overlay.Add(tx1))
+	require.NoError(t, overlay.Add(tx2))
+
+	// a phase-2 invalid transaction creates none of its outputs
+	require.NoError(t, overlay.RollForward(ledgertest.Block{Number: 1, Txs: []ledger.Transaction{ledgertest.Tx{ID: hashOf(tx1), Invalid: true}}}))
+	require.Empty(t, overlay.Pending())
+}
+
+func Test_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+	unlock, err := Lock(path, time.Second)
+	require.NoError(t, err)
+
+	// a second process waits for the first to release the lock
+	_, err = Lock(path, 0)
+	require.ErrorContains(t, err, "locked by another process")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlock()
+	}()
+	unlockSecond, err := Lock(path, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, unlockSecond())
+
+	// the lock file outlives the lock, it does not keep the next process out
+	_, err = os.Stat(path + ".lock")
+	require.NoError(t, err)
+	unlockThird, err := Lock(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, unlockThird())
+}
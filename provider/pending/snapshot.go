@@ -0,0 +1,146 @@
+package pending
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/kocubinski/gardano/tx"
+)
+
+type snapshotOutput struct {
+	Index   uint16 `json:"index"`
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"`
+}
+
+type snapshotTx struct {
+	Hash    string           `json:"hash"`
+	TTL     uint64           `json:"ttl"`
+	Inputs  []string         `json:"inputs"`
+	Outputs []snapshotOutput `json:"outputs"`
+	InBlock uint64           `json:"in_block,omitempty"`
+	InSlot  uint64           `json:"in_slot,omitempty"`
+}
+
+// SaveFile atomically writes the transactions of the overlay to path, so that a later process can chain on
+// them.
+func (o *Overlay) SaveFile(path string) error {
+	o.mu.Lock()
+	snap := make([]snapshotTx, 0, len(o.txs))
+	for _, p := range o.txs {
+		st := snapshotTx{Hash: p.hash, TTL: p.ttl, Inputs: p.inputs, InBlock: p.inBlock, InSlot: p.inSlot}
+		for _, out := range p.outputs {
+			st.Outputs = append(st.Outputs, snapshotOutput{
+				Index:   out.Index,
+				Address: hex.EncodeToString(out.Address),
+				Amount:  out.Amount,
+			})
+		}
+		snap = append(snap, st)
+	}
+	o.mu.Unlock()
+
+	bz, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return err
+	}
+	// a transaction lost by a crash after its submission would let the next one spend its inputs again
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes the entries of dir, making a rename in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// lockRetry is how often Lock tries again to take a lock held by another process.
+const lockRetry = 100 * time.Millisecond
+
+// Lock takes an exclusive flock of path.lock, so that processes loading the file at path, adding
+// transactions and saving it again see each other's transactions. It waits up to timeout for another
+// process to release the lock, which the kernel does when that process exits, even if it crashed.
+func Lock(path string, timeout time.Duration) (unlock func() error, err error) {
+	lockPath := path + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			// the file is left in place, removing it would let a process lock a new file while another one
+			// holds the lock of the old one
+			return f.Close, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s is locked by another process", path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// LoadFile returns an Overlay with the transactions saved at path. A missing file yields an empty overlay.
+func LoadFile(path string, opts ...Option) (*Overlay, error) {
+	o := New(opts...)
+	bz, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap []snapshotTx
+	if err := json.Unmarshal(bz, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode pending transactions %s: %w", path, err)
+	}
+	for _, st := range snap {
+		p := &pendingTx{hash: st.Hash, ttl: st.TTL, inputs: st.Inputs, inBlock: st.InBlock, inSlot: st.InSlot}
+		for _, so := range st.Outputs {
+			addr, err := hex.DecodeString(so.Address)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address of pending output %s#%d: %w", st.Hash, so.Index, err)
+			}
+			utxo := tx.NewTxInput(st.Hash, so.Index, so.Amount)
+			utxo.Address = addr
+			p.outputs = append(p.outputs, utxo)
+		}
+		o.txs = append(o.txs, p)
+	}
+	return o, nil
+}
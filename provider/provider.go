@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// ErrNotFound is returned when a requested datum, script or output is unknown to the provider.
+var ErrNotFound = errors.New("not found")
+
+// UTxOProvider resolves unspent transaction outputs into inputs which can be used by tx.TxBuilder.
+type UTxOProvider interface {
+	// UTxOsByAddress returns all unspent outputs locked at addr.
+	UTxOsByAddress(ctx context.Context, addr address.Address) ([]tx.TxInput, error)
+	// UTxOsByTxIn resolves the outputs referenced by the given inputs. Inputs which are spent
+	// or unknown are omitted from the result.
+	UTxOsByTxIn(ctx context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error)
+}
+
+// TipProvider reports the point the provider's view of the chain is synchronized to.
+type TipProvider interface {
+	Tip(ctx context.Context) (common.Point, error)
+}
+
+// Provider is the set of chain queries gardano needs to build transactions.
+type Provider interface {
+	UTxOProvider
+	TipProvider
+}
@@ -0,0 +1,167 @@
+// Package utxofile reads and writes UTxO sets in the JSON format of `cardano-cli query utxo --output-json`,
+// which lets transactions be built offline from a saved snapshot.
+package utxofile
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/tx"
+)
+
+type output struct {
+	Address         string                     `json:"address"`
+	Value           map[string]json.RawMessage `json:"value"`
+	DatumHash       *string                    `json:"datumhash"`
+	InlineDatumRaw  *string                    `json:"inlineDatumRaw,omitempty"`
+	ReferenceScript json.RawMessage            `json:"referenceScript,omitempty"`
+}
+
+// Decode reads a UTxO set keyed by "txhash#index".
+func Decode(r io.Reader) ([]tx.TxInput, error) {
+	var outputs map[string]output
+	if err := json.NewDecoder(r).Decode(&outputs); err != nil {
+		return nil, fmt.Errorf("failed to decode utxo json: %w", err)
+	}
+	var res []tx.TxInput
+	for key, out := range outputs {
+		txHash, index, ok := strings.Cut(key, "#")
+		if !ok {
+			return nil, fmt.Errorf("invalid utxo key %q, expected txhash#index", key)
+		}
+		idx, err := strconv.ParseUint(index, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid utxo index in %q: %w", key, err)
+		}
+		if _, err := hex.DecodeString(txHash); err != nil || len(txHash) != 64 {
+			return nil, fmt.Errorf("invalid utxo tx hash in %q", key)
+		}
+		in := tx.NewTxInput(txHash, uint16(idx), 0)
+		if in.Address, err = address.NewAddressFromBech32(out.Address); err != nil {
+			return nil, fmt.Errorf("invalid address of %s: %w", key, err)
+		}
+		for unit, raw := range out.Value {
+			if unit == "lovelace" {
+				if err := json.Unmarshal(raw, &in.Amount); err != nil {
+					return nil, fmt.Errorf("invalid lovelace of %s: %w", key, err)
+				}
+				continue
+			}
+			var assets map[string]uint64
+			if err := json.Unmarshal(raw, &assets); err != nil {
+				return nil, fmt.Errorf("invalid assets of %s: %w", key, err)
+			}
+			if in.Assets == nil {
+				in.Assets = make(tx.MultiAsset)
+			}
+			for name, quantity := range assets {
+				in.Assets.Add(unit, name, quantity)
+			}
+		}
+		if out.DatumHash != nil {
+			if in.DatumHash, err = hex.DecodeString(*out.DatumHash); err != nil {
+				return nil, fmt.Errorf("invalid datum hash of %s: %w", key, err)
+			}
+		}
+		if out.InlineDatumRaw != nil {
+			if in.Datum, err = hex.DecodeString(*out.InlineDatumRaw); err != nil {
+				return nil, fmt.Errorf("invalid inline datum of %s: %w", key, err)
+			}
+		}
+		res = append(res, in)
+	}
+	// map order is random, keep results stable
+	slices.SortFunc(res, func(a, b tx.TxInput) int {
+		if c := bytes.Compare(a.TxHash, b.TxHash); c != 0 {
+			return c
+		}
+		return int(a.Index) - int(b.Index)
+	})
+	return res, nil
+}
+
+// Encode writes utxos in the format read by Decode.
+func Encode(w io.Writer, utxos []tx.TxInput) error {
+	outputs := make(map[string]output, len(utxos))
+	for _, in := range utxos {
+		out := output{Value: map[string]json.RawMessage{"lovelace": json.RawMessage(strconv.FormatUint(in.Amount, 10))}}
+		if len(in.Address) > 0 {
+			out.Address = in.Address.String()
+		}
+		for policyId, assets := range in.Assets {
+			bz, err := json.Marshal(assets)
+			if err != nil {
+				return err
+			}
+			out.Value[policyId] = bz
+		}
+		if len(in.DatumHash) > 0 {
+			datumHash := hex.EncodeToString(in.DatumHash)
+			out.DatumHash = &datumHash
+		}
+		if len(in.Datum) > 0 {
+			datum := hex.EncodeToString(in.Datum)
+			out.InlineDatumRaw = &datum
+		}
+		outputs[fmt.Sprintf("%x#%d", in.TxHash, in.Index)] = out
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(outputs)
+}
+
+func Load(path string) ([]tx.TxInput, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Provider serves queries from a UTxO set loaded from a file.
+type Provider struct {
+	utxos []tx.TxInput
+}
+
+var _ provider.UTxOProvider = (*Provider)(nil)
+
+func NewProvider(path string) (*Provider, error) {
+	utxos, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{utxos: utxos}, nil
+}
+
+func (p *Provider) UTxOsByAddress(_ context.Context, addr address.Address) ([]tx.TxInput, error) {
+	var res []tx.TxInput
+	for _, in := range p.utxos {
+		if in.Address.Equals(addr) {
+			res = append(res, in)
+		}
+	}
+	return res, nil
+}
+
+func (p *Provider) UTxOsByTxIn(_ context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	var res []tx.TxInput
+	for _, want := range txIns {
+		for _, in := range p.utxos {
+			if bytes.Equal(in.TxHash, want.TxHash) && in.Index == want.Index {
+				res = append(res, in)
+			}
+		}
+	}
+	return res, nil
+}
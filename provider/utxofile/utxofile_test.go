@@ -0,0 +1,74 @@
+package utxofile_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kocubinski/gardano/address"
+	. "github.com/kocubinski/gardano/provider/utxofile"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+const cardanoCliUTxOs = `{
+    "086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab#1": {
+        "address": "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz",
+        "datum": null,
+        "datumhash": null,
+        "inlineDatum": null,
+        "referenceScript": null,
+        "value": {
+            "lovelace": 1500000,
+            "1d7f33bd23d85e1a25d87d86fac4f199c3197a2f7afeb662a0f34e1e": {
+                "776f726c646d6f62696c65746f6b656e": 42
+            }
+        }
+    },
+    "086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab#0": {
+        "address": "addr1v8hc0xl88ehea8698tjejhwjum87hsusdpne787znge7sps4x4v8v",
+        "datumhash": "923918e403bf43c34b4ef6b48eb2ee04babed17320d8d1b9ff9ad086e86f44ec",
+        "value": {
+            "lovelace": 5000000
+        }
+    }
+}`
+
+func Test_UTxOFile(t *testing.T) {
+	utxos, err := Decode(strings.NewReader(cardanoCliUTxOs))
+	require.NoError(t, err)
+	require.Len(t, utxos, 2)
+	require.Equal(t, uint16(0), utxos[0].Index)
+	require.Equal(t, uint64(5000000), utxos[0].Amount)
+	require.Len(t, utxos[0].DatumHash, 32)
+	require.Equal(t, uint64(1500000), utxos[1].Amount)
+	require.Equal(t, tx.MultiAsset{
+		"1d7f33bd23d85e1a25d87d86fac4f199c3197a2f7afeb662a0f34e1e": {"776f726c646d6f62696c65746f6b656e": 42},
+	}, utxos[1].Assets)
+
+	// round trip through a file
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, utxos))
+	path := filepath.Join(t.TempDir(), "utxo.json")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	p, err := NewProvider(path)
+	require.NoError(t, err)
+
+	addr, err := address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	require.NoError(t, err)
+	byAddr, err := p.UTxOsByAddress(context.Background(), addr)
+	require.NoError(t, err)
+	require.Equal(t, utxos[1:], byAddr)
+	byTxIn, err := p.UTxOsByTxIn(context.Background(),
+		tx.NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 0),
+		tx.NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 7, 0),
+	)
+	require.NoError(t, err)
+	require.Equal(t, utxos[:1], byTxIn)
+
+	_, err = Decode(strings.NewReader(`{"nohash": {"address": "", "value": {}}}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,321 @@
+package utxorpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/provider"
+	"github.com/kocubinski/gardano/tx"
+	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query/queryconnect"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/submit"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/submit/submitconnect"
+	utxosync "github.com/utxorpc/go-codegen/utxorpc/v1alpha/sync"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/sync/syncconnect"
+	"golang.org/x/net/http2"
+)
+
+// Client implements provider.Provider on top of the UTxO RPC query, submit and sync services,
+// see https://utxorpc.org
+type Client struct {
+	httpClient  connect.HTTPClient
+	headers     http.Header
+	connectOpts []connect.ClientOption
+
+	query  queryconnect.QueryServiceClient
+	submit submitconnect.SubmitServiceClient
+	sync   syncconnect.SyncServiceClient
+}
+
+var _ provider.Provider = (*Client)(nil)
+
+type ClientOption func(*Client)
+
+// WithHTTPClient overrides the HTTP client; it must support HTTP/2 for the gRPC protocol.
+func WithHTTPClient(c connect.HTTPClient) ClientOption {
+	return func(u *Client) {
+		u.httpClient = c
+	}
+}
+
+// WithHeader adds a header to every request, e.g. an API key for a hosted endpoint.
+func WithHeader(key, value string) ClientOption {
+	return func(u *Client) {
+		u.headers.Add(key, value)
+	}
+}
+
+// WithConnectProtocol uses the Connect protocol instead of gRPC.
+func WithConnectProtocol() ClientOption {
+	return func(u *Client) {
+		u.connectOpts = nil
+	}
+}
+
+// NewClient returns a UTxO RPC client for the server at baseURL, e.g. http://localhost:50051
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{
+		headers:     make(http.Header),
+		connectOpts: []connect.ClientOption{connect.WithGRPC()},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = newHTTP2Client(strings.HasPrefix(baseURL, "http://"))
+	}
+	c.connectOpts = append(c.connectOpts, connect.WithInterceptors(headerInterceptor(c.headers)))
+	c.query = queryconnect.NewQueryServiceClient(c.httpClient, baseURL, c.connectOpts...)
+	c.submit = submitconnect.NewSubmitServiceClient(c.httpClient, baseURL, c.connectOpts...)
+	c.sync = syncconnect.NewSyncServiceClient(c.httpClient, baseURL, c.connectOpts...)
+	return c
+}
+
+func newHTTP2Client(plaintext bool) *http.Client {
+	if !plaintext {
+		return &http.Client{Transport: &http2.Transport{}}
+	}
+	// gRPC over cleartext HTTP/2 (h2c)
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func headerInterceptor(headers http.Header) connect.Interceptor {
+	return &interceptor{headers: headers}
+}
+
+type interceptor struct {
+	headers http.Header
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		for k, v := range i.headers {
+			req.Header()[k] = v
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		for k, v := range i.headers {
+			conn.RequestHeader()[k] = v
+		}
+		return conn
+	}
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// UTxOsByAddress returns the unspent outputs at addr.
+func (c *Client) UTxOsByAddress(ctx context.Context, addr address.Address) ([]tx.TxInput, error) {
+	predicate := &query.UtxoPredicate{
+		Match: &query.AnyUtxoPattern{
+			UtxoPattern: &query.AnyUtxoPattern_Cardano{
+				Cardano: &utxocardano.TxOutputPattern{
+					Address: &utxocardano.AddressPattern{ExactAddress: addr},
+				},
+			},
+		},
+	}
+	var res []tx.TxInput
+	var startToken string
+	for {
+		resp, err := c.query.SearchUtxos(ctx, connect.NewRequest(&query.SearchUtxosRequest{
+			Predicate:  predicate,
+			StartToken: startToken,
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search utxos: %w", err)
+		}
+		txIns, err := txInputsFromUtxoData(resp.Msg.Items)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, txIns...)
+		if resp.Msg.NextToken == "" {
+			return res, nil
+		}
+		startToken = resp.Msg.NextToken
+	}
+}
+
+// UTxOsByTxIn resolves the unspent outputs referenced by txIns.
+func (c *Client) UTxOsByTxIn(ctx context.Context, txIns ...tx.TxInput) ([]tx.TxInput, error) {
+	req := &query.ReadUtxosRequest{}
+	for _, txIn := range txIns {
+		req.Keys = append(req.Keys, &query.TxoRef{Hash: txIn.TxHash, Index: uint32(txIn.Index)})
+	}
+	resp, err := c.query.ReadUtxos(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read utxos: %w", err)
+	}
+	return txInputsFromUtxoData(resp.Msg.Items)
+}
+
+// Tip returns the current tip of the chain.
+func (c *Client) Tip(ctx context.Context) (common.Point, error) {
+	resp, err := c.sync.ReadTip(ctx, connect.NewRequest(&utxosync.ReadTipRequest{}))
+	if err != nil {
+		return common.Point{}, fmt.Errorf("failed to read tip: %w", err)
+	}
+	if resp.Msg.Tip == nil {
+		return common.Point{}, fmt.Errorf("tip: %w", provider.ErrNotFound)
+	}
+	return common.NewPoint(resp.Msg.Tip.Index, resp.Msg.Tip.Hash), nil
+}
+
+// ProtocolParams returns the current protocol parameters.
+func (c *Client) ProtocolParams(ctx context.Context) (*utxocardano.PParams, error) {
+	resp, err := c.query.ReadParams(ctx, connect.NewRequest(&query.ReadParamsRequest{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read params: %w", err)
+	}
+	pparams := resp.Msg.GetValues().GetCardano()
+	if pparams == nil {
+		return nil, fmt.Errorf("cardano params: %w", provider.ErrNotFound)
+	}
+	return pparams, nil
+}
+
+// SubmitTx submits a CBOR encoded transaction and returns its id.
+func (c *Client) SubmitTx(ctx context.Context, txBz []byte) ([]byte, error) {
+	resp, err := c.submit.SubmitTx(ctx, connect.NewRequest(&submit.SubmitTxRequest{
+		Tx: []*submit.AnyChainTx{{Type: &submit.AnyChainTx_Raw{Raw: txBz}}},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit tx: %w", err)
+	}
+	if len(resp.Msg.Ref) != 1 {
+		return nil, fmt.Errorf("expected 1 tx ref, got %d", len(resp.Msg.Ref))
+	}
+	return resp.Msg.Ref[0], nil
+}
+
+// WaitForTx blocks until the transaction with id txHash reaches stage, or ctx is done.
+func (c *Client) WaitForTx(ctx context.Context, txHash []byte, stage submit.Stage) error {
+	stream, err := c.submit.WaitForTx(ctx, connect.NewRequest(&submit.WaitForTxRequest{Ref: [][]byte{txHash}}))
+	if err != nil {
+		return fmt.Errorf("failed to wait for tx: %w", err)
+	}
+	defer stream.Close()
+	for stream.Receive() {
+		if stream.Msg().Stage >= stage {
+			return nil
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("failed to wait for tx %s: %w", hex.EncodeToString(txHash), err)
+	}
+	return fmt.Errorf("stream closed before tx %s reached %s", hex.EncodeToString(txHash), stage)
+}
+
+// FollowEvent is a single chain event received by FollowTip. Exactly one of Apply, Undo and Reset is set.
+type FollowEvent struct {
+	Apply       *utxocardano.Block
+	Undo        *utxocardano.Block
+	Reset       *common.Point
+	NativeBytes []byte
+}
+
+// ErrStopFollowing can be returned from a FollowTip handler to end the stream without an error.
+var ErrStopFollowing = errors.New("stop following")
+
+// FollowTip streams chain events starting from the first intersecting point, or from the tip if
+// intersect is empty, until the handler returns an error or ctx is done.
+func (c *Client) FollowTip(ctx context.Context, intersect []common.Point, handler func(FollowEvent) error) error {
+	req := &utxosync.FollowTipRequest{}
+	for _, p := range intersect {
+		req.Intersect = append(req.Intersect, &utxosync.BlockRef{Index: p.Slot, Hash: p.Hash})
+	}
+	stream, err := c.sync.FollowTip(ctx, connect.NewRequest(req))
+	if err != nil {
+		return fmt.Errorf("failed to follow tip: %w", err)
+	}
+	defer stream.Close()
+	for stream.Receive() {
+		var ev FollowEvent
+		switch action := stream.Msg().Action.(type) {
+		case *utxosync.FollowTipResponse_Apply:
+			ev.Apply = action.Apply.GetCardano()
+			ev.NativeBytes = action.Apply.NativeBytes
+		case *utxosync.FollowTipResponse_Undo:
+			ev.Undo = action.Undo.GetCardano()
+			ev.NativeBytes = action.Undo.NativeBytes
+		case *utxosync.FollowTipResponse_Reset_:
+			p := common.NewPoint(action.Reset_.Index, action.Reset_.Hash)
+			ev.Reset = &p
+		default:
+			continue
+		}
+		if err := handler(ev); err != nil {
+			if errors.Is(err, ErrStopFollowing) {
+				return nil
+			}
+			return err
+		}
+	}
+	return stream.Err()
+}
+
+func txInputsFromUtxoData(items []*query.AnyUtxoData) ([]tx.TxInput, error) {
+	res := make([]tx.TxInput, 0, len(items))
+	for _, item := range items {
+		out := item.GetCardano()
+		if out == nil || item.TxoRef == nil {
+			return nil, fmt.Errorf("utxo is missing cardano output or reference")
+		}
+		res = append(res, TxInputFromOutput(item.TxoRef.Hash, uint16(item.TxoRef.Index), out))
+	}
+	return res, nil
+}
+
+// TxInputFromOutput converts a UTxO RPC output into a tx.TxInput referencing it.
+func TxInputFromOutput(txHash []byte, index uint16, out *utxocardano.TxOutput) tx.TxInput {
+	txIn := tx.TxInput{
+		TxHash:  txHash,
+		Index:   index,
+		Amount:  out.Coin,
+		Address: address.Address(out.Address),
+	}
+	for _, ma := range out.Assets {
+		if txIn.Assets == nil {
+			txIn.Assets = make(tx.MultiAsset)
+		}
+		for _, asset := range ma.Assets {
+			txIn.Assets.Add(hex.EncodeToString(ma.PolicyId), hex.EncodeToString(asset.Name), asset.OutputCoin)
+		}
+	}
+	if out.Datum != nil {
+		txIn.DatumHash = out.Datum.Hash
+		txIn.Datum = out.Datum.OriginalCbor
+	}
+	switch script := out.GetScript().GetScript().(type) {
+	case *utxocardano.Script_PlutusV1:
+		txIn.Script = script.PlutusV1
+	case *utxocardano.Script_PlutusV2:
+		txIn.Script = script.PlutusV2
+	case *utxocardano.Script_PlutusV3:
+		txIn.Script = script.PlutusV3
+	}
+	return txIn
+}
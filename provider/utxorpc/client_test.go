@@ -0,0 +1,76 @@
+package utxorpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/kocubinski/gardano/address"
+	. "github.com/kocubinski/gardano/provider/utxorpc"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query/queryconnect"
+)
+
+type stubQuery struct {
+	queryconnect.UnimplementedQueryServiceHandler
+	addr address.Address
+}
+
+func (s *stubQuery) SearchUtxos(
+	ctx context.Context,
+	req *connect.Request[query.SearchUtxosRequest],
+) (*connect.Response[query.SearchUtxosResponse], error) {
+	exact := req.Msg.Predicate.Match.GetCardano().Address.ExactAddress
+	if !s.addr.Equals(exact) {
+		return connect.NewResponse(&query.SearchUtxosResponse{}), nil
+	}
+	out := &utxocardano.TxOutput{
+		Address: exact,
+		Coin:    2500000,
+		Assets: []*utxocardano.Multiasset{{
+			PolicyId: []byte{0x01, 0x02},
+			Assets:   []*utxocardano.Asset{{Name: []byte("nft"), OutputCoin: 1}},
+		}},
+		Datum: &utxocardano.Datum{Hash: []byte{0xaa}, OriginalCbor: []byte{0xd8, 0x79, 0x80}},
+	}
+	resp := &query.SearchUtxosResponse{}
+	// serve one item per page to exercise pagination
+	if req.Msg.StartToken == "" {
+		resp.Items = []*query.AnyUtxoData{{
+			TxoRef:      &query.TxoRef{Hash: []byte{0x10}, Index: 0},
+			ParsedState: &query.AnyUtxoData_Cardano{Cardano: out},
+		}}
+		resp.NextToken = "page2"
+	} else {
+		resp.Items = []*query.AnyUtxoData{{
+			TxoRef:      &query.TxoRef{Hash: []byte{0x11}, Index: 3},
+			ParsedState: &query.AnyUtxoData_Cardano{Cardano: out},
+		}}
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func Test_UTxOsByAddress(t *testing.T) {
+	addr, err := address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.Handle(queryconnect.NewQueryServiceHandler(&stubQuery{addr: addr}))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	client := NewClient(srv.URL, WithConnectProtocol(), WithHTTPClient(srv.Client()))
+	utxos, err := client.UTxOsByAddress(context.Background(), addr)
+	require.NoError(t, err)
+	require.Len(t, utxos, 2)
+	require.Equal(t, []byte{0x11}, utxos[1].TxHash)
+	require.Equal(t, uint16(3), utxos[1].Index)
+	require.Equal(t, uint64(2500000), utxos[0].Amount)
+	require.True(t, addr.Equals(utxos[0].Address))
+	require.Equal(t, tx.MultiAsset{"0102": {"6e6674": 1}}, utxos[0].Assets)
+	require.Equal(t, []byte{0xd8, 0x79, 0x80}, utxos[0].Datum)
+}
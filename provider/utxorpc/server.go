@@ -0,0 +1,533 @@
+package utxorpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"connectrpc.com/connect"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/chainsync"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxmonitor"
+	"github.com/kocubinski/gardano/supervisor"
+	"github.com/kocubinski/gardano/tx"
+	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query/queryconnect"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/submit"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/submit/submitconnect"
+	utxosync "github.com/utxorpc/go-codegen/utxorpc/v1alpha/sync"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/sync/syncconnect"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Dialer opens a new node-to-client connection configured with opts. Errors are reported on the
+// connection's ErrorChan, which the Server consumes.
+type Dialer func(opts ...ouroboros.ConnectionOptionFunc) (*ouroboros.Connection, error)
+
+// Server exposes a node-to-client connection through the UTxO RPC query, submit and sync services.
+// Queries and submissions share one connection, which is redialed once it fails, streaming calls open a
+// connection each.
+type Server struct {
+	queryconnect.UnimplementedQueryServiceHandler
+	submitconnect.UnimplementedSubmitServiceHandler
+	syncconnect.UnimplementedSyncServiceHandler
+
+	dial Dialer
+	log  *slog.Logger
+	// pool holds the shared connection, used by one call at a time
+	pool *supervisor.Pool[*ouroboros.Connection]
+}
+
+// NewServer dials the shared query connection and returns a Server. opts set the backoff of redialing it,
+// which gives up after 3 attempts by default.
+func NewServer(dial Dialer, log *slog.Logger, opts ...supervisor.Option) (*Server, error) {
+	s := &Server{
+		dial: dial,
+		log:  log,
+		pool: supervisor.NewPool(1, func(context.Context) (*ouroboros.Connection, error) {
+			return dial(ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig()))
+		}, append([]supervisor.Option{supervisor.WithLogger(log), supervisor.WithMaxAttempts(3)}, opts...)...),
+	}
+	if err := s.pool.Do(context.Background(), func(*ouroboros.Connection) error { return nil }); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Handler returns an http.Handler serving all services over gRPC, gRPC-Web and Connect, including
+// cleartext HTTP/2 for gRPC clients.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(queryconnect.NewQueryServiceHandler(s))
+	mux.Handle(submitconnect.NewSubmitServiceHandler(s))
+	mux.Handle(syncconnect.NewSyncServiceHandler(s))
+	return h2c.NewHandler(mux, &http2.Server{})
+}
+
+// Close closes the shared node connection.
+func (s *Server) Close() error {
+	return s.pool.Close()
+}
+
+// do runs fn with the shared connection, redialing it first if it failed.
+func (s *Server) do(ctx context.Context, fn func(conn *ouroboros.Connection) error) error {
+	err := s.pool.Do(ctx, fn)
+	var connectErr *connect.Error
+	if err != nil && !errors.As(err, &connectErr) {
+		return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to connect to node: %w", err))
+	}
+	return err
+}
+
+// acquire re-acquires the volatile tip so queries see the current ledger state.
+func acquire(conn *ouroboros.Connection) (*localstatequery.Client, *query.ChainPoint, error) {
+	lsq := conn.LocalStateQuery().Client
+	if err := lsq.AcquireVolatileTip(); err != nil {
+		return nil, nil, connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to acquire ledger state: %w", err))
+	}
+	point, err := lsq.GetChainPoint()
+	if err != nil {
+		return nil, nil, connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to get chain point: %w", err))
+	}
+	return lsq, &query.ChainPoint{Slot: point.Slot, Hash: point.Hash}, nil
+}
+
+func (s *Server) ReadParams(
+	ctx context.Context,
+	req *connect.Request[query.ReadParamsRequest],
+) (*connect.Response[query.ReadParamsResponse], error) {
+	var resp *query.ReadParamsResponse
+	err := s.do(ctx, func(conn *ouroboros.Connection) error {
+		lsq, tip, err := acquire(conn)
+		if err != nil {
+			return err
+		}
+		pparams, err := lsq.GetCurrentProtocolParams()
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to get protocol parameters: %w", err))
+		}
+		resp = &query.ReadParamsResponse{
+			Values:    &query.AnyChainParams{Params: &query.AnyChainParams_Cardano{Cardano: pparams.Utxorpc()}},
+			LedgerTip: tip,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) ReadUtxos(
+	ctx context.Context,
+	req *connect.Request[query.ReadUtxosRequest],
+) (*connect.Response[query.ReadUtxosResponse], error) {
+	var txIns []ledger.TransactionInput
+	for _, ref := range req.Msg.Keys {
+		txIns = append(txIns, ledger.NewShelleyTransactionInput(hex.EncodeToString(ref.Hash), int(ref.Index)))
+	}
+	var resp *query.ReadUtxosResponse
+	err := s.do(ctx, func(conn *ouroboros.Connection) error {
+		lsq, tip, err := acquire(conn)
+		if err != nil {
+			return err
+		}
+		res, err := lsq.GetUTxOByTxIn(txIns)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to get utxos: %w", err))
+		}
+		resp = &query.ReadUtxosResponse{
+			Items:     utxoData(res.Results, nil),
+			LedgerTip: tip,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// SearchUtxos supports predicates matching an exact address, optionally restricted to an asset.
+func (s *Server) SearchUtxos(
+	ctx context.Context,
+	req *connect.Request[query.SearchUtxosRequest],
+) (*connect.Response[query.SearchUtxosResponse], error) {
+	pattern := req.Msg.GetPredicate().GetMatch().GetCardano()
+	if pattern.GetAddress().GetExactAddress() == nil || len(req.Msg.Predicate.Not)+len(req.Msg.Predicate.AllOf)+len(req.Msg.Predicate.AnyOf) > 0 {
+		return nil, connect.NewError(connect.CodeUnimplemented, errors.New("only exact address predicates are supported"))
+	}
+	addr, err := ledgerAddress(pattern.Address.ExactAddress)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	var resp *query.SearchUtxosResponse
+	err = s.do(ctx, func(conn *ouroboros.Connection) error {
+		lsq, tip, err := acquire(conn)
+		if err != nil {
+			return err
+		}
+		res, err := lsq.GetUTxOByAddress([]ledger.Address{addr})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to get utxos: %w", err))
+		}
+		resp = &query.SearchUtxosResponse{
+			Items:     utxoData(res.Results, pattern.Asset),
+			LedgerTip: tip,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// SubmitTx submits the transactions in order, after checking that they all decode. When the node rejects
+// one, the error details hold a SubmitTxResponse with the refs of the transactions submitted before it.
+func (s *Server) SubmitTx(
+	ctx context.Context,
+	req *connect.Request[submit.SubmitTxRequest],
+) (*connect.Response[submit.SubmitTxResponse], error) {
+	hashes := make([][]byte, len(req.Msg.Tx))
+	for i, anyTx := range req.Msg.Tx {
+		hash, err := tx.HashFromBytes(anyTx.GetRaw())
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("tx %d: %w", i, err))
+		}
+		hashes[i] = hash[:]
+	}
+	resp := &submit.SubmitTxResponse{}
+	err := s.do(ctx, func(conn *ouroboros.Connection) error {
+		era, err := conn.LocalStateQuery().Client.GetCurrentEra()
+		if err != nil {
+			return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to get current era: %w", err))
+		}
+		for i, anyTx := range req.Msg.Tx {
+			if err := conn.LocalTxSubmission().Client.SubmitTx(uint16(era), anyTx.GetRaw()); err != nil {
+				connectErr := connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("tx %x rejected: %w", hashes[i], err))
+				if detail, err := connect.NewErrorDetail(resp); err == nil {
+					connectErr.AddDetail(detail)
+				}
+				return connectErr
+			}
+			resp.Ref = append(resp.Ref, hashes[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// onChainOutputs is the number of outputs of each transaction WaitForTx looks up in the ledger state.
+const onChainOutputs = 16
+
+// WaitForTx reports STAGE_MEMPOOL for transactions found in the node's mempool and STAGE_CONFIRMED once
+// they are included in a block. The stream ends when all transactions are confirmed. Transactions already
+// on chain when the call starts are found through their unspent outputs among the first 16, so one whose
+// outputs are all spent is only reported again if it is in a block delivered after the call starts.
+func (s *Server) WaitForTx(
+	ctx context.Context,
+	req *connect.Request[submit.WaitForTxRequest],
+	stream *connect.ServerStream[submit.WaitForTxResponse],
+) error {
+	// mu guards pending and stream, which chain-sync shares with the checks of the current state
+	var mu sync.Mutex
+	pending := make(map[string]bool)
+	for _, ref := range req.Msg.Ref {
+		pending[string(ref)] = true
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	refs := slices.Collect(maps.Keys(pending))
+	done := make(chan error, 1)
+	finish := func(err error) {
+		select {
+		case done <- err:
+		default:
+		}
+	}
+	// confirm reports a confirmed transaction once. The caller must hold mu.
+	confirm := func(ref string) error {
+		if !pending[ref] {
+			return nil
+		}
+		if err := stream.Send(&submit.WaitForTxResponse{Ref: []byte(ref), Stage: submit.Stage_STAGE_CONFIRMED}); err != nil {
+			finish(err)
+			return err
+		}
+		delete(pending, ref)
+		if len(pending) == 0 {
+			finish(nil)
+		}
+		return nil
+	}
+	conn, err := s.dial(
+		ouroboros.WithLocalTxMonitorConfig(localtxmonitor.NewConfig()),
+		ouroboros.WithChainSyncConfig(chainsync.NewConfig(
+			chainsync.WithRollForwardFunc(func(_ chainsync.CallbackContext, _ uint, blockData any, _ chainsync.Tip) error {
+				block, ok := blockData.(ledger.Block)
+				if !ok {
+					return nil
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, blockTx := range block.Transactions() {
+					hash, err := hex.DecodeString(blockTx.Hash())
+					if err != nil {
+						continue
+					}
+					if err := confirm(string(hash)); err != nil {
+						return err
+					}
+				}
+				return nil
+			}),
+		)),
+	)
+	if err != nil {
+		return connect.NewError(connect.CodeUnavailable, err)
+	}
+	defer conn.Close()
+
+	// follow the chain before looking at the current state, so a transaction included in between is seen
+	// by one or the other
+	tip, err := conn.ChainSync().Client.GetCurrentTip()
+	if err != nil {
+		return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to get current tip: %w", err))
+	}
+	if err := conn.ChainSync().Client.Sync([]common.Point{tip.Point}); err != nil {
+		return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to start chain-sync: %w", err))
+	}
+
+	confirmed, err := s.onChain(ctx, refs)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	for _, ref := range refs {
+		if confirmed[ref] {
+			if err := confirm(ref); err != nil {
+				mu.Unlock()
+				return err
+			}
+		}
+	}
+	mu.Unlock()
+
+	monitor := conn.LocalTxMonitor().Client
+	if err := monitor.Acquire(); err != nil {
+		return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to acquire mempool snapshot: %w", err))
+	}
+	for _, ref := range refs {
+		if confirmed[ref] {
+			continue
+		}
+		inMempool, err := monitor.HasTx([]byte(ref))
+		if err != nil {
+			return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to query mempool: %w", err))
+		}
+		if !inMempool {
+			continue
+		}
+		mu.Lock()
+		if pending[ref] {
+			err = stream.Send(&submit.WaitForTxResponse{Ref: []byte(ref), Stage: submit.Stage_STAGE_MEMPOOL})
+		}
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if err := monitor.Release(); err != nil {
+		return connect.NewError(connect.CodeUnavailable, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		return err
+	case err := <-conn.ErrorChan():
+		return connect.NewError(connect.CodeUnavailable, err)
+	}
+}
+
+// onChain returns the transactions of refs with unspent outputs in the current ledger state.
+func (s *Server) onChain(ctx context.Context, refs []string) (map[string]bool, error) {
+	var txIns []ledger.TransactionInput
+	for _, ref := range refs {
+		for i := range onChainOutputs {
+			txIns = append(txIns, ledger.NewShelleyTransactionInput(hex.EncodeToString([]byte(ref)), i))
+		}
+	}
+	confirmed := make(map[string]bool)
+	err := s.do(ctx, func(conn *ouroboros.Connection) error {
+		lsq, _, err := acquire(conn)
+		if err != nil {
+			return err
+		}
+		res, err := lsq.GetUTxOByTxIn(txIns)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to get utxos: %w", err))
+		}
+		for utxoId := range res.Results {
+			confirmed[string(utxoId.Hash.Bytes())] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return confirmed, nil
+}
+
+func (s *Server) ReadTip(
+	ctx context.Context,
+	req *connect.Request[utxosync.ReadTipRequest],
+) (*connect.Response[utxosync.ReadTipResponse], error) {
+	var resp *utxosync.ReadTipResponse
+	err := s.do(ctx, func(conn *ouroboros.Connection) error {
+		_, tip, err := acquire(conn)
+		if err != nil {
+			return err
+		}
+		resp = &utxosync.ReadTipResponse{Tip: &utxosync.BlockRef{Index: tip.Slot, Hash: tip.Hash}}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// FollowTip streams blocks from the first point in the request intersecting the node's chain, or from
+// the node's tip if none are given. Rollbacks are reported as Reset actions.
+func (s *Server) FollowTip(
+	ctx context.Context,
+	req *connect.Request[utxosync.FollowTipRequest],
+	stream *connect.ServerStream[utxosync.FollowTipResponse],
+) error {
+	done := make(chan error, 1)
+	fail := func(err error) error {
+		select {
+		case done <- err:
+		default:
+		}
+		return err
+	}
+	conn, err := s.dial(ouroboros.WithChainSyncConfig(chainsync.NewConfig(
+		chainsync.WithRollForwardFunc(func(_ chainsync.CallbackContext, _ uint, blockData any, _ chainsync.Tip) error {
+			block, ok := blockData.(ledger.Block)
+			if !ok {
+				return fail(fmt.Errorf("expected full block, got %T", blockData))
+			}
+			err := stream.Send(&utxosync.FollowTipResponse{
+				Action: &utxosync.FollowTipResponse_Apply{Apply: &utxosync.AnyChainBlock{
+					NativeBytes: block.Cbor(),
+					Chain:       &utxosync.AnyChainBlock_Cardano{Cardano: block.Utxorpc()},
+				}},
+			})
+			if err != nil {
+				return fail(err)
+			}
+			return nil
+		}),
+		chainsync.WithRollBackwardFunc(func(_ chainsync.CallbackContext, point common.Point, _ chainsync.Tip) error {
+			err := stream.Send(&utxosync.FollowTipResponse{
+				Action: &utxosync.FollowTipResponse_Reset_{Reset_: &utxosync.BlockRef{Index: point.Slot, Hash: point.Hash}},
+			})
+			if err != nil {
+				return fail(err)
+			}
+			return nil
+		}),
+	)))
+	if err != nil {
+		return connect.NewError(connect.CodeUnavailable, err)
+	}
+	defer conn.Close()
+
+	var points []common.Point
+	for _, ref := range req.Msg.Intersect {
+		points = append(points, common.NewPoint(ref.Index, ref.Hash))
+	}
+	if len(points) == 0 {
+		tip, err := conn.ChainSync().Client.GetCurrentTip()
+		if err != nil {
+			return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to get current tip: %w", err))
+		}
+		points = []common.Point{tip.Point}
+	}
+	if err := conn.ChainSync().Client.Sync(points); err != nil {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("failed to find intersection: %w", err))
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-done:
+		return err
+	case err := <-conn.ErrorChan():
+		return connect.NewError(connect.CodeUnavailable, err)
+	}
+}
+
+func utxoData(results map[localstatequery.UtxoId]ledger.BabbageTransactionOutput, asset *utxocardano.AssetPattern) []*query.AnyUtxoData {
+	items := make([]*query.AnyUtxoData, 0, len(results))
+	for utxoId, txOut := range results {
+		if asset != nil && !hasAsset(txOut, asset) {
+			continue
+		}
+		items = append(items, &query.AnyUtxoData{
+			NativeBytes: txOut.Cbor(),
+			TxoRef:      &query.TxoRef{Hash: utxoId.Hash.Bytes(), Index: uint32(utxoId.Idx)},
+			ParsedState: &query.AnyUtxoData_Cardano{Cardano: txOut.Utxorpc()},
+		})
+	}
+	return items
+}
+
+func hasAsset(txOut ledger.BabbageTransactionOutput, pattern *utxocardano.AssetPattern) bool {
+	assets := txOut.Assets()
+	if assets == nil {
+		return false
+	}
+	for _, policyId := range assets.Policies() {
+		if pattern.PolicyId != nil && string(policyId.Bytes()) != string(pattern.PolicyId) {
+			continue
+		}
+		for _, name := range assets.Assets(policyId) {
+			if pattern.AssetName == nil || string(name) == string(pattern.AssetName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ledgerAddress(addrBz []byte) (ledger.Address, error) {
+	var addr ledger.Address
+	data, err := cbor.Encode(addrBz)
+	if err != nil {
+		return addr, err
+	}
+	if _, err := cbor.Decode(data, &addr); err != nil {
+		return addr, fmt.Errorf("invalid address: %w", err)
+	}
+	return addr, nil
+}
@@ -0,0 +1,320 @@
+package utxorpc_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/chainsync"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxmonitor"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxsubmission"
+	"github.com/kocubinski/gardano/address"
+	. "github.com/kocubinski/gardano/provider/utxorpc"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/query/queryconnect"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/submit"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/submit/submitconnect"
+	utxosync "github.com/utxorpc/go-codegen/utxorpc/v1alpha/sync"
+	"github.com/utxorpc/go-codegen/utxorpc/v1alpha/sync/syncconnect"
+)
+
+var nodeTip = common.NewPoint(1000, make([]byte, 32))
+
+// fakeNode serves the mini-protocols the Server uses over in-memory connections.
+type fakeNode struct {
+	t *testing.T
+
+	mu sync.Mutex
+	// utxos are the unspent outputs of the ledger state, all paying 2 ada to owner
+	utxos     []localstatequery.UtxoId
+	owner     address.Address
+	mempool   [][]byte
+	submitted [][]byte
+	// limit is the number of submissions the node accepts before rejecting the rest, unlimited when 0
+	limit int
+	// conns are the node ends of the connections dialed so far
+	conns []net.Conn
+}
+
+// kill drops every connection to the node.
+func (n *fakeNode) kill() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+}
+
+func (n *fakeNode) dial(opts ...ouroboros.ConnectionOptionFunc) (*ouroboros.Connection, error) {
+	clientConn, serverConn := net.Pipe()
+	n.mu.Lock()
+	n.conns = append(n.conns, serverConn)
+	n.mu.Unlock()
+	// both ends block on the handshake until the other one starts
+	serverErr := make(chan error, 1)
+	go func() {
+		server, err := ouroboros.NewConnection(
+			ouroboros.WithConnection(serverConn),
+			ouroboros.WithNetworkMagic(42),
+			ouroboros.WithServer(true),
+			ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig(
+				localstatequery.WithAcquireFunc(func(ctx localstatequery.CallbackContext, _ localstatequery.AcquireTarget, reacquire bool) error {
+					// the server of the library only answers first acquisitions
+					if reacquire {
+						return ctx.Server.SendMessage(localstatequery.NewMsgAcquired())
+					}
+					return nil
+				}),
+				localstatequery.WithQueryFunc(func(_ localstatequery.CallbackContext, q localstatequery.QueryWrapper) (any, error) {
+					return n.query(q.Cbor()), nil
+				}),
+				localstatequery.WithReleaseFunc(func(localstatequery.CallbackContext) error { return nil }),
+			)),
+			ouroboros.WithLocalTxSubmissionConfig(localtxsubmission.NewConfig(
+				localtxsubmission.WithSubmitTxFunc(func(_ localtxsubmission.CallbackContext, msg localtxsubmission.MsgSubmitTxTransaction) error {
+					n.mu.Lock()
+					defer n.mu.Unlock()
+					if n.limit > 0 && len(n.submitted) >= n.limit {
+						return errors.New("rejected")
+					}
+					n.submitted = append(n.submitted, msg.Raw.Content.([]byte))
+					return nil
+				}),
+			)),
+			ouroboros.WithLocalTxMonitorConfig(localtxmonitor.NewConfig(
+				localtxmonitor.WithGetMempoolFunc(func(localtxmonitor.CallbackContext) (uint64, uint32, []localtxmonitor.TxAndEraId, error) {
+					n.mu.Lock()
+					defer n.mu.Unlock()
+					var txs []localtxmonitor.TxAndEraId
+					for _, txBz := range n.mempool {
+						txs = append(txs, localtxmonitor.TxAndEraId{EraId: ledger.EraIdConway, Tx: txBz})
+					}
+					return nodeTip.Slot, 1 << 20, txs, nil
+				}),
+			)),
+			ouroboros.WithChainSyncConfig(chainsync.NewConfig(
+				chainsync.WithFindIntersectFunc(func(_ chainsync.CallbackContext, points []common.Point) (common.Point, chainsync.Tip, error) {
+					tip := chainsync.Tip{Point: nodeTip, BlockNumber: 100}
+					if len(points) == 0 {
+						return common.Point{}, tip, chainsync.IntersectNotFoundError
+					}
+					return points[0], tip, nil
+				}),
+				// no block is ever produced
+				chainsync.WithRequestNextFunc(func(chainsync.CallbackContext) error { return nil }),
+			)),
+		)
+		if err == nil {
+			n.t.Cleanup(func() { server.Close() })
+		}
+		serverErr <- err
+	}()
+	conn, err := ouroboros.NewConnection(append([]ouroboros.ConnectionOptionFunc{
+		ouroboros.WithConnection(clientConn),
+		ouroboros.WithNetworkMagic(42),
+	}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return conn, <-serverErr
+}
+
+// query answers the queries of the Server by their type.
+func (n *fakeNode) query(queryCbor []byte) any {
+	var q []any
+	_, err := cbor.Decode(queryCbor, &q)
+	require.NoError(n.t, err)
+	switch q[0] {
+	case uint64(localstatequery.QueryTypeChainPoint):
+		return nodeTip
+	case uint64(localstatequery.QueryTypeBlock):
+		inner := q[1].([]any)
+		if inner[0] == uint64(localstatequery.QueryTypeHardFork) {
+			return ledger.EraIdConway
+		}
+		// a query of the current era: [0, [era, [type, params]]]
+		shelley := inner[1].([]any)[1].([]any)
+		require.Equal(n.t, uint64(localstatequery.QueryTypeShelleyUtxoByTxin), shelley[0])
+		wanted := make(map[localstatequery.UtxoId]bool)
+		for _, txIn := range txIns(shelley[1]) {
+			in := txIn.([]any)
+			var id localstatequery.UtxoId
+			copy(id.Hash[:], in[0].([]byte))
+			id.Idx = int(in[1].(uint64))
+			wanted[id] = true
+		}
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		results := make(map[localstatequery.UtxoId]any)
+		for _, id := range n.utxos {
+			if wanted[id] {
+				results[id] = map[int]any{0: []byte(n.owner), 1: uint64(2_000_000)}
+			}
+		}
+		return []any{results}
+	}
+	n.t.Errorf("unexpected query %x", queryCbor)
+	return nil
+}
+
+// txIns returns the elements of a set of transaction inputs, which may be tagged.
+func txIns(set any) []any {
+	if tag, ok := set.(cbor.Tag); ok {
+		set = tag.Content
+	}
+	return set.([]any)
+}
+
+func serve(t *testing.T, node *fakeNode) *httptest.Server {
+	s, err := NewServer(node.dial, slog.Default())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testTx returns a transaction spending output index of a fixed transaction and its hash.
+func testTx(t *testing.T, owner address.Address, index uint16) ([]byte, []byte) {
+	txn := tx.NewTx()
+	txn.AddInputs(tx.NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", index, 10_000_000))
+	txn.AddOutputs(tx.NewTxOutput(owner, 9_800_000))
+	txn.Body.Fee = 200_000
+	txBz, err := txn.Bytes()
+	require.NoError(t, err)
+	hash, err := txn.Hash()
+	require.NoError(t, err)
+	return txBz, hash[:]
+}
+
+func Test_ServerReadUtxos(t *testing.T) {
+	owner, err := address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	require.NoError(t, err)
+	var id localstatequery.UtxoId
+	id.Hash[0], id.Idx = 0x10, 1
+	node := &fakeNode{t: t, owner: owner, utxos: []localstatequery.UtxoId{id}}
+	srv := serve(t, node)
+
+	client := queryconnect.NewQueryServiceClient(srv.Client(), srv.URL)
+	resp, err := client.ReadUtxos(context.Background(), connect.NewRequest(&query.ReadUtxosRequest{
+		Keys: []*query.TxoRef{{Hash: id.Hash[:], Index: 1}, {Hash: id.Hash[:], Index: 2}},
+	}))
+	require.NoError(t, err)
+	require.Len(t, resp.Msg.Items, 1)
+	require.Equal(t, uint32(1), resp.Msg.Items[0].TxoRef.Index)
+	require.Equal(t, uint64(2_000_000), resp.Msg.Items[0].GetCardano().Coin)
+	require.Equal(t, nodeTip.Slot, resp.Msg.LedgerTip.Slot)
+}
+
+func Test_ServerRedial(t *testing.T) {
+	owner, err := address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	require.NoError(t, err)
+	node := &fakeNode{t: t, owner: owner}
+	srv := serve(t, node)
+
+	client := syncconnect.NewSyncServiceClient(srv.Client(), srv.URL)
+	_, err = client.ReadTip(context.Background(), connect.NewRequest(&utxosync.ReadTipRequest{}))
+	require.NoError(t, err)
+
+	// calls on the dropped connection may fail until the server notices it and dials a new one
+	node.kill()
+	require.Eventually(t, func() bool {
+		_, err := client.ReadTip(context.Background(), connect.NewRequest(&utxosync.ReadTipRequest{}))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	require.Len(t, node.conns, 2)
+}
+
+func Test_ServerSubmitTx(t *testing.T) {
+	owner, err := address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	require.NoError(t, err)
+	node := &fakeNode{t: t, owner: owner}
+	srv := serve(t, node)
+	txBz, hash := testTx(t, owner, 0)
+
+	client := submitconnect.NewSubmitServiceClient(srv.Client(), srv.URL)
+	resp, err := client.SubmitTx(context.Background(), connect.NewRequest(&submit.SubmitTxRequest{
+		Tx: []*submit.AnyChainTx{{Type: &submit.AnyChainTx_Raw{Raw: txBz}}},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{hash}, resp.Msg.Ref)
+	require.Equal(t, [][]byte{txBz}, node.submitted)
+}
+
+func Test_ServerSubmitTxRejected(t *testing.T) {
+	owner, err := address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	require.NoError(t, err)
+	node := &fakeNode{t: t, owner: owner, limit: 1}
+	srv := serve(t, node)
+	txBz1, hash1 := testTx(t, owner, 0)
+	txBz2, _ := testTx(t, owner, 1)
+
+	client := submitconnect.NewSubmitServiceClient(srv.Client(), srv.URL)
+	_, err = client.SubmitTx(context.Background(), connect.NewRequest(&submit.SubmitTxRequest{
+		Tx: []*submit.AnyChainTx{{Type: &submit.AnyChainTx_Raw{Raw: txBz1}}, {Type: &submit.AnyChainTx_Raw{Raw: txBz2}}},
+	}))
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	require.Equal(t, connect.CodeFailedPrecondition, connectErr.Code())
+	require.Len(t, connectErr.Details(), 1)
+	detail, err := connectErr.Details()[0].Value()
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{hash1}, detail.(*submit.SubmitTxResponse).Ref)
+	require.Equal(t, [][]byte{txBz1}, node.submitted)
+
+	// nothing is submitted when a transaction does not decode
+	_, err = client.SubmitTx(context.Background(), connect.NewRequest(&submit.SubmitTxRequest{
+		Tx: []*submit.AnyChainTx{{Type: &submit.AnyChainTx_Raw{Raw: txBz2}}, {Type: &submit.AnyChainTx_Raw{Raw: []byte{0xff}}}},
+	}))
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	require.Equal(t, [][]byte{txBz1}, node.submitted)
+}
+
+func Test_ServerWaitForTx(t *testing.T) {
+	owner, err := address.NewAddressFromBech32("addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	require.NoError(t, err)
+	txBz, hash := testTx(t, owner, 0)
+	var id localstatequery.UtxoId
+	copy(id.Hash[:], hash)
+	node := &fakeNode{t: t, owner: owner}
+	srv := serve(t, node)
+	client := submitconnect.NewSubmitServiceClient(srv.Client(), srv.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a transaction in the mempool
+	node.mempool = [][]byte{txBz}
+	stream, err := client.WaitForTx(ctx, connect.NewRequest(&submit.WaitForTxRequest{Ref: [][]byte{hash}}))
+	require.NoError(t, err)
+	require.True(t, stream.Receive(), stream.Err())
+	require.Equal(t, submit.Stage_STAGE_MEMPOOL, stream.Msg().Stage)
+	require.Equal(t, hex.EncodeToString(hash), hex.EncodeToString(stream.Msg().Ref))
+	cancel()
+	stream.Close()
+
+	// a transaction already on chain ends the stream without a block
+	node.mempool = nil
+	node.utxos = []localstatequery.UtxoId{id}
+	stream, err = client.WaitForTx(context.Background(), connect.NewRequest(&submit.WaitForTxRequest{Ref: [][]byte{hash}}))
+	require.NoError(t, err)
+	require.True(t, stream.Receive(), stream.Err())
+	require.Equal(t, submit.Stage_STAGE_CONFIRMED, stream.Msg().Stage)
+	require.False(t, stream.Receive())
+	require.NoError(t, stream.Err())
+}
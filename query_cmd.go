@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/kocubinski/gardano/lsq"
+	"github.com/kocubinski/gardano/pparams"
+	"github.com/kocubinski/gardano/provider/utxofile"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// queryCmd runs the `query` subcommands against the node's ledger state, printing tables or, with
+// -output json, JSON.
+func queryCmd(f *cliFlags, subcommand string, parseFlags func()) error {
+	// -address names the queried addresses like cardano-cli does, so the node's TCP address is -node-address
+	f.flagset.StringVar(&f.clientAddress, "node-address", "", "TCP address for n2c communication")
+	f.flagset.StringVar(&f.clientSocket, "socket", "", "unix socket address for n2c communication")
+	f.flagset.BoolVar(&f.useTls, "tls", false, "use TLS for TCP connections")
+	f.flagset.StringVar(&f.output, "output", "text", "output format, text or json")
+	var networkMagic uint
+	f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
+
+	var run func(c *lsq.Client) error
+	switch subcommand {
+	case "utxo":
+		f.flagset.StringVar(&f.queryAddresses, "address", "", "comma separated addresses to list the UTxOs of")
+		f.flagset.StringVar(&f.txIns, "tx-in", "", "comma separated txhash#ix inputs to look up")
+		f.flagset.StringVar(&f.outFile, "out-file", "", "file to write the UTxOs to as cardano-cli JSON")
+		run = func(c *lsq.Client) error { return queryUTxO(f, c) }
+	case "balance":
+		f.flagset.StringVar(&f.queryAddresses, "address", "", "comma separated addresses to sum the UTxOs of")
+		run = func(c *lsq.Client) error { return queryBalance(f, c) }
+	case "tip":
+		run = func(c *lsq.Client) error { return queryTip(f, c) }
+	case "protocol-parameters":
+		f.flagset.StringVar(&f.outFile, "out-file", "", "file to write the protocol parameters JSON to, defaults to stdout")
+		run = func(c *lsq.Client) error { return queryProtocolParams(f, c) }
+	case "stake-address-info":
+		f.flagset.StringVar(&f.queryAddresses, "address", "", "comma separated stake or base addresses")
+		run = func(c *lsq.Client) error { return queryStakeAddressInfo(f, c) }
+	case "stake-distribution":
+		run = func(c *lsq.Client) error { return queryStakeDistribution(f, c) }
+	case "era-history":
+		run = func(c *lsq.Client) error { return queryEraHistory(f, c) }
+	default:
+		return fmt.Errorf("unknown query subcommand %q, expected utxo, balance, tip, protocol-parameters, "+
+			"stake-address-info, stake-distribution or era-history", subcommand)
+	}
+	parseFlags()
+	f.networkMagic = uint32(networkMagic)
+	if f.output != "text" && f.output != "json" {
+		return fmt.Errorf("unknown output format %q, expected text or json", f.output)
+	}
+	// checked before dialing, so that a node address given to -address is reported as such
+	if _, err := queryAddressList(f); err != nil {
+		return err
+	}
+	if f.clientAddress == "" && f.clientSocket == "" {
+		return fmt.Errorf("client address/socket is not set, use -node-address or -socket")
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	// the lsq client takes over local state query from gouroboros' own client
+	o, err := connectNodeToClient(f, log, ouroboros.WithDelayProtocolStart(true))
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+	c := lsq.New(o, log)
+	defer c.Release()
+	return run(c)
+}
+
+func queryAddressList(f *cliFlags) ([]ledger.Address, error) {
+	var addrs []ledger.Address
+	for _, s := range splitList(f.queryAddresses) {
+		addr, err := ledger.NewAddress(s)
+		if err != nil {
+			if _, _, hostErr := net.SplitHostPort(s); hostErr == nil {
+				return nil, fmt.Errorf("invalid address %s: -address is the queried address, set the node's TCP address with -node-address", s)
+			}
+			return nil, fmt.Errorf("invalid address %s: %w", s, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseTxIn(s string) (tx.TxInput, error) {
+	txHash, index, ok := strings.Cut(s, "#")
+	if !ok {
+		return tx.TxInput{}, fmt.Errorf("invalid tx input %q, expected txhash#ix", s)
+	}
+	if _, err := hex.DecodeString(txHash); err != nil || len(txHash) != 64 {
+		return tx.TxInput{}, fmt.Errorf("invalid tx hash in %q", s)
+	}
+	ix, err := strconv.ParseUint(index, 10, 16)
+	if err != nil {
+		return tx.TxInput{}, fmt.Errorf("invalid tx index in %q: %w", s, err)
+	}
+	return tx.NewTxInput(txHash, uint16(ix), 0), nil
+}
+
+func queryUTxO(f *cliFlags, c *lsq.Client) error {
+	var utxos []tx.TxInput
+	switch {
+	case f.queryAddresses != "" && f.txIns != "":
+		return fmt.Errorf("only one of -address and -tx-in can be set")
+	case f.queryAddresses != "":
+		addrs, err := queryAddressList(f)
+		if err != nil {
+			return err
+		}
+		if utxos, err = c.UTxOsByAddress(addrs...); err != nil {
+			return err
+		}
+	case f.txIns != "":
+		var txIns []tx.TxInput
+		for _, s := range splitList(f.txIns) {
+			txIn, err := parseTxIn(s)
+			if err != nil {
+				return err
+			}
+			txIns = append(txIns, txIn)
+		}
+		var err error
+		if utxos, err = c.UTxOsByTxIn(txIns...); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("one of -address and -tx-in is required")
+	}
+
+	if f.outFile != "" {
+		file, err := os.Create(f.outFile)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", f.outFile, err)
+		}
+		defer file.Close()
+		return utxofile.Encode(file, utxos)
+	}
+	if f.output == "json" {
+		return utxofile.Encode(os.Stdout, utxos)
+	}
+	w := newTable(os.Stdout)
+	fmt.Fprintln(w, "TxHash\tTxIx\tAmount")
+	fmt.Fprintln(w, strings.Repeat("-", 86))
+	for _, utxo := range utxos {
+		fmt.Fprintf(w, "%x\t%d\t%s\n", utxo.TxHash, utxo.Index, formatValue(utxo))
+	}
+	return w.Flush()
+}
+
+// formatValue prints the value and datum of an output the way cardano-cli does.
+func formatValue(utxo tx.TxInput) string {
+	parts := []string{fmt.Sprintf("%d lovelace", utxo.Amount)}
+	for _, asset := range sortedAssets(utxo.Assets) {
+		parts = append(parts, fmt.Sprintf("%d %s", asset.quantity, asset.unit))
+	}
+	switch {
+	case utxo.Datum != nil:
+		parts = append(parts, "TxOutDatumInline")
+	case utxo.DatumHash != nil:
+		parts = append(parts, fmt.Sprintf("TxOutDatumHash %x", utxo.DatumHash))
+	default:
+		parts = append(parts, "TxOutDatumNone")
+	}
+	return strings.Join(parts, " + ")
+}
+
+type assetQuantity struct {
+	// unit is the hex policy id and asset name, separated by a dot
+	unit     string
+	quantity uint64
+}
+
+func sortedAssets(assets tx.MultiAsset) []assetQuantity {
+	var res []assetQuantity
+	for policyId, names := range assets {
+		for name, quantity := range names {
+			unit := policyId
+			if name != "" {
+				unit += "." + name
+			}
+			res = append(res, assetQuantity{unit: unit, quantity: quantity})
+		}
+	}
+	slices.SortFunc(res, func(a, b assetQuantity) int {
+		return strings.Compare(a.unit, b.unit)
+	})
+	return res
+}
+
+func queryBalance(f *cliFlags, c *lsq.Client) error {
+	addrs, err := queryAddressList(f)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("-address is required")
+	}
+	utxos, err := c.UTxOsByAddress(addrs...)
+	if err != nil {
+		return err
+	}
+	balance := struct {
+		Lovelace uint64        `json:"lovelace"`
+		Assets   tx.MultiAsset `json:"assets"`
+		UTxOs    int           `json:"utxos"`
+	}{Assets: make(tx.MultiAsset), UTxOs: len(utxos)}
+	for _, utxo := range utxos {
+		balance.Lovelace += utxo.Amount
+		for policyId, names := range utxo.Assets {
+			for name, quantity := range names {
+				balance.Assets.Add(policyId, name, quantity)
+			}
+		}
+	}
+
+	if f.output == "json" {
+		return printJSON(os.Stdout, balance)
+	}
+	w := newTable(os.Stdout)
+	fmt.Fprintf(w, "utxos\t%d\n", balance.UTxOs)
+	fmt.Fprintf(w, "lovelace\t%d\n", balance.Lovelace)
+	for _, asset := range sortedAssets(balance.Assets) {
+		fmt.Fprintf(w, "%s\t%d\n", asset.unit, asset.quantity)
+	}
+	return w.Flush()
+}
+
+type tipInfo struct {
+	Block           uint64 `json:"block"`
+	Epoch           uint64 `json:"epoch"`
+	Era             string `json:"era"`
+	Hash            string `json:"hash"`
+	Slot            uint64 `json:"slot"`
+	SlotInEpoch     uint64 `json:"slotInEpoch"`
+	SlotsToEpochEnd uint64 `json:"slotsToEpochEnd"`
+	SyncProgress    string `json:"syncProgress"`
+}
+
+func queryTip(f *cliFlags, c *lsq.Client) error {
+	tip, err := c.Tip()
+	if err != nil {
+		return err
+	}
+	summary, err := c.Slotting()
+	if err != nil {
+		return err
+	}
+	info := tipInfo{
+		Block: tip.Block,
+		Epoch: tip.Epoch,
+		Era:   ledger.GetEraById(uint8(tip.Era)).Name,
+		Hash:  hex.EncodeToString(tip.Point.Hash),
+		Slot:  tip.Point.Slot,
+	}
+	if _, info.SlotInEpoch, info.SlotsToEpochEnd, err = summary.SlotToEpoch(tip.Point.Slot); err != nil {
+		return err
+	}
+	slotTime, err := summary.SlotToTime(tip.Point.Slot)
+	if err != nil {
+		return err
+	}
+	progress := 100 * float64(slotTime.Sub(summary.SystemStart)) / float64(time.Since(summary.SystemStart))
+	info.SyncProgress = fmt.Sprintf("%.2f", min(progress, 100))
+
+	if f.output == "json" {
+		return printJSON(os.Stdout, info)
+	}
+	w := newTable(os.Stdout)
+	fmt.Fprintf(w, "block\t%d\n", info.Block)
+	fmt.Fprintf(w, "epoch\t%d\n", info.Epoch)
+	fmt.Fprintf(w, "era\t%s\n", info.Era)
+	fmt.Fprintf(w, "hash\t%s\n", info.Hash)
+	fmt.Fprintf(w, "slot\t%d\n", info.Slot)
+	fmt.Fprintf(w, "slotInEpoch\t%d\n", info.SlotInEpoch)
+	fmt.Fprintf(w, "slotsToEpochEnd\t%d\n", info.SlotsToEpochEnd)
+	fmt.Fprintf(w, "syncProgress\t%s\n", info.SyncProgress)
+	return w.Flush()
+}
+
+func queryProtocolParams(f *cliFlags, c *lsq.Client) error {
+	params, err := c.ProtocolParams()
+	if err != nil {
+		return err
+	}
+	// protocol parameters are only printed as JSON, the input format of -protocol-parameters-file
+	if f.outFile == "" {
+		return printJSON(os.Stdout, params)
+	}
+	file, err := os.Create(f.outFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", f.outFile, err)
+	}
+	defer file.Close()
+	return printJSON(file, params)
+}
+
+type stakeAddressInfo struct {
+	Address              string  `json:"address"`
+	Registered           bool    `json:"registered"`
+	StakeDelegation      *string `json:"stakeDelegation"`
+	RewardAccountBalance uint64  `json:"rewardAccountBalance"`
+}
+
+func queryStakeAddressInfo(f *cliFlags, c *lsq.Client) error {
+	names := splitList(f.queryAddresses)
+	addrs, err := queryAddressList(f)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("-address is required")
+	}
+	creds := make([]lsq.Credential, 0, len(addrs))
+	for _, addr := range addrs {
+		cred, err := lsq.StakeCredential(addr)
+		if err != nil {
+			return err
+		}
+		creds = append(creds, cred)
+	}
+	res, err := c.StakeAddressInfo(creds...)
+	if err != nil {
+		return err
+	}
+	infos := make([]stakeAddressInfo, 0, len(res))
+	for i, r := range res {
+		info := stakeAddressInfo{Address: names[i], Registered: r.Registered, RewardAccountBalance: r.RewardBalance}
+		if r.Delegation != nil {
+			pool := r.Delegation.String()
+			info.StakeDelegation = &pool
+		}
+		infos = append(infos, info)
+	}
+
+	if f.output == "json" {
+		return printJSON(os.Stdout, infos)
+	}
+	w := newTable(os.Stdout)
+	fmt.Fprintln(w, "Address\tRegistered\tDelegation\tRewards")
+	for _, info := range infos {
+		pool := "-"
+		if info.StakeDelegation != nil {
+			pool = *info.StakeDelegation
+		}
+		fmt.Fprintf(w, "%s\t%t\t%s\t%d\n", info.Address, info.Registered, pool, info.RewardAccountBalance)
+	}
+	return w.Flush()
+}
+
+func queryStakeDistribution(f *cliFlags, c *lsq.Client) error {
+	pools, err := c.StakeDistribution()
+	if err != nil {
+		return err
+	}
+
+	if f.output == "json" {
+		res := make(map[string]*pparams.Rational, len(pools))
+		for _, pool := range pools {
+			res[pool.PoolId.String()] = &pparams.Rational{Rat: pool.Fraction}
+		}
+		return printJSON(os.Stdout, res)
+	}
+	w := newTable(os.Stdout)
+	fmt.Fprintln(w, "PoolId\tStake frac")
+	for _, pool := range pools {
+		frac, _ := pool.Fraction.Float64()
+		fmt.Fprintf(w, "%s\t%.3e\n", pool.PoolId.String(), frac)
+	}
+	return w.Flush()
+}
+
+type eraBound struct {
+	Time  time.Time `json:"time"`
+	Slot  uint64    `json:"slot"`
+	Epoch uint64    `json:"epoch"`
+}
+
+type eraInfo struct {
+	Era         string    `json:"era"`
+	Start       eraBound  `json:"start"`
+	End         *eraBound `json:"end"`
+	EpochLength uint64    `json:"epochLength"`
+	// SlotLength is in seconds, like in the genesis files
+	SlotLength float64 `json:"slotLength"`
+}
+
+func queryEraHistory(f *cliFlags, c *lsq.Client) error {
+	history, err := c.EraHistory()
+	if err != nil {
+		return err
+	}
+	systemStart, err := c.SystemStart()
+	if err != nil {
+		return err
+	}
+	bound := func(b lsq.Bound) eraBound {
+		return eraBound{Time: systemStart.Add(b.Time), Slot: b.Slot, Epoch: b.Epoch}
+	}
+	eras := make([]eraInfo, 0, len(history))
+	for id, summary := range history {
+		era := eraInfo{
+			Era:         ledger.GetEraById(uint8(id)).Name,
+			Start:       bound(summary.Start),
+			EpochLength: summary.EpochLength,
+			SlotLength:  summary.SlotLength.Seconds(),
+		}
+		if summary.End != nil {
+			end := bound(*summary.End)
+			era.End = &end
+		}
+		eras = append(eras, era)
+	}
+
+	if f.output == "json" {
+		return printJSON(os.Stdout, eras)
+	}
+	w := newTable(os.Stdout)
+	fmt.Fprintln(w, "Era\tStart slot\tStart epoch\tStart time\tEnd slot\tEnd epoch\tEpoch length\tSlot length")
+	for _, era := range eras {
+		endSlot, endEpoch := "-", "-"
+		if era.End != nil {
+			endSlot, endEpoch = strconv.FormatUint(era.End.Slot, 10), strconv.FormatUint(era.End.Epoch, 10)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%d\t%gs\n", era.Era, era.Start.Slot, era.Start.Epoch,
+			era.Start.Time.Format(time.RFC3339), endSlot, endEpoch, era.EpochLength, era.SlotLength)
+	}
+	return w.Flush()
+}
+
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
+
+func printJSON(w io.Writer, v any) error {
+	bz, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(bz))
+	return err
+}
@@ -13,12 +13,12 @@ fi
 
 export CARDANO_NODE_SOCKET_PATH CARDANO_NODE_NETWORK_ID
 
-ADDR=$(cardano-cli address build --payment-verification-key-file devnet/utxo-keys/utxo1.vkey)
+ADDR=$(gardano address build -payment-vkey devnet/utxo-keys/utxo1.vkey -network testnet)
 
-cardano-cli query protocol-parameters > pparams.json
+gardano query protocol-parameters -socket "$CARDANO_NODE_SOCKET_PATH" -magic "$CARDANO_NODE_NETWORK_ID" -out-file pparams.json
 
 # there should be one tx with 1800000000000 lovelace
-RES=$(cardano-cli query utxo --address "$ADDR")
+RES=$(gardano query utxo -socket "$CARDANO_NODE_SOCKET_PATH" -magic "$CARDANO_NODE_NETWORK_ID" -address "$ADDR")
 if [ $(echo "$RES" | wc -l) -ne 3 ]; then
     echo "Genesis UTxO not found"
     exit 1
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/kocubinski/gardano/provider/utxorpc"
+)
+
+func serveUtxorpc(f *cliFlags) error {
+	if f.clientAddress == "" && f.clientSocket == "" {
+		return fmt.Errorf("client address/socket is not set")
+	}
+	network, ok := ouroboros.NetworkByNetworkMagic(f.networkMagic)
+	if !ok {
+		return fmt.Errorf("unknown network magic: %d", f.networkMagic)
+	}
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	dial := func(opts ...ouroboros.ConnectionOptionFunc) (*ouroboros.Connection, error) {
+		client, err := dialNodeToClient(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client connection: %w", err)
+		}
+		o, err := ouroboros.NewConnection(append([]ouroboros.ConnectionOptionFunc{
+			ouroboros.WithConnection(client),
+			ouroboros.WithLogger(log),
+			ouroboros.WithNetwork(network),
+			ouroboros.WithKeepAlive(true),
+		}, opts...)...)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to network: %w", err)
+		}
+		return o, nil
+	}
+	server, err := utxorpc.NewServer(dial, log)
+	if err != nil {
+		return fmt.Errorf("failed to connect to node: %w", err)
+	}
+	defer server.Close()
+
+	log.Info("serving utxorpc", "address", f.listenAddress)
+	return http.ListenAndServe(f.listenAddress, server.Handler())
+}
@@ -0,0 +1,215 @@
+package sink
+
+import (
+	"encoding/hex"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/filter"
+	"github.com/kocubinski/gardano/observer"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// SchemaVersion is the version of the event schema. Fields may be added within a version, but never
+// renamed, removed or changed in meaning.
+const SchemaVersion = 1
+
+type Type string
+
+const (
+	// TypeBlock is emitted for every block chain-sync rolls forward to.
+	TypeBlock Type = "block"
+	// TypeTransaction is emitted for every transaction in a block, after the block event.
+	TypeTransaction Type = "transaction"
+	// TypeOutput is emitted for a confirmed output paying to a filtered address.
+	TypeOutput Type = "output"
+	// TypeOutputRetracted is emitted when a rollback removes a previously emitted output.
+	TypeOutputRetracted Type = "output_retracted"
+	// TypeMatch is emitted for a transaction satisfying the chain-sync filter expression.
+	TypeMatch Type = "match"
+	// TypeRollback is emitted when chain-sync rolls back to an earlier point.
+	TypeRollback Type = "rollback"
+)
+
+// Event is the envelope written to sinks. Exactly one of the payload fields matching Type is set.
+type Event struct {
+	Version     int          `json:"version"`
+	Type        Type         `json:"type"`
+	Block       *Block       `json:"block,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Output      *Output      `json:"output,omitempty"`
+	Match       *Match       `json:"match,omitempty"`
+	Rollback    *Rollback    `json:"rollback,omitempty"`
+}
+
+type Point struct {
+	Slot uint64 `json:"slot"`
+	Hash string `json:"hash"`
+}
+
+type Block struct {
+	Hash    string `json:"hash"`
+	Slot    uint64 `json:"slot"`
+	Number  uint64 `json:"number"`
+	Era     string `json:"era"`
+	TxCount int    `json:"tx_count"`
+}
+
+type Transaction struct {
+	Hash      string     `json:"hash"`
+	BlockHash string     `json:"block_hash"`
+	Slot      uint64     `json:"slot"`
+	Index     int        `json:"index"`
+	Fee       uint64     `json:"fee"`
+	Valid     bool       `json:"valid"`
+	Inputs    []string   `json:"inputs"`
+	Outputs   []TxOutput `json:"outputs"`
+}
+
+type TxOutput struct {
+	Address string        `json:"address"`
+	Amount  uint64        `json:"amount"`
+	Assets  tx.MultiAsset `json:"assets,omitempty"`
+}
+
+type Output struct {
+	TxHash      string        `json:"tx_hash"`
+	Index       uint32        `json:"index"`
+	Address     string        `json:"address"`
+	Amount      uint64        `json:"amount"`
+	Assets      tx.MultiAsset `json:"assets,omitempty"`
+	Memo        string        `json:"memo,omitempty"`
+	Slot        uint64        `json:"slot"`
+	BlockHash   string        `json:"block_hash"`
+	BlockNumber uint64        `json:"block_number"`
+}
+
+type Match struct {
+	TxHash    string `json:"tx_hash"`
+	BlockHash string `json:"block_hash"`
+	Slot      uint64 `json:"slot"`
+	Memo      string `json:"memo,omitempty"`
+	// Deposits are the outputs paying to watched addresses.
+	Deposits []UTxO `json:"deposits"`
+	// Withdrawals are the inputs spending from watched addresses.
+	Withdrawals []UTxO `json:"withdrawals"`
+}
+
+type UTxO struct {
+	TxHash  string        `json:"tx_hash"`
+	Index   uint32        `json:"index"`
+	Address string        `json:"address"`
+	Amount  uint64        `json:"amount"`
+	Assets  tx.MultiAsset `json:"assets,omitempty"`
+}
+
+type Rollback struct {
+	Point Point `json:"point"`
+	Tip   Point `json:"tip"`
+}
+
+// BlockEvents returns the block event for block followed by an event for each of its transactions.
+func BlockEvents(block ledger.Block) []Event {
+	txs := block.Transactions()
+	events := []Event{{
+		Version: SchemaVersion,
+		Type:    TypeBlock,
+		Block: &Block{
+			Hash:    block.Hash(),
+			Slot:    block.SlotNumber(),
+			Number:  block.BlockNumber(),
+			Era:     block.Era().Name,
+			TxCount: len(txs),
+		},
+	}}
+	for i, blockTx := range txs {
+		t := &Transaction{
+			Hash:      blockTx.Hash(),
+			BlockHash: block.Hash(),
+			Slot:      block.SlotNumber(),
+			Index:     i,
+			Fee:       blockTx.Fee(),
+			Valid:     blockTx.IsValid(),
+			Inputs:    []string{},
+			Outputs:   []TxOutput{},
+		}
+		for _, in := range blockTx.Inputs() {
+			t.Inputs = append(t.Inputs, in.String())
+		}
+		for _, out := range blockTx.Outputs() {
+			t.Outputs = append(t.Outputs, TxOutput{
+				Address: out.Address().String(),
+				Amount:  out.Amount(),
+				Assets:  tx.NewMultiAssetFromLedger(out.Assets()),
+			})
+		}
+		events = append(events, Event{Version: SchemaVersion, Type: TypeTransaction, Transaction: t})
+	}
+	return events
+}
+
+// OutputEvent converts a deposit observer event.
+func OutputEvent(ev observer.Event) Event {
+	d := ev.Deposit
+	typ := TypeOutput
+	if ev.Type == observer.EventRetraction {
+		typ = TypeOutputRetracted
+	}
+	return Event{
+		Version: SchemaVersion,
+		Type:    typ,
+		Output: &Output{
+			TxHash:      d.TxHash,
+			Index:       d.Index,
+			Address:     d.Address,
+			Amount:      d.Amount,
+			Assets:      d.Assets,
+			Memo:        d.Memo,
+			Slot:        d.Slot,
+			BlockHash:   d.BlockHash,
+			BlockNumber: d.BlockNumber,
+		},
+	}
+}
+
+// MatchEvent converts a filter match of a transaction in block.
+func MatchEvent(block ledger.Block, m filter.Match) Event {
+	match := &Match{
+		TxHash:      m.Tx.Hash(),
+		BlockHash:   block.Hash(),
+		Slot:        block.SlotNumber(),
+		Memo:        m.Memo,
+		Deposits:    []UTxO{},
+		Withdrawals: []UTxO{},
+	}
+	for _, utxo := range m.Deposits {
+		match.Deposits = append(match.Deposits, UTxO{
+			TxHash:  utxo.Id.Id().String(),
+			Index:   utxo.Id.Index(),
+			Address: utxo.Output.Address().String(),
+			Amount:  utxo.Output.Amount(),
+			Assets:  tx.NewMultiAssetFromLedger(utxo.Output.Assets()),
+		})
+	}
+	for _, s := range m.Withdrawals {
+		match.Withdrawals = append(match.Withdrawals, UTxO{
+			TxHash:  hex.EncodeToString(s.Input.TxHash),
+			Index:   uint32(s.Input.Index),
+			Address: s.Address.String(),
+			Amount:  s.Input.Amount,
+			Assets:  s.Input.Assets,
+		})
+	}
+	return Event{Version: SchemaVersion, Type: TypeMatch, Match: match}
+}
+
+func RollbackEvent(point, tip common.Point) Event {
+	return Event{
+		Version: SchemaVersion,
+		Type:    TypeRollback,
+		Rollback: &Rollback{
+			Point: Point{Slot: point.Slot, Hash: hex.EncodeToString(point.Hash)},
+			Tip:   Point{Slot: tip.Slot, Hash: hex.EncodeToString(tip.Hash)},
+		},
+	}
+}
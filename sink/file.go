@@ -0,0 +1,111 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File writes JSON lines to a file, rotating it once it reaches a maximum size. Rotated files are
+// renamed to path.1, path.2, ... with path.1 the most recent.
+type File struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+type FileOption func(*File)
+
+// WithMaxSize sets the size in bytes after which the file is rotated. Defaults to 100 MiB.
+func WithMaxSize(bytes int64) FileOption {
+	return func(f *File) {
+		f.maxSize = bytes
+	}
+}
+
+// WithMaxBackups sets the number of rotated files kept. Defaults to 5.
+func WithMaxBackups(n int) FileOption {
+	return func(f *File) {
+		f.maxBackups = n
+	}
+}
+
+// NewFile opens path for appending.
+func NewFile(path string, opts ...FileOption) (*File, error) {
+	f := &File{
+		path:       path,
+		maxSize:    100 << 20,
+		maxBackups: 5,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open event file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.f = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *File) rotate() error {
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+	if f.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+		for i := f.maxBackups - 1; i > 0; i-- {
+			if err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *File) Emit(_ context.Context, ev Event) error {
+	bz, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	bz = append(bz, '\n')
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.size+int64(len(bz)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate event file: %w", err)
+		}
+	}
+	n, err := f.f.Write(bz)
+	f.size += int64(n)
+	return err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Close()
+}
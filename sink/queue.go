@@ -0,0 +1,267 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kocubinski/gardano/supervisor"
+)
+
+// Publisher publishes a message on a subject of a message broker.
+type Publisher interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+	Close() error
+}
+
+// Queue publishes each event to a Publisher on the subject "<prefix>.<type>", e.g. "gardano.block".
+type Queue struct {
+	pub    Publisher
+	prefix string
+}
+
+func NewQueue(pub Publisher, prefix string) *Queue {
+	return &Queue{pub: pub, prefix: prefix}
+}
+
+func (q *Queue) Emit(ctx context.Context, ev Event) error {
+	bz, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if err := q.pub.Publish(ctx, q.prefix+"."+string(ev.Type), bz); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) Close() error {
+	return q.pub.Close()
+}
+
+// natsDialAttempts is how many times Publish dials a broker which dropped the connection before failing.
+const natsDialAttempts = 3
+
+// natsAckTimeout is how long Publish waits for the broker to confirm a message when ctx has no deadline.
+const natsAckTimeout = 10 * time.Second
+
+// NATS is a publish-only client for the NATS text protocol. A connection which fails, for instance
+// because the broker restarted, is redialed with backoff by the next Publish. Core NATS acknowledges no
+// message, so Publish follows each one with a PING and returns once the broker answers PONG: the broker
+// handles a connection's commands in order, so the PONG confirms it processed the message.
+type NATS struct {
+	url  *url.URL
+	opts []supervisor.Option
+
+	mu     sync.Mutex
+	conn   *natsConn
+	closed bool
+}
+
+var _ Publisher = (*NATS)(nil)
+
+// natsConn is a single connection to the broker. Its ErrorChan is closed once it fails.
+type natsConn struct {
+	conn     net.Conn
+	errs     chan error
+	failOnce sync.Once
+	// err is why the connection failed, set before errs is closed
+	err error
+	// pongs receives the answers to the pings of Publish
+	pongs chan struct{}
+
+	// mu guards w, which both Publish and the answers to pings write to
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// DialNATS connects to a NATS server at a URL of the form nats://[user:pass@]host:port. opts set the
+// backoff of reconnections.
+func DialNATS(ctx context.Context, rawURL string, opts ...supervisor.Option) (*NATS, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats url: %w", err)
+	}
+	n := &NATS{url: u, opts: append([]supervisor.Option{supervisor.WithMaxAttempts(natsDialAttempts)}, opts...)}
+	if n.conn, err = n.dial(ctx); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *NATS) dial(ctx context.Context) (*natsConn, error) {
+	host := n.url.Host
+	if n.url.Port() == "" {
+		host = net.JoinHostPort(n.url.Hostname(), "4222")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial nats: %w", err)
+	}
+	c := &natsConn{conn: conn, errs: make(chan error), pongs: make(chan struct{}, 1), w: bufio.NewWriter(conn)}
+	if err := c.handshake(n.url); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *natsConn) handshake(u *url.URL) error {
+	c.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer c.conn.SetDeadline(time.Time{})
+	r := bufio.NewReader(c.conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read nats info: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected nats greeting %q", strings.TrimSpace(line))
+	}
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "gardano", "lang": "go"}
+	if u.User != nil {
+		opts["user"] = u.User.Username()
+		opts["pass"], _ = u.User.Password()
+	}
+	bz, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.w, "CONNECT %s\r\nPING\r\n", bz)
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to connect to nats: %w", err)
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			go c.readLoop(r)
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", line)
+		}
+	}
+}
+
+// readLoop answers server pings and passes on the answers to ours until the connection fails. An error
+// fails the connection too, as the message Publish waits for may be the one the broker refused.
+func (c *natsConn) readLoop(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			c.fail(fmt.Errorf("nats connection failed: %w", err))
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			c.mu.Lock()
+			c.w.WriteString("PONG\r\n")
+			err = c.w.Flush()
+			c.mu.Unlock()
+			if err != nil {
+				c.fail(fmt.Errorf("nats connection failed: %w", err))
+				return
+			}
+		case line == "PONG":
+			select {
+			case c.pongs <- struct{}{}:
+			default:
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			c.fail(fmt.Errorf("nats: %s", line))
+			return
+		}
+	}
+}
+
+func (c *natsConn) fail(err error) {
+	c.failOnce.Do(func() {
+		c.err = err
+		c.conn.Close()
+		close(c.errs)
+	})
+}
+
+func (c *natsConn) ErrorChan() chan error {
+	return c.errs
+}
+
+func (c *natsConn) Close() error {
+	c.fail(net.ErrClosed)
+	return nil
+}
+
+func (c *natsConn) failed() bool {
+	select {
+	case <-c.errs:
+		return true
+	default:
+		return false
+	}
+}
+
+// Publish sends data on subject and waits for the broker to confirm it. A message which is not confirmed
+// may still have been delivered.
+func (n *NATS) Publish(ctx context.Context, subject string, data []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return errors.New("nats client is closed")
+	}
+	if n.conn.failed() {
+		conn, err := supervisor.Connect(ctx, n.dial, n.opts...)
+		if err != nil {
+			return fmt.Errorf("failed to reconnect to nats: %w", err)
+		}
+		n.conn = conn
+	}
+	c := n.conn
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, natsAckTimeout)
+		defer cancel()
+	}
+	if err := c.publish(ctx, subject, data); err != nil {
+		c.fail(err)
+		return err
+	}
+	select {
+	case <-c.pongs:
+		return nil
+	case <-c.errs:
+		return c.err
+	case <-ctx.Done():
+		c.fail(ctx.Err())
+		return fmt.Errorf("nats did not confirm the message: %w", ctx.Err())
+	}
+}
+
+// publish writes a message followed by a ping.
+func (c *natsConn) publish(ctx context.Context, subject string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deadline, _ := ctx.Deadline()
+	c.conn.SetWriteDeadline(deadline)
+	defer c.conn.SetWriteDeadline(time.Time{})
+	fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(data))
+	c.w.Write(data)
+	c.w.WriteString("\r\nPING\r\n")
+	return c.w.Flush()
+}
+
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	return n.conn.Close()
+}
@@ -0,0 +1,65 @@
+// Package sink delivers chain-sync events in a stable, versioned JSON schema to downstream consumers.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Sink receives events in chain order. Emit returning an error aborts processing of the current block so
+// that it is delivered again once chain-sync resumes, so sinks deliver events at least once.
+type Sink interface {
+	Emit(ctx context.Context, ev Event) error
+	Close() error
+}
+
+// Multi fans events out to each of its sinks. When some of them fail, the event is emitted again to all of
+// them once chain-sync resumes, so the others receive it twice.
+type Multi []Sink
+
+func (m Multi) Emit(ctx context.Context, ev Event) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Emit(ctx, ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// JSONLines writes each event as a single line of JSON, e.g. to stdout.
+type JSONLines struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w}
+}
+
+func (j *JSONLines) Emit(_ context.Context, ev Event) error {
+	bz, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.w.Write(append(bz, '\n'))
+	return err
+}
+
+// Close does not close the underlying writer.
+func (j *JSONLines) Close() error {
+	return nil
+}
@@ -0,0 +1,176 @@
+package sink_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/observer"
+	. "github.com/kocubinski/gardano/sink"
+	"github.com/kocubinski/gardano/supervisor"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_JSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewJSONLines(&buf)
+	require.NoError(t, s.Emit(context.Background(), RollbackEvent(common.NewPoint(10, []byte{0xab}), common.NewPoint(20, []byte{0xcd}))))
+	require.NoError(t, s.Emit(context.Background(), OutputEvent(observer.Event{
+		Type:    observer.EventRetraction,
+		Deposit: observer.Deposit{TxHash: "ff", Index: 1, Amount: 5},
+	})))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.JSONEq(t, `{"version":1,"type":"rollback","rollback":{"point":{"slot":10,"hash":"ab"},"tip":{"slot":20,"hash":"cd"}}}`, lines[0])
+	var ev Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &ev))
+	require.Equal(t, TypeOutputRetracted, ev.Type)
+	require.Equal(t, uint64(5), ev.Output.Amount)
+}
+
+func Test_FileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f, err := NewFile(path, WithMaxSize(150), WithMaxBackups(2))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, f.Emit(context.Background(), RollbackEvent(common.NewPoint(uint64(i), nil), common.NewPoint(0, nil))))
+	}
+	require.NoError(t, f.Close())
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		require.LessOrEqual(t, info.Size(), int64(150))
+	}
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+}
+
+func Test_Webhook(t *testing.T) {
+	secret := []byte("secret")
+	var attempts int
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received, _ = io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(received)
+		if r.Header.Get(SignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL, WithSecret(secret), WithBackoff(time.Millisecond))
+	require.NoError(t, w.Emit(context.Background(), RollbackEvent(common.NewPoint(1, nil), common.NewPoint(2, nil))))
+	require.Equal(t, 3, attempts)
+	require.Contains(t, string(received), `"type":"rollback"`)
+
+	// client errors are not retried
+	attempts = 0
+	w = NewWebhook(srv.URL, WithSecret([]byte("wrong")), WithBackoff(time.Millisecond))
+	require.Error(t, w.Emit(context.Background(), RollbackEvent(common.NewPoint(1, nil), common.NewPoint(2, nil))))
+	require.Equal(t, 3, attempts)
+}
+
+func Test_NATS(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	published := make(chan string, 10)
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				conn.Write([]byte("INFO {}\r\n"))
+				// a broker which lost a message leaves the pings behind it unanswered
+				silent := false
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch {
+					case strings.HasPrefix(line, "PING") && !silent:
+						conn.Write([]byte("PONG\r\n"))
+					case strings.HasPrefix(line, "PUB denied "):
+						r.ReadString('\n')
+						conn.Write([]byte("-ERR 'Permissions Violation for Publish to denied'\r\n"))
+					case strings.HasPrefix(line, "PUB lost "):
+						r.ReadString('\n')
+						silent = true
+					case strings.HasPrefix(line, "PUB "):
+						payload, _ := r.ReadString('\n')
+						published <- line + payload
+					}
+				}
+			}()
+		}
+	}()
+
+	pub, err := DialNATS(context.Background(), "nats://"+l.Addr().String(), supervisor.WithBackoff(time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+	q := NewQueue(pub, "gardano")
+	defer q.Close()
+	ev := RollbackEvent(common.NewPoint(1, nil), common.NewPoint(2, nil))
+	require.NoError(t, q.Emit(context.Background(), ev))
+
+	bz, err := json.Marshal(ev)
+	require.NoError(t, err)
+	select {
+	case msg := <-published:
+		require.Equal(t, "PUB gardano.rollback "+strconv.Itoa(len(bz))+"\r\n"+string(bz)+"\r\n", msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for publish")
+	}
+
+	// the client reconnects after the broker drops the connection, publishes to the dropped one may fail
+	(<-conns).Close()
+	require.Eventually(t, func() bool {
+		q.Emit(context.Background(), ev)
+		select {
+		case <-published:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, conns, 1)
+
+	// a message is only published once the broker confirms it
+	err = pub.Publish(context.Background(), "denied", []byte("{}"))
+	require.ErrorContains(t, err, "Permissions Violation")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, pub.Publish(ctx, "lost", []byte("{}")), context.DeadlineExceeded)
+	require.NoError(t, q.Emit(context.Background(), ev))
+	require.Len(t, published, 1)
+}
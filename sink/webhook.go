@@ -0,0 +1,121 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, prefixed with "sha256=", when the
+// webhook is configured with a secret.
+const SignatureHeader = "X-Gardano-Signature"
+
+// Webhook POSTs each event as JSON to a URL. Requests failing with a network error, 429 or 5xx status
+// are retried with exponential backoff.
+type Webhook struct {
+	url        string
+	secret     []byte
+	retries    int
+	backoff    time.Duration
+	httpClient *http.Client
+}
+
+type WebhookOption func(*Webhook)
+
+// WithSecret signs request bodies with secret, see SignatureHeader.
+func WithSecret(secret []byte) WebhookOption {
+	return func(w *Webhook) {
+		w.secret = secret
+	}
+}
+
+// WithRetries sets the number of retries after a failed delivery. Defaults to 5.
+func WithRetries(n int) WebhookOption {
+	return func(w *Webhook) {
+		w.retries = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled on each subsequent one. Defaults to 500ms.
+func WithBackoff(d time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.backoff = d
+	}
+}
+
+func WithWebhookHTTPClient(c *http.Client) WebhookOption {
+	return func(w *Webhook) {
+		w.httpClient = c
+	}
+}
+
+func NewWebhook(url string, opts ...WebhookOption) *Webhook {
+	w := &Webhook{
+		url:        url,
+		retries:    5,
+		backoff:    500 * time.Millisecond,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *Webhook) Emit(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.retries {
+			return fmt.Errorf("failed to deliver webhook: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != nil {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	res, err := w.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode/100 == 2 {
+		return false, nil
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500,
+		fmt.Errorf("unexpected status %s", res.Status)
+}
+
+func (w *Webhook) Close() error {
+	return nil
+}
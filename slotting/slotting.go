@@ -0,0 +1,225 @@
+// Package slotting converts between slots, epochs and wall-clock time. Slot lengths change at hard forks, so
+// conversions go through a summary of every era's start, which is read from the node's era history or from
+// the genesis files.
+package slotting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrBeforeStart is returned for times before the system start.
+	ErrBeforeStart = errors.New("time is before the system start")
+	// ErrPastHorizon is returned for slots and times after the end of the last known era, which can't be
+	// converted safely as a hard fork might change the slot length there.
+	ErrPastHorizon = errors.New("slot or time is past the era history horizon")
+)
+
+// Bound is the start or end of an era.
+type Bound struct {
+	// Time is relative to the system start.
+	Time  time.Duration
+	Slot  uint64
+	Epoch uint64
+}
+
+// Era is the slotting of one era.
+type Era struct {
+	Start Bound
+	// End is nil for an era without a known end.
+	End         *Bound
+	EpochLength uint64
+	SlotLength  time.Duration
+}
+
+// Summary holds the eras of a network, oldest first.
+type Summary struct {
+	SystemStart time.Time
+	Eras        []Era
+}
+
+// New returns a Summary of eras starting at systemStart.
+func New(systemStart time.Time, eras []Era) (*Summary, error) {
+	if len(eras) == 0 {
+		return nil, fmt.Errorf("era history is empty")
+	}
+	for i, era := range eras {
+		if era.EpochLength == 0 || era.SlotLength <= 0 {
+			return nil, fmt.Errorf("era %d has no epoch or slot length", i)
+		}
+		if era.End == nil && i < len(eras)-1 {
+			return nil, fmt.Errorf("era %d has no end but is followed by another era", i)
+		}
+	}
+	return &Summary{SystemStart: systemStart, Eras: eras}, nil
+}
+
+type byronGenesis struct {
+	StartTime      int64 `json:"startTime"`
+	ProtocolConsts struct {
+		K uint64 `json:"k"`
+	} `json:"protocolConsts"`
+	BlockVersionData struct {
+		// milliseconds, as a string
+		SlotDuration string `json:"slotDuration"`
+	} `json:"blockVersionData"`
+}
+
+type shelleyGenesis struct {
+	SystemStart time.Time `json:"systemStart"`
+	EpochLength uint64    `json:"epochLength"`
+	// seconds, fractional on some testnets
+	SlotLength float64 `json:"slotLength"`
+}
+
+// FromGenesis builds a Summary from the Byron and Shelley genesis files of a network which forked to
+// Shelley at hardForkEpoch. Networks starting directly in Shelley, like most devnets, pass a nil byron
+// genesis. The eras after Shelley keep its slotting, so they share its summary.
+func FromGenesis(byron, shelley []byte, hardForkEpoch uint64) (*Summary, error) {
+	var sg shelleyGenesis
+	if err := json.Unmarshal(shelley, &sg); err != nil {
+		return nil, fmt.Errorf("failed to parse shelley genesis: %w", err)
+	}
+	shelleyEra := Era{
+		EpochLength: sg.EpochLength,
+		SlotLength:  time.Duration(math.Round(sg.SlotLength*1000)) * time.Millisecond,
+	}
+	if byron == nil {
+		return New(sg.SystemStart, []Era{shelleyEra})
+	}
+
+	var bg byronGenesis
+	if err := json.Unmarshal(byron, &bg); err != nil {
+		return nil, fmt.Errorf("failed to parse byron genesis: %w", err)
+	}
+	slotMs, err := strconv.ParseUint(bg.BlockVersionData.SlotDuration, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid byron slot duration %q: %w", bg.BlockVersionData.SlotDuration, err)
+	}
+	byronEra := Era{
+		// a Byron epoch is 10k slots
+		EpochLength: 10 * bg.ProtocolConsts.K,
+		SlotLength:  time.Duration(slotMs) * time.Millisecond,
+	}
+	fork := Bound{
+		Time:  time.Duration(hardForkEpoch*byronEra.EpochLength) * byronEra.SlotLength,
+		Slot:  hardForkEpoch * byronEra.EpochLength,
+		Epoch: hardForkEpoch,
+	}
+	byronEra.End = &fork
+	shelleyEra.Start = fork
+	return New(time.Unix(bg.StartTime, 0).UTC(), []Era{byronEra, shelleyEra})
+}
+
+// ReadGenesisFiles reads FromGenesis' inputs from files, with an empty byronPath for networks starting in
+// Shelley.
+func ReadGenesisFiles(byronPath, shelleyPath string, hardForkEpoch uint64) (*Summary, error) {
+	var byron []byte
+	if byronPath != "" {
+		var err error
+		if byron, err = os.ReadFile(byronPath); err != nil {
+			return nil, fmt.Errorf("failed to read byron genesis: %w", err)
+		}
+	}
+	shelley, err := os.ReadFile(shelleyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shelley genesis: %w", err)
+	}
+	return FromGenesis(byron, shelley, hardForkEpoch)
+}
+
+// eraOfSlot returns the era slot belongs to.
+func (s *Summary) eraOfSlot(slot uint64) (Era, error) {
+	for _, era := range s.Eras {
+		if era.End == nil || slot < era.End.Slot {
+			return era, nil
+		}
+	}
+	return Era{}, ErrPastHorizon
+}
+
+// eraOfTime returns the era the time t, relative to the system start, belongs to.
+func (s *Summary) eraOfTime(t time.Duration) (Era, error) {
+	if t < 0 {
+		return Era{}, ErrBeforeStart
+	}
+	for _, era := range s.Eras {
+		if era.End == nil || t < era.End.Time {
+			return era, nil
+		}
+	}
+	return Era{}, ErrPastHorizon
+}
+
+// SlotToTime returns the time slot starts at.
+func (s *Summary) SlotToTime(slot uint64) (time.Time, error) {
+	era, err := s.eraOfSlot(slot)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.SystemStart.Add(era.Start.Time + time.Duration(slot-era.Start.Slot)*era.SlotLength), nil
+}
+
+// TimeToSlot returns the slot t falls in.
+func (s *Summary) TimeToSlot(t time.Time) (uint64, error) {
+	rel := t.Sub(s.SystemStart)
+	era, err := s.eraOfTime(rel)
+	if err != nil {
+		return 0, err
+	}
+	return era.Start.Slot + uint64((rel-era.Start.Time)/era.SlotLength), nil
+}
+
+// SlotToEpoch returns the epoch of slot, the index of slot in it and the number of slots left in it.
+func (s *Summary) SlotToEpoch(slot uint64) (epoch, slotInEpoch, slotsLeft uint64, err error) {
+	era, err := s.eraOfSlot(slot)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	slots := slot - era.Start.Slot
+	slotInEpoch = slots % era.EpochLength
+	return era.Start.Epoch + slots/era.EpochLength, slotInEpoch, era.EpochLength - slotInEpoch, nil
+}
+
+// SlotToPOSIX returns the start of slot as the POSIX time in milliseconds that Plutus validity ranges use.
+func (s *Summary) SlotToPOSIX(slot uint64) (int64, error) {
+	t, err := s.SlotToTime(slot)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// POSIXToSlot returns the slot the POSIX time in milliseconds falls in.
+func (s *Summary) POSIXToSlot(ms int64) (uint64, error) {
+	return s.TimeToSlot(time.UnixMilli(ms))
+}
+
+// ValidUntil returns the TTL of a transaction which must not be included after t: the transaction is
+// valid up to, but not in, the slot t falls in.
+func (s *Summary) ValidUntil(t time.Time) (uint64, error) {
+	return s.TimeToSlot(t)
+}
+
+// ValidFrom returns the validity interval start of a transaction which must not be included before t: the
+// first slot starting at or after t.
+func (s *Summary) ValidFrom(t time.Time) (uint64, error) {
+	slot, err := s.TimeToSlot(t)
+	if err != nil {
+		return 0, err
+	}
+	start, err := s.SlotToTime(slot)
+	if err != nil {
+		return 0, err
+	}
+	if start.Before(t) {
+		slot++
+	}
+	return slot, nil
+}
@@ -0,0 +1,104 @@
+package slotting_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/kocubinski/gardano/slotting"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mainnetByron = `{
+		"startTime": 1506203091,
+		"protocolConsts": {"k": 2160, "protocolMagic": 764824073},
+		"blockVersionData": {"slotDuration": "20000"}
+	}`
+	mainnetShelley = `{
+		"systemStart": "2017-09-23T21:44:51Z",
+		"epochLength": 432000,
+		"slotLength": 1
+	}`
+)
+
+func Test_Mainnet(t *testing.T) {
+	s, err := FromGenesis([]byte(mainnetByron), []byte(mainnetShelley), 208)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2017, time.September, 23, 21, 44, 51, 0, time.UTC), s.SystemStart)
+
+	// the first Shelley slot
+	shelleyStart := time.Date(2020, time.July, 29, 21, 44, 51, 0, time.UTC)
+	at, err := s.SlotToTime(4492800)
+	require.NoError(t, err)
+	require.Equal(t, shelleyStart, at)
+	slot, err := s.TimeToSlot(shelleyStart)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4492800), slot)
+
+	// Byron slots last 20 seconds
+	slot, err = s.TimeToSlot(shelleyStart.Add(-time.Second))
+	require.NoError(t, err)
+	require.Equal(t, uint64(4492799), slot)
+	at, err = s.SlotToTime(4492799)
+	require.NoError(t, err)
+	require.Equal(t, shelleyStart.Add(-20*time.Second), at)
+
+	epoch, slotInEpoch, slotsLeft, err := s.SlotToEpoch(4492800 + 432000 + 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(209), epoch)
+	require.Equal(t, uint64(10), slotInEpoch)
+	require.Equal(t, uint64(431990), slotsLeft)
+	epoch, _, _, err = s.SlotToEpoch(21600)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), epoch)
+
+	ms, err := s.SlotToPOSIX(4492800)
+	require.NoError(t, err)
+	require.Equal(t, shelleyStart.UnixMilli(), ms)
+	slot, err = s.POSIXToSlot(ms + 1500)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4492801), slot)
+
+	_, err = s.TimeToSlot(s.SystemStart.Add(-time.Second))
+	require.ErrorIs(t, err, ErrBeforeStart)
+}
+
+func Test_Validity(t *testing.T) {
+	s, err := FromGenesis(nil, []byte(`{"systemStart": "2024-01-01T00:00:00Z", "epochLength": 500, "slotLength": 0.2}`), 0)
+	require.NoError(t, err)
+	start := s.SystemStart
+
+	// the tx is invalid from the slot the deadline falls in
+	ttl, err := s.ValidUntil(start.Add(1100 * time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), ttl)
+
+	// and valid from the first slot starting after the lower bound
+	from, err := s.ValidFrom(start.Add(1100 * time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), from)
+	from, err = s.ValidFrom(start.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), from)
+}
+
+func Test_Horizon(t *testing.T) {
+	s, err := New(time.Unix(0, 0), []Era{
+		{
+			End:         &Bound{Time: 100 * time.Second, Slot: 10, Epoch: 1},
+			EpochLength: 10,
+			SlotLength:  10 * time.Second,
+		},
+	})
+	require.NoError(t, err)
+	_, err = s.SlotToTime(10)
+	require.ErrorIs(t, err, ErrPastHorizon)
+	_, err = s.TimeToSlot(time.Unix(100, 0))
+	require.ErrorIs(t, err, ErrPastHorizon)
+	slot, err := s.TimeToSlot(time.Unix(99, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), slot)
+
+	_, err = New(time.Unix(0, 0), []Era{{EpochLength: 10, SlotLength: time.Second}, {EpochLength: 10, SlotLength: time.Second}})
+	require.Error(t, err)
+}
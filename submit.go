@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/chainsync"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/mempool"
+	"github.com/kocubinski/gardano/submitter"
+)
+
+// nodeTxBackend submits transactions over LocalTxSubmission and checks the mempool over LocalTxMonitor.
+type nodeTxBackend struct {
+	conn *ouroboros.Connection
+}
+
+func (b nodeTxBackend) Submit(txBz []byte) error {
+	era, err := b.conn.LocalStateQuery().Client.GetCurrentEra()
+	if err != nil {
+		return fmt.Errorf("failed to get current era: %w", err)
+	}
+	return submitter.AsRejection(b.conn.LocalTxSubmission().Client.SubmitTx(uint16(era), txBz))
+}
+
+func (b nodeTxBackend) InMempool(txHash string) (bool, error) {
+	has, err := mempool.NewMonitor(b.conn.LocalTxMonitor().Client).HasTx(txHash)
+	if err != nil {
+		return false, err
+	}
+	return has[txHash], nil
+}
+
+// submitterChainSyncConfig feeds the blocks of an n2c chain-sync to the submitter *sub.
+func submitterChainSyncConfig(sub **submitter.Submitter) chainsync.Config {
+	return chainsync.NewConfig(
+		chainsync.WithRollForwardFunc(func(_ chainsync.CallbackContext, _ uint, blockData any, _ chainsync.Tip) error {
+			block, ok := blockData.(ledger.Block)
+			if !ok || *sub == nil {
+				return nil
+			}
+			return (*sub).RollForward(block)
+		}),
+		chainsync.WithRollBackwardFunc(func(_ chainsync.CallbackContext, point common.Point, _ chainsync.Tip) error {
+			if *sub == nil {
+				return nil
+			}
+			return (*sub).RollBackward(point)
+		}),
+	)
+}
+
+// waitForTx follows the chain from tip until the tracked transaction is confirmed or expires.
+func waitForTx(o *ouroboros.Connection, sub *submitter.Submitter, tracked *submitter.Tracked, tip common.Point) error {
+	if err := o.ChainSync().Client.Sync([]common.Point{tip}); err != nil {
+		return fmt.Errorf("failed to start chain-sync: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- sub.Run(ctx)
+	}()
+	select {
+	case err := <-runErr:
+		return err
+	case <-tracked.Done():
+	}
+	status, err := tracked.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("confirmed: tx-hash = %s, slot = %d, block = %x, depth = %d\n",
+		status.TxHash, status.Block.Slot, status.Block.Hash, status.Depth)
+	return nil
+}
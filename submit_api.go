@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/kocubinski/gardano/submitapi"
+	"github.com/kocubinski/gardano/supervisor"
+)
+
+func submitAPI(f *cliFlags) error {
+	if f.clientAddress == "" && f.clientSocket == "" {
+		return fmt.Errorf("client address/socket is not set")
+	}
+	network, ok := ouroboros.NetworkByNetworkMagic(f.networkMagic)
+	if !ok {
+		return fmt.Errorf("unknown network magic: %d", f.networkMagic)
+	}
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	pool := supervisor.NewPool(f.poolSize, func(context.Context) (*ouroboros.Connection, error) {
+		client, err := dialNodeToClient(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client connection: %w", err)
+		}
+		o, err := ouroboros.NewConnection(
+			ouroboros.WithConnection(client),
+			ouroboros.WithLogger(log),
+			ouroboros.WithNetwork(network),
+			ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig()),
+			ouroboros.WithKeepAlive(true),
+		)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to network: %w", err)
+		}
+		return o, nil
+	}, supervisor.WithLogger(log), supervisor.WithMaxAttempts(3))
+	defer pool.Close()
+
+	server := submitapi.NewServer(func(ctx context.Context, txBz []byte) error {
+		return pool.Do(ctx, func(o *ouroboros.Connection) error {
+			return nodeTxBackend{conn: o}.Submit(txBz)
+		})
+	}, submitapi.WithLogger(log))
+	log.Info("serving submit api", "address", f.listenAddress, "path", submitapi.SubmitPath)
+	return http.ListenAndServe(f.listenAddress, server)
+}
@@ -0,0 +1,180 @@
+// Package submitapi serves transaction submission over HTTP, compatible with cardano-submit-api.
+package submitapi
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kocubinski/gardano/submitter"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// SubmitPath is the route transactions are posted to.
+const SubmitPath = "/api/submit/tx"
+
+// maxTxBodySize bounds request bodies well above the ledger's maximum transaction size.
+const maxTxBodySize = 1 << 20
+
+// SubmitFunc submits a CBOR encoded transaction to a node. Ledger rejections are reported as a
+// *submitter.Rejection.
+type SubmitFunc func(ctx context.Context, txBz []byte) error
+
+// Server serves POST /api/submit/tx and GET /metrics.
+type Server struct {
+	submit SubmitFunc
+	era    string
+	log    *slog.Logger
+	mux    *http.ServeMux
+
+	submitted  atomic.Uint64
+	rejected   atomic.Uint64
+	failed     atomic.Uint64
+	durationNs atomic.Uint64
+}
+
+type Option func(*Server)
+
+func WithLogger(log *slog.Logger) Option {
+	return func(s *Server) {
+		s.log = log
+	}
+}
+
+// WithEraName sets the era named in rejections, which defaults to Conway.
+func WithEraName(era string) Option {
+	return func(s *Server) {
+		s.era = era
+	}
+}
+
+func NewServer(submit SubmitFunc, opts ...Option) *Server {
+	s := &Server{
+		submit: submit,
+		era:    "Conway",
+		log:    slog.Default(),
+		mux:    http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("POST "+SubmitPath, s.handleSubmit)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// apiError is the JSON error body of cardano-submit-api, a tagged value with optional contents.
+type apiError struct {
+	Tag      string `json:"tag"`
+	Contents any    `json:"contents,omitempty"`
+}
+
+type validationError struct {
+	Kind  string   `json:"kind"`
+	Error []string `json:"error"`
+	Era   string   `json:"era,omitempty"`
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/cbor" {
+		writeJSON(w, http.StatusUnsupportedMediaType, apiError{Tag: "TxSubmitUnsupportedMediaType"})
+		return
+	}
+	txBz, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTxBodySize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Tag: "TxSubmitDecodeFail", Contents: err.Error()})
+		return
+	}
+	if len(txBz) == 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Tag: "TxSubmitEmpty"})
+		return
+	}
+	hash, err := tx.HashFromBytes(txBz)
+	if err != nil {
+		// the most common mistake is posting the hex encoding, as found in text envelopes
+		if _, hexErr := hex.DecodeString(strings.TrimSpace(string(txBz))); hexErr == nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Tag: "TxSubmitDecodeHex"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Tag: "TxSubmitDecodeFail", Contents: err.Error()})
+		return
+	}
+	txId := hex.EncodeToString(hash[:])
+
+	start := time.Now()
+	err = s.submit(r.Context(), txBz)
+	s.durationNs.Add(uint64(time.Since(start)))
+	var rejection *submitter.Rejection
+	switch {
+	case err == nil:
+		s.submitted.Add(1)
+		s.log.Info("transaction submitted", "tx", txId)
+		writeJSON(w, http.StatusAccepted, txId)
+	case errors.As(err, &rejection):
+		s.rejected.Add(1)
+		s.log.Info("transaction rejected", "tx", txId, "error", rejection)
+		failures := make([]string, len(rejection.Failures))
+		for i, failure := range rejection.Failures {
+			failures[i] = failure.Error()
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{
+			Tag: "TxSubmitFail",
+			Contents: apiError{
+				Tag: "TxCmdTxSubmitValidationError",
+				Contents: apiError{
+					Tag: "TxValidationErrorInCardanoMode",
+					Contents: validationError{
+						Kind:  "ShelleyTxValidationError",
+						Error: failures,
+						Era:   "ShelleyBasedEra" + s.era,
+					},
+				},
+			},
+		})
+	default:
+		s.failed.Add(1)
+		s.log.Error("transaction submission failed", "tx", txId, "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, apiError{Tag: "TxSubmitFail", Contents: err.Error()})
+	}
+}
+
+// handleMetrics writes the Prometheus text exposition of the server's counters. The counter names are
+// those of cardano-submit-api, so existing dashboards keep working.
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	submitted, rejected, failed := s.submitted.Load(), s.rejected.Load(), s.failed.Load()
+	fmt.Fprintf(w, "# HELP tx_submit_count Transactions accepted by the node.\n")
+	fmt.Fprintf(w, "# TYPE tx_submit_count counter\n")
+	fmt.Fprintf(w, "tx_submit_count %d\n", submitted)
+	fmt.Fprintf(w, "# HELP tx_submit_fail_count Transactions rejected by the node or not submitted.\n")
+	fmt.Fprintf(w, "# TYPE tx_submit_fail_count counter\n")
+	fmt.Fprintf(w, "tx_submit_fail_count %d\n", rejected+failed)
+	fmt.Fprintf(w, "# HELP gardano_submit_api_submissions_total Submissions by result.\n")
+	fmt.Fprintf(w, "# TYPE gardano_submit_api_submissions_total counter\n")
+	fmt.Fprintf(w, "gardano_submit_api_submissions_total{result=\"accepted\"} %d\n", submitted)
+	fmt.Fprintf(w, "gardano_submit_api_submissions_total{result=\"rejected\"} %d\n", rejected)
+	fmt.Fprintf(w, "gardano_submit_api_submissions_total{result=\"error\"} %d\n", failed)
+	fmt.Fprintf(w, "# HELP gardano_submit_api_submit_duration_seconds Time spent submitting to the node.\n")
+	fmt.Fprintf(w, "# TYPE gardano_submit_api_submit_duration_seconds summary\n")
+	fmt.Fprintf(w, "gardano_submit_api_submit_duration_seconds_sum %g\n", time.Duration(s.durationNs.Load()).Seconds())
+	fmt.Fprintf(w, "gardano_submit_api_submit_duration_seconds_count %d\n", submitted+rejected+failed)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,78 @@
+package submitapi_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/kocubinski/gardano/submitapi"
+	"github.com/kocubinski/gardano/submitter"
+	"github.com/stretchr/testify/require"
+)
+
+// a payment of 2.5 ada to addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz
+const txCbor = "84a300d9010281825820086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab00018182581d6153e3d1d246eae7d89af8e41ae709979bc98ae0f9e23eac1f0e6609aa1a002625a0021a00028969a0f5f6"
+
+func post(t *testing.T, s http.Handler, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, SubmitPath, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_Server(t *testing.T) {
+	var submitErr error
+	var submitted []byte
+	s := NewServer(func(_ context.Context, txBz []byte) error {
+		submitted = txBz
+		return submitErr
+	})
+	txBz, err := hex.DecodeString(txCbor)
+	require.NoError(t, err)
+
+	rec := post(t, s, "application/cbor", string(txBz))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, txBz, submitted)
+	var id string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &id))
+	require.Len(t, id, 64)
+
+	submitErr = &submitter.Rejection{Era: 6, Failures: []error{&submitter.FeeTooSmall{MinimumFee: 170000, SuppliedFee: 1}}}
+	rec = post(t, s, "application/cbor", string(txBz))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var body struct {
+		Tag      string
+		Contents struct {
+			Contents struct {
+				Contents struct {
+					Kind  string
+					Error []string
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "TxSubmitFail", body.Tag)
+	require.Equal(t, []string{"fee too small: minimum 170000, supplied 1"}, body.Contents.Contents.Contents.Error)
+
+	submitErr = errors.New("connection refused")
+	require.Equal(t, http.StatusServiceUnavailable, post(t, s, "application/cbor", string(txBz)).Code)
+
+	rec = post(t, s, "application/cbor", txCbor)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "TxSubmitDecodeHex")
+	require.Equal(t, http.StatusBadRequest, post(t, s, "application/cbor", "").Code)
+	require.Equal(t, http.StatusUnsupportedMediaType, post(t, s, "application/json", "{}").Code)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "tx_submit_count 1\n")
+	require.Contains(t, rec.Body.String(), "tx_submit_fail_count 2\n")
+}
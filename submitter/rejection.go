@@ -0,0 +1,560 @@
+package submitter
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxsubmission"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// conwayEra is the hard fork combinator index of the Conway era.
+const conwayEra = 6
+
+// conwayUtxowFailure is the CBOR tag of ConwayUtxowFailure in a ConwayLedgerPredFailure. The other tags,
+// 2 for certificates, 3 for governance and so on, have no typed errors.
+const conwayUtxowFailure = 1
+
+// Rejection is a transaction rejected by the node's ledger rules. Each of its failures is a typed error
+// such as *BadInputs or *FeeTooSmall which errors.As finds through the Rejection.
+type Rejection struct {
+	Era      uint8
+	Failures []error
+	// Cbor is the node's encoding of the rejection.
+	Cbor []byte
+}
+
+func (r *Rejection) Error() string {
+	msgs := make([]string, len(r.Failures))
+	for i, failure := range r.Failures {
+		msgs[i] = failure.Error()
+	}
+	return fmt.Sprintf("transaction rejected: %s", strings.Join(msgs, "; "))
+}
+
+func (r *Rejection) Unwrap() []error {
+	return r.Failures
+}
+
+// BadInputs reports inputs which are not in the UTxO set, normally because they were already spent.
+type BadInputs struct {
+	Inputs []tx.TxInput
+}
+
+func (e *BadInputs) Error() string {
+	inputs := make([]string, len(e.Inputs))
+	for i, in := range e.Inputs {
+		inputs[i] = fmt.Sprintf("%x#%d", in.TxHash, in.Index)
+	}
+	return fmt.Sprintf("bad inputs: %s", strings.Join(inputs, ", "))
+}
+
+// OutsideValidityInterval reports a transaction submitted at a slot outside of its validity interval.
+// The bounds are nil when the transaction does not set them.
+type OutsideValidityInterval struct {
+	InvalidBefore    *uint64
+	InvalidHereafter *uint64
+	Slot             uint64
+}
+
+func (e *OutsideValidityInterval) Error() string {
+	bound := func(b *uint64) string {
+		if b == nil {
+			return "none"
+		}
+		return fmt.Sprint(*b)
+	}
+	return fmt.Sprintf("outside validity interval: slot %d, invalid before %s, invalid hereafter %s",
+		e.Slot, bound(e.InvalidBefore), bound(e.InvalidHereafter))
+}
+
+// Expired reports whether the transaction's TTL passed, so it can never be valid again.
+func (e *OutsideValidityInterval) Expired() bool {
+	return e.InvalidHereafter != nil && e.Slot >= *e.InvalidHereafter
+}
+
+type MaxTxSize struct {
+	ActualSize uint64
+	MaxSize    uint64
+}
+
+func (e *MaxTxSize) Error() string {
+	return fmt.Sprintf("transaction size %d exceeds maximum %d", e.ActualSize, e.MaxSize)
+}
+
+type InputSetEmpty struct{}
+
+func (e *InputSetEmpty) Error() string {
+	return "transaction has no inputs"
+}
+
+type FeeTooSmall struct {
+	MinimumFee  uint64
+	SuppliedFee uint64
+}
+
+func (e *FeeTooSmall) Error() string {
+	return fmt.Sprintf("fee too small: minimum %d, supplied %d", e.MinimumFee, e.SuppliedFee)
+}
+
+// Value is an amount of lovelace and native assets.
+type Value struct {
+	Coin   uint64
+	Assets tx.MultiAsset
+}
+
+// ValueNotConserved reports that the value consumed by a transaction, including withdrawals and deposit
+// refunds, differs from the value it produces, including the fee and deposits.
+type ValueNotConserved struct {
+	Consumed Value
+	Produced Value
+}
+
+func (e *ValueNotConserved) Error() string {
+	return fmt.Sprintf("value not conserved: consumed %d lovelace, produced %d lovelace",
+		e.Consumed.Coin, e.Produced.Coin)
+}
+
+// WrongNetwork reports output addresses for a network other than the node's.
+type WrongNetwork struct {
+	ExpectedNetwork uint8
+	Addresses       []string
+}
+
+func (e *WrongNetwork) Error() string {
+	return fmt.Sprintf("wrong network, expected %d: %s", e.ExpectedNetwork, strings.Join(e.Addresses, ", "))
+}
+
+// SmallOutput is an output holding less than the minimum lovelace for its size.
+type SmallOutput struct {
+	Address string
+	Amount  uint64
+	// MinAmount is the required amount, zero if the node did not report it.
+	MinAmount uint64
+}
+
+type OutputTooSmall struct {
+	Outputs []SmallOutput
+}
+
+func (e *OutputTooSmall) Error() string {
+	outputs := make([]string, len(e.Outputs))
+	for i, out := range e.Outputs {
+		outputs[i] = fmt.Sprintf("%s: %d", out.Address, out.Amount)
+		if out.MinAmount > 0 {
+			outputs[i] += fmt.Sprintf(" < %d", out.MinAmount)
+		}
+	}
+	return fmt.Sprintf("outputs too small: %s", strings.Join(outputs, ", "))
+}
+
+type InsufficientCollateral struct {
+	// Balance is the collateral inputs minus the collateral return, which may be negative.
+	Balance  int64
+	Required uint64
+}
+
+func (e *InsufficientCollateral) Error() string {
+	return fmt.Sprintf("insufficient collateral: balance %d, required %d", e.Balance, e.Required)
+}
+
+// MissingVKeyWitnesses reports payment or certificate key hashes, hex encoded, which did not sign.
+type MissingVKeyWitnesses struct {
+	KeyHashes []string
+}
+
+func (e *MissingVKeyWitnesses) Error() string {
+	return fmt.Sprintf("missing vkey witnesses: %s", strings.Join(e.KeyHashes, ", "))
+}
+
+// InvalidWitnesses reports hex encoded verification keys whose signatures do not verify.
+type InvalidWitnesses struct {
+	VKeys []string
+}
+
+func (e *InvalidWitnesses) Error() string {
+	return fmt.Sprintf("invalid witnesses: %s", strings.Join(e.VKeys, ", "))
+}
+
+type MissingScriptWitnesses struct {
+	ScriptHashes []string
+}
+
+func (e *MissingScriptWitnesses) Error() string {
+	return fmt.Sprintf("missing script witnesses: %s", strings.Join(e.ScriptHashes, ", "))
+}
+
+// ScriptFailures reports failed script validation: native scripts which do not validate, or Plutus scripts
+// which failed or could not be run.
+type ScriptFailures struct {
+	ScriptHashes []string
+	// Cbor is the node's encoding of the Plutus failures, which include the script's logs.
+	Cbor []byte
+}
+
+func (e *ScriptFailures) Error() string {
+	if len(e.ScriptHashes) > 0 {
+		return fmt.Sprintf("scripts failed: %s", strings.Join(e.ScriptHashes, ", "))
+	}
+	return fmt.Sprintf("plutus scripts failed: %x", e.Cbor)
+}
+
+// EraMismatch reports a transaction for an era other than the node's current one.
+type EraMismatch struct {
+	TxEra     string
+	LedgerEra string
+}
+
+func (e *EraMismatch) Error() string {
+	return fmt.Sprintf("era mismatch: transaction is for %s, ledger is in %s", e.TxEra, e.LedgerEra)
+}
+
+// UnknownFailure is a ledger failure without a typed error, identified by its rule and CBOR tag.
+type UnknownFailure struct {
+	Rule string
+	Tag  uint64
+	Cbor []byte
+}
+
+func (e *UnknownFailure) Error() string {
+	return fmt.Sprintf("%s failure %d: %x", e.Rule, e.Tag, e.Cbor)
+}
+
+// AsRejection replaces a LocalTxSubmission rejection in err by the decoded *Rejection. Other errors, and
+// rejections which fail to decode, are returned unchanged.
+func AsRejection(err error) error {
+	var rejected localtxsubmission.TransactionRejectedError
+	if !errors.As(err, &rejected) {
+		return err
+	}
+	rejection, decodeErr := DecodeRejection(rejected.ReasonCbor)
+	if decodeErr != nil {
+		return err
+	}
+	return rejection
+}
+
+// DecodeRejection decodes the reason of a LocalTxSubmission rejection. Failures of the Conway era are
+// decoded into typed errors; those of earlier eras are decoded by gouroboros.
+func DecodeRejection(reasonCbor []byte) (*Rejection, error) {
+	var outer []cbor.RawMessage
+	if err := cbor.Unmarshal(reasonCbor, &outer); err != nil {
+		return nil, fmt.Errorf("failed to decode rejection: %w", err)
+	}
+	r := &Rejection{Cbor: reasonCbor}
+	switch len(outer) {
+	case 1:
+	case 2:
+		mismatch, err := decodeEraMismatch(outer)
+		if err != nil {
+			return nil, err
+		}
+		r.Failures = []error{mismatch}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unexpected rejection length %d", len(outer))
+	}
+
+	var eraErr struct {
+		_    struct{} `cbor:",toarray"`
+		Era  uint8
+		Errs cbor.RawMessage
+	}
+	if err := cbor.Unmarshal(outer[0], &eraErr); err != nil {
+		return nil, fmt.Errorf("failed to decode rejection era: %w", err)
+	}
+	r.Era = eraErr.Era
+	if r.Era != conwayEra {
+		legacy, err := ledger.NewTxSubmitErrorFromCbor(reasonCbor)
+		if err != nil {
+			return nil, err
+		}
+		r.Failures = []error{legacy}
+		return r, nil
+	}
+
+	var failures []cbor.RawMessage
+	if err := cbor.Unmarshal(eraErr.Errs, &failures); err != nil {
+		return nil, fmt.Errorf("failed to decode ledger failures: %w", err)
+	}
+	for _, failure := range failures {
+		errs, err := decodeLedgerFailure(failure)
+		if err != nil {
+			return nil, err
+		}
+		r.Failures = append(r.Failures, errs...)
+	}
+	return r, nil
+}
+
+func decodeEraMismatch(outer []cbor.RawMessage) (*EraMismatch, error) {
+	var eras [2]struct {
+		_     struct{} `cbor:",toarray"`
+		Index uint8
+		Name  string
+	}
+	for i := range eras {
+		if err := cbor.Unmarshal(outer[i], &eras[i]); err != nil {
+			return nil, fmt.Errorf("failed to decode era mismatch: %w", err)
+		}
+	}
+	return &EraMismatch{TxEra: eras[0].Name, LedgerEra: eras[1].Name}, nil
+}
+
+// decodeSum splits a [tag, fields...] encoded failure.
+func decodeSum(data []byte) (uint64, []cbor.RawMessage, error) {
+	var fields []cbor.RawMessage
+	if err := cbor.Unmarshal(data, &fields); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode failure: %w", err)
+	}
+	if len(fields) == 0 {
+		return 0, nil, fmt.Errorf("empty failure")
+	}
+	var tag uint64
+	if err := cbor.Unmarshal(fields[0], &tag); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode failure tag: %w", err)
+	}
+	return tag, fields[1:], nil
+}
+
+func decodeFields(fields []cbor.RawMessage, dest ...any) error {
+	if len(fields) != len(dest) {
+		return fmt.Errorf("expected %d failure fields, got %d", len(dest), len(fields))
+	}
+	for i, field := range fields {
+		if err := cbor.Unmarshal(field, dest[i]); err != nil {
+			return fmt.Errorf("failed to decode failure field: %w", err)
+		}
+	}
+	return nil
+}
+
+// decodeLedgerFailure decodes a ConwayLedgerPredFailure.
+func decodeLedgerFailure(data []byte) ([]error, error) {
+	tag, fields, err := decodeSum(data)
+	if err != nil {
+		return nil, err
+	}
+	if tag != conwayUtxowFailure || len(fields) != 1 {
+		return []error{unknownFailure("ledger", tag, data)}, nil
+	}
+	return decodeUtxowFailure(fields[0])
+}
+
+// decodeUtxowFailure decodes a ConwayUtxowPredFailure.
+func decodeUtxowFailure(data []byte) ([]error, error) {
+	tag, fields, err := decodeSum(data)
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case 0:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("expected 1 utxo failure, got %d", len(fields))
+		}
+		failure, err := decodeUtxoFailure(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		return []error{failure}, nil
+	case 1:
+		// the verification keys only, not the witnesses holding them
+		var vkeys [][]byte
+		if err := decodeFields(fields, &vkeys); err != nil {
+			return nil, err
+		}
+		return []error{&InvalidWitnesses{VKeys: hexStrings(vkeys)}}, nil
+	case 2:
+		var hashes [][]byte
+		if err := decodeFields(fields, &hashes); err != nil {
+			return nil, err
+		}
+		return []error{&MissingVKeyWitnesses{KeyHashes: hexStrings(hashes)}}, nil
+	case 3:
+		var hashes [][]byte
+		if err := decodeFields(fields, &hashes); err != nil {
+			return nil, err
+		}
+		return []error{&MissingScriptWitnesses{ScriptHashes: hexStrings(hashes)}}, nil
+	case 4:
+		var hashes [][]byte
+		if err := decodeFields(fields, &hashes); err != nil {
+			return nil, err
+		}
+		return []error{&ScriptFailures{ScriptHashes: hexStrings(hashes)}}, nil
+	default:
+		return []error{unknownFailure("utxow", tag, data)}, nil
+	}
+}
+
+// decodeUtxoFailure decodes a ConwayUtxoPredFailure.
+func decodeUtxoFailure(data []byte) (error, error) {
+	tag, fields, err := decodeSum(data)
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case 0:
+		// ConwayUtxosPredFailure, a phase-2 validation failure
+		return &ScriptFailures{Cbor: data}, nil
+	case 1:
+		var inputs []struct {
+			_      struct{} `cbor:",toarray"`
+			TxHash []byte
+			Index  uint16
+		}
+		if err := decodeFields(fields, &inputs); err != nil {
+			return nil, err
+		}
+		e := &BadInputs{}
+		for _, in := range inputs {
+			e.Inputs = append(e.Inputs, tx.TxInput{TxHash: in.TxHash, Index: in.Index})
+		}
+		return e, nil
+	case 2:
+		var interval []cbor.RawMessage
+		e := &OutsideValidityInterval{}
+		if err := decodeFields(fields, &interval, &e.Slot); err != nil {
+			return nil, err
+		}
+		if len(interval) != 2 {
+			return nil, fmt.Errorf("expected validity interval of 2 bounds, got %d", len(interval))
+		}
+		if e.InvalidBefore, err = decodeStrictMaybe(interval[0]); err != nil {
+			return nil, err
+		}
+		if e.InvalidHereafter, err = decodeStrictMaybe(interval[1]); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case 3:
+		e := &MaxTxSize{}
+		return e, decodeFields(fields, &e.ActualSize, &e.MaxSize)
+	case 4:
+		return &InputSetEmpty{}, nil
+	case 5:
+		e := &FeeTooSmall{}
+		return e, decodeFields(fields, &e.MinimumFee, &e.SuppliedFee)
+	case 6:
+		var consumed, produced cbor.RawMessage
+		if err := decodeFields(fields, &consumed, &produced); err != nil {
+			return nil, err
+		}
+		e := &ValueNotConserved{}
+		if e.Consumed, err = decodeValue(consumed); err != nil {
+			return nil, err
+		}
+		if e.Produced, err = decodeValue(produced); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case 7:
+		var addrs []ledger.Address
+		e := &WrongNetwork{}
+		if err := decodeFields(fields, &e.ExpectedNetwork, &addrs); err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			e.Addresses = append(e.Addresses, addr.String())
+		}
+		return e, nil
+	case 9:
+		var outputs []cbor.RawMessage
+		if err := decodeFields(fields, &outputs); err != nil {
+			return nil, err
+		}
+		e := &OutputTooSmall{}
+		for _, out := range outputs {
+			small, err := decodeSmallOutput(out)
+			if err != nil {
+				return nil, err
+			}
+			e.Outputs = append(e.Outputs, small)
+		}
+		return e, nil
+	case 12:
+		e := &InsufficientCollateral{}
+		return e, decodeFields(fields, &e.Balance, &e.Required)
+	case 21:
+		var outputs []struct {
+			_         struct{} `cbor:",toarray"`
+			Output    cbor.RawMessage
+			MinAmount uint64
+		}
+		if err := decodeFields(fields, &outputs); err != nil {
+			return nil, err
+		}
+		e := &OutputTooSmall{}
+		for _, out := range outputs {
+			small, err := decodeSmallOutput(out.Output)
+			if err != nil {
+				return nil, err
+			}
+			small.MinAmount = out.MinAmount
+			e.Outputs = append(e.Outputs, small)
+		}
+		return e, nil
+	default:
+		return unknownFailure("utxo", tag, data), nil
+	}
+}
+
+// decodeStrictMaybe decodes a Haskell StrictMaybe, encoded as an empty or single element list.
+func decodeStrictMaybe(data []byte) (*uint64, error) {
+	var maybe []uint64
+	if err := cbor.Unmarshal(data, &maybe); err != nil {
+		return nil, fmt.Errorf("failed to decode optional value: %w", err)
+	}
+	if len(maybe) == 0 {
+		return nil, nil
+	}
+	return &maybe[0], nil
+}
+
+// decodeValue decodes a coin or a [coin, multiasset] value.
+func decodeValue(data []byte) (Value, error) {
+	var v Value
+	if err := cbor.Unmarshal(data, &v.Coin); err == nil {
+		return v, nil
+	}
+	var withAssets struct {
+		_      struct{} `cbor:",toarray"`
+		Coin   uint64
+		Assets map[cbor.ByteString]map[cbor.ByteString]uint64
+	}
+	if err := cbor.Unmarshal(data, &withAssets); err != nil {
+		return v, fmt.Errorf("failed to decode value: %w", err)
+	}
+	v.Coin = withAssets.Coin
+	v.Assets = make(tx.MultiAsset)
+	for policyId, assets := range withAssets.Assets {
+		for name, quantity := range assets {
+			v.Assets.Add(hex.EncodeToString(policyId.Bytes()), hex.EncodeToString(name.Bytes()), quantity)
+		}
+	}
+	return v, nil
+}
+
+func decodeSmallOutput(data []byte) (SmallOutput, error) {
+	out, err := ledger.NewTransactionOutputFromCbor(data)
+	if err != nil {
+		return SmallOutput{}, fmt.Errorf("failed to decode output: %w", err)
+	}
+	return SmallOutput{Address: out.Address().String(), Amount: out.Amount()}, nil
+}
+
+func unknownFailure(rule string, tag uint64, data []byte) *UnknownFailure {
+	return &UnknownFailure{Rule: rule, Tag: tag, Cbor: data}
+}
+
+func hexStrings(bzs [][]byte) []string {
+	res := make([]string, len(bzs))
+	for i, bz := range bzs {
+		res[i] = hex.EncodeToString(bz)
+	}
+	return res
+}
@@ -0,0 +1,348 @@
+// Package submitter follows submitted transactions until they are confirmed on chain or expire,
+// resubmitting them when they drop out of the mempool.
+package submitter
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/kocubinski/gardano/tx"
+)
+
+// ErrExpired is the error of a transaction whose TTL passed before it was included in a block.
+var ErrExpired = errors.New("transaction expired")
+
+type State int
+
+const (
+	// StateSubmitted is a transaction submitted to the node but not seen in a block.
+	StateSubmitted State = iota
+	// StateInMempool is a submitted transaction the node reported in its mempool.
+	StateInMempool
+	// StateInBlock is a transaction included in a block with fewer than the required confirmations.
+	StateInBlock
+	// StateConfirmed is a transaction with the required confirmations. It is final.
+	StateConfirmed
+	// StateExpired is a transaction whose TTL passed before inclusion. It is final.
+	StateExpired
+)
+
+func (s State) String() string {
+	switch s {
+	case StateSubmitted:
+		return "submitted"
+	case StateInMempool:
+		return "in-mempool"
+	case StateInBlock:
+		return "in-block"
+	case StateConfirmed:
+		return "confirmed"
+	case StateExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Final reports whether no further transitions follow.
+func (s State) Final() bool {
+	return s == StateConfirmed || s == StateExpired
+}
+
+// Status is the lifecycle state of a tracked transaction.
+type Status struct {
+	TxHash string
+	State  State
+	// Block is the point of the including block, if any.
+	Block *common.Point
+	// Depth is the number of blocks on chain from the including block to the tip, including both.
+	Depth       uint64
+	Submissions int
+	// LastError is the error of the most recent failed resubmission.
+	LastError error
+}
+
+// Backend submits transactions to and queries the mempool of a node.
+type Backend interface {
+	Submit(txBz []byte) error
+	InMempool(txHash string) (bool, error)
+}
+
+// Tracked is a transaction followed by a Submitter.
+type Tracked struct {
+	Hash string
+
+	txBz        []byte
+	ttl         uint64
+	blockNumber uint64
+	done        chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Status returns the current status of the transaction.
+func (t *Tracked) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// Done is closed once the transaction reaches a final state.
+func (t *Tracked) Done() <-chan struct{} {
+	return t.done
+}
+
+// Wait blocks until the transaction is confirmed, returning ErrExpired if it expires instead.
+func (t *Tracked) Wait(ctx context.Context) (Status, error) {
+	select {
+	case <-ctx.Done():
+		return t.Status(), ctx.Err()
+	case <-t.done:
+	}
+	status := t.Status()
+	if status.State == StateExpired {
+		return status, fmt.Errorf("%w: tx %s", ErrExpired, t.Hash)
+	}
+	return status, nil
+}
+
+// Submitter submits transactions and tracks them through the blocks fed to RollForward and RollBackward,
+// normally from chain-sync, and periodic mempool checks made by Run.
+type Submitter struct {
+	backend       Backend
+	confirmations uint64
+	interval      time.Duration
+	callback      func(Status)
+
+	mu      sync.Mutex
+	tracked map[string]*Tracked
+	tip     uint64
+	tipSlot uint64
+}
+
+type Option func(*Submitter)
+
+// WithConfirmations sets the depth at which a transaction is confirmed. Defaults to 1, inclusion.
+func WithConfirmations(n uint64) Option {
+	return func(s *Submitter) {
+		s.confirmations = max(n, 1)
+	}
+}
+
+// WithCheckInterval sets how often Run checks that pending transactions are still in the mempool.
+// Defaults to 20s, about one block.
+func WithCheckInterval(d time.Duration) Option {
+	return func(s *Submitter) {
+		s.interval = d
+	}
+}
+
+// WithCallback calls fn synchronously on every status change of every transaction.
+func WithCallback(fn func(Status)) Option {
+	return func(s *Submitter) {
+		s.callback = fn
+	}
+}
+
+func New(backend Backend, opts ...Option) *Submitter {
+	s := &Submitter{
+		backend:       backend,
+		confirmations: 1,
+		interval:      20 * time.Second,
+		tracked:       make(map[string]*Tracked),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Submit submits a signed transaction and starts tracking it. An error means the node rejected it.
+func (s *Submitter) Submit(t *tx.Tx) (*Tracked, error) {
+	txBz, err := t.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	hash, err := t.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash transaction: %w", err)
+	}
+	if err := s.backend.Submit(txBz); err != nil {
+		return nil, fmt.Errorf("failed to submit transaction: %w", err)
+	}
+	tracked := &Tracked{
+		Hash: hex.EncodeToString(hash[:]),
+		txBz: txBz,
+		ttl:  uint64(t.Body.TTL),
+		done: make(chan struct{}),
+	}
+	tracked.status = Status{TxHash: tracked.Hash, State: StateSubmitted, Submissions: 1}
+	s.mu.Lock()
+	s.tracked[tracked.Hash] = tracked
+	s.mu.Unlock()
+	s.notify(tracked.status)
+	return tracked, nil
+}
+
+// RollForward processes a block at the tip of the chain.
+func (s *Submitter) RollForward(block ledger.Block) error {
+	blockHash, err := hex.DecodeString(block.Hash())
+	if err != nil {
+		return fmt.Errorf("invalid block hash: %w", err)
+	}
+	point := common.NewPoint(block.SlotNumber(), blockHash)
+	included := make(map[string]bool)
+	for _, blockTx := range block.Transactions() {
+		// a phase-2 invalid transaction is on chain but only spent its collateral, it is not confirmed
+		if blockTx.IsValid() {
+			included[blockTx.Hash()] = true
+		}
+	}
+
+	s.mu.Lock()
+	s.tip = block.BlockNumber()
+	s.tipSlot = block.SlotNumber()
+	var changed []Status
+	for hash, t := range s.tracked {
+		t.mu.Lock()
+		prev := t.status
+		switch {
+		case included[hash]:
+			t.blockNumber = block.BlockNumber()
+			t.status.State = StateInBlock
+			t.status.Block = &point
+		case t.status.State != StateInBlock && t.ttl > 0 && s.tipSlot >= t.ttl:
+			t.status.State = StateExpired
+		}
+		if t.status.State == StateInBlock {
+			t.status.Depth = s.tip - t.blockNumber + 1
+			if t.status.Depth >= s.confirmations {
+				t.status.State = StateConfirmed
+			}
+		}
+		status := t.status
+		t.mu.Unlock()
+		if status.State.Final() {
+			delete(s.tracked, hash)
+			close(t.done)
+		}
+		if status.State != prev.State || status.Depth != prev.Depth {
+			changed = append(changed, status)
+		}
+	}
+	s.mu.Unlock()
+	for _, status := range changed {
+		s.notify(status)
+	}
+	return nil
+}
+
+// RollBackward reverts all blocks after point. Transactions included in reverted blocks are pending again,
+// and resubmitted by Run if the node dropped them.
+func (s *Submitter) RollBackward(point common.Point) error {
+	s.mu.Lock()
+	s.tipSlot = point.Slot
+	var changed []Status
+	for _, t := range s.tracked {
+		t.mu.Lock()
+		if t.status.State == StateInBlock && t.status.Block.Slot > point.Slot {
+			t.status.State = StateSubmitted
+			t.status.Block = nil
+			t.status.Depth = 0
+			changed = append(changed, t.status)
+		}
+		t.mu.Unlock()
+	}
+	s.mu.Unlock()
+	for _, status := range changed {
+		s.notify(status)
+	}
+	return nil
+}
+
+// Run checks pending transactions against the mempool every check interval until ctx is done,
+// resubmitting those which dropped out while still valid.
+func (s *Submitter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.Check(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Check runs one mempool check, see Run.
+func (s *Submitter) Check() error {
+	s.mu.Lock()
+	var pending []*Tracked
+	for _, t := range s.tracked {
+		if state := t.Status().State; state == StateSubmitted || state == StateInMempool {
+			pending = append(pending, t)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, t := range pending {
+		inMempool, err := s.backend.InMempool(t.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to check mempool: %w", err)
+		}
+		t.mu.Lock()
+		// a block may have arrived in the meantime
+		if state := t.status.State; state != StateSubmitted && state != StateInMempool {
+			t.mu.Unlock()
+			continue
+		}
+		prev := t.status.State
+		if inMempool {
+			t.status.State = StateInMempool
+		} else {
+			// a rejection is expected if the transaction was included in a block not yet processed
+			t.status.Submissions++
+			t.status.LastError = s.backend.Submit(t.txBz)
+			t.status.State = StateSubmitted
+			var outside *OutsideValidityInterval
+			if errors.As(t.status.LastError, &outside) && outside.Expired() {
+				t.status.State = StateExpired
+			}
+		}
+		status := t.status
+		t.mu.Unlock()
+		if status.State.Final() {
+			s.finish(t)
+		}
+		if status.State != prev || !inMempool {
+			s.notify(status)
+		}
+	}
+	return nil
+}
+
+// finish stops tracking t, which reached a final state.
+func (s *Submitter) finish(t *Tracked) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tracked[t.Hash]; ok {
+		delete(s.tracked, t.Hash)
+		close(t.done)
+	}
+}
+
+func (s *Submitter) notify(status Status) {
+	if s.callback != nil {
+		s.callback(status)
+	}
+}
@@ -0,0 +1,212 @@
+package submitter_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxsubmission"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/kocubinski/gardano/internal/ledgertest"
+	. "github.com/kocubinski/gardano/submitter"
+	"github.com/kocubinski/gardano/tx"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	mempool     map[string]bool
+	submissions int
+	rejectErr   error
+}
+
+func (b *fakeBackend) Submit(txBz []byte) error {
+	if b.rejectErr != nil {
+		return b.rejectErr
+	}
+	hash, err := tx.HashFromBytes(txBz)
+	if err != nil {
+		return err
+	}
+	b.submissions++
+	b.mempool[hex.EncodeToString(hash[:])] = true
+	return nil
+}
+
+func (b *fakeBackend) InMempool(txHash string) (bool, error) {
+	return b.mempool[txHash], nil
+}
+
+func newTx(ttl uint32) *tx.Tx {
+	t := tx.NewTx()
+	t.AddInputs(tx.NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 0))
+	t.Body.Fee = 170000
+	t.Body.TTL = ttl
+	return t
+}
+
+func Test_Confirmation(t *testing.T) {
+	backend := &fakeBackend{mempool: make(map[string]bool)}
+	var states []State
+	sub := New(backend, WithConfirmations(2), WithCallback(func(s Status) {
+		states = append(states, s.State)
+	}))
+	tracked, err := sub.Submit(newTx(1000))
+	require.NoError(t, err)
+	require.NoError(t, sub.Check())
+	require.Equal(t, StateInMempool, tracked.Status().State)
+	require.Equal(t, 1, backend.submissions)
+
+	require.NoError(t, sub.RollForward(ledgertest.Block{Number: 1, Txs: []ledger.Transaction{ledgertest.Tx{ID: tracked.Hash}}}))
+	require.Equal(t, StateInBlock, tracked.Status().State)
+	require.Equal(t, uint64(1), tracked.Status().Depth)
+
+	// a rollback of the including block makes the transaction pending again, the node dropped it
+	require.NoError(t, sub.RollBackward(common.NewPoint(0, nil)))
+	require.Equal(t, StateSubmitted, tracked.Status().State)
+	delete(backend.mempool, tracked.Hash)
+	require.NoError(t, sub.Check())
+	require.Equal(t, 2, backend.submissions)
+	require.Equal(t, 2, tracked.Status().Submissions)
+
+	require.NoError(t, sub.RollForward(ledgertest.Block{Number: 1}))
+	require.NoError(t, sub.RollForward(ledgertest.Block{Number: 2, Txs: []ledger.Transaction{ledgertest.Tx{ID: tracked.Hash}}}))
+	require.NoError(t, sub.RollForward(ledgertest.Block{Number: 3}))
+	status, err := tracked.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StateConfirmed, status.State)
+	require.Equal(t, uint64(2), status.Depth)
+	require.Equal(t, uint64(20), status.Block.Slot)
+	require.Equal(t, []State{
+		StateSubmitted, StateInMempool, StateInBlock, StateSubmitted, StateSubmitted, StateInBlock, StateConfirmed,
+	}, states)
+}
+
+func Test_Expiry(t *testing.T) {
+	backend := &fakeBackend{mempool: make(map[string]bool)}
+	sub := New(backend)
+	tracked, err := sub.Submit(newTx(25))
+	require.NoError(t, err)
+
+	// resubmissions after the node dropped the transaction may be rejected, tracking continues until the TTL
+	delete(backend.mempool, tracked.Hash)
+	backend.rejectErr = errors.New("bad inputs")
+	require.NoError(t, sub.Check())
+	require.Equal(t, backend.rejectErr, tracked.Status().LastError)
+
+	require.NoError(t, sub.RollForward(ledgertest.Block{Number: 2}))
+	require.Equal(t, StateSubmitted, tracked.Status().State)
+	require.NoError(t, sub.RollForward(ledgertest.Block{Number: 3}))
+	_, err = tracked.Wait(context.Background())
+	require.ErrorIs(t, err, ErrExpired)
+}
+
+func Test_SubmitRejected(t *testing.T) {
+	backend := &fakeBackend{mempool: make(map[string]bool), rejectErr: errors.New("fee too small")}
+	_, err := New(backend).Submit(newTx(25))
+	require.ErrorIs(t, err, backend.rejectErr)
+}
+
+// conwayRejection encodes ledger failures the way a Conway node rejects a transaction.
+func conwayRejection(t *testing.T, utxoFailures ...[]any) []byte {
+	var failures []any
+	for _, f := range utxoFailures {
+		failures = append(failures, []any{1, []any{0, f}})
+	}
+	bz, err := cbor.Marshal([]any{[]any{6, failures}})
+	require.NoError(t, err)
+	return bz
+}
+
+func Test_DecodeRejection(t *testing.T) {
+	spent, _ := hex.DecodeString("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab")
+	reason := conwayRejection(t,
+		[]any{1, cbor.Tag{Number: 258, Content: []any{[]any{spent, 1}}}},
+		[]any{5, 170000, 168000},
+		[]any{6, 5000000, []any{4830000, map[string]any{}}},
+		[]any{2, []any{[]any{}, []any{100}}, 120},
+		[]any{99},
+	)
+	err := AsRejection(fmt.Errorf("failed to submit: %w", localtxsubmission.TransactionRejectedError{ReasonCbor: reason}))
+
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	require.Len(t, rejection.Failures, 5)
+
+	var badInputs *BadInputs
+	require.ErrorAs(t, err, &badInputs)
+	require.Equal(t, []tx.TxInput{{TxHash: spent, Index: 1}}, badInputs.Inputs)
+
+	var feeTooSmall *FeeTooSmall
+	require.ErrorAs(t, err, &feeTooSmall)
+	require.Equal(t, FeeTooSmall{MinimumFee: 170000, SuppliedFee: 168000}, *feeTooSmall)
+
+	var notConserved *ValueNotConserved
+	require.ErrorAs(t, err, &notConserved)
+	require.Equal(t, uint64(5000000), notConserved.Consumed.Coin)
+	require.Equal(t, uint64(4830000), notConserved.Produced.Coin)
+
+	var outside *OutsideValidityInterval
+	require.ErrorAs(t, err, &outside)
+	require.Nil(t, outside.InvalidBefore)
+	require.True(t, outside.Expired())
+
+	var unknown *UnknownFailure
+	require.ErrorAs(t, err, &unknown)
+	require.Equal(t, uint64(99), unknown.Tag)
+
+	var witnesses *MissingVKeyWitnesses
+	require.False(t, errors.As(err, &witnesses))
+
+	// other errors pass through
+	other := errors.New("connection closed")
+	require.Equal(t, other, AsRejection(other))
+}
+
+func Test_DecodeNodeRejection(t *testing.T) {
+	// the reason of a Conway node rejecting a transaction which spends a spent input and pays too small a
+	// fee: [[6, [ConwayUtxowFailure (UtxoFailure (BadInputsUTxO {..})), ConwayUtxowFailure (UtxoFailure
+	// (FeeTooSmallUTxO ..))]]]
+	reason, _ := hex.DecodeString("818206828201820082" + "01d90102818258" + "20086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab00" +
+		"82018200" + "83051a000298101a00029040")
+	rejection, err := DecodeRejection(reason)
+	require.NoError(t, err)
+	require.Equal(t, uint8(6), rejection.Era)
+	require.Len(t, rejection.Failures, 2)
+	var badInputs *BadInputs
+	require.ErrorAs(t, rejection, &badInputs)
+	require.Equal(t, "086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", hex.EncodeToString(badInputs.Inputs[0].TxHash))
+	var feeTooSmall *FeeTooSmall
+	require.ErrorAs(t, rejection, &feeTooSmall)
+	require.Equal(t, FeeTooSmall{MinimumFee: 170000, SuppliedFee: 168000}, *feeTooSmall)
+
+	// [[6, [ConwayUtxowFailure (InvalidWitnessesUTXOW [VKey ..])]]], the keys are not paired with signatures
+	witnesses, _ := hex.DecodeString("8182068182018201815820" + "3b6a27bcceb6a42d62a3a8d02a6f0d73653215771de243a63ac048a18b59da29")
+	rejection, err = DecodeRejection(witnesses)
+	require.NoError(t, err)
+	var invalid *InvalidWitnesses
+	require.ErrorAs(t, rejection, &invalid)
+	require.Equal(t, []string{"3b6a27bcceb6a42d62a3a8d02a6f0d73653215771de243a63ac048a18b59da29"}, invalid.VKeys)
+
+	// failures of other ledger rules, here certificates, are not utxow failures
+	certs, _ := hex.DecodeString("8182068182028100")
+	rejection, err = DecodeRejection(certs)
+	require.NoError(t, err)
+	var unknown *UnknownFailure
+	require.ErrorAs(t, rejection, &unknown)
+	require.Equal(t, "ledger", unknown.Rule)
+	require.Equal(t, uint64(2), unknown.Tag)
+}
+
+func Test_DecodeEraMismatch(t *testing.T) {
+	bz, err := cbor.Marshal([]any{[]any{5, "Babbage"}, []any{6, "Conway"}})
+	require.NoError(t, err)
+	rejection, err := DecodeRejection(bz)
+	require.NoError(t, err)
+	var mismatch *EraMismatch
+	require.ErrorAs(t, rejection, &mismatch)
+	require.Equal(t, EraMismatch{TxEra: "Babbage", LedgerEra: "Conway"}, *mismatch)
+}
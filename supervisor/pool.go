@@ -0,0 +1,93 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Pool shares a fixed number of connections between concurrent users. Connections are dialed on first use
+// and replaced once they report an error.
+type Pool[C Conn] struct {
+	dial  func(ctx context.Context) (C, error)
+	opts  []Option
+	slots chan *poolSlot[C]
+
+	closeOnce sync.Once
+}
+
+type poolSlot[C Conn] struct {
+	conn C
+	open bool
+}
+
+// NewPool returns a Pool of up to size connections made by dial, which is retried as in Connect.
+func NewPool[C Conn](size int, dial func(ctx context.Context) (C, error), opts ...Option) *Pool[C] {
+	p := &Pool[C]{
+		dial:  dial,
+		opts:  opts,
+		slots: make(chan *poolSlot[C], max(size, 1)),
+	}
+	for range cap(p.slots) {
+		p.slots <- &poolSlot[C]{}
+	}
+	return p
+}
+
+// Do runs fn with a connection no other caller uses at the same time, waiting for one to become free or
+// ctx to be done.
+func (p *Pool[C]) Do(ctx context.Context, fn func(C) error) error {
+	var slot *poolSlot[C]
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case slot = <-p.slots:
+	}
+	defer func() { p.slots <- slot }()
+
+	if slot.open && !alive(slot.conn) {
+		slot.close()
+	}
+	if !slot.open {
+		conn, err := Connect(ctx, p.dial, p.opts...)
+		if err != nil {
+			return err
+		}
+		slot.conn, slot.open = conn, true
+	}
+	err := fn(slot.conn)
+	if !alive(slot.conn) {
+		slot.close()
+	}
+	return err
+}
+
+// Close closes the pool's connections once they are no longer in use.
+func (p *Pool[C]) Close() error {
+	var errs []error
+	p.closeOnce.Do(func() {
+		for range cap(p.slots) {
+			slot := <-p.slots
+			if slot.open {
+				errs = append(errs, slot.conn.Close())
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func (s *poolSlot[C]) close() {
+	s.conn.Close()
+	var zero C
+	s.conn, s.open = zero, false
+}
+
+// alive reports whether conn neither reported an error nor shut down.
+func alive(conn Conn) bool {
+	select {
+	case <-conn.ErrorChan():
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,252 @@
+// Package supervisor keeps a connection to a Cardano node alive, redialing it with exponential backoff
+// whenever it fails.
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Conn is the part of *ouroboros.Connection the supervisor relies on. Errors of the connection's
+// protocols, including keep-alive timeouts, are delivered on ErrorChan, which is closed on shutdown.
+type Conn interface {
+	ErrorChan() chan error
+	Close() error
+}
+
+// ErrConnectionClosed is recorded when a connection shuts down without reporting an error.
+var ErrConnectionClosed = errors.New("connection closed")
+
+type State string
+
+const (
+	StateConnecting State = "connecting"
+	StateConnected  State = "connected"
+	StateBackoff    State = "backoff"
+	StateStopped    State = "stopped"
+)
+
+// Status is a snapshot of the supervised connection's health.
+type Status struct {
+	State          State     `json:"state"`
+	ConnectedSince time.Time `json:"connected_since"`
+	Reconnects     int       `json:"reconnects"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastErrorTime  time.Time `json:"last_error_time"`
+}
+
+type config struct {
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+	log         *slog.Logger
+}
+
+type Option func(*config)
+
+// WithBackoff sets the delay before the first reconnection attempt and the cap it doubles up to.
+// Defaults to 1s and 1m.
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(c *config) {
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithMaxAttempts gives up after n consecutive failed connection attempts. Defaults to 0, retrying forever.
+func WithMaxAttempts(n int) Option {
+	return func(c *config) {
+		c.maxAttempts = n
+	}
+}
+
+func WithLogger(log *slog.Logger) Option {
+	return func(c *config) {
+		c.log = log
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+		log:        slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Supervisor dials a connection, hands it to a start function, and redials when the connection reports
+// an error.
+type Supervisor[C Conn] struct {
+	dial  func(ctx context.Context) (C, error)
+	start func(ctx context.Context, conn C) error
+	cfg   config
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns a Supervisor. start is called for every new connection, e.g. to find the chain-sync
+// intersection from the last processed points; an error from it is treated like a connection failure.
+func New[C Conn](dial func(ctx context.Context) (C, error), start func(ctx context.Context, conn C) error, opts ...Option) *Supervisor[C] {
+	return &Supervisor[C]{
+		dial:   dial,
+		start:  start,
+		cfg:    newConfig(opts),
+		status: Status{State: StateConnecting},
+	}
+}
+
+// Run keeps a connection alive until ctx is done or the maximum number of attempts is exhausted, in which
+// case the last error is returned.
+func (s *Supervisor[C]) Run(ctx context.Context) error {
+	attempts := 0
+	backoff := s.cfg.minBackoff
+	for {
+		s.setState(StateConnecting)
+		connected := time.Now()
+		err := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			s.setState(StateStopped)
+			return ctx.Err()
+		}
+		s.fail(err)
+		// a connection which stayed up for a while starts the backoff over
+		if time.Since(connected) > s.cfg.maxBackoff {
+			attempts = 0
+			backoff = s.cfg.minBackoff
+		}
+		attempts++
+		if s.cfg.maxAttempts > 0 && attempts >= s.cfg.maxAttempts {
+			s.setState(StateStopped)
+			return err
+		}
+		delay := Jitter(backoff)
+		s.cfg.log.Warn("node connection failed, reconnecting", "error", err, "delay", delay, "attempt", attempts)
+		s.setState(StateBackoff)
+		select {
+		case <-ctx.Done():
+			s.setState(StateStopped)
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, s.cfg.maxBackoff)
+	}
+}
+
+func (s *Supervisor[C]) runOnce(ctx context.Context) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// start may block on the protocols, so errors are watched while it runs
+	errs := conn.ErrorChan()
+	started := make(chan error, 1)
+	go func() {
+		started <- s.start(ctx, conn)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-started:
+			if err != nil {
+				return err
+			}
+			s.connected()
+			started = nil
+		case err, ok := <-errs:
+			if !ok || err == nil {
+				return ErrConnectionClosed
+			}
+			return err
+		}
+	}
+}
+
+func (s *Supervisor[C]) setState(state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.State = state
+	if state != StateConnected {
+		s.status.ConnectedSince = time.Time{}
+	}
+}
+
+func (s *Supervisor[C]) connected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.LastError != "" {
+		s.status.Reconnects++
+	}
+	s.status.State = StateConnected
+	s.status.ConnectedSince = time.Now()
+}
+
+func (s *Supervisor[C]) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastError = err.Error()
+	s.status.LastErrorTime = time.Now()
+}
+
+// Status returns the current health of the connection.
+func (s *Supervisor[C]) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// ServeHTTP reports Status as JSON, with status code 200 while connected and 503 otherwise.
+func (s *Supervisor[C]) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	status := s.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if status.State != StateConnected {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// Jitter returns a random duration in [d/2, d), spreading out reconnections of many clients.
+func Jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
+
+// Connect dials with the same backoff as a Supervisor until a connection is established, the maximum
+// number of attempts is exhausted or ctx is done. It suits one-shot commands that need a connection but
+// not its supervision.
+func Connect[C Conn](ctx context.Context, dial func(ctx context.Context) (C, error), opts ...Option) (C, error) {
+	cfg := newConfig(opts)
+	backoff := cfg.minBackoff
+	for attempt := 1; ; attempt++ {
+		conn, err := dial(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		if cfg.maxAttempts > 0 && attempt >= cfg.maxAttempts {
+			return conn, err
+		}
+		delay := Jitter(backoff)
+		cfg.log.Warn("node connection failed, retrying", "error", err, "delay", delay, "attempt", attempt)
+		select {
+		case <-ctx.Done():
+			return conn, ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, cfg.maxBackoff)
+	}
+}
@@ -0,0 +1,139 @@
+package supervisor_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/kocubinski/gardano/supervisor"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConn struct {
+	errs   chan error
+	closed atomic.Bool
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{errs: make(chan error, 1)}
+}
+
+func (c *fakeConn) ErrorChan() chan error { return c.errs }
+func (c *fakeConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func Test_Supervisor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var dials atomic.Int32
+	conns := make(chan *fakeConn, 10)
+	dial := func(context.Context) (*fakeConn, error) {
+		if dials.Add(1) == 1 {
+			return nil, errors.New("connection refused")
+		}
+		c := newFakeConn()
+		conns <- c
+		return c, nil
+	}
+	var starts atomic.Int32
+	start := func(context.Context, *fakeConn) error {
+		starts.Add(1)
+		return nil
+	}
+	s := New(dial, start, WithBackoff(time.Millisecond, 10*time.Millisecond))
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	// the failed first dial is retried
+	first := <-conns
+	require.Eventually(t, func() bool { return s.Status().State == StateConnected }, time.Second, time.Millisecond)
+	require.Equal(t, 1, s.Status().Reconnects)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// a keep-alive failure redials and starts again
+	first.errs <- errors.New("keep-alive timeout")
+	second := <-conns
+	require.Eventually(t, func() bool { return starts.Load() == 2 && s.Status().State == StateConnected }, time.Second, time.Millisecond)
+	require.True(t, first.closed.Load())
+	require.Equal(t, "keep-alive timeout", s.Status().LastError)
+
+	// a closed error channel is a dead connection too
+	close(second.errs)
+	<-conns
+	require.Eventually(t, func() bool { return starts.Load() == 3 }, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Equal(t, StateStopped, s.Status().State)
+}
+
+func Test_MaxAttempts(t *testing.T) {
+	dialErr := errors.New("connection refused")
+	dial := func(context.Context) (*fakeConn, error) { return nil, dialErr }
+	start := func(context.Context, *fakeConn) error { return nil }
+	s := New(dial, start, WithBackoff(time.Millisecond, time.Millisecond), WithMaxAttempts(3))
+	require.ErrorIs(t, s.Run(context.Background()), dialErr)
+
+	_, err := Connect(context.Background(), dial, WithBackoff(time.Millisecond, time.Millisecond), WithMaxAttempts(2))
+	require.ErrorIs(t, err, dialErr)
+}
+
+func Test_Jitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := Jitter(time.Second)
+		require.GreaterOrEqual(t, d, 500*time.Millisecond)
+		require.Less(t, d, time.Second)
+	}
+}
+
+func Test_Pool(t *testing.T) {
+	var dialed []*fakeConn
+	dial := func(context.Context) (*fakeConn, error) {
+		c := newFakeConn()
+		dialed = append(dialed, c)
+		return c, nil
+	}
+	p := NewPool(1, dial)
+
+	var used *fakeConn
+	require.NoError(t, p.Do(context.Background(), func(c *fakeConn) error {
+		used = c
+		return nil
+	}))
+	require.NoError(t, p.Do(context.Background(), func(c *fakeConn) error {
+		require.Same(t, used, c)
+		return nil
+	}))
+	require.Len(t, dialed, 1)
+
+	// a failed connection is closed and redialed by the next caller
+	used.errs <- errors.New("keep-alive timeout")
+	require.NoError(t, p.Do(context.Background(), func(c *fakeConn) error {
+		require.NotSame(t, used, c)
+		return nil
+	}))
+	require.True(t, used.closed.Load())
+	require.Len(t, dialed, 2)
+
+	// callers wait for a free connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.NoError(t, p.Do(context.Background(), func(*fakeConn) error {
+		require.ErrorIs(t, p.Do(ctx, func(*fakeConn) error { return nil }), context.DeadlineExceeded)
+		return nil
+	}))
+
+	require.NoError(t, p.Close())
+	require.True(t, dialed[1].closed.Load())
+}
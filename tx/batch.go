@@ -0,0 +1,259 @@
+package tx
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/kocubinski/gardano/address"
+	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
+)
+
+// minUTxOOverhead is the size in bytes the ledger adds to an output when computing its minimum ada.
+const minUTxOOverhead = 160
+
+// Payout is a payment of Amount lovelace to Address, one output of a batch.
+type Payout struct {
+	Address address.Address
+	Amount  uint64
+}
+
+// PayoutReceipt locates the output paying a payout, in the order of the payouts given to BatchPayout.
+type PayoutReceipt struct {
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"`
+	// Tx is the position of the transaction in the batch, which is also the order to submit them in.
+	Tx    int    `json:"tx"`
+	TxId  string `json:"txId"`
+	Index int    `json:"index"`
+}
+
+// Batch is a sequence of transactions paying a list of payouts. Each transaction after the first spends
+// the change of the one before it, so they must be submitted in order.
+type Batch struct {
+	Txs      []*TxBuilder
+	Receipts []PayoutReceipt
+}
+
+type batchConfig struct {
+	ttl          uint32
+	memo         string
+	memoOpts     []MemoOption
+	witnessCount int
+}
+
+type BatchOption func(*batchConfig)
+
+// WithBatchTTL sets the TTL of every transaction of the batch.
+func WithBatchTTL(ttl uint32) BatchOption {
+	return func(c *batchConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithBatchWitnessCount sets the number of keys which will sign every transaction of the batch, for their
+// witnesses to be accounted for in the fees and sizes. It defaults to 1, see WithWitnessCount.
+func WithBatchWitnessCount(count int) BatchOption {
+	return func(c *batchConfig) {
+		c.witnessCount = count
+	}
+}
+
+// WithBatchMemo sets a memo on every transaction of the batch, followed by the position of the transaction
+// when there are several, like "payouts (2/3)". opts may encrypt it, see WithPassphrase.
+func WithBatchMemo(memo string, opts ...MemoOption) BatchOption {
+	return func(c *batchConfig) {
+		c.memo = memo
+		c.memoOpts = opts
+	}
+}
+
+// BatchPayout builds the transactions paying payouts from utxos, returning the change to changeAddr. Each
+// transaction holds as many payouts as fit under the maximum transaction size of protocol, in the order
+// given, and spends the change of the previous one plus as many of utxos as it needs. Like SelectInputs,
+// it leaves utxos holding native assets or locked by a script unspent.
+func BatchPayout(protocol *utxocardano.PParams, payouts []Payout, utxos []TxInput, changeAddr address.Address, opts ...BatchOption) (*Batch, error) {
+	c := &batchConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if len(payouts) == 0 {
+		return nil, fmt.Errorf("no payouts")
+	}
+	for i, p := range payouts {
+		if p.Address.Equals(changeAddr) {
+			return nil, fmt.Errorf("payout %d pays the change address", i)
+		}
+		if minimum := minLovelace(protocol, NewTxOutput(p.Address, p.Amount)); p.Amount < minimum {
+			return nil, fmt.Errorf("payout %d of %d lovelace is less than the minimum of %d", i, p.Amount, minimum)
+		}
+	}
+
+	pool := spendable(utxos)
+	// the first pass packs the payouts with the longest memo suffix any transaction can have, the second
+	// builds the transactions with their final memos, which are no longer
+	var sizes []int
+	b := batcher{config: c, protocol: protocol, changeAddr: changeAddr, pool: pool}
+	for rest := payouts; len(rest) > 0; {
+		tb, n, err := b.pack(rest, len(payouts), len(payouts))
+		if err != nil {
+			return nil, err
+		}
+		b.next(tb)
+		sizes = append(sizes, n)
+		rest = rest[n:]
+	}
+
+	batch := &Batch{}
+	b = batcher{config: c, protocol: protocol, changeAddr: changeAddr, pool: pool}
+	for i, n := range sizes {
+		group := payouts[:n]
+		payouts = payouts[n:]
+		tb, err := b.build(group, i+1, len(sizes))
+		if err != nil {
+			return nil, err
+		}
+		if err := b.checkSize(tb); err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		hash, err := tb.tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+		for j, p := range group {
+			batch.Receipts = append(batch.Receipts, PayoutReceipt{
+				Address: p.Address.String(),
+				Amount:  p.Amount,
+				Tx:      i,
+				TxId:    hex.EncodeToString(hash[:]),
+				Index:   j,
+			})
+		}
+		batch.Txs = append(batch.Txs, tb)
+		b.next(tb)
+	}
+	return batch, nil
+}
+
+// batcher builds the transactions of a batch one after the other.
+type batcher struct {
+	config     *batchConfig
+	protocol   *utxocardano.PParams
+	changeAddr address.Address
+	// pool holds the utxos not yet spent by earlier transactions
+	pool []TxInput
+	// change is the change output of the previous transaction
+	change *TxInput
+}
+
+// pack returns the transaction of the longest prefix of payouts which fits, and the length of the prefix.
+// The size of a transaction grows with its payouts, so the prefix is binary searched.
+func (b *batcher) pack(payouts []Payout, position, total int) (*TxBuilder, int, error) {
+	fits, err := b.build(payouts[:1], position, total)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := b.checkSize(fits); err != nil {
+		return nil, 0, fmt.Errorf("payout to %s: %w", payouts[0].Address, err)
+	}
+	// the prefix of length lo fits, the longest one is at most hi long
+	lo, hi := 1, len(payouts)
+	for lo < hi {
+		n := lo + (hi-lo+1)/2
+		tb, err := b.build(payouts[:n], position, total)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := b.checkSize(tb); err != nil {
+			hi = n - 1
+			continue
+		}
+		fits, lo = tb, n
+	}
+	return fits, lo, nil
+}
+
+func (b *batcher) checkSize(tb *TxBuilder) error {
+	bz, err := tb.tx.Bytes()
+	if err != nil {
+		return err
+	}
+	if limit := b.protocol.GetMaxTxSize(); uint64(len(bz)) > limit {
+		return fmt.Errorf("transaction of %d bytes exceeds the maximum size of %d bytes", len(bz), limit)
+	}
+	return nil
+}
+
+// build returns the transaction paying payouts, with the fee calculated.
+func (b *batcher) build(payouts []Payout, position, total int) (*TxBuilder, error) {
+	var opts []TxBuilderOption
+	if b.config.witnessCount > 0 {
+		opts = append(opts, WithWitnessCount(b.config.witnessCount))
+	}
+	tb := NewTxBuilder(b.protocol, opts...)
+	var amount uint64
+	for _, p := range payouts {
+		tb.AddOutputs(NewTxOutput(p.Address, p.Amount))
+		amount += p.Amount
+	}
+	// reserve the largest fee and a change output worth keeping, so that subtracting the fee from the
+	// change can't underflow
+	maxFee := b.protocol.GetMinFeeCoefficient()*b.protocol.GetMaxTxSize() + b.protocol.GetMinFeeConstant()
+	need := amount + maxFee + minLovelace(b.protocol, NewTxOutput(b.changeAddr, amount))
+	var have uint64
+	if b.change != nil {
+		tb.AddInputs(*b.change)
+		have = b.change.Amount
+	}
+	for _, utxo := range b.pool {
+		if have >= need {
+			break
+		}
+		tb.AddInputs(utxo)
+		have += utxo.Amount
+	}
+	if have < need {
+		return nil, fmt.Errorf("insufficient funds; short by %d", need-have)
+	}
+	tb.SetTTL(b.config.ttl)
+	if err := tb.AddChangeIfNeeded(b.changeAddr); err != nil {
+		return nil, err
+	}
+	if b.config.memo != "" {
+		memo := b.config.memo
+		if total > 1 {
+			memo = fmt.Sprintf("%s (%d/%d)", memo, position, total)
+		}
+		if err := tb.SetMemo(memo, b.config.memoOpts...); err != nil {
+			return nil, err
+		}
+	}
+	if err := tb.CalculateFee(); err != nil {
+		return nil, err
+	}
+	return tb, nil
+}
+
+// next removes the utxos spent by tb from the pool and makes its change the first input of the next
+// transaction.
+func (b *batcher) next(tb *TxBuilder) {
+	ins := tb.tx.Body.Inputs.TxIns
+	if b.change != nil {
+		ins = ins[1:]
+	}
+	b.pool = b.pool[len(ins):]
+	hash, _ := tb.tx.Hash()
+	outs := tb.tx.Body.Outputs
+	change := NewTxInput(hex.EncodeToString(hash[:]), uint16(len(outs)-1), outs[len(outs)-1].Amount)
+	change.Address = b.changeAddr
+	b.change = &change
+}
+
+// minLovelace returns the minimum ada of an output.
+func minLovelace(protocol *utxocardano.PParams, out TxOutput) uint64 {
+	bz, err := cbor.Marshal(out)
+	if err != nil {
+		return 0
+	}
+	return (minUTxOOverhead + uint64(len(bz))) * protocol.GetCoinsPerUtxoByte()
+}
@@ -3,8 +3,12 @@ package tx
 import (
 	"crypto/ed25519"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/metadata"
+	"github.com/kocubinski/gardano/slotting"
 	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
 )
 
@@ -14,6 +18,7 @@ type TxBuilder struct {
 	witnessCount int
 	protocol     *utxocardano.PParams
 	changeAddr   address.Address
+	slotting     *slotting.Summary
 }
 
 func (tb *TxBuilder) CalculateFee() error {
@@ -105,31 +110,83 @@ func (tb *TxBuilder) SetTTL(ttl uint32) {
 	tb.tx.Body.TTL = ttl
 }
 
-// SetMemo sets the memo for the transaction as specified in https://cips.cardano.org/cip/CIP-20
-func (tb *TxBuilder) SetMemo(memo string) error {
-	if len(memo) == 0 {
-		return nil
-	}
-	memos := []string{}
-	for len(memo) > 64 {
-		memos = append(memos, memo[:64])
-		memo = memo[64:]
+// SetValidUntil sets the TTL so that the transaction can't be included in a block after t. It needs the
+// WithSlotting option.
+func (tb *TxBuilder) SetValidUntil(t time.Time) error {
+	slot, err := tb.slot(t, tb.slotting.ValidUntil)
+	if err != nil {
+		return err
 	}
-	if len(memo) > 0 {
-		memos = append(memos, memo)
+	tb.tx.Body.TTL = slot
+	return nil
+}
+
+// SetValidFrom sets the validity interval start so that the transaction can't be included in a block before
+// t. It needs the WithSlotting option.
+func (tb *TxBuilder) SetValidFrom(t time.Time) error {
+	slot, err := tb.slot(t, tb.slotting.ValidFrom)
+	if err != nil {
+		return err
 	}
+	tb.tx.Body.ValidityStart = slot
+	return nil
+}
 
-	if tb.tx.Metadata == nil {
-		tb.tx.Metadata = make(map[uint64]any)
+func (tb *TxBuilder) slot(t time.Time, convert func(time.Time) (uint64, error)) (uint32, error) {
+	if tb.slotting == nil {
+		return 0, fmt.Errorf("no era history to convert time to slots, see WithSlotting")
 	}
-	msgEnvelope := make(map[string][]string)
-	msgEnvelope["msg"] = memos
+	slot, err := convert(t)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s to a slot: %w", t.Format(time.RFC3339), err)
+	}
+	if slot > math.MaxUint32 {
+		return 0, fmt.Errorf("slot %d out of range", slot)
+	}
+	return uint32(slot), nil
+}
 
+// SetMemo sets the memo for the transaction as specified in https://cips.cardano.org/cip/CIP-20, encrypted
+// as in https://cips.cardano.org/cip/CIP-83 if a passphrase is given with WithPassphrase.
+func (tb *TxBuilder) SetMemo(memo string, opts ...MemoOption) error {
+	if len(memo) == 0 {
+		return nil
+	}
 	if _, ok := tb.tx.Metadata[674]; ok {
 		return fmt.Errorf("memo already set")
 	}
-	tb.tx.Metadata[674] = msgEnvelope
+	msg := metadata.ChunkString(memo)
+	c := newMemoConfig(opts)
+	if c.passphrase == "" {
+		return tb.AddMetadata(metadata.Metadata{674: metadata.Map{{Key: "msg", Value: msg}}})
+	}
+	encrypted, err := EncryptMemo(msg, c.passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt memo: %w", err)
+	}
+	return tb.AddMetadata(metadata.Metadata{674: metadata.Map{
+		{Key: "enc", Value: MemoEncryptionBasic},
+		{Key: "msg", Value: encrypted},
+	}})
+}
 
+// AddMetadata adds the labels of md to the transaction's metadata. It fails if md is not valid metadata or
+// a label is already set.
+func (tb *TxBuilder) AddMetadata(md metadata.Metadata) error {
+	if err := md.Validate(); err != nil {
+		return fmt.Errorf("invalid metadata: %w", err)
+	}
+	for label := range md {
+		if _, ok := tb.tx.Metadata[label]; ok {
+			return fmt.Errorf("metadata label %d already set", label)
+		}
+	}
+	if tb.tx.Metadata == nil {
+		tb.tx.Metadata = make(metadata.Metadata, len(md))
+	}
+	for label, v := range md {
+		tb.tx.Metadata[label] = v
+	}
 	return nil
 }
 
@@ -174,3 +231,10 @@ func WithWitnessCount(count int) TxBuilderOption {
 		tb.witnessCount = count
 	}
 }
+
+// WithSlotting sets the era history SetValidUntil and SetValidFrom convert times with.
+func WithSlotting(s *slotting.Summary) TxBuilderOption {
+	return func(tb *TxBuilder) {
+		tb.slotting = s
+	}
+}
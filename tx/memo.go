@@ -0,0 +1,139 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kocubinski/gardano/metadata"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// MemoEncryptionBasic is the enc value of memos encrypted as in https://cips.cardano.org/cip/CIP-83: the
+// JSON of the msg field is encrypted with aes-256-cbc in the salted format of `openssl enc -aes-256-cbc
+// -pbkdf2 -iter 10000 -md sha256`, and the base64 of the result replaces the msg array, split in chunks.
+const MemoEncryptionBasic = "basic"
+
+// DefaultMemoPassphrase is the passphrase CIP-83 memos are encrypted with when the sender sets none. Memos
+// are decrypted with it when no passphrase is given.
+const DefaultMemoPassphrase = "cardano"
+
+const (
+	// memoSaltMagic starts the salted format of openssl, followed by the salt.
+	memoSaltMagic  = "Salted__"
+	memoSaltSize   = 8
+	memoIterations = 10000
+	memoKeySize    = 32
+)
+
+// ErrEncryptedMemo is returned when decoding an encrypted memo without a passphrase that opens it.
+var ErrEncryptedMemo = errors.New("memo is encrypted")
+
+type memoConfig struct {
+	passphrase string
+}
+
+// MemoOption configures how memos are encoded and decoded.
+type MemoOption func(*memoConfig)
+
+// WithPassphrase encrypts memos set with SetMemo, and decrypts memos read with DecodeMemo, with passphrase.
+func WithPassphrase(passphrase string) MemoOption {
+	return func(c *memoConfig) {
+		c.passphrase = passphrase
+	}
+}
+
+func newMemoConfig(opts []MemoOption) memoConfig {
+	var c memoConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// memoContent is the encrypted JSON of a memo.
+type memoContent struct {
+	Msg []string `json:"msg"`
+}
+
+// EncryptMemo encrypts the msg array of a memo with passphrase, returning the chunks of the new msg array.
+func EncryptMemo(msg []string, passphrase string) ([]string, error) {
+	plaintext, err := json.Marshal(memoContent{Msg: msg})
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, memoSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	block, iv, err := memoCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	// PKCS#7 padding
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	plaintext = append(plaintext, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	header := len(memoSaltMagic) + memoSaltSize
+	sealed := make([]byte, header+len(plaintext))
+	copy(sealed, memoSaltMagic)
+	copy(sealed[len(memoSaltMagic):], salt)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(sealed[header:], plaintext)
+	return metadata.ChunkString(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// DecryptMemo opens the msg array of a memo encrypted by EncryptMemo or another CIP-83 implementation. It
+// returns an error wrapping ErrEncryptedMemo if passphrase is wrong.
+func DecryptMemo(msg []string, passphrase string) ([]string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(strings.Join(msg, "")), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted memo: %w", err)
+	}
+	header := len(memoSaltMagic) + memoSaltSize
+	if !bytes.HasPrefix(sealed, []byte(memoSaltMagic)) {
+		return nil, fmt.Errorf("invalid encrypted memo: no salt")
+	}
+	if len(sealed) < header+aes.BlockSize || (len(sealed)-header)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted memo: %d bytes is not a whole number of blocks", len(sealed))
+	}
+	block, iv, err := memoCipher(passphrase, sealed[len(memoSaltMagic):header])
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(sealed)-header)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, sealed[header:])
+
+	// a wrong key shows as invalid padding, or, rarely, as garbage JSON
+	wrong := fmt.Errorf("%w: wrong passphrase or corrupted memo", ErrEncryptedMemo)
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, wrong
+	}
+	plaintext = plaintext[:len(plaintext)-pad]
+	var content memoContent
+	if err := json.Unmarshal(plaintext, &content); err == nil && content.Msg != nil {
+		return content.Msg, nil
+	}
+	// some implementations encrypt the msg array alone
+	var res []string
+	if err := json.Unmarshal(plaintext, &res); err != nil {
+		return nil, wrong
+	}
+	return res, nil
+}
+
+// memoCipher derives the key and IV of a memo from passphrase and salt like `openssl enc -pbkdf2`.
+func memoCipher(passphrase string, salt []byte) (cipher.Block, []byte, error) {
+	keyIV := pbkdf2.Key([]byte(passphrase), salt, memoIterations, memoKeySize+aes.BlockSize, sha256.New)
+	block, err := aes.NewCipher(keyIV[:memoKeySize])
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, keyIV[memoKeySize:], nil
+}
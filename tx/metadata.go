@@ -2,40 +2,24 @@ package tx
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/blinklabs-io/gouroboros/cbor"
 	"github.com/blinklabs-io/gouroboros/ledger/common"
 )
 
-func DecodeMemo(tx common.Transaction) (string, error) {
-	return DecodeMemoFromMetadata(tx.Metadata())
+// DecodeMemo returns the CIP-20 memo of a transaction, see DecodeMemoFromMetadata.
+func DecodeMemo(tx common.Transaction, opts ...MemoOption) (string, error) {
+	return DecodeMemoFromMetadata(tx.Metadata(), opts...)
 }
 
-func DecodeMemoFromMetadata(val *cbor.LazyValue) (string, error) {
-	if val == nil {
-		return "", nil
-	}
-	if val.Value() == nil {
-		if val.Cbor() == nil {
-			return "", nil
-		}
-		_, err := val.Decode()
-		if err != nil {
-			return "", err
-		}
-	}
-
-	var md map[any]any
-	switch v := val.Value().(type) {
-	case map[any]any:
-		md = v
-	case cbor.Map:
-		md = map[any]any(v)
-	case []any:
-		// ignore this case
-		return "", nil
-	default:
-		return "", fmt.Errorf("failed to cast metadata want: map[any]any got: %T", val.Value())
+// DecodeMemoFromMetadata returns the CIP-20 memo of transaction metadata, or an empty memo if there is
+// none. A memo encrypted as in CIP-83 is decrypted with the passphrase given by WithPassphrase, or else
+// with DefaultMemoPassphrase, and decoding it fails with ErrEncryptedMemo if the passphrase is wrong.
+func DecodeMemoFromMetadata(val *cbor.LazyValue, opts ...MemoOption) (string, error) {
+	md, err := decodeMetadata(val)
+	if err != nil || md == nil {
+		return "", err
 	}
 
 	x, ok := md[uint64(674)]
@@ -51,21 +35,75 @@ func DecodeMemoFromMetadata(val *cbor.LazyValue) (string, error) {
 		return "", nil
 	}
 
+	var msgs []string
 	switch v := x.(type) {
 	case string:
-		return v, nil
+		msgs = []string{v}
 	case []any:
-		var memo string
 		for _, anyMsg := range v {
 			msg, ok := anyMsg.(string)
 			if !ok {
 				return "", fmt.Errorf("failed to cast memo want: string got: %T", anyMsg)
 			}
-			memo += msg
+			msgs = append(msgs, msg)
 		}
-		return memo, nil
 	default:
 		return "", fmt.Errorf("unknown memo type %T", x)
 	}
 
+	if enc, ok := envelope["enc"]; ok {
+		if enc != MemoEncryptionBasic {
+			return "", fmt.Errorf("unsupported memo encryption %v", enc)
+		}
+		c := newMemoConfig(opts)
+		if c.passphrase == "" {
+			c.passphrase = DefaultMemoPassphrase
+		}
+		if msgs, err = DecryptMemo(msgs, c.passphrase); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(msgs, ""), nil
+}
+
+// MetadataLabels returns the top level labels of a transaction's metadata.
+func MetadataLabels(val *cbor.LazyValue) ([]uint64, error) {
+	md, err := decodeMetadata(val)
+	if err != nil {
+		return nil, err
+	}
+	var labels []uint64
+	for k := range md {
+		if label, ok := k.(uint64); ok {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+func decodeMetadata(val *cbor.LazyValue) (map[any]any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	if val.Value() == nil {
+		if val.Cbor() == nil {
+			return nil, nil
+		}
+		_, err := val.Decode()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch v := val.Value().(type) {
+	case map[any]any:
+		return v, nil
+	case cbor.Map:
+		return map[any]any(v), nil
+	case []any:
+		// ignore this case
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to cast metadata want: map[any]any got: %T", val.Value())
+	}
 }
@@ -2,11 +2,15 @@ package tx
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/hex"
 	"fmt"
+	"slices"
 
+	"github.com/blinklabs-io/gouroboros/ledger/common"
 	"github.com/fxamacker/cbor/v2"
 	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/metadata"
 	"golang.org/x/crypto/blake2b"
 )
 
@@ -15,7 +19,7 @@ type Tx struct {
 	Body       TxBody
 	WitnessSet WitnessSet
 	Valid      bool
-	Metadata   map[uint64]any
+	Metadata   metadata.Metadata
 }
 
 // NewTx returns a pointer to a new Transaction
@@ -62,9 +66,22 @@ func (t *Tx) Hash() ([32]byte, error) {
 	return txHash, nil
 }
 
+// HashFromBytes returns the transaction id of a CBOR encoded transaction by hashing its body as it was
+// serialized, which makes it usable for transactions not built by gardano.
+func HashFromBytes(txBz []byte) ([32]byte, error) {
+	var parts []cbor.RawMessage
+	if err := cbor.Unmarshal(txBz, &parts); err != nil {
+		return [32]byte{}, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	if len(parts) < 3 {
+		return [32]byte{}, fmt.Errorf("invalid transaction: expected at least 3 elements, got %d", len(parts))
+	}
+	return blake2b.Sum256(parts[0]), nil
+}
+
 func (t *Tx) CalculateAuxiliaryDataHash() error {
 	if t.Metadata != nil {
-		mdBytes, err := cbor.Marshal(&t.Metadata)
+		mdBytes, err := cbor.Marshal(t.Metadata)
 		if err != nil {
 			return fmt.Errorf("cannot serialize metadata: %w", err)
 		}
@@ -106,6 +123,7 @@ type TxBody struct {
 	Fee               uint64     `cbor:"2,keyasint"`
 	TTL               uint32     `cbor:"3,keyasint,omitempty"`
 	AuxiliaryDataHash []byte     `cbor:"7,keyasint,omitempty"`
+	ValidityStart     uint32     `cbor:"8,keyasint,omitempty"`
 }
 
 // NewTxBody returns a pointer to a new transaction body.
@@ -135,6 +153,42 @@ type TxInput struct {
 	TxHash []byte
 	Index  uint16
 	Amount uint64
+
+	// The fields below describe the output being spent. They are not part of the
+	// input's CBOR encoding and are only populated by providers which resolve them.
+	Address    address.Address
+	Assets     MultiAsset
+	DatumHash  []byte
+	Datum      []byte
+	ScriptHash []byte
+	Script     []byte
+}
+
+// MultiAsset maps hex encoded policy ids to hex encoded asset names and their quantities.
+type MultiAsset map[string]map[string]uint64
+
+// Add adds quantity of the asset identified by policy id and asset name.
+func (ma MultiAsset) Add(policyId, assetName string, quantity uint64) {
+	assets, ok := ma[policyId]
+	if !ok {
+		assets = make(map[string]uint64)
+		ma[policyId] = assets
+	}
+	assets[assetName] += quantity
+}
+
+// NewMultiAssetFromLedger converts the assets of a gouroboros transaction output.
+func NewMultiAssetFromLedger(assets *common.MultiAsset[common.MultiAssetTypeOutput]) MultiAsset {
+	if assets == nil {
+		return nil
+	}
+	ma := make(MultiAsset)
+	for _, policyId := range assets.Policies() {
+		for _, name := range assets.Assets(policyId) {
+			ma.Add(hex.EncodeToString(policyId.Bytes()), hex.EncodeToString(name), assets.Asset(policyId, name))
+		}
+	}
+	return ma
 }
 
 // NewTxInput creates and returns a *TxInput from Transaction Hash(Hex Encoded), Transaction Index and Amount.
@@ -161,6 +215,39 @@ func (txI *TxInput) MarshalCBOR() ([]byte, error) {
 	return cbor.Marshal(input)
 }
 
+// spendable returns the utxos a payment may spend. The change of a payment is lovelace only, so utxos
+// holding native assets are left out, as are those locked by a script, which a key witness can't spend.
+func spendable(utxos []TxInput) []TxInput {
+	var res []TxInput
+	for _, utxo := range utxos {
+		if cred, ok := utxo.Address.PaymentCredential(); len(utxo.Assets) > 0 || ok && cred.Script {
+			continue
+		}
+		res = append(res, utxo)
+	}
+	return res
+}
+
+// SelectInputs picks utxos worth at least amount, smallest first, out of those a payment may spend.
+func SelectInputs(utxos []TxInput, amount uint64) ([]TxInput, error) {
+	spendable := spendable(utxos)
+	slices.SortStableFunc(spendable, func(a, b TxInput) int { return cmp.Compare(a.Amount, b.Amount) })
+
+	var res []TxInput
+	var have uint64
+	for _, utxo := range spendable {
+		if have >= amount {
+			break
+		}
+		res = append(res, utxo)
+		have += utxo.Amount
+	}
+	if have < amount {
+		return nil, fmt.Errorf("insufficient funds; short by %d", amount-have)
+	}
+	return res, nil
+}
+
 type TxOutput struct {
 	_       struct{} `cbor:",toarray"`
 	Address address.Address
@@ -1,13 +1,21 @@
 package tx_test
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"encoding/hex"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/blinklabs-io/gouroboros/cbor"
 	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/metadata"
+	"github.com/kocubinski/gardano/slotting"
 	. "github.com/kocubinski/gardano/tx"
 	"github.com/stretchr/testify/require"
+	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
 )
 
 func addrFromBech32(t *testing.T, addrBech32 string) address.Address {
@@ -108,3 +116,274 @@ func Test_Metadata(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "foo+bar-baz", memo)
 }
+
+func Test_AddMetadata(t *testing.T) {
+	builder := NewTxBuilder(nil)
+	require.NoError(t, builder.SetMemo("foo+bar-baz"))
+	bz, err := cbor.Encode(builder.Tx().Metadata)
+	require.NoError(t, err)
+	require.Equal(t, "a11902a2a1636d7367816b666f6f2b6261722d62617a", hex.EncodeToString(bz))
+
+	require.Error(t, builder.AddMetadata(metadata.Metadata{674: "again"}))
+	require.Error(t, builder.AddMetadata(metadata.Metadata{1: string(make([]byte, 65))}))
+	require.NoError(t, builder.AddMetadata(metadata.Metadata{1: []any{1, "one"}}))
+	require.Len(t, builder.Tx().Metadata, 2)
+}
+
+func Test_EncryptedMemo(t *testing.T) {
+	memo := strings.Repeat("route to account 42, ", 5)
+	builder := NewTxBuilder(nil)
+	require.NoError(t, builder.SetMemo(memo, WithPassphrase("secret")))
+	bz, err := cbor.Encode(builder.Tx().Metadata)
+	require.NoError(t, err)
+	lv := &cbor.LazyValue{}
+	require.NoError(t, lv.UnmarshalCBOR(bz))
+
+	decoded, err := DecodeMemoFromMetadata(lv, WithPassphrase("secret"))
+	require.NoError(t, err)
+	require.Equal(t, memo, decoded)
+	_, err = DecodeMemoFromMetadata(lv)
+	require.ErrorIs(t, err, ErrEncryptedMemo)
+	_, err = DecodeMemoFromMetadata(lv, WithPassphrase("wrong"))
+	require.ErrorIs(t, err, ErrEncryptedMemo)
+	require.NotContains(t, hex.EncodeToString(bz), hex.EncodeToString([]byte("route")))
+
+	// memos encrypted by `openssl enc -aes-256-cbc -pbkdf2 -iter 10000 -md sha256 -a` with the salt
+	// 0102030405060708, under the default passphrase and under "secret"
+	invoice := []string{"Invoice-No: 1234567890", "Customer-No: 555-1234"}
+	for passphrase, encrypted := range map[string]string{
+		"":       "U2FsdGVkX18BAgMEBQYHCDFT4xsFzkNrztzUhPZmO6Nj3CHi4YTPR+wboSeNWfAZa9anGoaUvi3kzP90VC4/lCZ/OBVEQp9rDNG9v+HusUo=",
+		"secret": "U2FsdGVkX18BAgMEBQYHCBiRe6CIrLtwDzFhPhwYBGRs0THYT3BHBKZw36wqHvjJVXl4lx1qpsT1glLmmmNjxbNXj02Evj/sLt4kBrYZ0ek=",
+	} {
+		bz, err := cbor.Encode(map[uint64]any{674: map[string]any{"enc": "basic", "msg": []string{encrypted[:64], encrypted[64:]}}})
+		require.NoError(t, err)
+		lv := &cbor.LazyValue{}
+		require.NoError(t, lv.UnmarshalCBOR(bz))
+		decoded, err := DecodeMemoFromMetadata(lv, WithPassphrase(passphrase))
+		require.NoError(t, err)
+		require.Equal(t, strings.Join(invoice, ""), decoded)
+	}
+	msg, err := DecryptMemo([]string{"U2FsdGVkX18BAgMEBQYHCDFT4xsFzkNrztzUhPZmO6Nj3CHi4YTPR+wboSeNWfAZa9anGoaUvi3kzP90VC4/lCZ/OBVEQp9rDNG9v+HusUo="}, DefaultMemoPassphrase)
+	require.NoError(t, err)
+	require.Equal(t, invoice, msg)
+}
+
+func Test_AddVKeyWitnesses(t *testing.T) {
+	unsigned := NewTx()
+	unsigned.AddInputs(NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 0))
+	unsigned.AddOutputs(NewTxOutput(addrFromBech32(t, "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz"), 2500000))
+	unsigned.Body.Fee = 166249
+	hash, err := unsigned.Hash()
+	require.NoError(t, err)
+	unsignedBz, err := unsigned.Bytes()
+	require.NoError(t, err)
+
+	alice := ed25519.NewKeyFromSeed(make([]byte, 32))
+	bob := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, 32))
+
+	// signing the encoded transaction matches signing it while building
+	signed := *unsigned
+	signed.WitnessSet = NewTXWitness(NewVKeyWitness(alice.Public().(ed25519.PublicKey), ed25519.Sign(alice, hash[:])))
+	wantBz, err := signed.Bytes()
+	require.NoError(t, err)
+	aliceBz, err := AddVKeyWitnesses(unsignedBz, alice)
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(wantBz), hex.EncodeToString(aliceBz))
+
+	// a second signer adds a witness, signing again replaces one
+	bothBz, err := AddVKeyWitnesses(aliceBz, bob, alice)
+	require.NoError(t, err)
+	vkeys, err := VKeyWitnesses(bothBz)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{alice.Public().(ed25519.PublicKey), bob.Public().(ed25519.PublicKey)}, vkeys)
+	bothHash, err := HashFromBytes(bothBz)
+	require.NoError(t, err)
+	require.Equal(t, hash, bothHash)
+}
+
+func Test_SetValidUntil(t *testing.T) {
+	builder := NewTxBuilder(nil)
+	require.Error(t, builder.SetValidUntil(time.Now()))
+
+	summary, err := slotting.FromGenesis(nil, []byte(`{"systemStart": "2024-01-01T00:00:00Z", "epochLength": 500, "slotLength": 1}`), 0)
+	require.NoError(t, err)
+	builder = NewTxBuilder(nil, WithSlotting(summary))
+	require.NoError(t, builder.SetValidFrom(summary.SystemStart.Add(90*time.Second)))
+	require.NoError(t, builder.SetValidUntil(summary.SystemStart.Add(time.Hour)))
+	require.Equal(t, uint32(90), builder.Tx().Body.ValidityStart)
+	require.Equal(t, uint32(3600), builder.Tx().Body.TTL)
+	require.ErrorIs(t, builder.SetValidUntil(summary.SystemStart.Add(-time.Hour)), slotting.ErrBeforeStart)
+
+	bz, err := builder.Tx().Body.Bytes()
+	require.NoError(t, err)
+	require.Contains(t, hex.EncodeToString(bz), "08185a")
+}
+
+func Test_View(t *testing.T) {
+	alice := ed25519.NewKeyFromSeed(make([]byte, 32))
+	addr := addrFromBech32(t, "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	builder := NewTxBuilder(nil)
+	builder.AddInputs(NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 1, 3000000))
+	builder.AddOutputs(NewTxOutput(addr, 2500000))
+	builder.SetTTL(1000)
+	require.NoError(t, builder.SetMemo("foo-bar"))
+	builder.Tx().Body.Fee = 170000
+	signed, err := builder.Sign([]ed25519.PrivateKey{alice})
+	require.NoError(t, err)
+	hash, err := signed.Hash()
+	require.NoError(t, err)
+	txBz, err := signed.Bytes()
+	require.NoError(t, err)
+
+	view, err := NewView(txBz)
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(hash[:]), view.Id)
+	require.Equal(t, "Conway", view.Era)
+	require.Equal(t, []string{"086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab#1"}, view.Inputs)
+	require.Equal(t, "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz", view.Outputs[0].Address)
+	require.Equal(t, "2.500000 ada", view.Outputs[0].Amount.String())
+	require.Equal(t, "0.170000 ada", view.Fee.String())
+	require.Equal(t, uint64(1000), view.TTL)
+	require.Equal(t, "foo-bar", view.Memo)
+	require.Equal(t, map[string]any{"674": map[string]any{"msg": []any{"foo-bar"}}}, view.Metadata)
+	require.Len(t, view.Witnesses.VKeys, 1)
+	require.True(t, view.Witnesses.VKeys[0].SignatureValid)
+	require.Empty(t, view.Warnings)
+
+	// a tampered auxiliary data hash changes the id, invalidating the signature too
+	signed.Body.AuxiliaryDataHash = make([]byte, 32)
+	body, err := signed.Body.Bytes()
+	require.NoError(t, err)
+	var parts []cbor.RawMessage
+	_, err = cbor.Decode(txBz, &parts)
+	require.NoError(t, err)
+	parts[0] = body
+	tampered, err := cbor.Encode(parts)
+	require.NoError(t, err)
+	view, err = NewView(tampered)
+	require.NoError(t, err)
+	require.Len(t, view.Warnings, 2)
+	require.Contains(t, view.Warnings[0], "does not match the auxiliary data")
+	require.Contains(t, view.Warnings[1], "signature of key")
+}
+
+func Test_SelectInputs(t *testing.T) {
+	hash := bytes.Repeat([]byte{1}, 28)
+	key, err := address.NewAddress(address.NetworkMainnet, address.Credential{Hash: hash}, nil)
+	require.NoError(t, err)
+	script, err := address.NewAddress(address.NetworkMainnet, address.Credential{Script: true, Hash: hash}, nil)
+	require.NoError(t, err)
+
+	small := NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 1000000)
+	small.Address = key
+	large := NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 1, 5000000)
+	large.Address = key
+	tokens := NewTxInput("186838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 500000)
+	tokens.Address = key
+	tokens.Assets = MultiAsset{}
+	tokens.Assets.Add("a0028f350aaabe0545fdcb56b039bfb08e4bb4d8c4d7c3c7d481c235", "484f534b59", 1)
+	locked := NewTxInput("286838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 700000)
+	locked.Address = script
+	utxos := []TxInput{large, tokens, locked, small}
+
+	// the smallest utxos first, leaving out those holding tokens or locked by a script
+	selected, err := SelectInputs(utxos, 3000000)
+	require.NoError(t, err)
+	require.Equal(t, []TxInput{small, large}, selected)
+	selected, err = SelectInputs(utxos, 1000000)
+	require.NoError(t, err)
+	require.Equal(t, []TxInput{small}, selected)
+
+	_, err = SelectInputs(utxos, 6500000)
+	require.ErrorContains(t, err, "insufficient funds; short by 500000")
+}
+
+func Test_BatchPayout(t *testing.T) {
+	protocol := &utxocardano.PParams{
+		MinFeeCoefficient: 44,
+		MinFeeConstant:    155381,
+		MaxTxSize:         16384,
+		CoinsPerUtxoByte:  4310,
+	}
+	change := addrFromBech32(t, "addr1v9f785wjgm4w0ky6lrjp4ecfj7dunzhql83ratqlpenqn2ssnlkjz")
+	var payouts []Payout
+	for i := range 600 {
+		payment := bytes.Repeat([]byte{byte(i), byte(i >> 8)}, 14)
+		addr, err := address.NewAddress(address.NetworkMainnet, address.Credential{Hash: payment}, nil)
+		require.NoError(t, err)
+		payouts = append(payouts, Payout{Address: addr, Amount: 2000000 + uint64(i)})
+	}
+	utxos := []TxInput{
+		NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 500000000),
+		NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 1, 500000000),
+		NewTxInput("086838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 2, 500000000),
+	}
+
+	batch, err := BatchPayout(protocol, payouts, utxos, change, WithBatchTTL(1000), WithBatchMemo("payouts"))
+	require.NoError(t, err)
+	require.Greater(t, len(batch.Txs), 1)
+	require.Len(t, batch.Receipts, len(payouts))
+	for i, tb := range batch.Txs {
+		txBz, err := tb.Tx().Bytes()
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(txBz), 16384)
+		if i < len(batch.Txs)-1 {
+			// full but for less than another output
+			require.Greater(t, len(txBz), 16384-100)
+		}
+		view, err := NewView(txBz)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("payouts (%d/%d)", i+1, len(batch.Txs)), view.Memo)
+		if i > 0 {
+			// chained on the change of the previous transaction
+			prev, err := batch.Txs[i-1].Tx().Hash()
+			require.NoError(t, err)
+			outs := batch.Txs[i-1].Tx().Body.Outputs
+			require.Equal(t, fmt.Sprintf("%x#%d", prev, len(outs)-1), view.Inputs[0])
+		}
+	}
+	for i, r := range batch.Receipts {
+		tb := batch.Txs[r.Tx]
+		hash, err := tb.Tx().Hash()
+		require.NoError(t, err)
+		require.Equal(t, hex.EncodeToString(hash[:]), r.TxId)
+		out := tb.Tx().Body.Outputs[r.Index]
+		require.Equal(t, payouts[i].Address, out.Address)
+		require.Equal(t, payouts[i].Amount, out.Amount)
+	}
+
+	// the witnesses of several signers are paid for
+	signed, err := BatchPayout(protocol, payouts[:10], utxos, change, WithBatchWitnessCount(3))
+	require.NoError(t, err)
+	single, err := BatchPayout(protocol, payouts[:10], utxos, change)
+	require.NoError(t, err)
+	require.Equal(t, 3, signed.Txs[0].Tx().WitnessSet.VKeys.Len())
+	require.Greater(t, signed.Txs[0].Tx().Body.Fee, single.Txs[0].Tx().Body.Fee)
+
+	// outputs holding tokens are not spent, the change carries no assets
+	tokens := NewTxInput("186838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 900000000)
+	tokens.Assets = MultiAsset{}
+	tokens.Assets.Add("a0028f350aaabe0545fdcb56b039bfb08e4bb4d8c4d7c3c7d481c235", "484f534b59", 1)
+	batch, err = BatchPayout(protocol, payouts[:10], append([]TxInput{tokens}, utxos...), change)
+	require.NoError(t, err)
+	for _, in := range batch.Txs[0].Tx().Body.Inputs.TxIns {
+		require.NotEqual(t, tokens.TxHash, in.TxHash)
+	}
+	// nor are outputs locked by a script
+	script, err := address.NewAddress(address.NetworkMainnet, address.Credential{Script: true, Hash: bytes.Repeat([]byte{1}, 28)}, nil)
+	require.NoError(t, err)
+	locked := NewTxInput("286838187822234a2153763a74daea139f29cf8753cb84f6e0c904e1db0ea3ab", 0, 900000000)
+	locked.Address = script
+	batch, err = BatchPayout(protocol, payouts[:10], append([]TxInput{locked}, utxos...), change)
+	require.NoError(t, err)
+	for _, in := range batch.Txs[0].Tx().Body.Inputs.TxIns {
+		require.NotEqual(t, locked.TxHash, in.TxHash)
+	}
+
+	_, err = BatchPayout(protocol, payouts, utxos[:1], change)
+	require.ErrorContains(t, err, "insufficient funds")
+	_, err = BatchPayout(protocol, []Payout{{Address: change, Amount: 2000000}}, utxos, change)
+	require.Error(t, err)
+	_, err = BatchPayout(protocol, []Payout{{Address: payouts[0].Address, Amount: 1}}, utxos, change)
+	require.Error(t, err)
+}
@@ -0,0 +1,262 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/kocubinski/gardano/metadata"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Ada is an amount of lovelace, printed in ada.
+type Ada uint64
+
+func (a Ada) String() string {
+	return fmt.Sprintf("%d.%06d ada", a/1_000_000, a%1_000_000)
+}
+
+func (a Ada) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// View is a human readable description of a transaction, see NewView.
+type View struct {
+	Id              string       `json:"id"`
+	Era             string       `json:"era"`
+	Size            int          `json:"size"`
+	Valid           bool         `json:"valid"`
+	Inputs          []string     `json:"inputs"`
+	ReferenceInputs []string     `json:"referenceInputs,omitempty"`
+	Collateral      []string     `json:"collateral,omitempty"`
+	Outputs         []OutputView `json:"outputs"`
+	Fee             Ada          `json:"fee"`
+	TTL             uint64       `json:"ttl,omitempty"`
+	ValidityStart   uint64       `json:"validityStart,omitempty"`
+	// Mint maps policy ids to asset names and their minted, or negative burned, quantities.
+	Mint              map[string]map[string]int64 `json:"mint,omitempty"`
+	Withdrawals       map[string]Ada              `json:"withdrawals,omitempty"`
+	Certificates      []string                    `json:"certificates,omitempty"`
+	RequiredSigners   []string                    `json:"requiredSigners,omitempty"`
+	ScriptDataHash    string                      `json:"scriptDataHash,omitempty"`
+	AuxiliaryDataHash string                      `json:"auxiliaryDataHash,omitempty"`
+	Metadata          map[string]any              `json:"metadata,omitempty"`
+	Memo              string                      `json:"memo,omitempty"`
+	MemoEncrypted     bool                        `json:"memoEncrypted,omitempty"`
+	Witnesses         WitnessesView               `json:"witnesses"`
+	Warnings          []string                    `json:"warnings,omitempty"`
+}
+
+// OutputView describes a transaction output.
+type OutputView struct {
+	Address     string     `json:"address"`
+	Amount      Ada        `json:"amount"`
+	Assets      MultiAsset `json:"assets,omitempty"`
+	DatumHash   string     `json:"datumHash,omitempty"`
+	InlineDatum string     `json:"inlineDatum,omitempty"`
+}
+
+// WitnessesView describes a transaction's witness set.
+type WitnessesView struct {
+	VKeys         []VKeyView `json:"vkeys,omitempty"`
+	Bootstrap     int        `json:"bootstrap,omitempty"`
+	NativeScripts int        `json:"nativeScripts,omitempty"`
+	PlutusScripts int        `json:"plutusScripts,omitempty"`
+	PlutusData    int        `json:"plutusData,omitempty"`
+}
+
+// VKeyView is a signature of the transaction, with the hash of the key that made it.
+type VKeyView struct {
+	VKey           string `json:"vkey"`
+	KeyHash        string `json:"keyHash"`
+	SignatureValid bool   `json:"signatureValid"`
+}
+
+// txTypes are the eras NewView tries to decode transactions of, newest first.
+var txTypes = []uint{
+	ledger.TxTypeConway,
+	ledger.TxTypeBabbage,
+	ledger.TxTypeAlonzo,
+	ledger.TxTypeMary,
+	ledger.TxTypeAllegra,
+	ledger.TxTypeShelley,
+}
+
+// NewView decodes a CBOR encoded transaction of any Shelley based era. Inconsistencies which would get the
+// transaction rejected, such as a wrong auxiliary data hash or an invalid signature, are reported as
+// warnings rather than errors so that broken transactions can be inspected too.
+func NewView(txBz []byte) (*View, error) {
+	var (
+		t    common.Transaction
+		errs []error
+	)
+	for _, txType := range txTypes {
+		decoded, err := ledger.NewTransactionFromCbor(txType, txBz)
+		if err == nil {
+			t = decoded
+			break
+		}
+		errs = append(errs, err)
+	}
+	if t == nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", errs[0])
+	}
+	id, err := HashFromBytes(txBz)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &View{
+		Id:              hex.EncodeToString(id[:]),
+		Era:             ledger.GetEraById(uint8(t.Type())).Name,
+		Size:            len(txBz),
+		Valid:           t.IsValid(),
+		Inputs:          inputStrings(t.Inputs()),
+		ReferenceInputs: inputStrings(t.ReferenceInputs()),
+		Collateral:      inputStrings(t.Collateral()),
+		Fee:             Ada(t.Fee()),
+		TTL:             t.TTL(),
+		ValidityStart:   t.ValidityIntervalStart(),
+	}
+	for _, out := range t.Outputs() {
+		v.Outputs = append(v.Outputs, outputView(out))
+	}
+	if mint := t.AssetMint(); mint != nil {
+		v.Mint = make(map[string]map[string]int64)
+		for _, policyId := range mint.Policies() {
+			assets := make(map[string]int64)
+			for _, name := range mint.Assets(policyId) {
+				assets[hex.EncodeToString(name)] = mint.Asset(policyId, name)
+			}
+			v.Mint[hex.EncodeToString(policyId.Bytes())] = assets
+		}
+	}
+	for addr, amount := range t.Withdrawals() {
+		if v.Withdrawals == nil {
+			v.Withdrawals = make(map[string]Ada)
+		}
+		v.Withdrawals[addr.String()] = Ada(amount)
+	}
+	for _, cert := range t.Certificates() {
+		v.Certificates = append(v.Certificates, reflect.Indirect(reflect.ValueOf(cert)).Type().Name())
+	}
+	for _, signer := range t.RequiredSigners() {
+		v.RequiredSigners = append(v.RequiredSigners, hex.EncodeToString(signer.Bytes()))
+	}
+	if h := t.ScriptDataHash(); h != nil {
+		v.ScriptDataHash = hex.EncodeToString(h.Bytes())
+	}
+	v.viewAuxiliaryData(t)
+	v.viewWitnesses(t.Witnesses(), id[:])
+	return v, nil
+}
+
+func inputStrings(inputs []common.TransactionInput) []string {
+	var res []string
+	for _, in := range inputs {
+		res = append(res, fmt.Sprintf("%x#%d", in.Id().Bytes(), in.Index()))
+	}
+	return res
+}
+
+func outputView(out common.TransactionOutput) OutputView {
+	o := OutputView{
+		Address: out.Address().String(),
+		Amount:  Ada(out.Amount()),
+		Assets:  NewMultiAssetFromLedger(out.Assets()),
+	}
+	// outputs without a datum hash report an empty hash
+	if h := out.DatumHash(); h != nil && *h != (common.Blake2b256{}) {
+		o.DatumHash = hex.EncodeToString(h.Bytes())
+	}
+	if d := out.Datum(); d != nil {
+		o.InlineDatum = hex.EncodeToString(d.Cbor())
+	}
+	return o
+}
+
+func (v *View) viewAuxiliaryData(t common.Transaction) {
+	declared := t.AuxDataHash()
+	if declared != nil {
+		v.AuxiliaryDataHash = hex.EncodeToString(declared.Bytes())
+	}
+	md := t.Metadata()
+	if md == nil || md.Cbor() == nil || bytes.Equal(md.Cbor(), []byte{0xf6}) {
+		if declared != nil {
+			v.Warnings = append(v.Warnings, "auxiliary data hash is set but the transaction has no auxiliary data")
+		}
+		return
+	}
+	actual := blake2b.Sum256(md.Cbor())
+	switch {
+	case declared == nil:
+		v.Warnings = append(v.Warnings, "transaction has auxiliary data but no auxiliary data hash")
+	case !bytes.Equal(declared.Bytes(), actual[:]):
+		v.Warnings = append(v.Warnings, fmt.Sprintf("auxiliary data hash %x does not match the auxiliary data, which hashes to %x",
+			declared.Bytes(), actual))
+	}
+
+	decoded, err := decodeMetadata(md)
+	if err != nil {
+		v.Warnings = append(v.Warnings, fmt.Sprintf("failed to decode metadata: %s", err))
+		return
+	}
+	if len(decoded) > 0 {
+		v.Metadata = make(map[string]any, len(decoded))
+		for label, value := range decoded {
+			if v.Metadata[fmt.Sprint(label)], err = metadata.JSONValue(value, metadata.NoSchema); err != nil {
+				v.Warnings = append(v.Warnings, fmt.Sprintf("label %v is not valid metadata: %s", label, err))
+			}
+		}
+	}
+	memo, err := DecodeMemoFromMetadata(md)
+	if errors.Is(err, ErrEncryptedMemo) {
+		v.MemoEncrypted = true
+	} else if err != nil {
+		v.Warnings = append(v.Warnings, fmt.Sprintf("invalid CIP-20 memo: %s", err))
+	}
+	v.Memo = memo
+}
+
+func (v *View) viewWitnesses(ws common.TransactionWitnessSet, id []byte) {
+	if ws == nil {
+		return
+	}
+	for _, w := range ws.Vkey() {
+		hash, _ := blake2b.New(28, nil)
+		hash.Write(w.Vkey)
+		vkey := VKeyView{
+			VKey:           hex.EncodeToString(w.Vkey),
+			KeyHash:        hex.EncodeToString(hash.Sum(nil)),
+			SignatureValid: len(w.Vkey) == ed25519.PublicKeySize && ed25519.Verify(w.Vkey, id, w.Signature),
+		}
+		if !vkey.SignatureValid {
+			v.Warnings = append(v.Warnings, fmt.Sprintf("signature of key %s is invalid", vkey.KeyHash))
+		}
+		v.Witnesses.VKeys = append(v.Witnesses.VKeys, vkey)
+	}
+	v.Witnesses.Bootstrap = len(ws.Bootstrap())
+	v.Witnesses.NativeScripts = len(ws.NativeScripts())
+	v.Witnesses.PlutusScripts = len(ws.PlutusV1Scripts()) + len(ws.PlutusV2Scripts()) + len(ws.PlutusV3Scripts())
+	v.Witnesses.PlutusData = len(ws.PlutusData())
+
+	// signers required by the body should have signed
+	signed := make([]string, 0, len(v.Witnesses.VKeys))
+	for _, vkey := range v.Witnesses.VKeys {
+		signed = append(signed, vkey.KeyHash)
+	}
+	for _, signer := range v.RequiredSigners {
+		if !slices.Contains(signed, signer) {
+			v.Warnings = append(v.Warnings, fmt.Sprintf("required signer %s has not signed", signer))
+		}
+	}
+	if len(v.Witnesses.VKeys) == 0 && v.Witnesses.Bootstrap == 0 {
+		v.Warnings = append(v.Warnings, "transaction is not signed")
+	}
+}
@@ -0,0 +1,90 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// witnessSetVKeys is the witness set key of the vkey witnesses.
+const witnessSetVKeys = 0
+
+// AddVKeyWitnesses signs a CBOR encoded transaction with privateKeys and returns it with their witnesses
+// added to any it already has. The body is kept byte for byte, so transactions built elsewhere can be
+// signed, and several signers can add their witnesses in turn.
+func AddVKeyWitnesses(txBz []byte, privateKeys ...ed25519.PrivateKey) ([]byte, error) {
+	var parts []cbor.RawMessage
+	if err := cbor.Unmarshal(txBz, &parts); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid transaction: expected 4 elements, got %d", len(parts))
+	}
+	hash, err := HashFromBytes(txBz)
+	if err != nil {
+		return nil, err
+	}
+
+	witnessSet := make(map[uint64]cbor.RawMessage)
+	if err := cbor.Unmarshal(parts[1], &witnessSet); err != nil {
+		return nil, fmt.Errorf("failed to decode witness set: %w", err)
+	}
+	var vkeys VKeyWitnessSet
+	if raw, ok := witnessSet[witnessSetVKeys]; ok {
+		if err := cbor.Unmarshal(raw, (*[]*VKeyWitness)(&vkeys)); err != nil {
+			return nil, fmt.Errorf("failed to decode vkey witnesses: %w", err)
+		}
+	}
+	for _, prv := range privateKeys {
+		pub := prv.Public().(ed25519.PublicKey)
+		signature, err := prv.Sign(nil, hash[:], &ed25519.Options{})
+		if err != nil {
+			return nil, err
+		}
+		replaced := false
+		for i, w := range vkeys {
+			if bytes.Equal(w.VKey, pub) {
+				vkeys[i] = NewVKeyWitness(pub, signature)
+				replaced = true
+			}
+		}
+		if !replaced {
+			vkeys.Append(NewVKeyWitness(pub, signature))
+		}
+	}
+	if witnessSet[witnessSetVKeys], err = vkeys.MarshalCBOR(); err != nil {
+		return nil, err
+	}
+	encMode, err := cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+	if err != nil {
+		return nil, err
+	}
+	if parts[1], err = encMode.Marshal(witnessSet); err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(parts)
+}
+
+// VKeyWitnesses returns the verification keys which witnessed a CBOR encoded transaction.
+func VKeyWitnesses(txBz []byte) ([][]byte, error) {
+	var parts []cbor.RawMessage
+	if err := cbor.Unmarshal(txBz, &parts); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid transaction: expected at least 2 elements, got %d", len(parts))
+	}
+	var witnessSet struct {
+		VKeys []VKeyWitness `cbor:"0,keyasint,omitempty"`
+	}
+	if err := cbor.Unmarshal(parts[1], &witnessSet); err != nil {
+		return nil, fmt.Errorf("failed to decode witness set: %w", err)
+	}
+	var res [][]byte
+	for _, w := range witnessSet.VKeys {
+		res = append(res, w.VKey)
+	}
+	return res, nil
+}
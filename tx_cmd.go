@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+	"github.com/kocubinski/gardano/address"
+	"github.com/kocubinski/gardano/envelope"
+	"github.com/kocubinski/gardano/lsq"
+	"github.com/kocubinski/gardano/pparams"
+	"github.com/kocubinski/gardano/supervisor"
+	"github.com/kocubinski/gardano/tx"
+	utxocardano "github.com/utxorpc/go-codegen/utxorpc/v1alpha/cardano"
+)
+
+// txCmd runs the `tx build`, `tx sign` and `tx submit` subcommands, which exchange text envelope files so
+// that building and signing can happen on machines without network access, `tx batch`, which pays many
+// receivers at once, and `tx view`, which prints a transaction as JSON.
+func txCmd(f *cliFlags, subcommand string, parseFlags func()) error {
+	addNodeFlags := func() *uint {
+		f.flagset.StringVar(&f.clientAddress, "address", "", "TCP address for n2c communication")
+		f.flagset.StringVar(&f.clientSocket, "socket", "", "unix socket address for n2c communication")
+		f.flagset.BoolVar(&f.useTls, "tls", false, "use TLS for TCP connections")
+		var networkMagic uint
+		f.flagset.UintVar(&networkMagic, "magic", testnetMagic, "network magic")
+		return &networkMagic
+	}
+	switch subcommand {
+	case "build":
+		f.flagset.StringVar(&f.fromAddress, "from", "", "address to spend from and return change to")
+		f.flagset.StringVar(&f.receiverAddress, "receiver-address", "", "Address to send to")
+		f.flagset.Uint64Var(&f.sendAmount, "amount", 0, "Amount to send")
+		f.flagset.StringVar(&f.memo, "memo", "", "optional tx memo")
+		f.flagset.StringVar(&f.memoPassphrase, "memo-passphrase", "", "optional passphrase to encrypt -memo with (CIP-83)")
+		f.flagset.StringVar(&f.metadataJSONFile, "metadata-json-file", "", "optional JSON file of metadata to attach, keyed by label")
+		f.flagset.BoolVar(&f.metadataDetailed, "json-metadata-detailed-schema", false, "read -metadata-json-file in the detailed schema instead of no schema")
+		f.flagset.Uint64Var(&f.fee, "fee", 0, "if unset fees are dynamically calculated")
+		f.flagset.Uint64Var(&f.ttl, "ttl", 0, "slot after which the tx is invalid, defaults to 300 slots after the node's tip")
+		f.flagset.StringVar(&f.validUntil, "valid-until", "", "RFC3339 time or duration from now after which the tx is invalid, instead of -ttl")
+		f.flagset.IntVar(&f.witnesses, "witnesses", 1, "number of signatures the tx will carry, for the fee calculation")
+		f.flagset.StringVar(&f.protocolParamsFile, "protocol-parameters-file", "", "cardano-cli protocol parameters JSON to use instead of querying the node")
+		f.flagset.StringVar(&f.utxoFile, "utxo-file", "", "cardano-cli UTxO JSON to select inputs from instead of querying the node")
+		f.flagset.StringVar(&f.kupoURL, "kupo-url", "", "optional Kupo URL to query UTxOs from instead of the node")
+		f.flagset.StringVar(&f.indexFile, "index-file", "", "optional UTxO index written by chain-sync to select inputs from")
+		f.flagset.StringVar(&f.outFile, "out-file", "", "file to write the unsigned tx envelope to, defaults to stdout")
+		networkMagic := addNodeFlags()
+		parseFlags()
+		f.networkMagic = uint32(*networkMagic)
+		return txBuild(f)
+	case "batch":
+		f.flagset.StringVar(&f.fromAddress, "from", "", "address to spend from and return change to")
+		f.flagset.StringVar(&f.payoutsFile, "payouts", "", "CSV of address,amount lines or JSON array of {address, amount} objects to pay")
+		f.flagset.StringVar(&f.memo, "memo", "", "optional memo of every tx, followed by the tx's position in the batch")
+		f.flagset.StringVar(&f.memoPassphrase, "memo-passphrase", "", "optional passphrase to encrypt -memo with (CIP-83)")
+		f.flagset.Uint64Var(&f.ttl, "ttl", 0, "slot after which the txs are invalid, defaults to 300 slots after the node's tip")
+		f.flagset.StringVar(&f.validUntil, "valid-until", "", "RFC3339 time or duration from now after which the txs are invalid, instead of -ttl")
+		f.flagset.StringVar(&f.protocolParamsFile, "protocol-parameters-file", "", "cardano-cli protocol parameters JSON to use instead of querying the node")
+		f.flagset.StringVar(&f.utxoFile, "utxo-file", "", "cardano-cli UTxO JSON to select inputs from instead of querying the node")
+		f.flagset.StringVar(&f.kupoURL, "kupo-url", "", "optional Kupo URL to query UTxOs from instead of the node")
+		f.flagset.StringVar(&f.indexFile, "index-file", "", "optional UTxO index written by chain-sync to select inputs from")
+		f.flagset.StringVar(&f.pendingFile, "pending-file", "", "optional JSON file of submitted but unconfirmed txs whose change the batch may spend, updated by -submit")
+		f.flagset.StringVar(&f.signingKeyFiles, "signing-key-file", "", "comma separated payment signing key envelope files to sign the txs with")
+		f.flagset.StringVar(&f.outDir, "out-dir", "", "directory to write the tx envelopes to, as tx-1.json, tx-2.json and so on")
+		f.flagset.BoolVar(&f.submit, "submit", false, "submit the signed txs to the node in order")
+		f.flagset.StringVar(&f.reportFile, "report-file", "", "file to write the JSON report of each payout's tx id and output index to, defaults to stdout")
+		networkMagic := addNodeFlags()
+		parseFlags()
+		f.networkMagic = uint32(*networkMagic)
+		return txBatch(f)
+	case "sign":
+		f.flagset.StringVar(&f.txFile, "tx-file", "", "tx envelope, hex or CBOR file to sign")
+		f.flagset.StringVar(&f.signingKeyFiles, "signing-key-file", "", "comma separated payment signing key envelope files")
+		f.flagset.StringVar(&f.outFile, "out-file", "", "file to write the signed tx envelope to, defaults to stdout")
+		parseFlags()
+		return txSign(f)
+	case "submit":
+		f.flagset.StringVar(&f.txFile, "tx-file", "", "signed tx envelope, hex or CBOR file to submit")
+		networkMagic := addNodeFlags()
+		parseFlags()
+		f.networkMagic = uint32(*networkMagic)
+		return txSubmit(f)
+	case "view":
+		f.flagset.StringVar(&f.txHex, "tx", "", "hex encoded transaction")
+		f.flagset.StringVar(&f.txFile, "tx-file", "", "tx envelope, hex or CBOR file to view")
+		parseFlags()
+		// the tx can also be given as the argument, a file or hex
+		if arg := f.flagset.Arg(0); arg != "" {
+			if _, err := os.Stat(arg); err == nil {
+				f.txFile = arg
+			} else {
+				f.txHex = arg
+			}
+		}
+		return txView(f)
+	default:
+		return fmt.Errorf("unknown tx subcommand %q, expected build, batch, sign, submit or view", subcommand)
+	}
+}
+
+func txBuild(f *cliFlags) error {
+	if f.sendAmount == 0 {
+		return fmt.Errorf("send amount is not set")
+	}
+	if f.receiverAddress == "" {
+		return fmt.Errorf("receiver address is not set")
+	}
+	sourceAddr, err := address.NewAddressFromBech32(f.fromAddress)
+	if err != nil {
+		return fmt.Errorf("invalid -from address: %w", err)
+	}
+
+	// the node is only needed for what was not given in files
+	var o *ouroboros.Connection
+	online := f.protocolParamsFile == "" || f.ttl == 0 ||
+		(f.utxoFile == "" && f.kupoURL == "" && f.indexFile == "")
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	if online {
+		if f.clientAddress == "" && f.clientSocket == "" {
+			return fmt.Errorf("client address/socket is not set, offline builds need -protocol-parameters-file, -utxo-file and -ttl")
+		}
+		if o, err = connectNodeToClient(f, log, ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig())); err != nil {
+			return err
+		}
+		defer o.Close()
+	}
+
+	protocol, err := protocolParams(f, o)
+	if err != nil {
+		return err
+	}
+	utxos, err := sourceUTxOs(f, o, sourceAddr)
+	if err != nil {
+		return err
+	}
+	ttl := f.ttl
+	if ttl == 0 {
+		if ttl, err = defaultTTL(f, o, log); err != nil {
+			return err
+		}
+	}
+
+	txBuilder := tx.NewTxBuilder(protocol, tx.WithWitnessCount(f.witnesses))
+	if err := buildPayment(f, txBuilder, utxos, sourceAddr, uint32(ttl)); err != nil {
+		return err
+	}
+	unsigned := *txBuilder.Tx()
+	// drop the placeholder witnesses of the fee calculation
+	unsigned.WitnessSet = tx.NewTXWitness()
+	hash, err := unsigned.Hash()
+	if err != nil {
+		return fmt.Errorf("failed to hash transaction: %w", err)
+	}
+	txBz, err := unsigned.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction bytes: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "built tx %x, fee = %d, ttl = %d\n", hash, unsigned.Body.Fee, ttl)
+	return writeEnvelope(f.outFile, envelope.NewTx(txBz, false))
+}
+
+func txSign(f *cliFlags) error {
+	txBz, err := readTx(f)
+	if err != nil {
+		return err
+	}
+	if f.signingKeyFiles == "" {
+		return fmt.Errorf("signing key file is not set")
+	}
+	keys, err := readSigningKeys(f.signingKeyFiles)
+	if err != nil {
+		return err
+	}
+	signed, err := tx.AddVKeyWitnesses(txBz, keys...)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return writeEnvelope(f.outFile, envelope.NewTx(signed, true))
+}
+
+// readSigningKeys reads comma separated signing key envelope files.
+func readSigningKeys(paths string) ([]ed25519.PrivateKey, error) {
+	var keys []ed25519.PrivateKey
+	for _, path := range strings.Split(paths, ",") {
+		e, err := envelope.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+		}
+		key, err := e.SigningKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func txSubmit(f *cliFlags) error {
+	if f.clientAddress == "" && f.clientSocket == "" {
+		return fmt.Errorf("client address/socket is not set")
+	}
+	txBz, err := readTx(f)
+	if err != nil {
+		return err
+	}
+	hash, err := tx.HashFromBytes(txBz)
+	if err != nil {
+		return err
+	}
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	o, err := connectNodeToClient(f, log, ouroboros.WithLocalStateQueryConfig(localstatequery.NewConfig()))
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+	if err := (nodeTxBackend{conn: o}).Submit(txBz); err != nil {
+		return fmt.Errorf("failed to submit transaction: %w", err)
+	}
+	fmt.Printf("submitted tx %x\n", hash)
+	return nil
+}
+
+func connectNodeToClient(f *cliFlags, log *slog.Logger, opts ...ouroboros.ConnectionOptionFunc) (*ouroboros.Connection, error) {
+	network, ok := ouroboros.NetworkByNetworkMagic(f.networkMagic)
+	if !ok {
+		return nil, fmt.Errorf("unknown network magic: %d", f.networkMagic)
+	}
+	return supervisor.Connect(context.Background(), func(context.Context) (*ouroboros.Connection, error) {
+		client, err := dialNodeToClient(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client connection: %w", err)
+		}
+		o, err := ouroboros.NewConnection(append([]ouroboros.ConnectionOptionFunc{
+			ouroboros.WithConnection(client),
+			ouroboros.WithLogger(log),
+			ouroboros.WithNetwork(network),
+			ouroboros.WithKeepAlive(true),
+		}, opts...)...)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to network: %w", err)
+		}
+		return o, nil
+	}, supervisor.WithLogger(log), supervisor.WithMaxAttempts(5))
+}
+
+func txView(f *cliFlags) error {
+	txBz, err := readTx(f)
+	if err != nil {
+		return err
+	}
+	view, err := tx.NewView(txBz)
+	if err != nil {
+		return err
+	}
+	return printJSON(os.Stdout, view)
+}
+
+// defaultTTL returns the slot of -valid-until, or 300 slots after the node's tip when it is unset.
+func defaultTTL(f *cliFlags, o *ouroboros.Connection, log *slog.Logger) (uint64, error) {
+	if f.validUntil == "" {
+		tip, err := o.ChainSync().Client.GetCurrentTip()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get current tip for TTL: %w", err)
+		}
+		return tip.Point.Slot + 300, nil
+	}
+	deadline, err := parseDeadline(f.validUntil, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	// gouroboros can't decode era histories with an unbounded era, so they are read with the lsq client over
+	// a connection of its own
+	lsqConn, err := connectNodeToClient(f, log, ouroboros.WithDelayProtocolStart(true))
+	if err != nil {
+		return 0, err
+	}
+	defer lsqConn.Close()
+	c := lsq.New(lsqConn, log)
+	defer c.Release()
+	summary, err := c.Slotting()
+	if err != nil {
+		return 0, err
+	}
+	ttl, err := summary.ValidUntil(deadline)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert -valid-until to a slot: %w", err)
+	}
+	return ttl, nil
+}
+
+// parseDeadline reads an RFC3339 time, or a duration added to now.
+func parseDeadline(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q, expected an RFC3339 time or a duration", s)
+	}
+	return t, nil
+}
+
+// protocolParams reads -protocol-parameters-file, falling back to querying the node when it is unset.
+func protocolParams(f *cliFlags, o *ouroboros.Connection) (*utxocardano.PParams, error) {
+	var params *pparams.PParams
+	var err error
+	if f.protocolParamsFile != "" {
+		params, err = pparams.ReadFile(f.protocolParamsFile)
+	} else {
+		params, err = pparams.FromNode(o.LocalStateQuery().Client)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load protocol parameters: %w", err)
+	}
+	return params.Utxorpc()
+}
+
+func writeEnvelope(path string, e envelope.Envelope) error {
+	if path != "" {
+		return envelope.WriteFile(path, e)
+	}
+	bz, err := json.MarshalIndent(e, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(bz))
+	return nil
+}